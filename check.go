@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/clambin/iss-exporter/internal/collector"
+	"github.com/clambin/iss-exporter/lightstreamer"
+	"io"
+	"log/slog"
+	"time"
+)
+
+// checkTimeout is how long checkSubscribe may take to connect to the server and subscribe to the configured groups.
+const checkTimeout = 30 * time.Second
+
+// checkConfig checks the exporter's configuration, and reports the result of each check to w. The configuration is
+// loaded by load, and the web configuration by loadWeb. If modulesPath is set, the probe modules are checked too. If subscribe is not nil, it's called to
+// check that the configured groups can be subscribed to. checkConfig returns false if any check failed. Groups that
+// aren't in the catalog are reported, but don't fail the check.
+func checkConfig(ctx context.Context, w io.Writer, load func() (collector.Config, error), loadWeb func() (webConfig, error), modulesPath string, subscribe func(context.Context, collector.Config) error) bool {
+	ok := true
+	report := func(what string, err error) {
+		if err != nil {
+			_, _ = fmt.Fprintf(w, "FAIL %s: %v\n", what, err)
+			ok = false
+			return
+		}
+		_, _ = fmt.Fprintf(w, "OK   %s\n", what)
+	}
+
+	cfg, err := load()
+	report("configuration", err)
+	if err == nil {
+		for _, id := range cfg.Uncatalogued() {
+			_, _ = fmt.Fprintf(w, "WARN group %s is not in the catalog\n", id)
+		}
+		if subscribe != nil {
+			report("subscription", subscribe(ctx, cfg))
+		}
+	}
+	_, err = loadWeb()
+	report("web configuration", err)
+	if modulesPath != "" {
+		_, err = loadProbeModules(modulesPath)
+		report("probe modules", err)
+	}
+	return ok
+}
+
+// checkSubscribe creates a session with the configured server, subscribes to the configured groups, and destroys the
+// session.
+func checkSubscribe(ctx context.Context, cfg collector.Config) error {
+	logger := slog.New(slog.DiscardHandler)
+	session := lightstreamer.NewClientSession(append(cfg.Server.Options(), lightstreamer.WithLogger(logger))...)
+	subscribeCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+	_, err := collector.NewCollector(subscribeCtx, cfg, session, logger)
+	cancel()
+	destroyCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	_ = session.Destroy(destroyCtx)
+	return err
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/clambin/iss-exporter/internal/collector"
+	"github.com/clambin/iss-exporter/lightstreamer"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCheckConfig(t *testing.T) {
+	valid := func() (collector.Config, error) {
+		return collector.Config{Groups: []collector.GroupConfig{{ID: "USLAB000058"}, {ID: "FOO"}}}, nil
+	}
+	tests := []struct {
+		name      string
+		load      func() (collector.Config, error)
+		web       func() (webConfig, error)
+		modules   string
+		subscribe func(context.Context, collector.Config) error
+		want      bool
+		wantLines []string
+	}{
+		{
+			name:      "valid",
+			load:      valid,
+			want:      true,
+			wantLines: []string{"OK   configuration", "WARN group FOO is not in the catalog", "OK   web configuration"},
+		},
+		{
+			name:      "invalid configuration",
+			load:      func() (collector.Config, error) { return collector.Config{}, errors.New("no groups configured") },
+			wantLines: []string{"FAIL configuration: no groups configured"},
+		},
+		{
+			name:      "invalid web configuration",
+			load:      valid,
+			web:       func() (webConfig, error) { return webConfig{}, errors.New("TLS needs both a certificate and a key") },
+			wantLines: []string{"FAIL web configuration"},
+		},
+		{
+			name:      "missing probe modules",
+			load:      valid,
+			modules:   "/nonexistent/modules.json",
+			wantLines: []string{"FAIL probe modules"},
+		},
+		{
+			name:      "subscription failed",
+			load:      valid,
+			subscribe: func(context.Context, collector.Config) error { return errors.New("subscribe(FOO): invalid group") },
+			wantLines: []string{"FAIL subscription: subscribe(FOO): invalid group"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loadWeb := tt.web
+			if loadWeb == nil {
+				loadWeb = func() (webConfig, error) { return webConfig{}, nil }
+			}
+			var out bytes.Buffer
+			if got := checkConfig(t.Context(), &out, tt.load, loadWeb, tt.modules, tt.subscribe); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			for _, line := range tt.wantLines {
+				if !strings.Contains(out.String(), line) {
+					t.Errorf("output does not contain %q:\n%s", line, out.String())
+				}
+			}
+		})
+	}
+}
+
+func TestCheckSubscribe(t *testing.T) {
+	s := lightstreamer.NewServer("FEED", "cid", map[string]lightstreamer.AdapterSet{
+		"DEFAULT": {"A": lightstreamer.InjectAdapter{Name: "A", Fields: 3}},
+	}, slog.New(slog.DiscardHandler))
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+
+	cfg := collector.Config{Server: collector.ServerConfig{URL: ts.URL, AdapterSet: "FEED", CID: "cid"}, Groups: []collector.GroupConfig{{ID: "A"}}}
+	if err := checkSubscribe(t.Context(), cfg); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if got := len(s.Sessions()); got != 0 {
+		t.Errorf("session not destroyed: got %d sessions", got)
+	}
+
+	cfg.Groups = []collector.GroupConfig{{ID: "B"}}
+	if err := checkSubscribe(t.Context(), cfg); err == nil {
+		t.Error("expected an error for an unknown group")
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"github.com/clambin/iss-exporter/internal/collector"
+	"github.com/clambin/iss-exporter/internal/orbit"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// aosStatusClass is the Status.Class of the TIME_000001 group when the signal is acquired.
+	aosStatusClass = 24
+	// trackInterval is the time between two points of the ground track.
+	trackInterval = 30 * time.Second
+	// trackLength is the number of points of the ground track: a little over one orbit.
+	trackLength = 200
+)
+
+// units are the symbols of the catalog's units.
+var units = map[string]string{
+	"amperes":           "A",
+	"celsius":           "°C",
+	"degrees":           "°",
+	"kilometers":        "km",
+	"meters_per_second": "m/s",
+	"mmhg":              "mmHg",
+	"percent":           "%",
+	"pounds_per_day":    "lb/day",
+	"volts":             "V",
+}
+
+// location is a point of the ground track.
+type location struct {
+	latitude  float64
+	longitude float64
+	altitude  float64
+}
+
+// reading is the last value received for a group.
+type reading struct {
+	value    string
+	received time.Time
+}
+
+// dashboard holds the state of the station, as received from Lightstreamer, and renders it.
+type dashboard struct {
+	title  string
+	groups []collector.GroupConfig
+
+	lock     sync.Mutex
+	readings map[string]reading
+	status   string
+	statusAt time.Time
+	xyz      [3]float64
+	xyzSet   [3]bool
+	current  *location
+	track    []location
+	tracked  time.Time
+}
+
+func newDashboard(title string, groups []string) *dashboard {
+	d := dashboard{title: title, readings: make(map[string]reading)}
+	for _, id := range groups {
+		group, ok := collector.Lookup(id)
+		if !ok {
+			group = collector.GroupConfig{ID: id}
+		}
+		d.groups = append(d.groups, group)
+	}
+	return &d
+}
+
+// setValue records the value of a group.
+func (d *dashboard) setValue(group string, value string, received time.Time) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.readings[group] = reading{value: value, received: received}
+}
+
+// setStatus records the Status.Class of the signal.
+func (d *dashboard) setStatus(class string, received time.Time) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.status, d.statusAt = class, received
+}
+
+// setPosition records the ISS position along one axis (0: X, 1: Y, 2: Z), in km, in the J2000 frame. Once all axes
+// are known, it updates the location, and adds it to the ground track every trackInterval.
+func (d *dashboard) setPosition(axis int, value float64, received time.Time) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.xyz[axis], d.xyzSet[axis] = value, true
+	if !d.xyzSet[0] || !d.xyzSet[1] || !d.xyzSet[2] {
+		return
+	}
+	// the position is in the J2000 frame, rather than TEME, but the difference doesn't show on the map
+	l := orbit.Geodetic(orbit.Vector(d.xyz), received)
+	d.current = &location{latitude: l.Latitude, longitude: l.Longitude, altitude: l.Altitude}
+	if received.Sub(d.tracked) >= trackInterval {
+		d.track = append(d.track, *d.current)
+		if len(d.track) > trackLength {
+			d.track = d.track[len(d.track)-trackLength:]
+		}
+		d.tracked = received
+	}
+}
+
+// render writes the dashboard, as lines of text, ended by clearing the rest of the line, so it can be redrawn over
+// itself.
+func (d *dashboard) render(w io.Writer, now time.Time, connected bool) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	var lines []string
+
+	session := "connected"
+	if !connected {
+		session = "disconnected"
+	}
+	lines = append(lines, d.title+"  "+now.UTC().Format("2006-01-02 15:04:05 UTC")+"  session: "+session, "")
+
+	signal := "unknown"
+	if d.status != "" {
+		if class, err := strconv.ParseFloat(d.status, 64); err == nil && class == aosStatusClass {
+			signal = "AOS (signal acquired)"
+		} else {
+			signal = "LOS (loss of signal)"
+		}
+		signal += ", since " + age(now, d.statusAt) + " ago"
+	}
+	lines = append(lines, "Signal:   "+signal)
+	position := "unknown"
+	if d.current != nil {
+		position = fmt.Sprintf("%s %s, altitude %.0f km", coordinate(d.current.latitude, "N", "S"), coordinate(d.current.longitude, "E", "W"), d.current.altitude)
+	}
+	lines = append(lines, "Position: "+position, "")
+	lines = append(lines, renderMap(d.track, d.current)...)
+	lines = append(lines, "@ ISS   + ground track", "")
+
+	lines = append(lines, fmt.Sprintf("%-13s %-40s %14s %-6s %s", "GROUP", "DESCRIPTION", "VALUE", "UNIT", "AGE"))
+	for _, group := range d.groups {
+		value, updated := "-", "-"
+		if r, ok := d.readings[group.ID]; ok {
+			value, updated = r.value, age(now, r.received)
+			if state, ok := group.States[r.value]; ok {
+				value = state
+			}
+		}
+		unit := units[group.Unit]
+		if unit == "" {
+			unit = group.Unit
+		}
+		lines = append(lines, fmt.Sprintf("%-13s %-40s %14s %-6s %s", group.ID, truncate(group.Help, 40), truncate(value, 14), unit, updated))
+	}
+
+	var b strings.Builder
+	for _, line := range lines {
+		b.WriteString(line + "\x1b[K\r\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// coordinate formats a latitude or longitude, e.g. 51.2°N.
+func coordinate(degrees float64, positive string, negative string) string {
+	if degrees < 0 {
+		return fmt.Sprintf("%.1f°%s", -degrees, negative)
+	}
+	return fmt.Sprintf("%.1f°%s", degrees, positive)
+}
+
+// age formats the time since t, to the second.
+func age(now time.Time, t time.Time) string {
+	return now.Sub(t).Truncate(time.Second).String()
+}
+
+func truncate(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n-1]) + "…"
+	}
+	return s
+}
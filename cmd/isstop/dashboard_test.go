@@ -0,0 +1,74 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMapCell(t *testing.T) {
+	tests := []struct {
+		latitude, longitude float64
+		column, line        int
+	}{
+		{latitude: 90, longitude: -180, column: 0, line: 0},
+		{latitude: 0, longitude: 0, column: 36, line: 9},
+		{latitude: -90, longitude: 180, column: 71, line: 17},
+		{latitude: 51.5, longitude: -0.1, column: 35, line: 3},
+	}
+	for _, tt := range tests {
+		if column, line := mapCell(tt.latitude, tt.longitude); column != tt.column || line != tt.line {
+			t.Errorf("%v,%v: got %d,%d, want %d,%d", tt.latitude, tt.longitude, column, line, tt.column, tt.line)
+		}
+	}
+}
+
+func TestRenderMap(t *testing.T) {
+	lines := renderMap([]location{{latitude: 0, longitude: -10}}, &location{latitude: 0, longitude: 0})
+	if len(lines) != mapHeight+2 {
+		t.Fatalf("got %d lines, want %d", len(lines), mapHeight+2)
+	}
+	if got := lines[10][35:38]; got != "+ @" {
+		t.Errorf("got %q, want %q", got, "+ @")
+	}
+}
+
+func TestDashboard_render(t *testing.T) {
+	now := time.Date(2026, time.October, 15, 12, 0, 0, 0, time.UTC)
+	d := newDashboard("ISS", []string{"USLAB000058", "NODE3000004", "FOO"})
+	d.setValue("USLAB000058", "757.1", now.Add(-3*time.Second))
+	d.setStatus("24", now.Add(-time.Minute))
+	// a position above the equator, at the Greenwich meridian, if Greenwich was aligned with the X axis
+	for axis, value := range []float64{6790, 0, 0} {
+		d.setPosition(axis, value, now)
+	}
+
+	var b strings.Builder
+	if err := d.render(&b, now, true); err != nil {
+		t.Fatal(err)
+	}
+	screen := b.String()
+	for _, want := range []string{
+		"session: connected",
+		"AOS (signal acquired), since 1m0s ago",
+		"0.0°N",
+		"altitude 412 km",
+		"Cabin pressure",
+		"757.1 mmHg   3s",
+		"FOO",
+	} {
+		if !strings.Contains(screen, want) {
+			t.Errorf("screen doesn't contain %q:\n%s", want, screen)
+		}
+	}
+	if len(d.track) != 1 {
+		t.Errorf("got %d track points, want 1", len(d.track))
+	}
+
+	d.setStatus("0", now)
+	b.Reset()
+	_ = d.render(&b, now, false)
+	if screen = b.String(); !strings.Contains(screen, "LOS") || !strings.Contains(screen, "session: disconnected") {
+		t.Errorf("unexpected screen:\n%s", screen)
+	}
+}
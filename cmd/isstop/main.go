@@ -0,0 +1,160 @@
+// isstop shows the live telemetry of the ISS in a terminal: the values of a selection of groups, the signal status,
+// and the position of the station on a world map, e.g.
+//
+//	isstop -subsystems ECLSS
+//
+// It subscribes to ISSLIVE directly, with the lightstreamer client. Press Ctrl-C to exit.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/clambin/iss-exporter/internal/collector"
+	"github.com/clambin/iss-exporter/lightstreamer"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// defaultGroups are shown if no groups or subsystems are selected.
+var defaultGroups = []string{
+	"USLAB000058", // cabin pressure
+	"USLAB000059", // cabin temperature
+	"NODE3000001", // ppO2
+	"NODE3000003", // ppCO2
+	"NODE3000011", // oxygen production rate
+	"NODE3000005", // urine tank
+	"NODE3000009", // clean water tank
+	"S4000001",    // solar array 1A voltage
+	"S4000002",    // solar array 1A current
+}
+
+// ISSLIVE groups with the signal status and the position of the station.
+const statusGroup = "TIME_000001"
+
+var positionGroups = []string{"USLAB000032", "USLAB000033", "USLAB000034"}
+
+var (
+	serverURL  = flag.String("url", "https://push.lightstreamer.com/lightstreamer", "Lightstreamer server URL")
+	adapterSet = flag.String("adapter-set", "ISSLIVE", "adapter set")
+	cid        = flag.String("cid", lightstreamer.DefaultCID, "client ID")
+	groups     = flag.String("groups", "", "groups to show, separated by commas or spaces (default: a selection of life support and power groups)")
+	subsystems = flag.String("subsystems", "", "also show all catalog groups of these subsystems, separated by commas (e.g. ECLSS,EPS)")
+	refresh    = flag.Duration("refresh", time.Second, "interval at which the screen is redrawn")
+	timeout    = flag.Duration("timeout", 10*time.Second, "time allowed to establish the session")
+	logFile    = flag.String("log", "", "write the session's log to this file, as the terminal is taken up by the dashboard")
+)
+
+func main() {
+	flag.Parse()
+	ids := strings.Fields(strings.ReplaceAll(*groups, ",", " "))
+	if *subsystems != "" {
+		cfg := collector.Config{Subsystems: strings.Split(*subsystems, ",")}
+		if err := cfg.Validate(); err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, "isstop:", err)
+			os.Exit(2)
+		}
+		for _, group := range cfg.AllGroups() {
+			if !slices.Contains(ids, group.ID) {
+				ids = append(ids, group.ID)
+			}
+		}
+	}
+	if len(ids) == 0 {
+		ids = defaultGroups
+	}
+
+	logger := slog.New(slog.DiscardHandler)
+	if *logFile != "" {
+		f, err := os.OpenFile(*logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, "isstop:", err)
+			os.Exit(2)
+		}
+		defer func() { _ = f.Close() }()
+		logger = slog.New(slog.NewTextHandler(f, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err := run(ctx, os.Stdout, ids, logger)
+	cancel()
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, "isstop:", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, w io.Writer, ids []string, logger *slog.Logger) error {
+	session := lightstreamer.NewClientSession(
+		lightstreamer.WithLogger(logger),
+		lightstreamer.WithServerURL(*serverURL),
+		lightstreamer.WithAdapterSet(*adapterSet),
+		lightstreamer.WithCID(*cid),
+	)
+	if err := session.ConnectWithSession(ctx, *timeout); err != nil {
+		return fmt.Errorf("connect: %w", err)
+	}
+	defer func() {
+		destroyCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = session.Destroy(destroyCtx)
+	}()
+
+	d := newDashboard("ISS telemetry - "+*serverURL, ids)
+	if err := subscribe(ctx, session, d, ids); err != nil {
+		return fmt.Errorf("subscribe: %w", err)
+	}
+
+	// draw on the alternate screen, without a cursor, and restore the terminal on exit
+	_, _ = io.WriteString(w, "\x1b[?1049h\x1b[?25l")
+	defer func() { _, _ = io.WriteString(w, "\x1b[?25h\x1b[?1049l") }()
+	ticker := time.NewTicker(*refresh)
+	defer ticker.Stop()
+	for {
+		_, _ = io.WriteString(w, "\x1b[H")
+		if err := d.render(w, time.Now(), session.State().Connections > 0); err != nil {
+			return err
+		}
+		_, _ = io.WriteString(w, "\x1b[J")
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// subscribe subscribes to the groups, the signal status and the position, and passes their updates to d.
+func subscribe(ctx context.Context, session *lightstreamer.ClientSession, d *dashboard, ids []string) error {
+	err := session.Subscribe(ctx, "DEFAULT", strings.Join(ids, " "), []string{"Value"}, 0, func(item int, values lightstreamer.Values) {
+		if item > 0 && item <= len(ids) && len(values) > 0 && values[0] != nil {
+			d.setValue(ids[item-1], string(*values[0]), time.Now())
+		}
+	})
+	if err != nil {
+		return err
+	}
+	err = session.Subscribe(ctx, "DEFAULT", statusGroup, []string{"Status.Class"}, 0, func(_ int, values lightstreamer.Values) {
+		if len(values) > 0 && values[0] != nil {
+			d.setStatus(string(*values[0]), time.Now())
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return session.Subscribe(ctx, "DEFAULT", strings.Join(positionGroups, " "), []string{"Value"}, 0, func(item int, values lightstreamer.Values) {
+		if item < 1 || item > len(positionGroups) || len(values) == 0 || values[0] == nil {
+			return
+		}
+		if value, err := strconv.ParseFloat(string(*values[0]), 64); err == nil {
+			d.setPosition(item-1, value, time.Now())
+		}
+	})
+}
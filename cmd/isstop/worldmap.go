@@ -0,0 +1,65 @@
+package main
+
+import (
+	"math"
+	"strings"
+)
+
+// worldMap is a coarse equirectangular map of the Earth: each character covers 5° of longitude, from 180°W, and each
+// line covers 10° of latitude, from 90°N.
+var worldMap = [...]string{
+	"                        .......                                         ",
+	"            ...........  ........      ..      .    .......             ",
+	"   .....................  ...   .    ...................................",
+	"          ................        ...............................  ..   ",
+	"           .............          ................................      ",
+	"            ..........            ........................... ..        ",
+	"             ....  ..            ............... ............           ",
+	"               .....             ..............   ..   .... ..          ",
+	"                    .......       ............      .  .....            ",
+	"                    ..........        .......            .........      ",
+	"                     ........         .........              .....      ",
+	"                      ......           ....               .........     ",
+	"                     .....             ...                 ........   ..",
+	"                     ...                                         .   .. ",
+	"                     ..                                                 ",
+	"                    ......              ................................",
+	"........................................................................",
+	"........................................................................",
+}
+
+const (
+	mapWidth  = 72
+	mapHeight = len(worldMap)
+)
+
+// mapCell returns the column and line of a location on worldMap.
+func mapCell(latitude float64, longitude float64) (int, int) {
+	column := int(math.Floor((longitude + 180) / 360 * mapWidth))
+	line := int(math.Floor((90 - latitude) / 180 * float64(mapHeight)))
+	return min(max(column, 0), mapWidth-1), min(max(line, 0), mapHeight-1)
+}
+
+// renderMap returns worldMap, framed, with the ground track marked by '+', and the current location, if known, by
+// '@'.
+func renderMap(track []location, current *location) []string {
+	lines := make([][]byte, mapHeight)
+	for i, line := range worldMap {
+		lines[i] = []byte(line)
+	}
+	for _, l := range track {
+		column, line := mapCell(l.latitude, l.longitude)
+		lines[line][column] = '+'
+	}
+	if current != nil {
+		column, line := mapCell(current.latitude, current.longitude)
+		lines[line][column] = '@'
+	}
+	border := "+" + strings.Repeat("-", mapWidth) + "+"
+	framed := make([]string, 0, mapHeight+2)
+	framed = append(framed, border)
+	for _, line := range lines {
+		framed = append(framed, "|"+string(line)+"|")
+	}
+	return append(framed, border)
+}
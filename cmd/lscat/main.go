@@ -0,0 +1,217 @@
+// lscat subscribes to a group of a Lightstreamer server, and prints its updates to stdout, e.g.
+//
+//	lscat -group "NODE3000005 USLAB000058" -schema Value,TimeStamp
+//
+// prints the urine tank level and the cabin pressure of the ISS, as published by ISSLIVE.
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/clambin/iss-exporter/lightstreamer"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var (
+	serverURL    = flag.String("url", "https://push.lightstreamer.com/lightstreamer", "Lightstreamer server URL")
+	adapterSet   = flag.String("adapter-set", "ISSLIVE", "adapter set")
+	cid          = flag.String("cid", lightstreamer.DefaultCID, "client ID")
+	username     = flag.String("user", "", "username, if the server requires authentication")
+	password     = flag.String("password", "", "password, if the server requires authentication")
+	dataAdapter  = flag.String("adapter", "DEFAULT", "data adapter")
+	group        = flag.String("group", "", "group to subscribe to: one or more space-separated items (required)")
+	schema       = flag.String("schema", "Value", "fields to subscribe to, separated by commas or spaces")
+	mode         = flag.String("mode", "MERGE", "subscription mode: MERGE, DISTINCT, RAW or COMMAND")
+	maxFrequency = flag.Float64("frequency", 0, "maximum number of updates per second (0: as sent by the server)")
+	maxItems     = flag.Int("max-items", 0, "maximum number of items whose last values are kept to decode updates, evicting the least recently updated (0: no limit)")
+	format       = flag.String("format", "text", "output format: text, json or csv")
+	count        = flag.Int("n", 0, "exit after printing this many updates (0: run until interrupted)")
+	timeout      = flag.Duration("timeout", 10*time.Second, "time allowed to establish the session")
+	debug        = flag.Bool("debug", false, "log the session's debug messages to stderr")
+)
+
+func main() {
+	flag.Parse()
+	if *group == "" {
+		_, _ = fmt.Fprintln(os.Stderr, "lscat: -group is required")
+		flag.Usage()
+		os.Exit(2)
+	}
+	fields := strings.Fields(strings.ReplaceAll(*schema, ",", " "))
+	p, err := newPrinter(os.Stdout, *format, strings.Fields(*group), fields)
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, "lscat:", err)
+		os.Exit(2)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err = run(ctx, p, fields)
+	cancel()
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, "lscat:", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, p *printer, fields []string) error {
+	level := slog.LevelWarn
+	if *debug {
+		level = slog.LevelDebug
+	}
+	options := []lightstreamer.ClientSessionOption{
+		lightstreamer.WithLogger(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level}))),
+		lightstreamer.WithServerURL(*serverURL),
+		lightstreamer.WithAdapterSet(*adapterSet),
+		lightstreamer.WithCID(*cid),
+		lightstreamer.WithMaxItems(*maxItems),
+	}
+	if *username != "" {
+		options = append(options, lightstreamer.WithCredentials(*username, *password))
+	}
+	session := lightstreamer.NewClientSession(options...)
+	if err := session.ConnectWithSession(ctx, *timeout); err != nil {
+		return fmt.Errorf("connect: %w", err)
+	}
+	defer func() {
+		destroyCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = session.Destroy(destroyCtx)
+	}()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	updates := make(chan update, 100)
+	err := session.SubscribeWithMode(ctx, *mode, *dataAdapter, *group, fields, *maxFrequency, func(item int, values lightstreamer.Values) {
+		u := update{time: time.Now(), item: item, values: make([]*string, len(values))}
+		for i, value := range values {
+			if value != nil {
+				s := string(*value)
+				u.values[i] = &s
+			}
+		}
+		select {
+		case updates <- u:
+		case <-ctx.Done():
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("subscribe: %w", err)
+	}
+
+	for printed := 0; *count == 0 || printed < *count; printed++ {
+		select {
+		case <-ctx.Done():
+			return p.flush()
+		case u := <-updates:
+			if err = p.print(u); err != nil {
+				return err
+			}
+		}
+	}
+	return p.flush()
+}
+
+// update is a received update. values are copied, as the session may reuse them. A nil value is a null value.
+type update struct {
+	time   time.Time
+	item   int
+	values []*string
+}
+
+// printer prints updates in the requested format.
+type printer struct {
+	w      io.Writer
+	csv    *csv.Writer
+	format string
+	items  []string
+	fields []string
+}
+
+func newPrinter(w io.Writer, format string, items []string, fields []string) (*printer, error) {
+	p := printer{w: w, format: format, items: items, fields: fields}
+	switch format {
+	case "text", "json":
+	case "csv":
+		p.csv = csv.NewWriter(w)
+		if err := p.csv.Write(append([]string{"time", "item"}, fields...)); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported format %q", format)
+	}
+	return &p, nil
+}
+
+// item returns the name of an item, or its index if the group doesn't have that many items.
+func (p *printer) item(index int) string {
+	if index > 0 && index <= len(p.items) {
+		return p.items[index-1]
+	}
+	return strconv.Itoa(index)
+}
+
+func (p *printer) print(u update) error {
+	item := p.item(u.item)
+	switch p.format {
+	case "json":
+		values := make(map[string]*string, len(p.fields))
+		for i, field := range p.fields {
+			if i < len(u.values) {
+				values[field] = u.values[i]
+			}
+		}
+		return json.NewEncoder(p.w).Encode(struct {
+			Time   time.Time          `json:"time"`
+			Item   string             `json:"item"`
+			Values map[string]*string `json:"values"`
+		}{Time: u.time, Item: item, Values: values})
+	case "csv":
+		record := []string{u.time.Format(time.RFC3339Nano), item}
+		for _, value := range u.values {
+			record = append(record, deref(value))
+		}
+		return p.csv.Write(record)
+	default:
+		var line strings.Builder
+		line.WriteString(u.time.Format(time.RFC3339) + " " + item)
+		for i, value := range u.values {
+			field := strconv.Itoa(i + 1)
+			if i < len(p.fields) {
+				field = p.fields[i]
+			}
+			if value == nil {
+				line.WriteString(" " + field + "=null")
+				continue
+			}
+			line.WriteString(" " + field + "=" + strconv.Quote(*value))
+		}
+		_, err := fmt.Fprintln(p.w, line.String())
+		return err
+	}
+}
+
+// flush writes any buffered output.
+func (p *printer) flush() error {
+	if p.csv == nil {
+		return nil
+	}
+	p.csv.Flush()
+	return p.csv.Error()
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
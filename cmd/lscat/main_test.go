@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestPrinter(t *testing.T) {
+	value := "12.5"
+	u := update{time: time.Date(2025, time.January, 1, 12, 0, 0, 0, time.UTC), item: 2, values: []*string{&value, nil}}
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{format: "text", want: "2025-01-01T12:00:00Z B Value=\"12.5\" Status=null\n"},
+		{format: "json", want: `{"time":"2025-01-01T12:00:00Z","item":"B","values":{"Status":null,"Value":"12.5"}}` + "\n"},
+		{format: "csv", want: "time,item,Value,Status\n2025-01-01T12:00:00Z,B,12.5,\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var out bytes.Buffer
+			p, err := newPrinter(&out, tt.format, []string{"A", "B"}, []string{"Value", "Status"})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err = p.print(u); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err = p.flush(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := out.String(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := newPrinter(&bytes.Buffer{}, "xml", nil, nil); err == nil {
+		t.Error("expected an error for an unsupported format")
+	}
+}
+
+func TestPrinter_item(t *testing.T) {
+	p := printer{items: []string{"A"}}
+	if got := p.item(1); got != "A" {
+		t.Errorf("got %q, want A", got)
+	}
+	if got := p.item(3); got != "3" {
+		t.Errorf("got %q, want 3", got)
+	}
+}
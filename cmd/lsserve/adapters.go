@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"github.com/clambin/iss-exporter/lightstreamer"
+	"math"
+	"math/rand/v2"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// statusOK is the Status.Class of a valid ISSLIVE reading.
+const statusOK = "24"
+
+// A generator is an Adapter that publishes a generated value for each of its items at a fixed interval. Updates have
+// the fields of an ISSLIVE item (Value, Status.Class and TimeStamp), so the exporter can subscribe to them.
+//
+// Updates are published with Server.Publish, rather than sent to the adapter's subscriptions: Server.Publish only
+// reaches the sessions that are still active. New subscriptions receive the last values as their snapshot.
+type generator struct {
+	lightstreamer.InjectAdapter
+	next   func(value float64) float64
+	lock   sync.Mutex
+	values []float64
+	last   map[int]lightstreamer.Values
+}
+
+var _ lightstreamer.SnapshotAdapter = &generator{}
+
+// newCounter returns a generator whose items count the updates they published.
+func newCounter(name string, items int) *generator {
+	return newGenerator(name, items, 0, func(value float64) float64 { return value + 1 })
+}
+
+// newRandomWalk returns a generator whose items start at start, and change by at most step per update.
+func newRandomWalk(name string, items int, start float64, step float64, r *rand.Rand) *generator {
+	return newGenerator(name, items, start, func(value float64) float64 {
+		return math.Round((value+step*(2*r.Float64()-1))*1e4) / 1e4
+	})
+}
+
+func newGenerator(name string, items int, start float64, next func(float64) float64) *generator {
+	items = max(items, 1)
+	g := generator{
+		InjectAdapter: lightstreamer.InjectAdapter{Name: name, Items: items, Fields: 3},
+		next:          next,
+		values:        make([]float64, items),
+		last:          make(map[int]lightstreamer.Values, items),
+	}
+	for i := range g.values {
+		g.values[i] = start
+	}
+	return &g
+}
+
+// Run publishes an update for each item every interval, until ctx is canceled.
+func (g *generator) Run(ctx context.Context, s *lightstreamer.Server, set string, dataAdapter string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			for item, values := range g.generate(now) {
+				s.Publish(set, dataAdapter, g.Name, item, values)
+			}
+		}
+	}
+}
+
+// generate returns the next update of each item, by item index.
+func (g *generator) generate(now time.Time) map[int]lightstreamer.Values {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	updates := make(map[int]lightstreamer.Values, len(g.values))
+	for i := range g.values {
+		g.values[i] = g.next(g.values[i])
+		values := issValues(g.values[i], now)
+		g.last[i+1] = values
+		updates[i+1] = values.Clone()
+	}
+	return updates
+}
+
+func (g *generator) Snapshot() []lightstreamer.AdapterUpdate {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	updates := make([]lightstreamer.AdapterUpdate, 0, len(g.last))
+	for item, values := range g.last {
+		updates = append(updates, lightstreamer.AdapterUpdate{Item: item, Values: values.Clone()})
+	}
+	return updates
+}
+
+// issValues returns the fields of an ISSLIVE item: its value, a valid Status.Class, and its TimeStamp, i.e. the time
+// of the reading in hours since the start of the year (UTC).
+func issValues(value float64, now time.Time) lightstreamer.Values {
+	now = now.UTC()
+	hours := now.Sub(time.Date(now.Year(), time.January, 1, 0, 0, 0, 0, time.UTC)).Hours()
+	return lightstreamer.Values{
+		valuePtr(strconv.FormatFloat(value, 'f', -1, 64)),
+		valuePtr(statusOK),
+		valuePtr(strconv.FormatFloat(hours, 'f', 6, 64)),
+	}
+}
+
+func valuePtr(s string) *lightstreamer.Value {
+	v := lightstreamer.Value(s)
+	return &v
+}
+
+// runPlayback serves a recording as a group: it subscribes to the PlaybackAdapter, and publishes its updates to the
+// server's sessions, until ctx is canceled or the recording ends.
+//
+// Updates recorded with an item name are published to the group with that name, which is registered on its first
+// update.
+func runPlayback(ctx context.Context, s *lightstreamer.Server, set string, dataAdapter string, group string, a *lightstreamer.PlaybackAdapter) {
+	ch := make(chan lightstreamer.AdapterUpdate)
+	items, fields, _ := a.Subscribe(ch, 0, lightstreamer.ModeMerge, "")
+	s.RegisterAdapter(set, dataAdapter, group, lightstreamer.InjectAdapter{Name: group, Items: items, Fields: fields})
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		a.Run(ctx)
+	}()
+
+	named := make(map[string]struct{})
+	for {
+		select {
+		case <-ctx.Done():
+			<-done
+			return
+		case <-done:
+			return
+		case update := <-ch:
+			if update.ItemName == "" {
+				s.Publish(set, dataAdapter, group, update.Item, update.Values)
+				continue
+			}
+			if _, ok := named[update.ItemName]; !ok {
+				s.RegisterAdapter(set, dataAdapter, update.ItemName, lightstreamer.InjectAdapter{Name: update.ItemName, Fields: fields})
+				named[update.ItemName] = struct{}{}
+			}
+			s.Publish(set, dataAdapter, update.ItemName, 1, update.Values)
+		}
+	}
+}
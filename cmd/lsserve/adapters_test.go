@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"github.com/clambin/iss-exporter/lightstreamer"
+	"log/slog"
+	"math/rand/v2"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGenerator(t *testing.T) {
+	now := time.Date(2025, time.January, 2, 12, 0, 0, 0, time.UTC)
+
+	c := newCounter("COUNTER", 2)
+	if got := len(c.Snapshot()); got != 0 {
+		t.Errorf("got %d snapshot updates before the first update, want 0", got)
+	}
+	c.generate(now)
+	updates := c.generate(now)
+	if got := len(updates); got != 2 {
+		t.Fatalf("got %d updates, want 2", got)
+	}
+	fields := lightstreamer.NewFieldMap([]string{"Value", "Status.Class", "TimeStamp"}, updates[2])
+	for field, want := range map[string]string{"Value": "2", "Status.Class": statusOK, "TimeStamp": "36.000000"} {
+		if got, ok := fields.Get(field); !ok || got == nil || string(*got) != want {
+			t.Errorf("%s: got %v, want %q", field, got, want)
+		}
+	}
+	if got := len(c.Snapshot()); got != 2 {
+		t.Errorf("got %d snapshot updates, want 2", got)
+	}
+
+	w := newRandomWalk("WALK", 1, 100, 0.5, rand.New(rand.NewPCG(1, 2)))
+	for range 100 {
+		before := w.values[0]
+		w.generate(now)
+		if diff := w.values[0] - before; diff < -0.5 || diff > 0.5 {
+			t.Fatalf("random walk changed by %v, want at most 0.5", diff)
+		}
+	}
+}
+
+func TestNewServer(t *testing.T) {
+	recording := filepath.Join(t.TempDir(), "recording.csv")
+	if err := os.WriteFile(recording, []byte("0,1,a\n0.01,NAMED,b\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := config{AdapterSet: "DEMO", CID: "cid", Groups: []groupConfig{
+		{Name: "COUNTER", Type: typeCounter, Interval: "10ms"},
+		{Name: "REPLAY", Type: typePlayback, File: recording, Loop: true},
+	}}
+	l := slog.New(slog.DiscardHandler)
+	s, err := newServer(t.Context(), cfg, l)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+
+	// subscribe twice: updates keep flowing after the first session ends.
+	for range 2 {
+		c := lightstreamer.NewClientSession(lightstreamer.WithLogger(l), lightstreamer.WithServerURL(ts.URL), lightstreamer.WithAdapterSet("DEMO"), lightstreamer.WithCID("cid"))
+		if err = c.ConnectWithSession(t.Context(), time.Second); err != nil {
+			t.Fatalf("failed to connect: %v", err)
+		}
+		received := make(chan string, 100)
+		for _, group := range []string{"COUNTER", "REPLAY", "NAMED"} {
+			// NAMED is only registered once the recording's first NAMED update has been replayed.
+			deadline := time.Now().Add(time.Second)
+			for {
+				err = c.Subscribe(t.Context(), "DEFAULT", group, []string{"Value"}, 0, func(int, lightstreamer.Values) {
+					select {
+					case received <- group:
+					default:
+					}
+				})
+				if err == nil || time.Now().After(deadline) {
+					break
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			if err != nil {
+				t.Fatalf("subscribe(%s): %v", group, err)
+			}
+		}
+		waitForGroups(t, received, "COUNTER", "REPLAY", "NAMED")
+		if err = c.Destroy(t.Context()); err != nil {
+			t.Fatalf("failed to destroy session: %v", err)
+		}
+	}
+}
+
+func waitForGroups(t *testing.T, received <-chan string, groups ...string) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	pending := make(map[string]struct{}, len(groups))
+	for _, group := range groups {
+		pending[group] = struct{}{}
+	}
+	for len(pending) > 0 {
+		select {
+		case group := <-received:
+			delete(pending, group)
+		case <-ctx.Done():
+			t.Fatalf("timeout waiting for updates of %v", pending)
+		}
+	}
+}
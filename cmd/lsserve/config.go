@@ -0,0 +1,146 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/clambin/iss-exporter/lightstreamer"
+	"os"
+	"strings"
+	"time"
+)
+
+// Adapter types served by lsserve.
+const (
+	typeCounter    = "counter"
+	typeRandomWalk = "random-walk"
+	typePlayback   = "playback"
+)
+
+// config configures the groups served by lsserve.
+type config struct {
+	// AdapterSet is the adapter set serving the groups. Default: the -adapter-set flag (DEMO).
+	AdapterSet string `json:"adapter_set,omitempty"`
+	// CID is the client ID that clients must present. Default: the -cid flag (the ISSLIVE client ID).
+	CID    string        `json:"cid,omitempty"`
+	Groups []groupConfig `json:"groups"`
+}
+
+// groupConfig configures a group, and the adapter that serves it.
+type groupConfig struct {
+	// Name is the name of the group.
+	Name string `json:"name"`
+	// DataAdapter is the data adapter serving the group. Default: DEFAULT.
+	DataAdapter string `json:"data_adapter,omitempty"`
+	// Type is the type of adapter: counter, random-walk or playback.
+	Type string `json:"type"`
+	// Items is the number of items of a counter or random-walk group. Default: 1.
+	Items int `json:"items,omitempty"`
+	// Interval is the time between updates of a counter or random-walk group, e.g. "500ms". Default: 1s.
+	Interval string `json:"interval,omitempty"`
+	// Start is the initial value of a random walk.
+	Start float64 `json:"start,omitempty"`
+	// Step is the largest change of a random walk's value per update. Default: 1.
+	Step float64 `json:"step,omitempty"`
+	// File is the recording replayed by a playback group.
+	File string `json:"file,omitempty"`
+	// Format is the format of the recording: csv or tlcp. Default: csv.
+	Format string `json:"format,omitempty"`
+	// Speed scales the time between replayed updates: 2 replays the recording twice as fast. Default: 1.
+	Speed float64 `json:"speed,omitempty"`
+	// Loop restarts the recording when all updates have been replayed.
+	Loop bool `json:"loop,omitempty"`
+}
+
+// loadConfig reads the configuration from a JSON file.
+func loadConfig(path string) (config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return config{}, err
+	}
+	defer func() { _ = f.Close() }()
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	var cfg config
+	if err = dec.Decode(&cfg); err != nil {
+		return config{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Validate checks the configuration.
+func (c config) Validate() error {
+	if len(c.Groups) == 0 {
+		return errors.New("no groups configured")
+	}
+	seen := make(map[[2]string]struct{}, len(c.Groups))
+	for _, g := range c.Groups {
+		if g.Name == "" {
+			return errors.New("group without a name")
+		}
+		key := [2]string{g.dataAdapter(), g.Name}
+		if _, ok := seen[key]; ok {
+			return fmt.Errorf("group %s: duplicate group", g.Name)
+		}
+		seen[key] = struct{}{}
+		if err := g.validate(); err != nil {
+			return fmt.Errorf("group %s: %w", g.Name, err)
+		}
+	}
+	return nil
+}
+
+func (g groupConfig) validate() error {
+	if _, err := g.interval(); err != nil {
+		return err
+	}
+	if g.Items < 0 {
+		return errors.New("items can't be negative")
+	}
+	switch g.Type {
+	case typeCounter, typeRandomWalk:
+		if g.File != "" {
+			return fmt.Errorf("%s group can't have a file", g.Type)
+		}
+	case typePlayback:
+		if g.File == "" {
+			return errors.New("playback group needs a file")
+		}
+		if _, err := g.playbackFormat(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("invalid type %q: must be %s, %s or %s", g.Type, typeCounter, typeRandomWalk, typePlayback)
+	}
+	return nil
+}
+
+func (g groupConfig) dataAdapter() string {
+	return cmp.Or(g.DataAdapter, "DEFAULT")
+}
+
+func (g groupConfig) interval() (time.Duration, error) {
+	if g.Interval == "" {
+		return time.Second, nil
+	}
+	interval, err := time.ParseDuration(g.Interval)
+	if err != nil {
+		return 0, fmt.Errorf("invalid interval: %w", err)
+	}
+	if interval <= 0 {
+		return 0, errors.New("interval must be positive")
+	}
+	return interval, nil
+}
+
+func (g groupConfig) playbackFormat() (lightstreamer.PlaybackFormat, error) {
+	switch strings.ToLower(cmp.Or(g.Format, "csv")) {
+	case "csv":
+		return lightstreamer.PlaybackCSV, nil
+	case "tlcp":
+		return lightstreamer.PlaybackTLCP, nil
+	default:
+		return 0, fmt.Errorf("invalid format %q: must be csv or tlcp", g.Format)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name   string
+		groups []groupConfig
+		pass   bool
+	}{
+		{
+			name:   "valid",
+			groups: []groupConfig{{Name: "A", Type: typeCounter}, {Name: "B", Type: typeRandomWalk, Interval: "500ms"}, {Name: "C", Type: typePlayback, File: "c.csv", Format: "TLCP"}},
+			pass:   true,
+		},
+		{name: "same group, different data adapters", groups: []groupConfig{{Name: "A", Type: typeCounter}, {Name: "A", DataAdapter: "OTHER", Type: typeCounter}}, pass: true},
+		{name: "no groups"},
+		{name: "no name", groups: []groupConfig{{Type: typeCounter}}},
+		{name: "duplicate", groups: []groupConfig{{Name: "A", Type: typeCounter}, {Name: "A", Type: typeRandomWalk}}},
+		{name: "invalid type", groups: []groupConfig{{Name: "A", Type: "sine"}}},
+		{name: "invalid interval", groups: []groupConfig{{Name: "A", Type: typeCounter, Interval: "soon"}}},
+		{name: "negative interval", groups: []groupConfig{{Name: "A", Type: typeCounter, Interval: "-1s"}}},
+		{name: "negative items", groups: []groupConfig{{Name: "A", Type: typeCounter, Items: -1}}},
+		{name: "counter with file", groups: []groupConfig{{Name: "A", Type: typeCounter, File: "a.csv"}}},
+		{name: "playback without file", groups: []groupConfig{{Name: "A", Type: typePlayback}}},
+		{name: "invalid playback format", groups: []groupConfig{{Name: "A", Type: typePlayback, File: "a.json", Format: "json"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := config{Groups: tt.groups}.Validate()
+			if tt.pass != (err == nil) {
+				t.Errorf("got error %v, want pass %v", err, tt.pass)
+			}
+		})
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"adapter_set": "ISSLIVE", "groups": [{"name": "A", "type": "counter", "items": 2}]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.AdapterSet != "ISSLIVE" || len(cfg.Groups) != 1 || cfg.Groups[0].Items != 2 {
+		t.Errorf("unexpected configuration: %+v", cfg)
+	}
+
+	if err = os.WriteFile(path, []byte(`{"groups": [{"name": "A", "kind": "counter"}]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = loadConfig(path); err == nil {
+		t.Error("expected an error for an unknown field")
+	}
+}
@@ -0,0 +1,182 @@
+// lsserve runs a standalone Lightstreamer server, serving generated or recorded data, to develop and test
+// Lightstreamer clients without depending on a live feed, e.g.
+//
+//	lsserve -counter COUNTER -random-walk USLAB000058 -interval 500ms
+//
+// serves a counter and a random walk, updated twice per second, at http://localhost:8080/lightstreamer.
+// Larger setups are configured with a JSON file (-config):
+//
+//	{
+//	  "adapter_set": "ISSLIVE",
+//	  "groups": [
+//	    {"name": "USLAB000058", "type": "random-walk", "start": 14.7, "step": 0.05, "interval": "2s"},
+//	    {"name": "NODE3000005", "type": "counter", "interval": "10s"},
+//	    {"name": "REPLAY", "type": "playback", "file": "recording.csv", "speed": 2, "loop": true}
+//	  ]
+//	}
+//
+// Counter and random-walk items have the fields of an ISSLIVE item: Value, Status.Class and TimeStamp.
+// Playback groups replay a recording in the formats supported by lightstreamer.PlaybackAdapter.
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/clambin/iss-exporter/lightstreamer"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var (
+	addr        = flag.String("addr", ":8080", "listener address")
+	configPath  = flag.String("config", "", "configuration file (JSON)")
+	adapterSet  = flag.String("adapter-set", "DEMO", "adapter set, if not set in the configuration file")
+	cid         = flag.String("cid", lightstreamer.DefaultCID, "client ID, if not set in the configuration file")
+	counters    = flag.String("counter", "", "comma-separated list of counter groups")
+	randomWalks = flag.String("random-walk", "", "comma-separated list of random-walk groups")
+	playbacks   = flag.String("playback", "", "comma-separated list of playback groups, as GROUP=FILE (CSV recordings)")
+	interval    = flag.Duration("interval", time.Second, "time between updates of counter and random-walk groups set by flags")
+	admin       = flag.Bool("admin", false, "manage sessions at /admin/sessions")
+	inject      = flag.Bool("inject", false, "accept updates at /test/update")
+	debug       = flag.Bool("debug", false, "log debug messages")
+)
+
+func main() {
+	flag.Parse()
+	level := slog.LevelInfo
+	if *debug {
+		level = slog.LevelDebug
+	}
+	l := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+
+	cfg, err := buildConfig()
+	if err != nil {
+		l.Error("invalid configuration", "err", err)
+		os.Exit(2)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	s, err := newServer(ctx, cfg, l)
+	if err != nil {
+		l.Error("failed to start server", "err", err)
+		os.Exit(1)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/", s)
+	mux.Handle("/lightstreamer/", http.StripPrefix("/lightstreamer", s))
+	if *admin {
+		mux.Handle("/admin/", http.StripPrefix("/admin", s.AdminHandler()))
+	}
+	if *inject {
+		mux.Handle("/test/", s.InjectHandler())
+	}
+
+	httpServer := &http.Server{Addr: *addr, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		// streams never go idle: close them, rather than wait for them to end.
+		_ = httpServer.Close()
+	}()
+	l.Info("serving", "addr", *addr, "adapterSet", cfg.AdapterSet, "groups", len(cfg.Groups))
+	if err = httpServer.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		l.Error("failed to start http server", "err", err)
+		os.Exit(1)
+	}
+}
+
+// buildConfig returns the configuration file, if any, with the groups configured by flags. Without any groups, lsserve
+// serves a counter (COUNTER) and a random walk (WALK).
+func buildConfig() (config, error) {
+	var cfg config
+	if *configPath != "" {
+		var err error
+		if cfg, err = loadConfig(*configPath); err != nil {
+			return config{}, err
+		}
+	}
+	cfg.AdapterSet = cmp.Or(cfg.AdapterSet, *adapterSet)
+	cfg.CID = cmp.Or(cfg.CID, *cid)
+	for _, name := range splitList(*counters) {
+		cfg.Groups = append(cfg.Groups, groupConfig{Name: name, Type: typeCounter, Interval: interval.String()})
+	}
+	for _, name := range splitList(*randomWalks) {
+		cfg.Groups = append(cfg.Groups, groupConfig{Name: name, Type: typeRandomWalk, Interval: interval.String()})
+	}
+	for _, playback := range splitList(*playbacks) {
+		name, file, ok := strings.Cut(playback, "=")
+		if !ok {
+			return config{}, fmt.Errorf("invalid playback %q: expected GROUP=FILE", playback)
+		}
+		cfg.Groups = append(cfg.Groups, groupConfig{Name: name, Type: typePlayback, File: file})
+	}
+	if len(cfg.Groups) == 0 {
+		cfg.Groups = []groupConfig{
+			{Name: "COUNTER", Type: typeCounter, Interval: interval.String()},
+			{Name: "WALK", Type: typeRandomWalk, Interval: interval.String()},
+		}
+	}
+	return cfg, cfg.Validate()
+}
+
+func splitList(list string) []string {
+	var items []string
+	for item := range strings.SplitSeq(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// newServer returns a Server serving the configured groups. Their adapters run until ctx is canceled.
+func newServer(ctx context.Context, cfg config, logger *slog.Logger) (*lightstreamer.Server, error) {
+	s := lightstreamer.NewServer(cfg.AdapterSet, cfg.CID, nil, logger, lightstreamer.WithServerName("lsserve"))
+	for _, g := range cfg.Groups {
+		interval, _ := g.interval()
+		switch g.Type {
+		case typeCounter, typeRandomWalk:
+			a := newCounter(g.Name, g.Items)
+			if g.Type == typeRandomWalk {
+				a = newRandomWalk(g.Name, g.Items, g.Start, cmp.Or(g.Step, 1), rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64())))
+			}
+			s.RegisterAdapter(cfg.AdapterSet, g.dataAdapter(), g.Name, a)
+			go a.Run(ctx, s, cfg.AdapterSet, g.dataAdapter(), interval)
+		case typePlayback:
+			a, err := openPlayback(g)
+			if err != nil {
+				return nil, fmt.Errorf("group %s: %w", g.Name, err)
+			}
+			go runPlayback(ctx, s, cfg.AdapterSet, g.dataAdapter(), g.Name, a)
+		}
+	}
+	return s, nil
+}
+
+func openPlayback(g groupConfig) (*lightstreamer.PlaybackAdapter, error) {
+	format, err := g.playbackFormat()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(g.File)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	a, err := lightstreamer.NewPlaybackAdapter(g.Name, f, format)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", g.File, err)
+	}
+	a.Speed, a.Loop = g.Speed, g.Loop
+	return a, nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"expvar"
+	"github.com/clambin/iss-exporter/internal/collector"
+	"github.com/clambin/iss-exporter/lightstreamer"
+	"sync"
+)
+
+// debugVars is a collector.Subscriber that counts the updates received by each subscription, and publishes them as
+// expvars, with other internal state of the exporter: the messages received from the Lightstreamer server, by type,
+// the number of reconnects and the depth of the outputs' queues. With -debug.pprof, they're served on /debug/vars, so
+// a quick look doesn't require scraping the exporter.
+type debugVars struct {
+	collector.Subscriber
+	vars    *expvar.Map
+	updates *expvar.Map
+	lock    sync.Mutex
+	queues  map[string]<-chan collector.Update
+}
+
+// newDebugVars returns debugVars for the subscriptions of subscriber, reporting the messages received by session.
+// The variables aren't published: publish vars to serve them on /debug/vars.
+func newDebugVars(subscriber collector.Subscriber, session *lightstreamer.ClientSession) *debugVars {
+	d := debugVars{
+		Subscriber: subscriber,
+		vars:       new(expvar.Map).Init(),
+		updates:    new(expvar.Map).Init(),
+		queues:     make(map[string]<-chan collector.Update),
+	}
+	d.vars.Set("messages", expvar.Func(func() any { return session.Messages() }))
+	d.vars.Set("updates", d.updates)
+	d.vars.Set("queues", expvar.Func(d.queueDepths))
+	return &d
+}
+
+// Subscribe subscribes to a group, counting its updates.
+func (d *debugVars) Subscribe(ctx context.Context, dataAdapter string, group string, schema []string, maxFrequency float64, f func(item int, values lightstreamer.Values)) error {
+	return d.Subscriber.Subscribe(ctx, dataAdapter, group, schema, maxFrequency, func(item int, values lightstreamer.Values) {
+		d.updates.Add(group, 1)
+		f(item, values)
+	})
+}
+
+// watchCollector reports the number of times c re-established the session.
+func (d *debugVars) watchCollector(c *collector.Collector) {
+	d.vars.Set("reconnects", expvar.Func(func() any { return c.Reconnects() }))
+}
+
+// watchQueue reports the number of updates queued for an output.
+func (d *debugVars) watchQueue(output string, updates <-chan collector.Update) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.queues[output] = updates
+}
+
+func (d *debugVars) queueDepths() any {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	depths := make(map[string]int, len(d.queues))
+	for output, updates := range d.queues {
+		depths[output] = len(updates)
+	}
+	return depths
+}
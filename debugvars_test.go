@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/clambin/iss-exporter/internal/collector"
+	"github.com/clambin/iss-exporter/lightstreamer"
+	"testing"
+)
+
+func TestDebugVars(t *testing.T) {
+	up := upstream{subscriptions: make(map[string]func(int, lightstreamer.Values))}
+	d := newDebugVars(&up, lightstreamer.NewClientSession())
+
+	var received int
+	if err := d.Subscribe(t.Context(), "DEFAULT", "USLAB000058", []string{"Value"}, 0, func(int, lightstreamer.Values) {
+		received++
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		up.subscriptions["USLAB000058"](1, lightstreamer.Values{valuePtr("760")})
+	}
+	if received != 2 {
+		t.Errorf("got %d updates, want 2", received)
+	}
+
+	updates := make(chan collector.Update, 10)
+	updates <- collector.Update{Group: "USLAB000058"}
+	d.watchQueue("mqtt", updates)
+
+	var got struct {
+		Messages map[string]int `json:"messages"`
+		Updates  map[string]int `json:"updates"`
+		Queues   map[string]int `json:"queues"`
+	}
+	if err := json.Unmarshal([]byte(d.vars.String()), &got); err != nil {
+		t.Fatalf("invalid vars %q: %v", d.vars.String(), err)
+	}
+	if got.Messages == nil || len(got.Messages) != 0 {
+		t.Errorf("got messages %v", got.Messages)
+	}
+	if got.Updates["USLAB000058"] != 2 {
+		t.Errorf("got updates %v", got.Updates)
+	}
+	if got.Queues["mqtt"] != 1 {
+		t.Errorf("got queues %v", got.Queues)
+	}
+}
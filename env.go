@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/clambin/iss-exporter/internal/collector"
+	"os"
+	"strings"
+)
+
+// envPrefix is the prefix of the environment variables that set flags.
+const envPrefix = "ISS_EXPORTER_"
+
+// envName returns the environment variable for a flag: the flag name, in upper case, with dashes and dots replaced by
+// underscores, e.g. ISS_EXPORTER_TELEMETRY_INCLUDE for -telemetry.include.
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(flagName))
+}
+
+// setFromEnv sets all flags that aren't set on the command line from their environment variable, if it's set.
+// Command-line flags take precedence over environment variables, which take precedence over the flag defaults.
+func setFromEnv(fs *flag.FlagSet, lookup func(string) (string, bool)) error {
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if set[f.Name] || err != nil {
+			return
+		}
+		name := envName(f.Name)
+		if value, ok := lookup(name); ok {
+			if err2 := fs.Set(f.Name, value); err2 != nil {
+				err = fmt.Errorf("%s: invalid value %q for -%s: %w", name, value, f.Name, err2)
+			}
+		}
+	})
+	return err
+}
+
+// setFromConfig sets the flags of the settings of the configuration file, unless they're set on the command line or by
+// their environment variable. Empty settings leave their flag unchanged.
+func setFromConfig(fs *flag.FlagSet, cfg collector.Config) error {
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	settings := []struct{ flag, value string }{
+		{"addr", cfg.Listen.Metrics},
+		{"health", cfg.Listen.Health},
+		{"relay", cfg.Listen.Relay},
+		{"grpc.addr", cfg.Listen.GRPC},
+		{"tls-cert", cfg.TLS.CertFile},
+		{"tls-key", cfg.TLS.KeyFile},
+		{"log.format", cfg.LogFormat},
+	}
+	for _, s := range settings {
+		if s.value == "" || set[s.flag] {
+			continue
+		}
+		if err := fs.Set(s.flag, s.value); err != nil {
+			return fmt.Errorf("invalid value %q for -%s: %w", s.value, s.flag, err)
+		}
+	}
+	return nil
+}
+
+// fatal logs a startup error and exits.
+func fatal(msg string, err error) {
+	_, _ = fmt.Fprintf(os.Stderr, "iss-exporter: %s: %v\n", msg, err)
+	os.Exit(2)
+}
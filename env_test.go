@@ -0,0 +1,72 @@
+package main
+
+import (
+	"flag"
+	"github.com/clambin/iss-exporter/internal/collector"
+	"testing"
+)
+
+func TestSetFromEnv(t *testing.T) {
+	env := map[string]string{
+		"ISS_EXPORTER_ADDR":              ":9091",
+		"ISS_EXPORTER_DEBUG":             "true",
+		"ISS_EXPORTER_TELEMETRY_INCLUDE": "^NODE3",
+	}
+	lookup := func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	addr := fs.String("addr", ":9090", "")
+	debug := fs.Bool("debug", false, "")
+	include := fs.String("telemetry.include", "", "")
+	other := fs.String("other", "default", "")
+	if err := fs.Parse([]string{"-telemetry.include", "USLAB"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := setFromEnv(fs, lookup); err != nil {
+		t.Fatal(err)
+	}
+	if *addr != ":9091" || !*debug || *other != "default" {
+		t.Errorf("got addr %q, debug %v, other %q", *addr, *debug, *other)
+	}
+	if *include != "USLAB" {
+		t.Errorf("command line should take precedence: got %q", *include)
+	}
+
+	env["ISS_EXPORTER_DEBUG"] = "maybe"
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Bool("debug", false, "")
+	if err := setFromEnv(fs, lookup); err == nil {
+		t.Error("expected an error for an invalid value")
+	}
+}
+
+func TestSetFromConfig(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	addr := fs.String("addr", ":9090", "")
+	health := fs.String("health", ":8080", "")
+	fs.String("relay", "", "")
+	fs.String("grpc.addr", "", "")
+	tlsCert := fs.String("tls-cert", "", "")
+	tlsKey := fs.String("tls-key", "", "")
+	logFormat := fs.String("log.format", "text", "")
+	if err := fs.Parse([]string{"-health", ":8081"}); err != nil {
+		t.Fatal(err)
+	}
+	cfg := collector.Config{
+		Listen:    collector.ListenConfig{Metrics: ":9091", Health: ":8082"},
+		TLS:       collector.TLSConfig{CertFile: "cert.pem", KeyFile: "key.pem"},
+		LogFormat: "json",
+	}
+	if err := setFromConfig(fs, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if *addr != ":9091" || *tlsCert != "cert.pem" || *tlsKey != "key.pem" || *logFormat != "json" {
+		t.Errorf("got addr %q, tls-cert %q, tls-key %q, log.format %q", *addr, *tlsCert, *tlsKey, *logFormat)
+	}
+	if *health != ":8081" {
+		t.Errorf("command line should take precedence: got %q", *health)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/clambin/iss-exporter/internal/collector"
+	"github.com/coder/websocket"
+	"github.com/prometheus/client_golang/prometheus"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// grafanaOutput pushes telemetry updates to Grafana Live, over a WebSocket, so dashboards update as soon as the
+// telemetry does, rather than at the scrape interval. Updates are pushed in line protocol, as written to InfluxDB:
+// Grafana publishes each measurement (a group's catalog metric, e.g. cabin_pressure, or "telemetry") to the channel
+// stream/<stream>/<measurement>.
+type grafanaOutput struct {
+	url    string
+	token  string
+	group  func(id string) (collector.GroupConfig, bool)
+	errors prometheus.Counter
+	logger *slog.Logger
+}
+
+// grafanaMaxBatch is the maximum number of queued updates pushed in one message.
+const grafanaMaxBatch = 100
+
+// grafanaWriteTimeout is the time allowed to push a message, before the connection is closed.
+const grafanaWriteTimeout = 10 * time.Second
+
+var grafanaStreamID = regexp.MustCompile(`^[A-Za-z0-9_\-]+$`)
+
+// grafanaLiveURL returns the WebSocket URL of the Grafana Live push endpoint of a stream, for a Grafana at endpoint.
+func grafanaLiveURL(endpoint string, stream string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	switch u.Scheme {
+	case "http":
+		u.Scheme = "ws"
+	case "https":
+		u.Scheme = "wss"
+	default:
+		return "", errors.New("endpoint must be an http or https URL")
+	}
+	if u.Host == "" {
+		return "", errors.New("endpoint must be an http or https URL")
+	}
+	if !grafanaStreamID.MatchString(stream) {
+		return "", fmt.Errorf("invalid stream %q: must only contain letters, digits, '_' and '-'", stream)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/api/live/push/" + stream
+	return u.String(), nil
+}
+
+// run pushes the updates until ctx is canceled, reconnecting to Grafana if the connection is lost.
+func (o *grafanaOutput) run(ctx context.Context, updates <-chan collector.Update, retry time.Duration, maxRetry time.Duration) {
+	runOutput(ctx, "grafana", func(ctx context.Context) error {
+		var header http.Header
+		if o.token != "" {
+			header = http.Header{"Authorization": {"Bearer " + o.token}}
+		}
+		dialCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		conn, _, err := websocket.Dial(dialCtx, o.url, &websocket.DialOptions{HTTPHeader: header})
+		cancel()
+		if err != nil {
+			return err
+		}
+		defer func() { _ = conn.Close(websocket.StatusGoingAway, "") }()
+		return o.push(ctx, conn, updates)
+	}, retry, maxRetry, o.errors, o.logger)
+}
+
+// push pushes the updates over a connection, until the connection fails or ctx is canceled. Updates queued while a
+// message is sent are pushed together, in the next message.
+func (o *grafanaOutput) push(ctx context.Context, conn *websocket.Conn, updates <-chan collector.Update) error {
+	// Grafana doesn't send messages, but reading answers its pings, and notices when it closes the connection. It reads
+	// until the connection is closed: canceling a read's context would close the connection without a close message.
+	closed := make(chan error, 1)
+	go func() {
+		for {
+			if _, _, err := conn.Read(context.Background()); err != nil {
+				closed <- err
+				return
+			}
+		}
+	}()
+	var batch []byte
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-closed:
+			return fmt.Errorf("connection lost: %w", err)
+		case u := <-updates:
+			batch = o.appendUpdate(batch[:0], u)
+			for n := 1; n < grafanaMaxBatch && len(updates) > 0; n++ {
+				batch = o.appendUpdate(batch, <-updates)
+			}
+			if err := o.write(conn, batch); err != nil {
+				return fmt.Errorf("push: %w", err)
+			}
+		}
+	}
+}
+
+// write sends a message. If it takes longer than grafanaWriteTimeout, the connection is closed.
+func (o *grafanaOutput) write(conn *websocket.Conn, message []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), grafanaWriteTimeout)
+	defer cancel()
+	return conn.Write(ctx, websocket.MessageText, message)
+}
+
+func (o *grafanaOutput) appendUpdate(b []byte, u collector.Update) []byte {
+	group, ok := o.group(u.Group)
+	if !ok {
+		group = collector.GroupConfig{ID: u.Group}
+	}
+	return appendLineProtocol(b, u, group)
+}
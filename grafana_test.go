@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"github.com/clambin/iss-exporter/internal/collector"
+	"github.com/coder/websocket"
+	"github.com/prometheus/client_golang/prometheus"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGrafanaLiveURL(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		stream   string
+		want     string
+		wantErr  bool
+	}{
+		{endpoint: "http://grafana:3000", stream: "iss", want: "ws://grafana:3000/api/live/push/iss"},
+		{endpoint: "https://example.com/grafana/", stream: "iss-telemetry", want: "wss://example.com/grafana/api/live/push/iss-telemetry"},
+		{endpoint: "grafana:3000", stream: "iss", wantErr: true},
+		{endpoint: "http://grafana:3000", stream: "iss/telemetry", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.endpoint+"/"+tt.stream, func(t *testing.T) {
+			got, err := grafanaLiveURL(tt.endpoint, tt.stream)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("grafanaLiveURL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGrafanaOutput(t *testing.T) {
+	messages := make(chan string, 10)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/live/push/iss" || r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		conn, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			return
+		}
+		for {
+			_, message, err := conn.Read(context.Background())
+			if err != nil {
+				return
+			}
+			messages <- string(message)
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	u, err := grafanaLiveURL(ts.URL, "iss")
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := grafanaOutput{
+		url:   u,
+		token: "token",
+		group: func(id string) (collector.GroupConfig, bool) {
+			return collector.GroupConfig{ID: id, Metric: "cabin_pressure"}, id == "USLAB000058"
+		},
+		errors: prometheus.NewCounter(prometheus.CounterOpts{Name: "errors"}),
+		logger: slog.New(slog.DiscardHandler),
+	}
+	updates := make(chan collector.Update, 10)
+	go o.run(t.Context(), updates, 10*time.Millisecond, 10*time.Millisecond)
+	timestamp := time.Unix(1700000000, 0)
+	updates <- collector.Update{Group: "USLAB000058", Value: 757.1, Timestamp: timestamp}
+	updates <- collector.Update{Group: "X", Value: 1, Timestamp: timestamp}
+
+	// the updates may be pushed in one message, or two
+	var got string
+	for !strings.Contains(got, "telemetry") {
+		select {
+		case message := <-messages:
+			got += message
+		case <-time.After(time.Second):
+			t.Fatalf("timeout. received %q", got)
+		}
+	}
+	want := "cabin_pressure,group=USLAB000058 value=757.1 1700000000000000000\ntelemetry,group=X value=1 1700000000000000000\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/clambin/iss-exporter/internal/collector"
+	"github.com/clambin/iss-exporter/internal/store"
+	"github.com/prometheus/client_golang/prometheus"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// historyStore keeps the telemetry updates in an embedded SQLite store, so their history survives restarts, and serves
+// it on GET /api/v1/history?group=<group>&from=<time>&to=<time>. from and to are RFC 3339 times, and default to the
+// last hour. The group's samples are returned in chronological order.
+type historyStore struct {
+	store   *store.Store
+	group   func(id string) (collector.GroupConfig, bool)
+	errors  prometheus.Counter
+	dropped prometheus.Counter
+	logger  *slog.Logger
+}
+
+// maxHistorySamples is the maximum number of samples returned by a query.
+const maxHistorySamples = 100_000
+
+// historyResponse is the response of /api/v1/history.
+type historyResponse struct {
+	Group   string          `json:"group"`
+	Unit    string          `json:"unit,omitempty"`
+	From    time.Time       `json:"from"`
+	To      time.Time       `json:"to"`
+	Samples []historySample `json:"samples"`
+}
+
+type historySample struct {
+	Timestamp time.Time `json:"timestamp"`
+	Value     float64   `json:"value"`
+	State     string    `json:"state,omitempty"`
+	Status    *float64  `json:"status,omitempty"`
+}
+
+// run appends the updates to the store every interval, and removes expired samples every hour, until ctx is canceled.
+// Updates that fail to be stored are dropped.
+func (h *historyStore) run(ctx context.Context, updates <-chan collector.Update, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	prune := time.NewTicker(time.Hour)
+	defer prune.Stop()
+	h.prune(time.Now())
+	var batch []store.Sample
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := h.store.Append(batch...); err != nil {
+			h.errors.Inc()
+			h.dropped.Add(float64(len(batch)))
+			h.logger.Warn("failed to store telemetry history", "err", err, "samples", len(batch))
+		}
+		batch = batch[:0]
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			flush()
+			return
+		case u := <-updates:
+			batch = append(batch, store.Sample{Group: u.Group, Time: u.Timestamp, Value: u.Value, State: u.State, Status: u.Status})
+		case <-ticker.C:
+			flush()
+		case now := <-prune.C:
+			h.prune(now)
+		}
+	}
+}
+
+func (h *historyStore) prune(now time.Time) {
+	if err := h.store.Prune(now); err != nil {
+		h.errors.Inc()
+		h.logger.Warn("failed to remove expired telemetry history", "err", err)
+	}
+}
+
+func (h *historyStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("group")
+	if id == "" {
+		http.Error(w, "missing group", http.StatusBadRequest)
+		return
+	}
+	group, ok := h.group(id)
+	if !ok {
+		http.Error(w, "group not found", http.StatusNotFound)
+		return
+	}
+	to, err := parseHistoryTime(r.URL.Query().Get("to"), time.Now())
+	if err != nil {
+		http.Error(w, "invalid to: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	from, err := parseHistoryTime(r.URL.Query().Get("from"), to.Add(-time.Hour))
+	if err != nil {
+		http.Error(w, "invalid from: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if from.After(to) {
+		http.Error(w, "from is after to", http.StatusBadRequest)
+		return
+	}
+	samples, err := h.store.Query(id, from, to)
+	if err != nil {
+		h.errors.Inc()
+		h.logger.Warn("failed to query telemetry history", "err", err)
+		http.Error(w, "failed to query history", http.StatusInternalServerError)
+		return
+	}
+	if len(samples) > maxHistorySamples {
+		http.Error(w, "too many samples: narrow the time range", http.StatusUnprocessableEntity)
+		return
+	}
+	response := historyResponse{Group: id, Unit: group.Unit, From: from, To: to, Samples: make([]historySample, len(samples))}
+	for i, sample := range samples {
+		response.Samples[i] = historySample{Timestamp: sample.Time, Value: sample.Value, State: sample.State, Status: sample.Status}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// parseHistoryTime parses an RFC 3339 time, or returns def if s is empty.
+func parseHistoryTime(s string, def time.Time) (time.Time, error) {
+	if s == "" {
+		return def.UTC(), nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	return t.UTC(), err
+}
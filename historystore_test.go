@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/clambin/iss-exporter/internal/collector"
+	"github.com/clambin/iss-exporter/internal/store"
+	"github.com/prometheus/client_golang/prometheus"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestHistoryStore(t *testing.T) {
+	s, err := store.Open(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	h := historyStore{
+		store: s,
+		group: func(id string) (collector.GroupConfig, bool) {
+			return collector.GroupConfig{ID: id, Unit: "mmhg"}, id == "USLAB000058"
+		},
+		errors:  prometheus.NewCounter(prometheus.CounterOpts{Name: "errors"}),
+		dropped: prometheus.NewCounter(prometheus.CounterOpts{Name: "dropped"}),
+		logger:  slog.New(slog.DiscardHandler),
+	}
+
+	// updates are stored when run stops
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	status := 24.0
+	updates := make(chan collector.Update, 3)
+	updates <- collector.Update{Group: "USLAB000058", Value: 760, Timestamp: now.Add(-2 * time.Hour)}
+	updates <- collector.Update{Group: "USLAB000058", Value: 761, Status: &status, Timestamp: now.Add(-time.Minute)}
+	updates <- collector.Update{Group: "USLAB000059", Value: 20, Timestamp: now.Add(-time.Minute)}
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan struct{})
+	go func() { h.run(ctx, updates, time.Hour); close(done) }()
+	for len(updates) > 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	tests := []struct {
+		name       string
+		query      url.Values
+		wantStatus int
+		want       []float64
+	}{
+		{name: "last hour", query: url.Values{"group": {"USLAB000058"}}, wantStatus: http.StatusOK, want: []float64{761}},
+		{name: "range", query: url.Values{"group": {"USLAB000058"}, "from": {now.Add(-3 * time.Hour).Format(time.RFC3339)}}, wantStatus: http.StatusOK, want: []float64{760, 761}},
+		{name: "empty", query: url.Values{"group": {"USLAB000058"}, "to": {now.Add(-3 * time.Hour).Format(time.RFC3339)}}, wantStatus: http.StatusOK, want: []float64{}},
+		{name: "missing group", query: url.Values{}, wantStatus: http.StatusBadRequest},
+		{name: "unknown group", query: url.Values{"group": {"USLAB000059"}}, wantStatus: http.StatusNotFound},
+		{name: "invalid time", query: url.Values{"group": {"USLAB000058"}, "from": {"yesterday"}}, wantStatus: http.StatusBadRequest},
+		{name: "inverted range", query: url.Values{"group": {"USLAB000058"}, "from": {now.Format(time.RFC3339)}, "to": {now.Add(-time.Hour).Format(time.RFC3339)}}, wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/history?"+tt.query.Encode(), nil))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("got status %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var response historyResponse
+			if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+				t.Fatalf("invalid response: %v", err)
+			}
+			if response.Group != "USLAB000058" || response.Unit != "mmhg" || response.Samples == nil {
+				t.Errorf("unexpected response: %+v", response)
+			}
+			if len(response.Samples) != len(tt.want) {
+				t.Fatalf("got %d samples, want %d", len(response.Samples), len(tt.want))
+			}
+			for i, sample := range response.Samples {
+				if sample.Value != tt.want[i] {
+					t.Errorf("sample %d: got %v, want %v", i, sample.Value, tt.want[i])
+				}
+			}
+		})
+	}
+}
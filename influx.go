@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/clambin/iss-exporter/internal/collector"
+	"github.com/prometheus/client_golang/prometheus"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// influxWriter writes telemetry updates to InfluxDB v2, or Telegraf, in line protocol. Each update is a point of the
+// group's catalog metric (e.g. cabin_pressure), tagged with the group, its module and subsystem, and timestamped with
+// the telemetry's timestamp. Groups without a metric are written to the "telemetry" measurement. Points are written
+// every interval, or as soon as batchSize points are queued.
+type influxWriter struct {
+	url        string
+	token      string
+	batchSize  int
+	group      func(id string) (collector.GroupConfig, bool)
+	httpClient *http.Client
+	errors     prometheus.Counter
+	dropped    prometheus.Counter
+	logger     *slog.Logger
+}
+
+// influxURL returns the URL to write points to. If endpoint has no path, the InfluxDB v2 write API is used. org and
+// bucket are added to the query, if set, and the precision is set to nanoseconds.
+func influxURL(endpoint string, org string, bucket string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", errors.New("endpoint must be an http or https URL")
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/api/v2/write"
+	}
+	q := u.Query()
+	if org != "" {
+		q.Set("org", org)
+	}
+	if bucket != "" {
+		q.Set("bucket", bucket)
+	}
+	q.Set("precision", "ns")
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// run writes the updates until ctx is canceled. Points that fail to be written are dropped.
+func (w *influxWriter) run(ctx context.Context, updates <-chan collector.Update, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var batch bytes.Buffer
+	var points int
+	flush := func(ctx context.Context) {
+		if points == 0 {
+			return
+		}
+		if err := w.write(ctx, batch.Bytes()); err != nil {
+			w.errors.Inc()
+			w.dropped.Add(float64(points))
+			w.logger.Warn("failed to write points to InfluxDB", "err", err, "points", points)
+		}
+		batch.Reset()
+		points = 0
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			flush(context.WithoutCancel(ctx))
+			return
+		case u := <-updates:
+			group, ok := w.group(u.Group)
+			if !ok {
+				group = collector.GroupConfig{ID: u.Group}
+			}
+			batch.Write(appendLineProtocol(nil, u, group))
+			if points++; points >= w.batchSize {
+				flush(ctx)
+			}
+		case <-ticker.C:
+			flush(ctx)
+		}
+	}
+}
+
+// write sends a batch of points.
+func (w *influxWriter) write(ctx context.Context, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if w.token != "" {
+		req.Header.Set("Authorization", "Token "+w.token)
+	}
+	resp, err := w.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode/100 != 2 {
+		// InfluxDB explains why a write was rejected in the body
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// appendLineProtocol appends an update as a point in line protocol, e.g.:
+//
+//	cabin_pressure,group=USLAB000058,module=Lab,subsystem=ECLSS,unit=mmhg value=757.1,status=24 1700000000000000000
+func appendLineProtocol(b []byte, u collector.Update, group collector.GroupConfig) []byte {
+	measurement := group.Metric
+	if measurement == "" {
+		measurement = "telemetry"
+	}
+	b = append(b, influxMeasurementEscaper.Replace(measurement)...)
+	for _, tag := range [][2]string{{"group", u.Group}, {"module", group.Module}, {"subsystem", group.Subsystem}, {"unit", u.Unit}} {
+		if tag[1] != "" {
+			b = append(b, ',')
+			b = append(b, tag[0]...)
+			b = append(b, '=')
+			b = append(b, influxTagEscaper.Replace(tag[1])...)
+		}
+	}
+	b = append(b, " value="...)
+	b = strconv.AppendFloat(b, u.Value, 'g', -1, 64)
+	if u.State != "" {
+		b = append(b, `,state="`...)
+		b = append(b, influxStringEscaper.Replace(u.State)...)
+		b = append(b, '"')
+	}
+	if u.Status != nil {
+		b = append(b, ",status="...)
+		b = strconv.AppendFloat(b, *u.Status, 'g', -1, 64)
+	}
+	b = append(b, ' ')
+	b = strconv.AppendInt(b, u.Timestamp.UnixNano(), 10)
+	return append(b, '\n')
+}
+
+var (
+	influxMeasurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `, "\n", `\n`)
+	influxTagEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `, "\n", `\n`)
+	influxStringEscaper      = strings.NewReplacer(`"`, `\"`, `\`, `\\`)
+)
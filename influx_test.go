@@ -0,0 +1,104 @@
+package main
+
+import (
+	"github.com/clambin/iss-exporter/internal/collector"
+	"github.com/prometheus/client_golang/prometheus"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestInfluxURL(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		want     string
+		wantErr  bool
+	}{
+		{endpoint: "http://influxdb:8086", want: "http://influxdb:8086/api/v2/write?bucket=iss&org=home&precision=ns"},
+		{endpoint: "http://telegraf:8186/write?db=iss", want: "http://telegraf:8186/write?bucket=iss&db=iss&org=home&precision=ns"},
+		{endpoint: "influxdb:8086", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.endpoint, func(t *testing.T) {
+			got, err := influxURL(tt.endpoint, "home", "iss")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("influxURL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAppendLineProtocol(t *testing.T) {
+	status := 24.0
+	timestamp := time.Unix(1700000000, 0)
+	tests := []struct {
+		name   string
+		update collector.Update
+		group  collector.GroupConfig
+		want   string
+	}{
+		{
+			name:   "catalog",
+			update: collector.Update{Group: "USLAB000058", Value: 757.1, Unit: "mmhg", Status: &status, Timestamp: timestamp},
+			group:  collector.GroupConfig{ID: "USLAB000058", Metric: "cabin_pressure", Module: "Lab", Subsystem: "ECLSS"},
+			want:   "cabin_pressure,group=USLAB000058,module=Lab,subsystem=ECLSS,unit=mmhg value=757.1,status=24 1700000000000000000\n",
+		},
+		{
+			name:   "state",
+			update: collector.Update{Group: "X", Value: 1, State: `open "fully"`, Timestamp: timestamp},
+			group:  collector.GroupConfig{ID: "X", Module: "Node 3, aft"},
+			want:   `telemetry,group=X,module=Node\ 3\,\ aft value=1,state="open \"fully\"" 1700000000000000000` + "\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(appendLineProtocol(nil, tt.update, tt.group)); got != tt.want {
+				t.Errorf("got  %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInfluxWriter(t *testing.T) {
+	bodies := make(chan string, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Token secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		bodies <- string(body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(ts.Close)
+
+	w := influxWriter{
+		url:        ts.URL,
+		token:      "secret",
+		batchSize:  2,
+		group:      func(string) (collector.GroupConfig, bool) { return collector.GroupConfig{}, false },
+		httpClient: http.DefaultClient,
+		errors:     prometheus.NewCounter(prometheus.CounterOpts{Name: "errors"}),
+		dropped:    prometheus.NewCounter(prometheus.CounterOpts{Name: "dropped"}),
+		logger:     slog.New(slog.DiscardHandler),
+	}
+	updates := make(chan collector.Update)
+	go w.run(t.Context(), updates, time.Hour)
+	updates <- collector.Update{Group: "A", Value: 1, Timestamp: time.Unix(1, 0)}
+	updates <- collector.Update{Group: "B", Value: 2, Timestamp: time.Unix(2, 0)}
+	want := "telemetry,group=A value=1 1000000000\ntelemetry,group=B value=2 2000000000\n"
+	select {
+	case got := <-bodies:
+		if got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for a write")
+	}
+}
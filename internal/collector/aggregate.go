@@ -0,0 +1,138 @@
+package collector
+
+import (
+	"cmp"
+	"github.com/prometheus/client_golang/prometheus"
+	"sync"
+	"time"
+)
+
+// defaultAggregateWindow is the interval over which a group's value is aggregated, unless configured otherwise.
+const defaultAggregateWindow = time.Minute
+
+// aggregations are the supported aggregations of a group's value. Each is exported as the group's metric, suffixed
+// with the aggregation's name.
+var aggregations = []string{"min", "max", "avg", "count"}
+
+var aggregationHelp = map[string]string{
+	"min":   "minimum value over the last complete aggregation window",
+	"max":   "maximum value over the last complete aggregation window",
+	"avg":   "average value over the last complete aggregation window",
+	"count": "number of updates over the last complete aggregation window",
+}
+
+// aggregateWindow returns the configured aggregation window.
+func (g GroupConfig) aggregateWindow() (time.Duration, error) {
+	if g.AggregateWindow == "" {
+		return defaultAggregateWindow, nil
+	}
+	return time.ParseDuration(g.AggregateWindow)
+}
+
+// aggregatedMetricName returns the name of the metric that exports the aggregation of a group's value.
+func (g GroupConfig) aggregatedMetricName(aggregation string) string {
+	if g.Metric == "" {
+		return "iss_telemetry_metric_" + aggregation
+	}
+	return g.metricName() + "_" + aggregation
+}
+
+// newAggregates returns a descriptor for each of the group's aggregations. Groups without a metric of their own
+// share the generic iss_telemetry_metric_<aggregation> metrics, with a "group" label.
+func newAggregates(group GroupConfig) map[string]*prometheus.Desc {
+	if len(group.Aggregate) == 0 {
+		return nil
+	}
+	help := cmp.Or(group.Help, "lightstreamer telemetry "+group.ID)
+	var labels prometheus.Labels
+	if group.Metric == "" {
+		help = "lightstreamer telemetry"
+		labels = prometheus.Labels{"group": group.ID}
+	}
+	descs := make(map[string]*prometheus.Desc, len(group.Aggregate))
+	for _, aggregation := range group.Aggregate {
+		descs[aggregation] = prometheus.NewDesc(
+			group.aggregatedMetricName(aggregation),
+			help+": "+aggregationHelp[aggregation],
+			nil,
+			labels,
+		)
+	}
+	return descs
+}
+
+// window aggregates the values of a signal over fixed intervals of period, aligned to the clock. The aggregations
+// of an interval are reported once it's complete, so they don't depend on when, or by how many servers, the
+// metrics are scraped.
+type window struct {
+	lock   sync.Mutex
+	period time.Duration
+	// start is the start of the current interval.
+	start   time.Time
+	current stats
+	// complete is the last complete interval, and completeLast the last known value at its end.
+	complete     stats
+	completeLast float64
+	hasComplete  bool
+	last         float64
+	hasLast      bool
+}
+
+// stats are the aggregations of the values received during an interval.
+type stats struct {
+	min, max float64
+	sum      float64
+	count    int
+}
+
+// add adds a value received at the given time.
+func (w *window) add(value float64, now time.Time) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.roll(now)
+	if w.current.count == 0 {
+		w.current.min, w.current.max = value, value
+	} else {
+		w.current.min, w.current.max = min(w.current.min, value), max(w.current.max, value)
+	}
+	w.current.sum += value
+	w.current.count++
+	w.last, w.hasLast = value, true
+}
+
+// aggregates returns the aggregations of the last complete interval, as of now. If the interval has no values, the
+// last known value is reported as its minimum, maximum and average. ok is false if no interval with a known value
+// has completed yet.
+func (w *window) aggregates(now time.Time) (aggregates map[string]float64, ok bool) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.roll(now)
+	if !w.hasComplete {
+		return nil, false
+	}
+	aggregates = map[string]float64{"min": w.completeLast, "max": w.completeLast, "avg": w.completeLast, "count": 0}
+	if w.complete.count > 0 {
+		aggregates["min"], aggregates["max"] = w.complete.min, w.complete.max
+		aggregates["avg"] = w.complete.sum / float64(w.complete.count)
+		aggregates["count"] = float64(w.complete.count)
+	}
+	return aggregates, true
+}
+
+// roll completes the current interval, if now is past its end. If more than one interval has passed, the last
+// complete interval had no values.
+func (w *window) roll(now time.Time) {
+	period := cmp.Or(w.period, defaultAggregateWindow)
+	start := now.Truncate(period)
+	if !start.After(w.start) {
+		return
+	}
+	if w.hasLast {
+		w.complete = w.current
+		if start.Sub(w.start) > period {
+			w.complete = stats{}
+		}
+		w.completeLast, w.hasComplete = w.last, true
+	}
+	w.start, w.current = start, stats{}
+}
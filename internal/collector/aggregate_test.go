@@ -0,0 +1,86 @@
+package collector
+
+import (
+	"github.com/clambin/iss-exporter/lightstreamer"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"log/slog"
+	"maps"
+	"testing"
+	"time"
+)
+
+func TestWindow(t *testing.T) {
+	w := window{period: time.Minute}
+	start := time.Date(2025, time.March, 1, 12, 0, 0, 0, time.UTC)
+	if _, ok := w.aggregates(start); ok {
+		t.Fatal("empty window should not report aggregates")
+	}
+	for i, value := range []float64{2, 1, 6} {
+		w.add(value, start.Add(time.Duration(i)*10*time.Second))
+	}
+	if _, ok := w.aggregates(start.Add(50 * time.Second)); ok {
+		t.Fatal("incomplete interval should not report aggregates")
+	}
+	w.add(4, start.Add(time.Minute))
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want map[string]float64
+	}{
+		{name: "first interval", now: start.Add(time.Minute), want: map[string]float64{"min": 1, "max": 6, "avg": 3, "count": 3}},
+		{name: "repeated collect", now: start.Add(90 * time.Second), want: map[string]float64{"min": 1, "max": 6, "avg": 3, "count": 3}},
+		{name: "second interval", now: start.Add(2 * time.Minute), want: map[string]float64{"min": 4, "max": 4, "avg": 4, "count": 1}},
+		{name: "no updates", now: start.Add(5 * time.Minute), want: map[string]float64{"min": 4, "max": 4, "avg": 4, "count": 0}},
+	}
+	for _, tt := range tests {
+		got, ok := w.aggregates(tt.now)
+		if !ok {
+			t.Fatalf("%s: no aggregates", tt.name)
+		}
+		if !maps.Equal(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSignal_collectAggregates(t *testing.T) {
+	c := newCollector(Config{Groups: []GroupConfig{
+		{ID: "A", Metric: "foo", Unit: "percent", Aggregate: []string{"min", "max"}},
+		{ID: "B", Aggregate: []string{"avg"}, AggregateWindow: "5m"},
+	}}, slog.New(slog.DiscardHandler))
+	now := time.Date(2025, time.March, 1, 12, 0, 0, 0, time.UTC)
+	for _, value := range []string{"10", "20"} {
+		for _, s := range c.signals {
+			s.update(lightstreamer.Values{valuePtr(value)}, now)
+		}
+	}
+
+	if got := c.signals[0].aggregatedMetricName("max"); got != "iss_foo_percent_max" {
+		t.Errorf("got metric name %q, want iss_foo_percent_max", got)
+	}
+	// B's five-minute window isn't complete yet
+	want := map[*prometheus.Desc]float64{
+		c.signals[0].aggregates["min"]: 10,
+		c.signals[0].aggregates["max"]: 20,
+	}
+	got := make(map[*prometheus.Desc]float64)
+	ch := make(chan prometheus.Metric)
+	go func() {
+		for _, s := range c.signals {
+			s.collectAggregates(ch, now.Add(time.Minute))
+		}
+		close(ch)
+	}()
+	for metric := range ch {
+		var m dto.Metric
+		if err := metric.Write(&m); err != nil {
+			t.Fatal(err)
+		}
+		got[metric.Desc()] = m.GetGauge().GetValue()
+	}
+	if !maps.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
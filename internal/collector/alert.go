@@ -0,0 +1,148 @@
+package collector
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultAlertCooldown is the minimum time between two alerts for the same group, unless configured otherwise.
+const defaultAlertCooldown = 15 * time.Minute
+
+// AlertConfig configures where threshold alerts are sent.
+type AlertConfig struct {
+	// WebhookURL receives a POST with a Slack-compatible JSON payload ({"text": "..."}) when an alert fires or resolves.
+	WebhookURL string `json:"webhook_url"`
+	// Cooldown is the minimum time between two alerts for the same group, e.g. "5m". Defaults to 15 minutes.
+	Cooldown string `json:"cooldown,omitempty"`
+}
+
+// cooldown returns the configured cooldown.
+func (c AlertConfig) cooldown() (time.Duration, error) {
+	if c.Cooldown == "" {
+		return defaultAlertCooldown, nil
+	}
+	return time.ParseDuration(c.Cooldown)
+}
+
+// AlertRule fires an alert when the value of a group crosses a threshold.
+type AlertRule struct {
+	// Below fires the alert when the value drops below the threshold.
+	Below *float64 `json:"below,omitempty"`
+	// Above fires the alert when the value rises above the threshold.
+	Above *float64 `json:"above,omitempty"`
+}
+
+// firing returns true if the value breaches the rule's thresholds.
+func (r AlertRule) firing(value float64) bool {
+	return (r.Below != nil && value < *r.Below) || (r.Above != nil && value > *r.Above)
+}
+
+// String describes the rule's thresholds.
+func (r AlertRule) String() string {
+	var thresholds []string
+	if r.Below != nil {
+		thresholds = append(thresholds, "below "+strconv.FormatFloat(*r.Below, 'f', -1, 64))
+	}
+	if r.Above != nil {
+		thresholds = append(thresholds, "above "+strconv.FormatFloat(*r.Above, 'f', -1, 64))
+	}
+	return strings.Join(thresholds, " or ")
+}
+
+// alerter evaluates the alert rules of the configured groups, and sends an alert to a webhook when a group starts or
+// stops breaching its thresholds. Alerts are evaluated as updates are received, rather than at scrape time.
+type alerter struct {
+	url        string
+	cooldown   time.Duration
+	httpClient *http.Client
+	logger     *slog.Logger
+	lock       sync.Mutex
+	states     map[string]*alertState
+}
+
+// alertState is the alert status of a group.
+type alertState struct {
+	firing   bool
+	notified bool
+	lastSent time.Time
+}
+
+// newAlerter returns an alerter for cfg, or nil if no webhook is configured.
+func newAlerter(cfg Config, logger *slog.Logger) *alerter {
+	if cfg.Alerts.WebhookURL == "" {
+		return nil
+	}
+	cooldown, _ := cfg.Alerts.cooldown() // validated by Config.Validate
+	return &alerter{
+		url:        cfg.Alerts.WebhookURL,
+		cooldown:   cooldown,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		logger:     logger,
+		states:     make(map[string]*alertState),
+	}
+}
+
+// evaluate checks a group's new value against its rule, and sends an alert if it starts or stops breaching it.
+// When an alert fires, no new alert is sent for the group until cooldown has passed, to avoid alerting on a value
+// that flaps around its threshold. An alert is resolved only if it was sent.
+func (a *alerter) evaluate(group GroupConfig, value float64, now time.Time) {
+	if a == nil || group.Alert == nil {
+		return
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	state, ok := a.states[group.ID]
+	if !ok {
+		state = new(alertState)
+		a.states[group.ID] = state
+	}
+	firing := group.Alert.firing(value)
+	if firing == state.firing {
+		return
+	}
+	state.firing = firing
+	var text string
+	switch {
+	case firing && now.Sub(state.lastSent) >= a.cooldown:
+		state.notified, state.lastSent = true, now
+		text = fmt.Sprintf(":rotating_light: ISS %s is %s: %g", cmp.Or(group.Help, group.ID), group.Alert, value)
+	case !firing && state.notified:
+		state.notified = false
+		text = fmt.Sprintf(":white_check_mark: ISS %s is back to normal: %g", cmp.Or(group.Help, group.ID), value)
+	default:
+		return
+	}
+	go func() {
+		if err := a.send(context.Background(), text); err != nil {
+			a.logger.Warn("failed to send alert", "group", group.ID, "err", err)
+		}
+	}()
+}
+
+// send posts a Slack-compatible message to the webhook.
+func (a *alerter) send(ctx context.Context, text string) error {
+	body, _ := json.Marshal(struct {
+		Text string `json:"text"`
+	}{Text: text})
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, a.url, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return errors.New(resp.Status)
+	}
+	return nil
+}
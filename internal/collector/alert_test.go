@@ -0,0 +1,82 @@
+package collector
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAlerter_evaluate(t *testing.T) {
+	var lock sync.Mutex
+	var got []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg struct {
+			Text string `json:"text"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		got = append(got, msg.Text)
+	}))
+	t.Cleanup(ts.Close)
+
+	below := 700.0
+	cfg := Config{Alerts: AlertConfig{WebhookURL: ts.URL, Cooldown: "10m"}}
+	a := newAlerter(cfg, slog.New(slog.DiscardHandler))
+	group := GroupConfig{ID: "A", Help: "cabin pressure", Alert: &AlertRule{Below: &below}}
+
+	now := time.Now()
+	for _, update := range []struct {
+		value float64
+		after time.Duration
+	}{
+		{value: 750},                     // ok
+		{value: 690, after: time.Minute}, // fires
+		{value: 680, after: time.Minute}, // still firing
+		{value: 710, after: time.Minute}, // resolves
+		{value: 690, after: time.Minute}, // within cooldown: not sent
+		{value: 710, after: time.Minute}, // not sent, so not resolved
+		{value: 690, after: time.Hour},   // fires
+	} {
+		now = now.Add(update.after)
+		a.evaluate(group, update.value, now)
+		// alerts are sent asynchronously: wait for each one, so they arrive in order
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	want := []string{
+		":rotating_light: ISS cabin pressure is below 700: 690",
+		":white_check_mark: ISS cabin pressure is back to normal: 710",
+		":rotating_light: ISS cabin pressure is below 700: 690",
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestAlertRule_String(t *testing.T) {
+	below, above := 1.5, 10.0
+	tests := []struct {
+		rule AlertRule
+		want string
+	}{
+		{rule: AlertRule{Below: &below}, want: "below 1.5"},
+		{rule: AlertRule{Above: &above}, want: "above 10"},
+		{rule: AlertRule{Below: &below, Above: &above}, want: "below 1.5 or above 10"},
+	}
+	for _, tt := range tests {
+		if got := tt.rule.String(); got != tt.want {
+			t.Errorf("got %q, want %q", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sync"
+)
+
+const (
+	// aosGroup is the ISSLIVE group that reports whether the ground has a signal from the ISS.
+	aosGroup = "TIME_000001"
+	// aosStatusClass is the Status.Class of aosGroup when the signal is acquired. Any other value means loss of signal.
+	aosStatusClass = 24
+)
+
+var signalAcquiredMetric = prometheus.NewDesc(
+	prometheus.BuildFQName("iss", "", "signal_acquired"),
+	"1 if the ground has acquired the ISS signal (AOS), 0 during loss of signal (LOS)",
+	nil,
+	nil,
+)
+
+// aos tracks the ISS signal status.
+type aos struct {
+	lock     sync.RWMutex
+	known    bool
+	acquired bool
+	los      prometheus.Counter
+}
+
+func newAOS() *aos {
+	return &aos{
+		los: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: prometheus.BuildFQName("iss", "signal", "los_total"),
+			Help: "number of times the ISS signal was lost",
+		}),
+	}
+}
+
+// update records the signal status, reported as the Status.Class of aosGroup. It returns true if the status changed,
+// and whether this is the first status received, rather than a transition.
+func (a *aos) update(statusClass float64) (changed bool, initial bool) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	acquired := statusClass == aosStatusClass
+	if a.known && a.acquired == acquired {
+		return false, false
+	}
+	if a.known && !acquired {
+		a.los.Inc()
+	}
+	initial = !a.known
+	a.known, a.acquired = true, acquired
+	return true, initial
+}
+
+func (a *aos) Describe(ch chan<- *prometheus.Desc) {
+	ch <- signalAcquiredMetric
+	a.los.Describe(ch)
+}
+
+func (a *aos) Collect(ch chan<- prometheus.Metric) {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+	if a.known {
+		var acquired float64
+		if a.acquired {
+			acquired = 1
+		}
+		ch <- prometheus.MustNewConstMetric(signalAcquiredMetric, prometheus.GaugeValue, acquired)
+	}
+	a.los.Collect(ch)
+}
@@ -0,0 +1,55 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"testing"
+)
+
+func TestAOS(t *testing.T) {
+	a := newAOS()
+	if got := collectCount(a); got != 1 {
+		t.Errorf("got %d metrics before the first update, want 1", got)
+	}
+
+	updates := []struct {
+		statusClass float64
+		wantChanged bool
+		wantInitial bool
+		wantLOS     float64
+	}{
+		{statusClass: 0, wantChanged: true, wantInitial: true, wantLOS: 0},
+		{statusClass: 24, wantChanged: true, wantLOS: 0},
+		{statusClass: 24, wantChanged: false, wantLOS: 0},
+		{statusClass: 0, wantChanged: true, wantLOS: 1},
+		{statusClass: 18, wantChanged: false, wantLOS: 1},
+		{statusClass: 24, wantChanged: true, wantLOS: 1},
+		{statusClass: 0, wantChanged: true, wantLOS: 2},
+	}
+	for _, u := range updates {
+		if changed, initial := a.update(u.statusClass); changed != u.wantChanged || initial != u.wantInitial {
+			t.Errorf("update(%v) got %v/%v, want %v/%v", u.statusClass, changed, initial, u.wantChanged, u.wantInitial)
+		}
+		var m dto.Metric
+		_ = a.los.Write(&m)
+		if got := m.GetCounter().GetValue(); got != u.wantLOS {
+			t.Errorf("update(%v): los got %v, want %v", u.statusClass, got, u.wantLOS)
+		}
+	}
+	if got := collectCount(a); got != 2 {
+		t.Errorf("got %d metrics, want 2", got)
+	}
+}
+
+func collectCount(c prometheus.Collector) int {
+	ch := make(chan prometheus.Metric)
+	go func() {
+		c.Collect(ch)
+		close(ch)
+	}()
+	var count int
+	for range ch {
+		count++
+	}
+	return count
+}
@@ -0,0 +1,42 @@
+package collector
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// APIHandler returns an http.Handler that serves the last update of the telemetry groups as JSON, for clients that
+// poll the current state rather than scrape metrics:
+//
+//   - GET /api/v1/telemetry returns the last update of each group that has been updated
+//   - GET /api/v1/telemetry/{group} returns the last update of a group
+//
+// A group that isn't configured returns 404 Not Found. A group that hasn't been updated yet returns 503 Service
+// Unavailable.
+func (c *Collector) APIHandler() http.Handler {
+	m := http.NewServeMux()
+	m.HandleFunc("GET /api/v1/telemetry", func(w http.ResponseWriter, _ *http.Request) {
+		updates := c.Latest()
+		if updates == nil {
+			updates = []Update{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(updates)
+	})
+	m.HandleFunc("GET /api/v1/telemetry/{group}", func(w http.ResponseWriter, r *http.Request) {
+		s, _, ok := c.lookup(r.PathValue("group"))
+		if !ok {
+			http.Error(w, "group not found", http.StatusNotFound)
+			return
+		}
+		u, ok := c.lastUpdate(s, time.Now())
+		if !ok {
+			http.Error(w, "no update received yet", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(u)
+	})
+	return m
+}
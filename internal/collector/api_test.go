@@ -0,0 +1,61 @@
+package collector
+
+import (
+	"encoding/json"
+	"github.com/clambin/iss-exporter/lightstreamer"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCollector_APIHandler(t *testing.T) {
+	c := newCollector(Config{Groups: []GroupConfig{{ID: "A"}, {ID: "B"}, {ID: "C"}}}, slog.New(slog.DiscardHandler))
+	c.StaleAfter = time.Minute
+	now := time.Now()
+	c.signals[0].update(lightstreamer.Values{valuePtr("1"), valuePtr("24")}, now)
+	c.signals[1].update(lightstreamer.Values{valuePtr("2")}, now.Add(-time.Hour))
+	h := c.APIHandler()
+
+	tests := []struct {
+		path     string
+		wantCode int
+		want     []Update
+	}{
+		{path: "/api/v1/telemetry", wantCode: http.StatusOK, want: []Update{{Group: "A", Value: 1}, {Group: "B", Value: 2, Stale: true}}},
+		{path: "/api/v1/telemetry/A", wantCode: http.StatusOK, want: []Update{{Group: "A", Value: 1}}},
+		{path: "/api/v1/telemetry/C", wantCode: http.StatusServiceUnavailable},
+		{path: "/api/v1/telemetry/D", wantCode: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			resp := httptest.NewRecorder()
+			h.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if resp.Code != tt.wantCode {
+				t.Fatalf("got %d, want %d", resp.Code, tt.wantCode)
+			}
+			if resp.Code != http.StatusOK {
+				return
+			}
+			var updates []Update
+			if len(tt.want) == 1 {
+				var u Update
+				if err := json.NewDecoder(resp.Body).Decode(&u); err != nil {
+					t.Fatal(err)
+				}
+				updates = []Update{u}
+			} else if err := json.NewDecoder(resp.Body).Decode(&updates); err != nil {
+				t.Fatal(err)
+			}
+			if len(updates) != len(tt.want) {
+				t.Fatalf("got %d updates, want %d", len(updates), len(tt.want))
+			}
+			for i, want := range tt.want {
+				if got := updates[i]; got.Group != want.Group || got.Value != want.Value || got.Stale != want.Stale {
+					t.Errorf("got %+v, want %+v", got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"math"
+	"time"
+)
+
+var attitudeMetric = prometheus.NewDesc(
+	prometheus.BuildFQName("iss", "attitude", "degrees"),
+	"ISS attitude (LVLH), as yaw, pitch and roll angles, derived from its attitude quaternion",
+	[]string{"axis"},
+	nil,
+)
+
+// attitudeGroups are the catalog groups with the ISS attitude quaternion (LVLH): the scalar, X, Y and Z components.
+var attitudeGroups = [4]string{"USLAB000018", "USLAB000019", "USLAB000020", "USLAB000021"}
+
+// collectAttitude collects the ISS yaw, pitch and roll, if all components of the attitude quaternion are subscribed
+// to and known. Stale readings are skipped.
+func (c *Collector) collectAttitude(ch chan<- prometheus.Metric, now time.Time) {
+	var q [4]float64
+	var found int
+	for _, s := range c.currentSignals() {
+		for i, group := range attitudeGroups {
+			if s.ID != group {
+				continue
+			}
+			r := s.last()
+			if r.hasValue && (c.StaleAfter == 0 || now.Sub(r.received) <= c.StaleAfter) {
+				q[i] = r.value
+				found++
+			}
+		}
+	}
+	if found != len(attitudeGroups) {
+		return
+	}
+	yaw, pitch, roll, ok := eulerAngles(q)
+	if !ok {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(attitudeMetric, prometheus.GaugeValue, yaw, "yaw")
+	ch <- prometheus.MustNewConstMetric(attitudeMetric, prometheus.GaugeValue, pitch, "pitch")
+	ch <- prometheus.MustNewConstMetric(attitudeMetric, prometheus.GaugeValue, roll, "roll")
+}
+
+// eulerAngles converts a quaternion (scalar first) to yaw, pitch and roll angles, in degrees, in the yaw-pitch-roll
+// (3-2-1) sequence used for the ISS attitude. ok is false if the quaternion is zero.
+func eulerAngles(q [4]float64) (yaw float64, pitch float64, roll float64, ok bool) {
+	norm := math.Sqrt(q[0]*q[0] + q[1]*q[1] + q[2]*q[2] + q[3]*q[3])
+	if norm == 0 {
+		return 0, 0, 0, false
+	}
+	q0, q1, q2, q3 := q[0]/norm, q[1]/norm, q[2]/norm, q[3]/norm
+	roll = math.Atan2(2*(q0*q1+q2*q3), 1-2*(q1*q1+q2*q2))
+	pitch = math.Asin(max(-1, min(1, 2*(q0*q2-q3*q1))))
+	yaw = math.Atan2(2*(q0*q3+q1*q2), 1-2*(q2*q2+q3*q3))
+	return yaw * 180 / math.Pi, pitch * 180 / math.Pi, roll * 180 / math.Pi, true
+}
@@ -0,0 +1,65 @@
+package collector
+
+import (
+	"github.com/clambin/iss-exporter/lightstreamer"
+	"github.com/prometheus/client_golang/prometheus"
+	"log/slog"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestEulerAngles(t *testing.T) {
+	// sin and cos of half of 30 degrees
+	s, c := math.Sincos(15 * math.Pi / 180)
+	tests := []struct {
+		name             string
+		q                [4]float64
+		yaw, pitch, roll float64
+		wantOK           bool
+	}{
+		{name: "identity", q: [4]float64{1, 0, 0, 0}, wantOK: true},
+		{name: "roll", q: [4]float64{c, s, 0, 0}, roll: 30, wantOK: true},
+		{name: "pitch", q: [4]float64{c, 0, s, 0}, pitch: 30, wantOK: true},
+		{name: "yaw", q: [4]float64{c, 0, 0, s}, yaw: 30, wantOK: true},
+		{name: "not normalized", q: [4]float64{2 * c, 0, 0, 2 * s}, yaw: 30, wantOK: true},
+		{name: "zero"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			yaw, pitch, roll, ok := eulerAngles(tt.q)
+			if ok != tt.wantOK {
+				t.Fatalf("got ok %v, want %v", ok, tt.wantOK)
+			}
+			if math.Abs(yaw-tt.yaw) > 1e-9 || math.Abs(pitch-tt.pitch) > 1e-9 || math.Abs(roll-tt.roll) > 1e-9 {
+				t.Errorf("got (%v, %v, %v), want (%v, %v, %v)", yaw, pitch, roll, tt.yaw, tt.pitch, tt.roll)
+			}
+		})
+	}
+}
+
+func TestCollector_collectAttitude(t *testing.T) {
+	c := newCollector(Config{Subsystems: []string{"GNC"}}, slog.New(slog.DiscardHandler))
+	now := time.Now()
+	if got := collectCount(collectorFunc(func(ch chan<- prometheus.Metric) { c.collectAttitude(ch, now) })); got != 0 {
+		t.Errorf("got %d metrics without a quaternion, want 0", got)
+	}
+	for _, s := range c.signals {
+		for i, group := range attitudeGroups {
+			if s.ID == group {
+				s.update(lightstreamer.Values{valuePtr(strconv.Itoa(1 - min(i, 1)))}, now)
+			}
+		}
+	}
+	if got := collectCount(collectorFunc(func(ch chan<- prometheus.Metric) { c.collectAttitude(ch, now) })); got != 3 {
+		t.Errorf("got %d metrics, want 3", got)
+	}
+}
+
+// collectorFunc turns a collect function into a prometheus.Collector.
+type collectorFunc func(ch chan<- prometheus.Metric)
+
+func (f collectorFunc) Describe(chan<- *prometheus.Desc) {}
+
+func (f collectorFunc) Collect(ch chan<- prometheus.Metric) { f(ch) }
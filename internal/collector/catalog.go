@@ -0,0 +1,158 @@
+package collector
+
+import (
+	"maps"
+	"slices"
+)
+
+// catalog describes the public ISSLIVE telemetry groups that iss-exporter knows about. Groups in the catalog are
+// exported with a meaningful metric name, rather than by the generic iss_telemetry_metric gauge.
+// Use Config.Subsystems to subscribe to all groups of a subsystem.
+var catalog = map[string]GroupConfig{
+	// ECLSS: Environmental Control and Life Support System
+	"NODE3000001":   {Metric: "node3_ppo2", Unit: "mmhg", Help: "Node 3 partial pressure of oxygen", Module: "Node3", Subsystem: "ECLSS"},
+	"NODE3000002":   {Metric: "node3_ppn2", Unit: "mmhg", Help: "Node 3 partial pressure of nitrogen", Module: "Node3", Subsystem: "ECLSS"},
+	"NODE3000003":   {Metric: "node3_ppco2", Unit: "mmhg", Help: "Node 3 partial pressure of carbon dioxide", Module: "Node3", Subsystem: "ECLSS"},
+	"NODE3000004":   {Metric: "urine_processor_state", Help: "Urine processor state", Module: "Node3", Subsystem: "ECLSS"},
+	"NODE3000005":   {Metric: "urine_tank", Unit: "percent", Help: "Urine tank quantity", Module: "Node3", Subsystem: "ECLSS"},
+	"NODE3000006":   {Metric: "water_processor_state", Help: "Water processor state", Module: "Node3", Subsystem: "ECLSS"},
+	"NODE3000007":   {Metric: "water_processor_step", Help: "Water processor step", Module: "Node3", Subsystem: "ECLSS"},
+	"NODE3000008":   {Metric: "waste_water_tank", Unit: "percent", Help: "Waste water tank quantity", Module: "Node3", Subsystem: "ECLSS"},
+	"NODE3000009":   {Metric: "clean_water_tank", Unit: "percent", Help: "Clean water tank quantity", Module: "Node3", Subsystem: "ECLSS"},
+	"NODE3000010":   {Metric: "oxygen_generator_state", Help: "Oxygen generator state", Module: "Node3", Subsystem: "ECLSS"},
+	"NODE3000011":   {Metric: "oxygen_production_rate", Unit: "pounds_per_day", Help: "Oxygen production rate", Module: "Node3", Subsystem: "ECLSS"},
+	"USLAB000053":   {Metric: "lab_ppo2", Unit: "mmhg", Help: "Lab partial pressure of oxygen", Module: "Lab", Subsystem: "ECLSS"},
+	"USLAB000054":   {Metric: "lab_ppn2", Unit: "mmhg", Help: "Lab partial pressure of nitrogen", Module: "Lab", Subsystem: "ECLSS"},
+	"USLAB000055":   {Metric: "lab_ppco2", Unit: "mmhg", Help: "Lab partial pressure of carbon dioxide", Module: "Lab", Subsystem: "ECLSS"},
+	"USLAB000058":   {Metric: "cabin_pressure", Unit: "mmhg", Help: "Cabin pressure", Module: "Lab", Subsystem: "ECLSS"},
+	"USLAB000059":   {Metric: "cabin_temperature", Unit: "celsius", Help: "Cabin temperature", Module: "Lab", Subsystem: "ECLSS"},
+	"AIRLOCK000049": {Metric: "crewlock_pressure", Unit: "mmhg", Help: "Crewlock pressure", Module: "Airlock", Subsystem: "ECLSS"},
+	"AIRLOCK000054": {Metric: "airlock_pressure", Unit: "mmhg", Help: "Airlock pressure", Module: "Airlock", Subsystem: "ECLSS"},
+
+	// EPS: Electrical Power System
+	"S4000001": {Metric: "solar_array_1a_voltage", Unit: "volts", Help: "Solar array 1A voltage", Module: "S4", Subsystem: "EPS"},
+	"S4000002": {Metric: "solar_array_1a_current", Unit: "amperes", Help: "Solar array 1A current", Module: "S4", Subsystem: "EPS"},
+	"S4000004": {Metric: "solar_array_3a_voltage", Unit: "volts", Help: "Solar array 3A voltage", Module: "S4", Subsystem: "EPS"},
+	"S4000005": {Metric: "solar_array_3a_current", Unit: "amperes", Help: "Solar array 3A current", Module: "S4", Subsystem: "EPS"},
+	"S4000007": {Metric: "beta_gimbal_1a_angle", Unit: "degrees", Help: "Beta gimbal 1A angle", Module: "S4", Subsystem: "EPS"},
+	"S4000008": {Metric: "beta_gimbal_3a_angle", Unit: "degrees", Help: "Beta gimbal 3A angle", Module: "S4", Subsystem: "EPS"},
+	"P4000001": {Metric: "solar_array_2a_voltage", Unit: "volts", Help: "Solar array 2A voltage", Module: "P4", Subsystem: "EPS"},
+	"P4000002": {Metric: "solar_array_2a_current", Unit: "amperes", Help: "Solar array 2A current", Module: "P4", Subsystem: "EPS"},
+	"P4000004": {Metric: "solar_array_4a_voltage", Unit: "volts", Help: "Solar array 4A voltage", Module: "P4", Subsystem: "EPS"},
+	"P4000005": {Metric: "solar_array_4a_current", Unit: "amperes", Help: "Solar array 4A current", Module: "P4", Subsystem: "EPS"},
+	"P4000007": {Metric: "beta_gimbal_2a_angle", Unit: "degrees", Help: "Beta gimbal 2A angle", Module: "P4", Subsystem: "EPS"},
+	"P4000008": {Metric: "beta_gimbal_4a_angle", Unit: "degrees", Help: "Beta gimbal 4A angle", Module: "P4", Subsystem: "EPS"},
+	"S6000001": {Metric: "solar_array_3b_voltage", Unit: "volts", Help: "Solar array 3B voltage", Module: "S6", Subsystem: "EPS"},
+	"S6000002": {Metric: "solar_array_3b_current", Unit: "amperes", Help: "Solar array 3B current", Module: "S6", Subsystem: "EPS"},
+	"S6000004": {Metric: "solar_array_1b_voltage", Unit: "volts", Help: "Solar array 1B voltage", Module: "S6", Subsystem: "EPS"},
+	"S6000005": {Metric: "solar_array_1b_current", Unit: "amperes", Help: "Solar array 1B current", Module: "S6", Subsystem: "EPS"},
+	"S6000007": {Metric: "beta_gimbal_3b_angle", Unit: "degrees", Help: "Beta gimbal 3B angle", Module: "S6", Subsystem: "EPS"},
+	"S6000008": {Metric: "beta_gimbal_1b_angle", Unit: "degrees", Help: "Beta gimbal 1B angle", Module: "S6", Subsystem: "EPS"},
+	"P6000001": {Metric: "solar_array_4b_voltage", Unit: "volts", Help: "Solar array 4B voltage", Module: "P6", Subsystem: "EPS"},
+	"P6000002": {Metric: "solar_array_4b_current", Unit: "amperes", Help: "Solar array 4B current", Module: "P6", Subsystem: "EPS"},
+	"P6000004": {Metric: "solar_array_2b_voltage", Unit: "volts", Help: "Solar array 2B voltage", Module: "P6", Subsystem: "EPS"},
+	"P6000005": {Metric: "solar_array_2b_current", Unit: "amperes", Help: "Solar array 2B current", Module: "P6", Subsystem: "EPS"},
+	"P6000007": {Metric: "beta_gimbal_4b_angle", Unit: "degrees", Help: "Beta gimbal 4B angle", Module: "P6", Subsystem: "EPS"},
+	"P6000008": {Metric: "beta_gimbal_2b_angle", Unit: "degrees", Help: "Beta gimbal 2B angle", Module: "P6", Subsystem: "EPS"},
+	"S0000003": {Metric: "port_sarj_angle", Unit: "degrees", Help: "Port solar alpha rotary joint angle", Module: "S0", Subsystem: "EPS"},
+	"S0000004": {Metric: "starboard_sarj_angle", Unit: "degrees", Help: "Starboard solar alpha rotary joint angle", Module: "S0", Subsystem: "EPS"},
+
+	// GNC: Guidance, Navigation and Control
+	"USLAB000032": {Metric: "position_x", Unit: "kilometers", Help: "ISS position (J2000), X axis", Module: "Lab", Subsystem: "GNC"},
+	"USLAB000033": {Metric: "position_y", Unit: "kilometers", Help: "ISS position (J2000), Y axis", Module: "Lab", Subsystem: "GNC"},
+	"USLAB000034": {Metric: "position_z", Unit: "kilometers", Help: "ISS position (J2000), Z axis", Module: "Lab", Subsystem: "GNC"},
+	"USLAB000035": {Metric: "velocity_x", Unit: "meters_per_second", Help: "ISS velocity (J2000), X axis", Module: "Lab", Subsystem: "GNC"},
+	"USLAB000036": {Metric: "velocity_y", Unit: "meters_per_second", Help: "ISS velocity (J2000), Y axis", Module: "Lab", Subsystem: "GNC"},
+	"USLAB000037": {Metric: "velocity_z", Unit: "meters_per_second", Help: "ISS velocity (J2000), Z axis", Module: "Lab", Subsystem: "GNC"},
+	"USLAB000018": {Metric: "attitude_quaternion_0", Help: "ISS attitude quaternion (LVLH), scalar component", Module: "Lab", Subsystem: "GNC"},
+	"USLAB000019": {Metric: "attitude_quaternion_1", Help: "ISS attitude quaternion (LVLH), X component", Module: "Lab", Subsystem: "GNC"},
+	"USLAB000020": {Metric: "attitude_quaternion_2", Help: "ISS attitude quaternion (LVLH), Y component", Module: "Lab", Subsystem: "GNC"},
+	"USLAB000021": {Metric: "attitude_quaternion_3", Help: "ISS attitude quaternion (LVLH), Z component", Module: "Lab", Subsystem: "GNC"},
+
+	// Robotics: Mobile Servicing System
+	"CSASSRMS002": {Metric: "ssrms_shoulder_roll_angle", Unit: "degrees", Help: "SSRMS shoulder roll joint angle", Module: "SSRMS", Subsystem: "Robotics"},
+	"CSASSRMS003": {Metric: "ssrms_shoulder_yaw_angle", Unit: "degrees", Help: "SSRMS shoulder yaw joint angle", Module: "SSRMS", Subsystem: "Robotics"},
+	"CSASSRMS004": {Metric: "ssrms_shoulder_pitch_angle", Unit: "degrees", Help: "SSRMS shoulder pitch joint angle", Module: "SSRMS", Subsystem: "Robotics"},
+	"CSASSRMS005": {Metric: "ssrms_elbow_pitch_angle", Unit: "degrees", Help: "SSRMS elbow pitch joint angle", Module: "SSRMS", Subsystem: "Robotics"},
+	"CSASSRMS006": {Metric: "ssrms_wrist_pitch_angle", Unit: "degrees", Help: "SSRMS wrist pitch joint angle", Module: "SSRMS", Subsystem: "Robotics"},
+	"CSASSRMS007": {Metric: "ssrms_wrist_yaw_angle", Unit: "degrees", Help: "SSRMS wrist yaw joint angle", Module: "SSRMS", Subsystem: "Robotics"},
+	"CSASSRMS008": {Metric: "ssrms_wrist_roll_angle", Unit: "degrees", Help: "SSRMS wrist roll joint angle", Module: "SSRMS", Subsystem: "Robotics"},
+
+	// Russian Segment
+	"RUSSEG000001": {Metric: "russian_segment_station_mode", Help: "Russian segment station mode", Module: "Zvezda", Subsystem: "Russian Segment"},
+}
+
+// Lookup returns the catalog entry of a group. ok is false if the group isn't in the catalog.
+func Lookup(id string) (group GroupConfig, ok bool) {
+	if group, ok = catalog[id]; ok {
+		group.ID = id
+	}
+	return group, ok
+}
+
+// subsystems returns the subsystems in the catalog, in alphabetical order.
+func subsystems() []string {
+	set := make(map[string]struct{})
+	for _, entry := range catalog {
+		set[entry.Subsystem] = struct{}{}
+	}
+	return slices.Sorted(maps.Keys(set))
+}
+
+// subsystemGroups returns the IDs of all catalog groups of a subsystem, in alphabetical order.
+func subsystemGroups(subsystem string) []string {
+	var ids []string
+	for id, entry := range catalog {
+		if entry.Subsystem == subsystem {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// Uncatalogued returns the IDs of the ISS groups that aren't in the built-in catalog: these may be valid, but can't be
+// checked. For other servers, it returns nil.
+func (c Config) Uncatalogued() []string {
+	if !c.Server.iss() {
+		return nil
+	}
+	var ids []string
+	for _, group := range c.AllGroups() {
+		if _, ok := catalog[group.ID]; !ok {
+			ids = append(ids, group.ID)
+		}
+	}
+	return ids
+}
+
+// resolve completes the group's configuration from the catalog. If the group has no metric name, it uses the
+// catalog's metric name, unit and help string. The group's own value mappings and metadata take precedence over
+// the catalog's.
+func (g GroupConfig) resolve() GroupConfig {
+	entry, ok := catalog[g.ID]
+	if !ok {
+		return g
+	}
+	if g.Module == "" {
+		g.Module = entry.Module
+	}
+	if g.Subsystem == "" {
+		g.Subsystem = entry.Subsystem
+	}
+	if g.Metric != "" {
+		return g
+	}
+	g.Metric = entry.Metric
+	g.Unit = entry.Unit
+	if g.Help == "" {
+		g.Help = entry.Help
+	}
+	if g.Values == nil {
+		g.Values = entry.Values
+	}
+	if g.States == nil {
+		g.States = entry.States
+	}
+	return g
+}
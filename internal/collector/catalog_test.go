@@ -0,0 +1,73 @@
+package collector
+
+import (
+	"maps"
+	"slices"
+	"testing"
+)
+
+func TestCatalog(t *testing.T) {
+	var cfg Config
+	for _, id := range slices.Sorted(maps.Keys(catalog)) {
+		cfg.Groups = append(cfg.Groups, GroupConfig{ID: id})
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("catalog is invalid: %v", err)
+	}
+	for id, entry := range catalog {
+		if entry.Module == "" || entry.Subsystem == "" || entry.Help == "" {
+			t.Errorf("%s: missing metadata", id)
+		}
+	}
+	if got := len(subsystems()); got != 5 {
+		t.Errorf("got %d subsystems, want 5", got)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	if group, ok := Lookup("USLAB000058"); !ok || group.ID != "USLAB000058" || group.Metric != "cabin_pressure" {
+		t.Errorf("got %+v, %v", group, ok)
+	}
+	if _, ok := Lookup("UNKNOWN"); ok {
+		t.Error("expected UNKNOWN not to be in the catalog")
+	}
+}
+
+func TestConfig_Uncatalogued(t *testing.T) {
+	cfg := Config{Groups: []GroupConfig{{ID: "USLAB000058"}, {ID: "FOO"}}}
+	if got := cfg.Uncatalogued(); !slices.Equal(got, []string{"FOO"}) {
+		t.Errorf("got %v, want [FOO]", got)
+	}
+	if got := DefaultConfig.Uncatalogued(); len(got) != 0 {
+		t.Errorf("default configuration: got %v, want none", got)
+	}
+	cfg.Server.AdapterSet = "OTHER"
+	if got := cfg.Uncatalogued(); got != nil {
+		t.Errorf("other server: got %v, want nil", got)
+	}
+}
+
+func TestGroupConfig_resolve(t *testing.T) {
+	tests := []struct {
+		name  string
+		group GroupConfig
+		want  string
+	}{
+		{name: "catalog", group: GroupConfig{ID: "USLAB000058"}, want: "iss_cabin_pressure_mmhg"},
+		{name: "configured", group: GroupConfig{ID: "USLAB000058", Metric: "pressure"}, want: "iss_pressure"},
+		{name: "metadata", group: GroupConfig{ID: "USLAB000058", Module: "Node1"}, want: "iss_cabin_pressure_mmhg"},
+		{name: "unknown", group: GroupConfig{ID: "UNKNOWN"}, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.group.resolve()
+			var name string
+			if got.Metric != "" {
+				name = got.metricName()
+			}
+			if name != tt.want {
+				t.Errorf("resolve() got metric %q, want %q", name, tt.want)
+			}
+		})
+	}
+}
@@ -1,15 +1,19 @@
 package collector
 
 import (
+	"cmp"
 	"context"
-	"encoding/json"
-	"errors"
 	"fmt"
+	"github.com/clambin/iss-exporter/internal/orbit"
 	"github.com/clambin/iss-exporter/lightstreamer"
 	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 	"log/slog"
+	"maps"
 	"net/http"
+	"slices"
 	"strconv"
+	"sync"
 	"time"
 )
 
@@ -21,13 +25,75 @@ var (
 		nil,
 	)
 
-	telemetryMetric = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Namespace:   "iss",
-		Subsystem:   "telemetry",
-		Name:        "metric",
-		Help:        "lightstreamer telemetry",
-		ConstLabels: nil,
-	}, []string{"group"})
+	longitudeMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("iss", "", "longitude_degrees"),
+		"current ISS longitude",
+		nil,
+		nil,
+	)
+
+	latitudeMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("iss", "", "latitude_degrees"),
+		"current ISS latitude",
+		nil,
+		nil,
+	)
+
+	locationStaleMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("iss", "", "location_stale"),
+		"1 if the ISS location is unknown or out of date",
+		nil,
+		nil,
+	)
+
+	signalInfoMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("iss", "signal", "info"),
+		"metadata of the telemetry signals. join on the group label to add it to telemetry metrics",
+		[]string{"group", "metric", "module", "subsystem", "description"},
+		nil,
+	)
+
+	telemetryMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("iss", "telemetry", "metric"),
+		"lightstreamer telemetry",
+		[]string{"group"},
+		nil,
+	)
+
+	statusMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("iss", "telemetry", "status_class"),
+		"status class of the telemetry signal, as reported by ISSLIVE",
+		[]string{"group"},
+		nil,
+	)
+
+	timestampMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("iss", "telemetry", "timestamp_seconds"),
+		"time of the last telemetry reading, as reported by ISSLIVE",
+		[]string{"group"},
+		nil,
+	)
+
+	lastUpdateMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("iss", "telemetry", "last_update_timestamp_seconds"),
+		"time the last telemetry update was received",
+		[]string{"group"},
+		nil,
+	)
+
+	stateMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("iss", "", "state"),
+		"state of an enumerated telemetry signal: 1 for the current state, 0 for all others",
+		[]string{"group", "state"},
+		nil,
+	)
+
+	streamDelayMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("iss", "lightstreamer", "stream_delay_seconds"),
+		"how far the stream runs behind the lightstreamer server, as of the last SYNC message",
+		nil,
+		nil,
+	)
 
 	connectionMetric = prometheus.NewDesc(
 		prometheus.BuildFQName("iss", "lightstreamer", "connection_count"),
@@ -35,102 +101,633 @@ var (
 		nil,
 		nil,
 	)
+
+	cachedItemsMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("iss", "lightstreamer", "cached_items"),
+		"number of items of which the subscriptions keep the last values, to decode updates",
+		nil,
+		nil,
+	)
+
+	cacheEvictionsMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("iss", "lightstreamer", "cache_evictions_total"),
+		"number of items evicted from the subscriptions' caches, as limited by the server's max_items",
+		nil,
+		nil,
+	)
 )
 
+// A Subscriber is a Lightstreamer session that the Collector subscribes through. lightstreamer.ClientSession
+// implements Subscriber.
+type Subscriber interface {
+	// ConnectWithSession establishes a new session, replacing the current one, if any.
+	ConnectWithSession(ctx context.Context, timeout time.Duration) error
+	// Subscribe subscribes to a group. f is called for each update.
+	Subscribe(ctx context.Context, adapter string, group string, schema []string, maxFrequency float64, f func(item int, values lightstreamer.Values)) error
+	// State returns the current state of the session.
+	State() lightstreamer.SessionState
+	// Done returns a channel that's closed when the current session ends.
+	Done() <-chan struct{}
+}
+
+// locationStaleAfter is the age after which the ISS location is reported as stale. The position groups are
+// subscribed at 0.1 Hz, so this allows for a few missed updates.
+const locationStaleAfter = time.Minute
+
 type Collector struct {
-	ClientSession *lightstreamer.ClientSession
-	Logger        *slog.Logger
+	// Subscriber is the Lightstreamer session to subscribe through.
+	Subscriber Subscriber
+	Logger     *slog.Logger
+	// LocationLabels also exports the location as labels of the iss_location metric, for backward compatibility.
+	LocationLabels bool
+	// StaleAfter removes a signal's metrics if it hasn't been updated for the specified duration, e.g. during loss of
+	// signal. Zero keeps the last value forever.
+	StaleAfter time.Duration
+	// Timestamps exports the value, status and state of a signal with the time of its reading, as reported by
+	// ISSLIVE, rather than the time of the scrape. Note that Prometheus doesn't mark series with explicit timestamps
+	// as stale when they disappear.
+	Timestamps bool
+	// lock guards signals and alerts, which are replaced by Reload.
+	lock       sync.RWMutex
+	signals    []*signal
+	alerts     *alerter
+	position   *position
+	aos        *aos
+	reconnects prometheus.Counter
+	latency    prometheus.Histogram
+	server     ServerConfig
+	tle        *tleSource
+	crew       *crewSource
+	history    *history
+	docking    *docking
+	listeners  listeners[Update]
+	events     listeners[Event]
+	// subscribeLock serializes (re)subscribing, and guards subscribed: the groups subscribed in the current session.
+	subscribeLock sync.Mutex
+	subscribed    map[string]bool
 }
 
-func NewCollector(ctx context.Context, logger *slog.Logger) (c *Collector, err error) {
-	c = &Collector{
-		Logger: logger,
+// NewCollector subscribes to the telemetry groups in cfg through subscriber, and returns a Collector that exports them.
+// subscriber is typically a lightstreamer.ClientSession, created with cfg.Server.Options().
+//
+// If the Lightstreamer session is lost, the Collector establishes a new session and subscribes again, until ctx is
+// canceled.
+func NewCollector(ctx context.Context, cfg Config, subscriber Subscriber, logger *slog.Logger) (c *Collector, err error) {
+	c = newCollector(cfg, logger)
+	c.Subscriber = subscriber
+	if err = c.connect(ctx); err != nil {
+		return c, err
 	}
-	c.ClientSession, err = lightStreamerClientSession(ctx, logger)
-	return c, err
+	go c.supervise(ctx, reconnectInterval)
+	return c, nil
 }
 
-func (c Collector) Describe(ch chan<- *prometheus.Desc) {
-	ch <- locationMetric
+// EnableOrbit exports orbital metrics (altitude, velocity and, if observer is not nil, the time to the next pass over
+// the observer), propagated from the ISS TLE, fetched from tleURL. The TLE is refreshed periodically until ctx is
+// canceled. Call EnableOrbit before registering the Collector.
+func (c *Collector) EnableOrbit(ctx context.Context, tleURL string, observer *orbit.Location) {
+	c.tle = &tleSource{
+		url:        tleURL,
+		httpClient: http.DefaultClient,
+		logger:     c.Logger,
+		observer:   observer,
+	}
+	go c.tle.run(ctx, tleRefresh, tleRetry)
+}
+
+// EnableCrew exports the people on board the ISS, fetched from crewURL (an open-notify astros.json endpoint).
+// The crew is refreshed periodically until ctx is canceled. Call EnableCrew before registering the Collector.
+func (c *Collector) EnableCrew(ctx context.Context, crewURL string) {
+	c.crew = &crewSource{
+		url:        crewURL,
+		httpClient: http.DefaultClient,
+		logger:     c.Logger,
+	}
+	go c.crew.run(ctx, crewRefresh, crewRetry)
+}
+
+// newCollector returns a Collector for the telemetry groups in cfg, without subscribing to them.
+func newCollector(cfg Config, logger *slog.Logger) *Collector {
+	c := &Collector{
+		Logger:   logger,
+		signals:  newSignals(cfg),
+		server:   cfg.Server,
+		position: new(position),
+		aos:      newAOS(),
+		docking:  newDocking(),
+		latency: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: "iss",
+			Subsystem: "telemetry",
+			Name:      "latency_seconds",
+			Help:      "time between a telemetry reading on the ground (its TimeStamp) and its receipt by the exporter",
+			Buckets:   []float64{0.5, 1, 2, 5, 10, 30, 60, 300},
+		}),
+		reconnects: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: prometheus.BuildFQName("iss", "lightstreamer", "reconnects_total"),
+			Help: "number of times the lightstreamer session was re-established",
+		}),
+	}
+	c.alerts = newAlerter(cfg, logger)
+	return c
+}
+
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- longitudeMetric
+	ch <- latitudeMetric
+	ch <- locationStaleMetric
+	if c.LocationLabels {
+		ch <- locationMetric
+	}
 	ch <- connectionMetric
-	telemetryMetric.Describe(ch)
+	ch <- cachedItemsMetric
+	ch <- cacheEvictionsMetric
+	ch <- signalInfoMetric
+	ch <- streamDelayMetric
+	c.latency.Describe(ch)
+	c.reconnects.Describe(ch)
+	if c.tle != nil {
+		c.tle.Describe(ch)
+	}
+	if c.crew != nil {
+		c.crew.Describe(ch)
+	}
+	c.aos.Describe(ch)
+	c.docking.Describe(ch)
+	ch <- telemetryMetric
+	ch <- statusMetric
+	ch <- timestampMetric
+	ch <- lastUpdateMetric
+	ch <- stateMetric
+	ch <- solarArrayPowerMetric
+	ch <- solarPowerMetric
+	ch <- attitudeMetric
+	for _, s := range c.currentSignals() {
+		if s.desc != telemetryMetric {
+			ch <- s.desc
+		}
+		for _, desc := range s.aggregates {
+			ch <- desc
+		}
+	}
 }
 
-func (c Collector) Collect(ch chan<- prometheus.Metric) {
-	telemetryMetric.Collect(ch)
-	ch <- prometheus.MustNewConstMetric(connectionMetric, prometheus.GaugeValue, float64(c.ClientSession.Connections.Load()))
-	longitude, latitude, err := getLocation()
-	if err != nil {
-		c.Logger.Error("failed to get location", "err", err)
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.collectSignals(ch, time.Now())
+	c.collectPower(ch, time.Now())
+	c.collectAttitude(ch, time.Now())
+	c.reconnects.Collect(ch)
+	if c.tle != nil {
+		c.tle.Collect(ch)
+	}
+	if c.crew != nil {
+		c.crew.Collect(ch)
+	}
+	state := c.Subscriber.State()
+	ch <- prometheus.MustNewConstMetric(connectionMetric, prometheus.GaugeValue, float64(state.Connections))
+	ch <- prometheus.MustNewConstMetric(cachedItemsMetric, prometheus.GaugeValue, float64(state.CachedItems))
+	ch <- prometheus.MustNewConstMetric(cacheEvictionsMetric, prometheus.CounterValue, float64(state.Evictions))
+	ch <- prometheus.MustNewConstMetric(streamDelayMetric, prometheus.GaugeValue, -state.TimeDifference.Seconds())
+	c.latency.Collect(ch)
+	c.docking.Collect(ch)
+	// the signal status and the position are only known for the ISS
+	if !c.server.iss() {
 		return
 	}
-	//c.Logger.Debug("location found", "longitude", longitude, "latitude", latitude)
-	ch <- prometheus.MustNewConstMetric(locationMetric, prometheus.GaugeValue, 1.0, longitude, latitude)
+	c.aos.Collect(ch)
+	var stale float64
+	if c.position.stale(time.Now(), locationStaleAfter) {
+		stale = 1
+	}
+	ch <- prometheus.MustNewConstMetric(locationStaleMetric, prometheus.GaugeValue, stale)
+	longitude, latitude, ok := c.position.location()
+	if !ok {
+		c.Logger.Debug("location not yet known")
+		return
+	}
+	if c.LocationLabels {
+		ch <- prometheus.MustNewConstMetric(locationMetric, prometheus.GaugeValue, 1.0,
+			strconv.FormatFloat(longitude, 'f', 4, 64),
+			strconv.FormatFloat(latitude, 'f', 4, 64),
+		)
+	}
+	ch <- prometheus.MustNewConstMetric(longitudeMetric, prometheus.GaugeValue, longitude)
+	ch <- prometheus.MustNewConstMetric(latitudeMetric, prometheus.GaugeValue, latitude)
 }
 
-func getLocation() (string, string, error) {
-	type ISSUpdate struct {
-		IssPosition struct {
-			Latitude  string `json:"latitude"`
-			Longitude string `json:"longitude"`
-		} `json:"iss_position"`
-		Message   string `json:"message"`
-		Timestamp int    `json:"timestamp"`
+// collectSignals collects the metadata of all signals, and the metrics of all signals that have been updated.
+// If StaleAfter is set, the metrics of signals that haven't been updated since then are skipped, except for their
+// last update time.
+func (c *Collector) collectSignals(ch chan<- prometheus.Metric, now time.Time) {
+	for _, s := range c.currentSignals() {
+		ch <- s.info()
+		r := s.last()
+		if r.received.IsZero() {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(lastUpdateMetric, prometheus.GaugeValue, float64(r.received.UnixMilli())/1000, s.ID)
+		if c.StaleAfter > 0 && now.Sub(r.received) > c.StaleAfter {
+			continue
+		}
+		// withTimestamp sets the time of the reading as the timestamp of readings, if Timestamps is set.
+		withTimestamp := func(m prometheus.Metric) prometheus.Metric {
+			if c.Timestamps && !r.timestamp.IsZero() {
+				return prometheus.NewMetricWithTimestamp(r.timestamp, m)
+			}
+			return m
+		}
+		if r.hasValue {
+			ch <- withTimestamp(prometheus.MustNewConstMetric(s.desc, prometheus.GaugeValue, r.value, s.labels...))
+		}
+		if r.hasStatus {
+			ch <- withTimestamp(prometheus.MustNewConstMetric(statusMetric, prometheus.GaugeValue, r.status, s.ID))
+		}
+		if !r.timestamp.IsZero() {
+			ch <- prometheus.MustNewConstMetric(timestampMetric, prometheus.GaugeValue, float64(r.timestamp.UnixMilli())/1000, s.ID)
+		}
+		for _, state := range s.stateNames() {
+			var value float64
+			if state == r.state {
+				value = 1
+			}
+			ch <- withTimestamp(prometheus.MustNewConstMetric(stateMetric, prometheus.GaugeValue, value, s.ID, state))
+		}
+		s.collectAggregates(ch, now)
 	}
-	resp, err := http.Get("http://api.open-notify.org/iss-now.json")
-	if err != nil {
-		return "", "", err
+}
+
+// A signal is a configured telemetry group, and its last reading.
+type signal struct {
+	GroupConfig
+	// desc is the metric that exports the signal's value, and labels are its label values.
+	desc       *prometheus.Desc
+	labels     []string
+	aggregates map[string]*prometheus.Desc
+	window     window
+	lock       sync.RWMutex
+	reading    reading
+}
+
+// A reading is the state of a signal, as of its last update.
+type reading struct {
+	// received is the time the last update was received, or zero if no update has been received yet.
+	received  time.Time
+	value     float64
+	hasValue  bool
+	status    float64
+	hasStatus bool
+	// timestamp is the time of the reading, as reported by ISSLIVE, or zero if unknown.
+	timestamp time.Time
+	// state is the current state of an enumerated signal, or empty if the value doesn't map to a state.
+	state string
+}
+
+// newSignals creates a signal for each configured group: groups with a metric name, either configured or from the
+// catalog, get their own metric. All others are exported by the generic telemetry metric.
+func newSignals(cfg Config) []*signal {
+	groups := cfg.AllGroups()
+	signals := make([]*signal, len(groups))
+	for i, group := range groups {
+		group = group.resolve()
+		signals[i] = &signal{
+			GroupConfig: group,
+			desc:        telemetryMetric,
+			labels:      []string{group.ID},
+			aggregates:  newAggregates(group),
+		}
+		signals[i].window.period, _ = group.aggregateWindow() // validated by Config.Validate
+		if group.Metric != "" {
+			signals[i].desc = prometheus.NewDesc(group.metricName(), cmp.Or(group.Help, "lightstreamer telemetry "+group.ID), nil, nil)
+			signals[i].labels = nil
+		}
+	}
+	return signals
+}
+
+// schema lists the fields of an ISSLIVE item. Value is the reading, Status.Class indicates its quality
+// and TimeStamp is the time of the reading, in hours since the start of the year (UTC).
+var schema = []string{"Value", "Status.Class", "TimeStamp"}
+
+// value returns the numeric value of an update. Non-numeric values are translated by the group's value mapping.
+func (s *signal) value(values lightstreamer.Values) (float64, bool) {
+	if value, ok := values.Floats(schema)["Value"]; ok {
+		return value, true
 	}
-	defer func() { _ = resp.Body.Close() }()
-	if resp.StatusCode != http.StatusOK {
-		return "", "", errors.New(resp.Status)
+	if len(values) == 0 || values[0] == nil {
+		return 0, false
 	}
-	var update ISSUpdate
-	err = json.NewDecoder(resp.Body).Decode(&update)
-	return update.IssPosition.Longitude, update.IssPosition.Latitude, err
+	value, ok := s.Values[string(*values[0])]
+	return value, ok
 }
 
-var groups = []string{
-	"NODE3000005",   // Urine Tank Qty
-	"NODE3000008",   // Waste Water Tank Qty
-	"NODE3000009",   // Clean Water Tank Qty
-	"NODE3000011",   // O2 production rate
-	"USLAB000058",   // cabin pressure
-	"USLAB000059",   // cabin temperature
-	"AIRLOCK000049", // crewlock pressure
-	"AIRLOCK000054", // Airlock Pressure
-	"USLAB000053",   // Lab ppO2
+// info returns the signal's iss_signal_info metric.
+func (s *signal) info() prometheus.Metric {
+	var metric string
+	if s.Metric != "" {
+		metric = s.metricName()
+	}
+	return prometheus.MustNewConstMetric(signalInfoMetric, prometheus.GaugeValue, 1,
+		s.ID, metric, s.Module, s.Subsystem, s.Help,
+	)
 }
 
-var schema = []string{"Value"}
+// stateNames returns the states of an enumerated signal.
+func (s *signal) stateNames() []string {
+	states := make(map[string]struct{}, len(s.States))
+	for _, state := range s.States {
+		states[state] = struct{}{}
+	}
+	return slices.Sorted(maps.Keys(states))
+}
 
-func lightStreamerClientSession(ctx context.Context, logger *slog.Logger) (*lightstreamer.ClientSession, error) {
-	session := lightstreamer.NewClientSession(
-		lightstreamer.WithLogger(logger),
-		lightstreamer.WithAdapterSet("ISSLIVE"),
-	)
+// update processes an update: it records the update, and the signal's value and state. It returns the value,
+// or false if the update has no numeric value.
+func (s *signal) update(values lightstreamer.Values, now time.Time) (float64, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.record(values, now)
+	s.setState(values)
+	value, ok := s.value(values)
+	if ok {
+		s.reading.value, s.reading.hasValue = value, true
+		if len(s.aggregates) > 0 {
+			s.window.add(value, now)
+		}
+	}
+	return value, ok
+}
+
+// collectAggregates collects the signal's aggregations over the last complete window.
+func (s *signal) collectAggregates(ch chan<- prometheus.Metric, now time.Time) {
+	if len(s.aggregates) == 0 {
+		return
+	}
+	aggregates, ok := s.window.aggregates(now)
+	if !ok {
+		return
+	}
+	for aggregation, desc := range s.aggregates {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, aggregates[aggregation])
+	}
+}
+
+// setState records the signal's current state. If the value doesn't map to a state, the signal has no current
+// state.
+func (s *signal) setState(values lightstreamer.Values) {
+	if len(s.States) == 0 || len(values) == 0 || values[0] == nil {
+		return
+	}
+	s.reading.state = s.States[string(*values[0])]
+}
+
+// record records the status and the time of an update, and the time it was received. Fields missing from the update
+// are ignored.
+func (s *signal) record(values lightstreamer.Values, now time.Time) {
+	s.reading.received = now
+	fields := values.Floats(schema)
+	if status, ok := fields["Status.Class"]; ok {
+		s.reading.status, s.reading.hasStatus = status, true
+	}
+	if timestamp, ok := fields["TimeStamp"]; ok {
+		s.reading.timestamp = fromTimeStamp(timestamp, now)
+	}
+}
+
+// last returns the signal's last reading.
+func (s *signal) last() reading {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.reading
+}
+
+// lastUpdated returns the time the signal was last updated, or the zero time if it hasn't been updated yet.
+func (s *signal) lastUpdated() time.Time {
+	return s.last().received
+}
+
+// LastUpdate returns the time the most recent update was received for any of the telemetry groups, or the zero time
+// if none has been received yet.
+func (c *Collector) LastUpdate() time.Time {
+	var last time.Time
+	for _, s := range c.currentSignals() {
+		if updated := s.lastUpdated(); updated.After(last) {
+			last = updated
+		}
+	}
+	return last
+}
+
+// LastUpdates returns the time each telemetry group was last updated, by group ID. Groups that haven't been updated
+// yet have the zero time.
+func (c *Collector) LastUpdates() map[string]time.Time {
+	signals := c.currentSignals()
+	updates := make(map[string]time.Time, len(signals))
+	for _, s := range signals {
+		updates[s.ID] = s.lastUpdated()
+	}
+	return updates
+}
+
+// Reconnects returns the number of times the Collector established a new session, after losing the previous one.
+func (c *Collector) Reconnects() int {
+	var m dto.Metric
+	_ = c.reconnects.Write(&m)
+	return int(m.GetCounter().GetValue())
+}
+
+// Pending returns the IDs of the telemetry groups that haven't received an update yet.
+func (c *Collector) Pending() []string {
+	var pending []string
+	for _, s := range c.currentSignals() {
+		if s.lastUpdated().IsZero() {
+			pending = append(pending, s.ID)
+		}
+	}
+	return pending
+}
+
+// WaitForUpdates waits until all telemetry groups have received an update, timeout elapses or ctx is canceled,
+// whichever comes first. It returns the groups that haven't received an update yet.
+func (c *Collector) WaitForUpdates(ctx context.Context, timeout time.Duration) []string {
+	return c.waitForUpdates(ctx, timeout, time.Second)
+}
+
+func (c *Collector) waitForUpdates(ctx context.Context, timeout time.Duration, interval time.Duration) []string {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		pending := c.Pending()
+		if len(pending) == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return pending
+		case <-ticker.C:
+		}
+	}
+}
+
+// observeLatency records the delivery latency of an update: the time between the reading on the ground and its
+// receipt at now. This includes the stream delay (iss_lightstreamer_stream_delay_seconds): subtracting it leaves the
+// latency between the ground and the lightstreamer server.
+func (c *Collector) observeLatency(values lightstreamer.Values, now time.Time) {
+	if timestamp, ok := values.Floats(schema)["TimeStamp"]; ok {
+		c.latency.Observe(max(0, now.Sub(fromTimeStamp(timestamp, now)).Seconds()))
+	}
+}
+
+// updateTime returns the time of an update, or now if the update has no TimeStamp.
+func updateTime(values lightstreamer.Values, now time.Time) time.Time {
+	if timestamp, ok := values.Floats(schema)["TimeStamp"]; ok {
+		return fromTimeStamp(timestamp, now)
+	}
+	return now
+}
+
+// fromTimeStamp converts an ISSLIVE TimeStamp (hours since the start of the year, UTC) to a time. now determines the
+// year: a TimeStamp that would be in the future (e.g. a reading from late December, received in January) belongs to
+// the previous year.
+func fromTimeStamp(hours float64, now time.Time) time.Time {
+	now = now.UTC()
+	year := now.Year()
+	t := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(hours * float64(time.Hour)))
+	if t.After(now.Add(24 * time.Hour)) {
+		t = time.Date(year-1, time.January, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(hours * float64(time.Hour)))
+	}
+	return t
+}
+
+// subscribe subscribes to a signal's group. Updates are processed by the signal currently configured for the group:
+// after a Reload, updates for groups that are no longer configured are ignored. Call subscribe with subscribeLock held.
+func (c *Collector) subscribe(ctx context.Context, s *signal) error {
+	logger := c.Logger
+	remap := c.server.remap()
+	id := s.ID
+	err := c.Subscriber.Subscribe(ctx, c.server.dataAdapter(), id, c.server.schema(), max(0, s.MaxFrequency), func(_ int, values lightstreamer.Values) {
+		s, alerts, ok := c.lookup(id)
+		if !ok {
+			return
+		}
+		values = remap(values)
+		now := time.Now()
+		c.observeLatency(values, now)
+		value, ok := s.update(values, now)
+		if !ok {
+			if len(s.States) == 0 {
+				logger.Warn("no numeric value in subscription. ignoring", "group", id, "values", values)
+			}
+			return
+		}
+		alerts.evaluate(s.GroupConfig, value, now)
+		if s.Port != "" {
+			c.docking.update(s.Port, value != 0)
+		}
+		if u, ok := s.newUpdate(s.last()); ok {
+			c.listeners.notify(u)
+		}
+		logger.Debug("update processed", "group", id, "value", value)
+	})
+	if err != nil {
+		c.emitSubscriptionError(id, err)
+		return fmt.Errorf("subscribe(%s): %w", id, err)
+	}
+	c.subscribed[id] = true
+	logger.Info("subscribed successfully", "group", id)
+	return nil
+}
+
+// currentSignals returns the configured signals.
+func (c *Collector) currentSignals() []*signal {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.signals
+}
+
+// lookup returns the signal of a group, and the alerter, as currently configured. It returns false if the group is
+// no longer configured.
+func (c *Collector) lookup(id string) (*signal, *alerter, bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	for _, s := range c.signals {
+		if s.ID == id {
+			return s, c.alerts, true
+		}
+	}
+	return nil, nil, false
+}
+
+// Group returns the configuration of a telemetry group, including the defaults from the catalog. ok is false if the
+// group isn't configured.
+func (c *Collector) Group(id string) (GroupConfig, bool) {
+	s, _, ok := c.lookup(id)
+	if !ok {
+		return GroupConfig{}, false
+	}
+	return s.GroupConfig, true
+}
+
+// connect establishes a Lightstreamer session and subscribes to the configured signals, the ISS position and the
+// signal status.
+func (c *Collector) connect(ctx context.Context) error {
+	c.subscribeLock.Lock()
+	defer c.subscribeLock.Unlock()
+	logger := c.Logger
+	session := c.Subscriber
 	if err := session.ConnectWithSession(ctx, 10*time.Second); err != nil {
-		return nil, err
+		return err
 	}
 
-	for _, group := range groups {
+	c.subscribed = make(map[string]bool)
+	for _, s := range c.currentSignals() {
+		if err := c.subscribe(ctx, s); err != nil {
+			return err
+		}
+	}
+	if !c.server.iss() {
+		return nil
+	}
+
+	for axis, group := range positionGroups {
 		err := session.Subscribe(ctx, "DEFAULT", group, schema, 0.1, func(_ int, values lightstreamer.Values) {
-			if values[0] == nil {
-				logger.Warn("empty value in subscription. ignoring")
+			value, ok := values.Floats(schema)["Value"]
+			if !ok {
+				logger.Warn("no numeric value in position update. ignoring", "group", group, "values", values)
 				return
 			}
-			value, err := strconv.ParseFloat(string(*values[0]), 64)
-			if err != nil {
-				logger.Error("failed to parse value", "group", group, "value", *values[0], "err", err)
-				return
-			}
-			telemetryMetric.WithLabelValues(group).Set(value)
-			logger.Debug("update processed", "group", group, "value", value)
+			c.position.update(axis, value, updateTime(values, time.Now()))
 		})
 		if err != nil {
-			return nil, fmt.Errorf("subscribe(%s): %w", group, err)
+			c.emitSubscriptionError(group, err)
+			return fmt.Errorf("subscribe(%s): %w", group, err)
+		}
+	}
+	logger.Info("subscribed to position")
+
+	err := session.Subscribe(ctx, "DEFAULT", aosGroup, schema, 0, func(_ int, values lightstreamer.Values) {
+		statusClass, ok := values.Floats(schema)["Status.Class"]
+		if !ok {
+			logger.Warn("no status in signal update. ignoring", "group", aosGroup, "values", values)
+			return
+		}
+		changed, initial := c.aos.update(statusClass)
+		if !changed {
+			return
 		}
-		logger.Info("subscribed successfully", "group", group)
+		acquired := statusClass == aosStatusClass
+		logger.Info("signal status changed", "acquired", acquired)
+		switch {
+		case initial:
+		case acquired:
+			c.emit(EventSignalAcquired, "ISS signal acquired (AOS)")
+		default:
+			c.emit(EventSignalLost, "ISS signal lost (LOS)")
+		}
+	})
+	if err != nil {
+		c.emitSubscriptionError(aosGroup, err)
+		return fmt.Errorf("subscribe(%s): %w", aosGroup, err)
 	}
-	return session, nil
+	return nil
 }
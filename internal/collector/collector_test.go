@@ -0,0 +1,284 @@
+package collector
+
+import (
+	"github.com/clambin/iss-exporter/lightstreamer"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSignal_value(t *testing.T) {
+	s := signal{GroupConfig: GroupConfig{ID: "A", Values: map[string]float64{"OPEN": 1, "CLOSED": 0}}}
+	tests := []struct {
+		name   string
+		values lightstreamer.Values
+		want   float64
+		wantOK bool
+	}{
+		{name: "numeric", values: lightstreamer.Values{valuePtr("12.5")}, want: 12.5, wantOK: true},
+		{name: "mapped", values: lightstreamer.Values{valuePtr("OPEN")}, want: 1, wantOK: true},
+		{name: "unmapped", values: lightstreamer.Values{valuePtr("UNKNOWN")}},
+		{name: "nil", values: lightstreamer.Values{nil}},
+		{name: "empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := s.value(tt.values)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("value() got (%v, %v), want (%v, %v)", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestSignal_record(t *testing.T) {
+	now := time.Date(2025, time.March, 1, 12, 0, 0, 0, time.UTC)
+	var s signal
+	if !s.lastUpdated().IsZero() {
+		t.Error("lastUpdated() should be zero before the first update")
+	}
+	s.record(lightstreamer.Values{valuePtr("12.5"), valuePtr("24"), valuePtr("1404.5")}, now)
+	if r := s.last(); !r.hasStatus || r.status != 24 {
+		t.Errorf("status got %v, want 24", r.status)
+	}
+	want := time.Date(2025, time.February, 28, 12, 30, 0, 0, time.UTC)
+	if got := s.last().timestamp; !got.Equal(want) {
+		t.Errorf("timestamp got %v, want %v", got, want)
+	}
+	if got := s.lastUpdated(); !got.Equal(now) {
+		t.Errorf("lastUpdated() got %v, want %v", got, now)
+	}
+}
+
+func TestCollector_LastUpdate(t *testing.T) {
+	c := newCollector(Config{Groups: []GroupConfig{{ID: "A"}, {ID: "B"}}}, slog.New(slog.DiscardHandler))
+	if !c.LastUpdate().IsZero() {
+		t.Error("LastUpdate() should be zero before the first update")
+	}
+	now := time.Date(2025, time.March, 1, 12, 0, 0, 0, time.UTC)
+	c.signals[1].record(lightstreamer.Values{valuePtr("1")}, now)
+	c.signals[0].record(lightstreamer.Values{valuePtr("1")}, now.Add(-time.Minute))
+	if got := c.LastUpdate(); !got.Equal(now) {
+		t.Errorf("LastUpdate() got %v, want %v", got, now)
+	}
+	if got := c.LastUpdates(); len(got) != 2 || !got["A"].Equal(now.Add(-time.Minute)) || !got["B"].Equal(now) {
+		t.Errorf("LastUpdates() got %v", got)
+	}
+}
+
+func TestCollector_waitForUpdates(t *testing.T) {
+	c := newCollector(Config{Groups: []GroupConfig{{ID: "A"}, {ID: "B"}}}, slog.New(slog.DiscardHandler))
+	if got := c.Pending(); !slices.Equal(got, []string{"A", "B"}) {
+		t.Errorf("Pending() got %v, want [A B]", got)
+	}
+
+	c.signals[0].record(lightstreamer.Values{valuePtr("1")}, time.Now())
+	if got := c.waitForUpdates(t.Context(), 50*time.Millisecond, 10*time.Millisecond); !slices.Equal(got, []string{"B"}) {
+		t.Errorf("waitForUpdates() got %v, want [B]", got)
+	}
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		s := c.signals[1]
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		s.record(lightstreamer.Values{valuePtr("1")}, time.Now())
+	}()
+	if got := c.waitForUpdates(t.Context(), time.Minute, 10*time.Millisecond); len(got) != 0 {
+		t.Errorf("waitForUpdates() got %v, want none", got)
+	}
+}
+
+func TestSignal_update_States(t *testing.T) {
+	c := newCollector(Config{Groups: []GroupConfig{{ID: "A", States: map[string]string{"0": "closed", "1": "open"}}}}, slog.New(slog.DiscardHandler))
+	s := c.signals[0]
+
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "1", want: "open"},
+		{value: "0", want: "closed"},
+		{value: "2", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			s.update(lightstreamer.Values{valuePtr(tt.value), nil, nil}, time.Now())
+			if got := s.last().state; got != tt.want {
+				t.Errorf("got state %q, want %q", got, tt.want)
+			}
+		})
+	}
+	if got := s.stateNames(); !slices.Equal(got, []string{"closed", "open"}) {
+		t.Errorf("got states %v", got)
+	}
+}
+
+func TestSignal_info(t *testing.T) {
+	c := newCollector(Config{Groups: []GroupConfig{{ID: "USLAB000059"}, {ID: "A"}}}, slog.New(slog.DiscardHandler))
+	tests := []struct {
+		signal *signal
+		want   map[string]string
+	}{
+		{
+			signal: c.signals[0],
+			want: map[string]string{
+				"group":       "USLAB000059",
+				"metric":      "iss_cabin_temperature_celsius",
+				"module":      "Lab",
+				"subsystem":   "ECLSS",
+				"description": "Cabin temperature",
+			},
+		},
+		{
+			signal: c.signals[1],
+			want:   map[string]string{"group": "A", "metric": "", "module": "", "subsystem": "", "description": ""},
+		},
+	}
+	for _, tt := range tests {
+		var m dto.Metric
+		if err := tt.signal.info().Write(&m); err != nil {
+			t.Fatal(err)
+		}
+		for _, label := range m.GetLabel() {
+			if want := tt.want[label.GetName()]; label.GetValue() != want {
+				t.Errorf("%s: label %s got %q, want %q", tt.signal.ID, label.GetName(), label.GetValue(), want)
+			}
+		}
+	}
+}
+
+func TestCollector_collectSignals(t *testing.T) {
+	c := newCollector(Config{Groups: []GroupConfig{{ID: "A"}, {ID: "B"}, {ID: "C"}}}, slog.New(slog.DiscardHandler))
+	now := time.Now()
+	values := lightstreamer.Values{valuePtr("1"), valuePtr("24"), valuePtr("0")}
+	c.signals[0].update(values, now)
+	c.signals[1].update(values, now.Add(-time.Hour))
+
+	tests := []struct {
+		name       string
+		staleAfter time.Duration
+		want       int
+	}{
+		// A: info + 4 metrics. B: info + last update. C: info
+		{name: "expiry", staleAfter: time.Minute, want: 8},
+		// A & B: info + 4 metrics. C: info
+		{name: "no expiry", staleAfter: 0, want: 11},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c.StaleAfter = tt.staleAfter
+			ch := make(chan prometheus.Metric)
+			go func() {
+				c.collectSignals(ch, now)
+				close(ch)
+			}()
+			var got int
+			for range ch {
+				got++
+			}
+			if got != tt.want {
+				t.Errorf("collectSignals() got %d metrics, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCollector_observeLatency(t *testing.T) {
+	c := newCollector(DefaultConfig, slog.New(slog.DiscardHandler))
+	now := time.Date(2025, time.March, 1, 12, 0, 0, 0, time.UTC)
+	for _, timestamp := range []string{
+		"1427.99", // 36 seconds ago
+		"1428.01", // in the future: clock skew
+	} {
+		c.observeLatency(lightstreamer.Values{valuePtr("1"), valuePtr("24"), valuePtr(timestamp)}, now)
+	}
+	c.observeLatency(lightstreamer.Values{valuePtr("1"), valuePtr("24"), nil}, now)
+
+	var m dto.Metric
+	if err := c.latency.Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.GetHistogram().GetSampleCount(); got != 2 {
+		t.Errorf("got %d samples, want 2", got)
+	}
+	if got := m.GetHistogram().GetSampleSum(); got < 35.9 || got > 36.1 {
+		t.Errorf("got sum %f, want 36", got)
+	}
+}
+
+func TestCollector_collectSignals_Timestamps(t *testing.T) {
+	c := newCollector(Config{Groups: []GroupConfig{{ID: "A", States: map[string]string{"1": "open"}}}}, slog.New(slog.DiscardHandler))
+	now := time.Date(2025, time.March, 1, 12, 0, 0, 0, time.UTC)
+	c.signals[0].update(lightstreamer.Values{valuePtr("1"), valuePtr("24"), valuePtr("1427.5")}, now)
+	reading := time.Date(2025, time.March, 1, 11, 30, 0, 0, time.UTC)
+
+	for _, timestamps := range []bool{false, true} {
+		c.Timestamps = timestamps
+		ch := make(chan prometheus.Metric)
+		go func() {
+			c.collectSignals(ch, now)
+			close(ch)
+		}()
+		var withTimestamp int
+		for metric := range ch {
+			var m dto.Metric
+			if err := metric.Write(&m); err != nil {
+				t.Fatal(err)
+			}
+			if m.TimestampMs != nil {
+				if got := time.UnixMilli(m.GetTimestampMs()); !got.Equal(reading) {
+					t.Errorf("got timestamp %v, want %v", got, reading)
+				}
+				withTimestamp++
+			}
+		}
+		// value, status and state
+		if want := map[bool]int{false: 0, true: 3}[timestamps]; withTimestamp != want {
+			t.Errorf("timestamps: %v: got %d metrics with a timestamp, want %d", timestamps, withTimestamp, want)
+		}
+	}
+}
+
+func TestFromTimeStamp(t *testing.T) {
+	tests := []struct {
+		name  string
+		hours float64
+		now   time.Time
+		want  time.Time
+	}{
+		{
+			name:  "start of year",
+			hours: 0.5,
+			now:   time.Date(2025, time.January, 1, 1, 0, 0, 0, time.UTC),
+			want:  time.Date(2025, time.January, 1, 0, 30, 0, 0, time.UTC),
+		},
+		{
+			name:  "previous year",
+			hours: 8759.5, // 2024 is a leap year
+			now:   time.Date(2025, time.January, 1, 1, 0, 0, 0, time.UTC),
+			want:  time.Date(2024, time.December, 30, 23, 30, 0, 0, time.UTC),
+		},
+		{
+			name:  "local time",
+			hours: 1,
+			now:   time.Date(2025, time.January, 1, 3, 0, 0, 0, time.FixedZone("CET", 3600)),
+			want:  time.Date(2025, time.January, 1, 1, 0, 0, 0, time.UTC),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fromTimeStamp(tt.hours, tt.now); !got.Equal(tt.want) {
+				t.Errorf("fromTimeStamp() got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func valuePtr(s string) *lightstreamer.Value {
+	v := lightstreamer.Value(s)
+	return &v
+}
@@ -0,0 +1,309 @@
+package collector
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sigs.k8s.io/yaml"
+	"slices"
+	"strings"
+)
+
+// Config lists the telemetry groups to subscribe to, and how to export them. It also holds the exporter's settings
+// that can be configured in the configuration file, rather than with flags: LogLevel, LogFormat, Listen and TLS.
+type Config struct {
+	// Server is the Lightstreamer server to subscribe to. Defaults to the ISS telemetry.
+	Server ServerConfig  `json:"server"`
+	Groups []GroupConfig `json:"groups"`
+	// Subsystems subscribes to all catalog groups of the specified subsystems (e.g. "EPS"), in addition to Groups.
+	Subsystems []string `json:"subsystems,omitempty"`
+	// Include and Exclude are regular expressions that select the groups to subscribe to, matched against the group's
+	// ID and metric name (e.g. "iss_cabin_pressure_mmhg"). If set, a group must match Include and may not match Exclude.
+	Include string `json:"include,omitempty"`
+	Exclude string `json:"exclude,omitempty"`
+	// MaxFrequency is the maximum number of updates per second requested for groups that don't set their own.
+	// Defaults to 0.1.
+	MaxFrequency float64 `json:"max_frequency,omitempty"`
+	// Alerts configures where the alerts of groups with an alert rule are sent.
+	Alerts AlertConfig `json:"alerts"`
+	// LogLevel is the level of the exporter's log messages: "debug", "info", "warn" or "error". If empty, the
+	// exporter's default level is used.
+	LogLevel string `json:"log_level,omitempty"`
+	// LogFormat is the format of the exporter's log messages: "text" or "json". Flags take precedence. Changes require a
+	// restart.
+	LogFormat string `json:"log_format,omitempty"`
+	// Listen configures the addresses the exporter serves on. Flags take precedence.
+	Listen ListenConfig `json:"listen"`
+	// TLS configures the certificate the exporter serves its endpoints with. Flags take precedence.
+	TLS TLSConfig `json:"tls"`
+}
+
+// ListenConfig configures the addresses the exporter serves on, e.g. ":9090". An empty address leaves the address of
+// the corresponding flag unchanged. Changes require a restart.
+type ListenConfig struct {
+	// Metrics is the address of /metrics and the exporter's other endpoints (-addr).
+	Metrics string `json:"metrics,omitempty"`
+	// Health is the address of the health endpoints (-health).
+	Health string `json:"health,omitempty"`
+	// Relay is the address of the Lightstreamer relay (-relay).
+	Relay string `json:"relay,omitempty"`
+	// GRPC is the address of the gRPC telemetry API (-grpc.addr).
+	GRPC string `json:"grpc,omitempty"`
+}
+
+// TLSConfig configures the certificate the exporter serves its endpoints with, over HTTPS. Changes require a restart.
+type TLSConfig struct {
+	// CertFile is the certificate file (-tls-cert).
+	CertFile string `json:"cert_file,omitempty"`
+	// KeyFile is the private key file (-tls-key).
+	KeyFile string `json:"key_file,omitempty"`
+}
+
+// Level returns the configured log level, or false if none is configured.
+func (c Config) Level() (slog.Level, bool) {
+	var level slog.Level
+	if c.LogLevel == "" || level.UnmarshalText([]byte(c.LogLevel)) != nil {
+		return level, false
+	}
+	return level, true
+}
+
+// GroupConfig configures a single telemetry group.
+//
+// If Metric is set, the group is exported as its own gauge, iss_<metric>[_<unit>]. If not, and the group is in the
+// built-in catalog, the catalog's metric name is used. Otherwise, it's exported by the generic iss_telemetry_metric
+// gauge, with a "group" label.
+type GroupConfig struct {
+	// ID is the Lightstreamer item name of the group, e.g. "NODE3000005".
+	ID string `json:"id"`
+	// Metric is the name of the metric, without the "iss_" prefix.
+	Metric string `json:"metric,omitempty"`
+	// Help is the help string of the metric.
+	Help string `json:"help,omitempty"`
+	// Unit is the unit of the metric, e.g. "percent". It's added as a suffix to the metric name.
+	Unit string `json:"unit,omitempty"`
+	// Values maps non-numeric values to a number, e.g. {"OPEN": 1, "CLOSED": 0}.
+	Values map[string]float64 `json:"values,omitempty"`
+	// States maps the values of an enumerated signal (e.g. a valve position) to a state, e.g. {"0": "closed",
+	// "1": "open"}. The group's state is exported as iss_state{group,state}: 1 for the current state, 0 for the others.
+	States map[string]string `json:"states,omitempty"`
+	// Module is the ISS module reporting the group, e.g. "Node3". Defaults to the catalog's module.
+	Module string `json:"module,omitempty"`
+	// Subsystem is the ISS subsystem the group belongs to, e.g. "ECLSS". Defaults to the catalog's subsystem.
+	Subsystem string `json:"subsystem,omitempty"`
+	// Aggregate exports the minimum, maximum, average and/or number of updates over the last complete aggregation
+	// window as <metric>_min, <metric>_max, <metric>_avg and <metric>_count, e.g. ["min", "max"].
+	Aggregate []string `json:"aggregate,omitempty"`
+	// AggregateWindow is the interval over which Aggregate aggregates the group's value, e.g. "5m". Windows are
+	// aligned to the clock. Defaults to 1 minute.
+	AggregateWindow string `json:"aggregate_window,omitempty"`
+	// MaxFrequency is the maximum number of updates per second requested for the group. Defaults to the configuration's
+	// MaxFrequency. A negative value requests all updates.
+	MaxFrequency float64 `json:"max_frequency,omitempty"`
+	// Alert sends an alert when the group's value crosses a threshold, e.g. {"below": 700}. Requires Alerts to be
+	// configured.
+	Alert *AlertRule `json:"alert,omitempty"`
+	// Port marks the group as the status of a docking port, e.g. "Node2 forward". A non-zero value means a vehicle is
+	// docked at the port. The port's status is exported as iss_docking_port_occupied{port}.
+	Port string `json:"port,omitempty"`
+}
+
+// DefaultConfig is the configuration used if no configuration file is specified.
+var DefaultConfig = Config{
+	Groups: []GroupConfig{
+		{ID: "NODE3000005"},   // Urine Tank Qty
+		{ID: "NODE3000008"},   // Waste Water Tank Qty
+		{ID: "NODE3000009"},   // Clean Water Tank Qty
+		{ID: "NODE3000011"},   // O2 production rate
+		{ID: "USLAB000058"},   // cabin pressure
+		{ID: "USLAB000059"},   // cabin temperature
+		{ID: "AIRLOCK000049"}, // crewlock pressure
+		{ID: "AIRLOCK000054"}, // Airlock Pressure
+		{ID: "USLAB000053"},   // Lab ppO2
+	},
+}
+
+// LoadConfig reads a configuration file. Files with a .yaml or .yml extension are read as YAML, using the same field
+// names as JSON. Others are read as JSON.
+func LoadConfig(path string) (Config, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+	if ext := filepath.Ext(path); ext == ".yaml" || ext == ".yml" {
+		if content, err = yaml.YAMLToJSON(content); err != nil {
+			return Config{}, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	dec := json.NewDecoder(bytes.NewReader(content))
+	dec.DisallowUnknownFields()
+	var cfg Config
+	if err = dec.Decode(&cfg); err != nil {
+		return Config{}, fmt.Errorf("%s: %w", path, err)
+	}
+	if err = cfg.Validate(); err != nil {
+		return Config{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+var metricNameRegExp = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+// AllGroups returns the configured groups, followed by the catalog groups of the configured subsystems that aren't
+// configured explicitly. Groups not selected by Include and Exclude are left out. Groups without a MaxFrequency get
+// the configuration's default. AllGroups assumes the configuration is valid.
+func (c Config) AllGroups() []GroupConfig {
+	groups := slices.Clone(c.Groups)
+	for _, subsystem := range c.Subsystems {
+		for _, id := range subsystemGroups(subsystem) {
+			if !slices.ContainsFunc(groups, func(g GroupConfig) bool { return g.ID == id }) {
+				groups = append(groups, GroupConfig{ID: id})
+			}
+		}
+	}
+	include, exclude, _ := c.filters()
+	groups = slices.DeleteFunc(groups, func(g GroupConfig) bool {
+		return !selected(g, include, exclude)
+	})
+	for i := range groups {
+		groups[i].MaxFrequency = cmp.Or(groups[i].MaxFrequency, c.MaxFrequency, defaultMaxFrequency)
+	}
+	return groups
+}
+
+// defaultMaxFrequency is the maximum number of updates per second requested for a group, unless configured otherwise.
+const defaultMaxFrequency = 0.1
+
+// filters compiles Include and Exclude. An empty expression returns nil.
+func (c Config) filters() (include *regexp.Regexp, exclude *regexp.Regexp, err error) {
+	if c.Include != "" {
+		if include, err = regexp.Compile(c.Include); err != nil {
+			return nil, nil, fmt.Errorf("include: %w", err)
+		}
+	}
+	if c.Exclude != "" {
+		if exclude, err = regexp.Compile(c.Exclude); err != nil {
+			return nil, nil, fmt.Errorf("exclude: %w", err)
+		}
+	}
+	return include, exclude, nil
+}
+
+// selected returns true if the group's ID or metric name matches include (if set), and neither matches exclude.
+func selected(group GroupConfig, include *regexp.Regexp, exclude *regexp.Regexp) bool {
+	names := []string{group.ID}
+	if group = group.resolve(); group.Metric != "" {
+		names = append(names, group.metricName())
+	}
+	if include != nil && !slices.ContainsFunc(names, include.MatchString) {
+		return false
+	}
+	return exclude == nil || !slices.ContainsFunc(names, exclude.MatchString)
+}
+
+// Validate checks that the configuration is valid: the server's schema has a value field, all subsystems exist,
+// Include and Exclude are valid regular expressions, the log level and format are supported, TLS has both a
+// certificate and a key, or neither, at least one group is selected, all groups have a unique ID, all aggregations are
+// supported, all alert rules have a threshold and a webhook to send to, each docking port is reported by a single
+// group, and all metric names are valid and unique.
+func (c Config) Validate() error {
+	for _, subsystem := range c.Subsystems {
+		if !slices.Contains(subsystems(), subsystem) {
+			return fmt.Errorf("unknown subsystem %q. supported: %s", subsystem, strings.Join(subsystems(), ", "))
+		}
+	}
+	if err := c.Server.validate(); err != nil {
+		return fmt.Errorf("server: %w", err)
+	}
+	if _, _, err := c.filters(); err != nil {
+		return err
+	}
+	if _, err := c.Alerts.cooldown(); err != nil {
+		return fmt.Errorf("alerts: invalid cooldown: %w", err)
+	}
+	if _, ok := c.Level(); c.LogLevel != "" && !ok {
+		return fmt.Errorf("invalid log level %q", c.LogLevel)
+	}
+	if c.LogFormat != "" && c.LogFormat != "text" && c.LogFormat != "json" {
+		return fmt.Errorf("invalid log format %q. supported: text, json", c.LogFormat)
+	}
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		return errors.New("tls: cert_file and key_file must be set together")
+	}
+	groups := c.AllGroups()
+	if len(groups) == 0 {
+		return errors.New("no groups configured")
+	}
+	ids := make(map[string]struct{}, len(groups))
+	ports := make(map[string]string)
+	metrics := make(map[string]string, len(groups))
+	for _, group := range groups {
+		if group.ID == "" {
+			return errors.New("group has no id")
+		}
+		if _, ok := ids[group.ID]; ok {
+			return fmt.Errorf("group %s: duplicate id", group.ID)
+		}
+		ids[group.ID] = struct{}{}
+		for value, state := range group.States {
+			if state == "" {
+				return fmt.Errorf("group %s: value %q has no state", group.ID, value)
+			}
+		}
+		if group.Port != "" {
+			if other, ok := ports[group.Port]; ok {
+				return fmt.Errorf("group %s: port %q already reported by group %s", group.ID, group.Port, other)
+			}
+			ports[group.Port] = group.ID
+		}
+		if group.Alert != nil {
+			if group.Alert.Below == nil && group.Alert.Above == nil {
+				return fmt.Errorf("group %s: alert has no threshold", group.ID)
+			}
+			if c.Alerts.WebhookURL == "" {
+				return fmt.Errorf("group %s: alert configured, but no webhook_url", group.ID)
+			}
+		}
+		group = group.resolve()
+		var names []string
+		if group.Metric != "" {
+			names = append(names, group.metricName())
+		}
+		if window, err := group.aggregateWindow(); err != nil || window <= 0 {
+			return fmt.Errorf("group %s: invalid aggregate_window %q", group.ID, group.AggregateWindow)
+		}
+		for _, aggregation := range group.Aggregate {
+			if !slices.Contains(aggregations, aggregation) {
+				return fmt.Errorf("group %s: unknown aggregation %q. supported: %s", group.ID, aggregation, strings.Join(aggregations, ", "))
+			}
+			if group.Metric != "" {
+				names = append(names, group.aggregatedMetricName(aggregation))
+			}
+		}
+		for _, name := range names {
+			if !metricNameRegExp.MatchString(name) {
+				return fmt.Errorf("group %s: invalid metric name %q", group.ID, name)
+			}
+			if other, ok := metrics[name]; ok {
+				return fmt.Errorf("group %s: metric %q already used by group %s", group.ID, name, other)
+			}
+			metrics[name] = group.ID
+		}
+	}
+	return nil
+}
+
+// metricName returns the fully-qualified name of the group's metric.
+func (g GroupConfig) metricName() string {
+	name := "iss_" + g.Metric
+	if g.Unit != "" && !strings.HasSuffix(name, "_"+g.Unit) {
+		name += "_" + g.Unit
+	}
+	return name
+}
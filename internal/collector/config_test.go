@@ -0,0 +1,227 @@
+package collector
+
+import (
+	"cmp"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		wantErr bool
+		want    int
+	}{
+		{
+			name:    "valid",
+			content: `{"groups":[{"id":"NODE3000005","metric":"urine_tank","unit":"percent","help":"Urine Tank Qty"},{"id":"USLAB000058"}]}`,
+			want:    2,
+		},
+		{
+			name:    "invalid json",
+			content: `{"groups":[`,
+			wantErr: true,
+		},
+		{
+			name:    "unknown field",
+			content: `{"groups":[{"id":"NODE3000005","name":"foo"}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "no groups",
+			content: `{"groups":[]}`,
+			wantErr: true,
+		},
+		{
+			name: "yaml",
+			file: "config.yaml",
+			content: `server:
+  url: https://push.lightstreamer.com/lightstreamer
+  adapter_set: ISSLIVE
+max_frequency: 1
+groups:
+  - id: NODE3000005
+    max_frequency: 0.5
+log_level: debug
+log_format: json
+listen:
+  metrics: ":9091"
+  health: ":8081"
+tls:
+  cert_file: cert.pem
+  key_file: key.pem
+`,
+			want: 1,
+		},
+		{
+			name:    "invalid yaml",
+			file:    "config.yml",
+			content: "groups: [",
+			wantErr: true,
+		},
+		{
+			name:    "unknown yaml field",
+			file:    "config.yaml",
+			content: "groups:\n  - id: NODE3000005\n    name: foo\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), cmp.Or(tt.file, "config.json"))
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			cfg, err := LoadConfig(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(cfg.Groups) != tt.want {
+				t.Errorf("LoadConfig() got %d groups, want %d", len(cfg.Groups), tt.want)
+			}
+		})
+	}
+
+	if _, err := LoadConfig(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("LoadConfig() expected error for missing file")
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name       string
+		groups     []GroupConfig
+		subsystems []string
+		include    string
+		exclude    string
+		alerts     AlertConfig
+		logLevel   string
+		logFormat  string
+		tls        TLSConfig
+		wantErr    bool
+	}{
+		{name: "default", groups: DefaultConfig.Groups},
+		{name: "missing id", groups: []GroupConfig{{Metric: "foo"}}, wantErr: true},
+		{name: "duplicate id", groups: []GroupConfig{{ID: "A"}, {ID: "A"}}, wantErr: true},
+		{name: "invalid metric name", groups: []GroupConfig{{ID: "A", Metric: "foo-bar"}}, wantErr: true},
+		{name: "duplicate metric name", groups: []GroupConfig{{ID: "A", Metric: "foo", Unit: "bar"}, {ID: "B", Metric: "foo_bar"}}, wantErr: true},
+		{name: "empty state", groups: []GroupConfig{{ID: "A", States: map[string]string{"0": ""}}}, wantErr: true},
+		{name: "subsystem", subsystems: []string{"EPS"}},
+		{name: "subsystem and groups", groups: DefaultConfig.Groups, subsystems: []string{"ECLSS"}},
+		{name: "unknown subsystem", subsystems: []string{"foo"}, wantErr: true},
+		{name: "invalid include", groups: DefaultConfig.Groups, include: "(", wantErr: true},
+		{name: "invalid exclude", groups: DefaultConfig.Groups, exclude: "(", wantErr: true},
+		{name: "nothing selected", groups: DefaultConfig.Groups, exclude: ".", wantErr: true},
+		{name: "alert", groups: []GroupConfig{{ID: "A", Alert: &AlertRule{Below: new(float64)}}}, alerts: AlertConfig{WebhookURL: "http://localhost", Cooldown: "5m"}},
+		{name: "alert without threshold", groups: []GroupConfig{{ID: "A", Alert: &AlertRule{}}}, alerts: AlertConfig{WebhookURL: "http://localhost"}, wantErr: true},
+		{name: "alert without webhook", groups: []GroupConfig{{ID: "A", Alert: &AlertRule{Below: new(float64)}}}, wantErr: true},
+		{name: "invalid cooldown", groups: []GroupConfig{{ID: "A"}}, alerts: AlertConfig{Cooldown: "soon"}, wantErr: true},
+		{name: "ports", groups: []GroupConfig{{ID: "A", Port: "Node2 forward"}, {ID: "B", Port: "Node2 zenith"}}},
+		{name: "duplicate port", groups: []GroupConfig{{ID: "A", Port: "Node2 forward"}, {ID: "B", Port: "Node2 forward"}}, wantErr: true},
+		{name: "aggregate", groups: []GroupConfig{{ID: "A", Metric: "foo", Aggregate: []string{"min", "max", "avg", "count"}}, {ID: "B", Aggregate: []string{"avg"}}}},
+		{name: "aggregate window", groups: []GroupConfig{{ID: "A", Aggregate: []string{"avg"}, AggregateWindow: "5m"}}},
+		{name: "invalid aggregate window", groups: []GroupConfig{{ID: "A", Aggregate: []string{"avg"}, AggregateWindow: "0s"}}, wantErr: true},
+		{name: "unknown aggregation", groups: []GroupConfig{{ID: "A", Aggregate: []string{"median"}}}, wantErr: true},
+		{name: "aggregated metric name in use", groups: []GroupConfig{{ID: "A", Metric: "foo", Aggregate: []string{"max"}}, {ID: "B", Metric: "foo_max"}}, wantErr: true},
+		{name: "log level", groups: []GroupConfig{{ID: "A"}}, logLevel: "debug"},
+		{name: "invalid log level", groups: []GroupConfig{{ID: "A"}}, logLevel: "verbose", wantErr: true},
+		{name: "log format", groups: []GroupConfig{{ID: "A"}}, logFormat: "json"},
+		{name: "invalid log format", groups: []GroupConfig{{ID: "A"}}, logFormat: "xml", wantErr: true},
+		{name: "tls", groups: []GroupConfig{{ID: "A"}}, tls: TLSConfig{CertFile: "cert.pem", KeyFile: "key.pem"}},
+		{name: "tls without key", groups: []GroupConfig{{ID: "A"}}, tls: TLSConfig{CertFile: "cert.pem"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := (Config{Groups: tt.groups, Subsystems: tt.subsystems, Include: tt.include, Exclude: tt.exclude, Alerts: tt.alerts, LogLevel: tt.logLevel, LogFormat: tt.logFormat, TLS: tt.tls}).Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfig_AllGroups(t *testing.T) {
+	cfg := Config{
+		Groups:     []GroupConfig{{ID: "S4000001", Metric: "foo"}, {ID: "A"}},
+		Subsystems: []string{"EPS"},
+	}
+	groups := cfg.AllGroups()
+	if want := 2 + len(subsystemGroups("EPS")) - 1; len(groups) != want {
+		t.Fatalf("got %d groups, want %d", len(groups), want)
+	}
+	if groups[0].Metric != "foo" || groups[1].ID != "A" {
+		t.Errorf("configured groups should come first: got %v", groups[:2])
+	}
+}
+
+func TestConfig_AllGroups_MaxFrequency(t *testing.T) {
+	tests := []struct {
+		name   string
+		global float64
+		group  float64
+		want   float64
+	}{
+		{name: "default", want: defaultMaxFrequency},
+		{name: "global", global: 1, want: 1},
+		{name: "group", global: 1, group: 2, want: 2},
+		{name: "unlimited", group: -1, want: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{Groups: []GroupConfig{{ID: "A", MaxFrequency: tt.group}}, MaxFrequency: tt.global}
+			if got := cfg.AllGroups()[0].MaxFrequency; got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfig_AllGroups_Filter(t *testing.T) {
+	tests := []struct {
+		name    string
+		include string
+		exclude string
+		want    []string
+	}{
+		{name: "none", want: []string{"NODE3000005", "USLAB000058", "A"}},
+		{name: "include by id", include: "^NODE3", want: []string{"NODE3000005"}},
+		{name: "include by metric name", include: "cabin_pressure", want: []string{"USLAB000058"}},
+		{name: "exclude", exclude: "^USLAB|^A$", want: []string{"NODE3000005"}},
+		{name: "include and exclude", include: "0000", exclude: "NODE3", want: []string{"USLAB000058"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{
+				Groups:  []GroupConfig{{ID: "NODE3000005"}, {ID: "USLAB000058"}, {ID: "A"}},
+				Include: tt.include,
+				Exclude: tt.exclude,
+			}
+			var got []string
+			for _, group := range cfg.AllGroups() {
+				got = append(got, group.ID)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGroupConfig_metricName(t *testing.T) {
+	tests := []struct {
+		group GroupConfig
+		want  string
+	}{
+		{group: GroupConfig{Metric: "cabin_pressure"}, want: "iss_cabin_pressure"},
+		{group: GroupConfig{Metric: "cabin_pressure", Unit: "mmhg"}, want: "iss_cabin_pressure_mmhg"},
+		{group: GroupConfig{Metric: "cabin_pressure_mmhg", Unit: "mmhg"}, want: "iss_cabin_pressure_mmhg"},
+	}
+	for _, tt := range tests {
+		if got := tt.group.metricName(); got != tt.want {
+			t.Errorf("metricName() got %q, want %q", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultCrewURL returns the people currently in space, from open-notify.
+const DefaultCrewURL = "http://api.open-notify.org/astros.json"
+
+const (
+	// crewRefresh is how often the crew is refreshed. The crew only changes a few times a year.
+	crewRefresh = time.Hour
+	// crewRetry is how long to wait before retrying a failed fetch.
+	crewRetry = 5 * time.Minute
+)
+
+var (
+	crewCountMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("iss", "crew", "count"),
+		"number of people on board the ISS",
+		nil,
+		nil,
+	)
+
+	crewInfoMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("iss", "crew", "info"),
+		"people currently in space, and their spacecraft",
+		[]string{"name", "craft"},
+		nil,
+	)
+)
+
+type astronaut struct {
+	Name  string `json:"name"`
+	Craft string `json:"craft"`
+}
+
+// crewSource periodically fetches the people in space and exports them as metrics.
+type crewSource struct {
+	url        string
+	httpClient *http.Client
+	logger     *slog.Logger
+	crew       atomic.Pointer[[]astronaut]
+	status     fetchStatus
+}
+
+// run fetches the crew, and refreshes it, until ctx is canceled. If a fetch fails, the previous crew is kept.
+func (s *crewSource) run(ctx context.Context, refresh time.Duration, retry time.Duration) {
+	for {
+		wait := refresh
+		err := s.fetch(ctx)
+		s.status.record(err, time.Now())
+		if err != nil {
+			s.logger.Warn("failed to fetch crew", "err", err)
+			wait = retry
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (s *crewSource) fetch(ctx context.Context) error {
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return errors.New(resp.Status)
+	}
+	var astros struct {
+		Message string      `json:"message"`
+		People  []astronaut `json:"people"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&astros); err != nil {
+		return err
+	}
+	if astros.Message != "success" {
+		return errors.New("unexpected response: " + astros.Message)
+	}
+	s.crew.Store(&astros.People)
+	s.logger.Debug("crew updated", "count", len(astros.People))
+	return nil
+}
+
+func (s *crewSource) Describe(ch chan<- *prometheus.Desc) {
+	ch <- crewCountMetric
+	ch <- crewInfoMetric
+}
+
+func (s *crewSource) Collect(ch chan<- prometheus.Metric) {
+	crew := s.crew.Load()
+	if crew == nil {
+		return
+	}
+	var count int
+	for _, person := range *crew {
+		ch <- prometheus.MustNewConstMetric(crewInfoMetric, prometheus.GaugeValue, 1, person.Name, person.Craft)
+		if person.Craft == "ISS" {
+			count++
+		}
+	}
+	ch <- prometheus.MustNewConstMetric(crewCountMetric, prometheus.GaugeValue, float64(count))
+}
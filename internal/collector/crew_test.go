@@ -0,0 +1,56 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCrewSource(t *testing.T) {
+	response := `{"people": [{"craft": "ISS", "name": "Oleg Kononenko"}, {"craft": "ISS", "name": "Nikolai Chub"}, {"craft": "Tiangong", "name": "Ye Guangfu"}], "number": 3, "message": "success"}`
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(response))
+	}))
+	t.Cleanup(ts.Close)
+
+	s := crewSource{url: ts.URL, httpClient: ts.Client(), logger: slog.New(slog.DiscardHandler)}
+	if got := collectCount(&s); got != 0 {
+		t.Errorf("got %d metrics without a crew, want 0", got)
+	}
+	if err := s.fetch(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	// a failed fetch keeps the current crew
+	response = `{"message": "failure"}`
+	if err := s.fetch(t.Context()); err == nil {
+		t.Error("expected fetch to fail")
+	}
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		s.Collect(ch)
+		close(ch)
+	}()
+	var info int
+	var count float64
+	for m := range ch {
+		var d dto.Metric
+		_ = m.Write(&d)
+		switch m.Desc() {
+		case crewInfoMetric:
+			info++
+		case crewCountMetric:
+			count = d.GetGauge().GetValue()
+		}
+	}
+	if info != 3 {
+		t.Errorf("got %d crew info metrics, want 3", info)
+	}
+	if count != 2 {
+		t.Errorf("got crew count %v, want 2", count)
+	}
+}
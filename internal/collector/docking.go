@@ -0,0 +1,67 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"maps"
+	"slices"
+	"sync"
+)
+
+var dockingPortOccupiedMetric = prometheus.NewDesc(
+	prometheus.BuildFQName("iss", "docking", "port_occupied"),
+	"1 if a vehicle is docked at the port, 0 if the port is free",
+	[]string{"port"},
+	nil,
+)
+
+// docking tracks the status of the docking ports. A port's status is reported by the group configured with its Port.
+type docking struct {
+	lock     sync.RWMutex
+	occupied map[string]bool
+	events   *prometheus.CounterVec
+}
+
+func newDocking() *docking {
+	return &docking{
+		occupied: make(map[string]bool),
+		events: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: prometheus.BuildFQName("iss", "docking", "events_total"),
+			Help: "number of dockings and undockings, derived from changes in the status of the docking ports",
+		}, []string{"port", "event"}),
+	}
+}
+
+// update records the status of a port. A change from free to occupied counts as a docking, the reverse as an
+// undocking. The first status of a port is not an event.
+func (d *docking) update(port string, occupied bool) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	previous, known := d.occupied[port]
+	d.occupied[port] = occupied
+	if !known || previous == occupied {
+		return
+	}
+	event := "undocking"
+	if occupied {
+		event = "docking"
+	}
+	d.events.WithLabelValues(port, event).Inc()
+}
+
+func (d *docking) Describe(ch chan<- *prometheus.Desc) {
+	ch <- dockingPortOccupiedMetric
+	d.events.Describe(ch)
+}
+
+func (d *docking) Collect(ch chan<- prometheus.Metric) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	for _, port := range slices.Sorted(maps.Keys(d.occupied)) {
+		var occupied float64
+		if d.occupied[port] {
+			occupied = 1
+		}
+		ch <- prometheus.MustNewConstMetric(dockingPortOccupiedMetric, prometheus.GaugeValue, occupied, port)
+	}
+	d.events.Collect(ch)
+}
@@ -0,0 +1,79 @@
+package collector
+
+import (
+	"github.com/clambin/iss-exporter/lightstreamer"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"log/slog"
+	"testing"
+)
+
+func TestDocking(t *testing.T) {
+	d := newDocking()
+	for _, update := range []struct {
+		port     string
+		occupied bool
+	}{
+		{port: "Node2 forward", occupied: true},
+		{port: "Node2 zenith", occupied: false},
+		{port: "Node2 forward", occupied: true},
+		{port: "Node2 forward", occupied: false},
+		{port: "Node2 zenith", occupied: true},
+		{port: "Node2 forward", occupied: true},
+	} {
+		d.update(update.port, update.occupied)
+	}
+
+	want := map[string]float64{"Node2 forward/docking": 1, "Node2 forward/undocking": 1, "Node2 zenith/docking": 1}
+	got := make(map[string]float64)
+	occupied := make(map[string]float64)
+	ch := make(chan prometheus.Metric)
+	go func() {
+		d.Collect(ch)
+		close(ch)
+	}()
+	for metric := range ch {
+		var m dto.Metric
+		if err := metric.Write(&m); err != nil {
+			t.Fatal(err)
+		}
+		labels := make(map[string]string)
+		for _, label := range m.GetLabel() {
+			labels[label.GetName()] = label.GetValue()
+		}
+		if m.Counter != nil {
+			got[labels["port"]+"/"+labels["event"]] = m.GetCounter().GetValue()
+		} else {
+			occupied[labels["port"]] = m.GetGauge().GetValue()
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("got events %v, want %v", got, want)
+	}
+	for event, count := range want {
+		if got[event] != count {
+			t.Errorf("%s: got %v, want %v", event, got[event], count)
+		}
+	}
+	if occupied["Node2 forward"] != 1 || occupied["Node2 zenith"] != 1 {
+		t.Errorf("got occupied %v", occupied)
+	}
+}
+
+func TestCollector_Docking(t *testing.T) {
+	var s fakeSubscriber
+	c, err := NewCollector(t.Context(), Config{Groups: []GroupConfig{{ID: "A", Port: "Node2 forward", Values: map[string]float64{"DOCKED": 1, "FREE": 0}}}}, &s, slog.New(slog.DiscardHandler))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, value := range []string{"FREE", "DOCKED"} {
+		s.publish("A", lightstreamer.Values{valuePtr(value), valuePtr("24"), nil})
+	}
+	var m dto.Metric
+	if err = c.docking.events.WithLabelValues("Node2 forward", "docking").Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.GetCounter().GetValue(); got != 1 {
+		t.Errorf("got %v dockings, want 1", got)
+	}
+}
@@ -0,0 +1,207 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// feedSize is the number of updates queued for an events client before new updates are dropped.
+	feedSize = 256
+	// eventsKeepAlive is the time between keep-alive comments on an idle event stream, so proxies don't close it.
+	eventsKeepAlive = 15 * time.Second
+)
+
+// An Update is a telemetry update, as processed by the Collector.
+type Update struct {
+	Group string  `json:"group"`
+	Value float64 `json:"value"`
+	// State is the state of an enumerated group, or empty if the group has no states, or the value doesn't map to one.
+	State string `json:"state,omitempty"`
+	// Status is the Status.Class of the reading, if the update has one.
+	Status *float64 `json:"status,omitempty"`
+	Unit   string   `json:"unit,omitempty"`
+	// Timestamp is the time of the reading, as reported by ISSLIVE, or the time the update was received, if unknown.
+	Timestamp time.Time `json:"timestamp"`
+	Received  time.Time `json:"received"`
+	// Stale is set if the group hasn't been updated for the Collector's StaleAfter.
+	Stale bool `json:"stale,omitempty"`
+}
+
+// newUpdate returns a signal's reading as an Update. ok is false if the signal doesn't have a value.
+func (s *signal) newUpdate(r reading) (Update, bool) {
+	if !r.hasValue {
+		return Update{}, false
+	}
+	u := Update{Group: s.ID, Value: r.value, State: r.state, Unit: s.Unit, Timestamp: r.timestamp, Received: r.received}
+	if r.hasStatus {
+		u.Status = &r.status
+	}
+	if u.Timestamp.IsZero() {
+		u.Timestamp = r.received
+	}
+	return u, true
+}
+
+// listeners are the functions called for each processed update, or state transition. The zero value is ready to use.
+type listeners[T any] struct {
+	lock      sync.RWMutex
+	next      int
+	listeners map[int]func(T)
+}
+
+func (l *listeners[T]) add(f func(T)) func() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.listeners == nil {
+		l.listeners = make(map[int]func(T))
+	}
+	id := l.next
+	l.next++
+	l.listeners[id] = f
+	return func() {
+		l.lock.Lock()
+		defer l.lock.Unlock()
+		delete(l.listeners, id)
+	}
+}
+
+func (l *listeners[T]) notify(v T) {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	for _, f := range l.listeners {
+		f(v)
+	}
+}
+
+// Listen calls f for each telemetry update processed by the Collector, until the returned function is called.
+// f is called by the session's reader: it must not block.
+func (c *Collector) Listen(f func(Update)) (stop func()) {
+	return c.listeners.add(f)
+}
+
+// Latest returns the last update of each telemetry group that has a value, in the order of the configuration.
+func (c *Collector) Latest() []Update {
+	return c.latest(time.Now())
+}
+
+func (c *Collector) latest(now time.Time) []Update {
+	var updates []Update
+	for _, s := range c.currentSignals() {
+		if u, ok := c.lastUpdate(s, now); ok {
+			updates = append(updates, u)
+		}
+	}
+	return updates
+}
+
+// lastUpdate returns the last update of a signal, marked as stale if it's older than StaleAfter.
+func (c *Collector) lastUpdate(s *signal, now time.Time) (Update, bool) {
+	u, ok := s.newUpdate(s.last())
+	u.Stale = ok && c.StaleAfter > 0 && now.Sub(u.Received) > c.StaleAfter
+	return u, ok
+}
+
+// A feed queues the updates of a set of groups for a client. If the client falls behind, and the queue is full, new
+// updates are dropped and counted as lost.
+type feed struct {
+	updates chan Update
+	groups  atomic.Pointer[[]string]
+	lost    atomic.Int64
+	stop    func()
+}
+
+// newFeed returns a feed of the updates of the specified groups, or of all groups if none are specified. Call stop
+// when the feed is no longer needed.
+func (c *Collector) newFeed(groups []string, size int) *feed {
+	f := feed{updates: make(chan Update, size)}
+	f.setGroups(groups)
+	f.stop = c.Listen(f.push)
+	return &f
+}
+
+// setGroups selects the groups of the feed. No groups selects all groups.
+func (f *feed) setGroups(groups []string) {
+	f.groups.Store(&groups)
+}
+
+func (f *feed) wants(group string) bool {
+	groups := *f.groups.Load()
+	return len(groups) == 0 || slices.Contains(groups, group)
+}
+
+func (f *feed) push(u Update) {
+	if !f.wants(u.Group) {
+		return
+	}
+	select {
+	case f.updates <- u:
+	default:
+		f.lost.Add(1)
+	}
+}
+
+// EventsHandler returns an http.Handler that streams telemetry updates as Server-Sent Events. Each event is an Update,
+// as JSON. Clients select groups with one or more "group" query parameters, e.g. /events?group=USLAB000058, or
+// receive the updates of all groups.
+//
+// The stream starts with the last update of each selected group. If a client falls behind, updates are dropped, and
+// the client receives a "lost" event with the number of updates it missed. Streams end when ctx is canceled, so they
+// don't hold up a graceful shutdown.
+func (c *Collector) EventsHandler(ctx context.Context) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming not supported", http.StatusInternalServerError)
+			return
+		}
+		f := c.newFeed(r.URL.Query()["group"], feedSize)
+		defer f.stop()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		for _, u := range c.Latest() {
+			if f.wants(u.Group) {
+				writeEvent(w, "", u)
+			}
+		}
+		flusher.Flush()
+
+		keepAlive := time.NewTicker(eventsKeepAlive)
+		defer keepAlive.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-r.Context().Done():
+				return
+			case <-keepAlive.C:
+				_, _ = w.Write([]byte(": keep-alive\n\n"))
+			case u := <-f.updates:
+				if lost := f.lost.Swap(0); lost > 0 {
+					writeEvent(w, "lost", lost)
+				}
+				writeEvent(w, "", u)
+			}
+			flusher.Flush()
+		}
+	})
+}
+
+// writeEvent writes an event with a JSON payload. An empty event type sends a "message" event.
+func writeEvent(w http.ResponseWriter, event string, payload any) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+	if event != "" {
+		_, _ = w.Write([]byte("event: " + event + "\n"))
+	}
+	_, _ = w.Write([]byte("data: " + string(body) + "\n\n"))
+}
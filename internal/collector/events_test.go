@@ -0,0 +1,116 @@
+package collector
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"github.com/clambin/iss-exporter/lightstreamer"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCollector_Listen(t *testing.T) {
+	var s fakeSubscriber
+	c, err := NewCollector(t.Context(), Config{Groups: []GroupConfig{{ID: "FOO", Unit: "psi"}}}, &s, slog.New(slog.DiscardHandler))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var updates []Update
+	stop := c.Listen(func(u Update) { updates = append(updates, u) })
+	s.publish("FOO", lightstreamer.Values{valuePtr("14.7"), valuePtr("24"), valuePtr("1")})
+	s.publish("FOO", lightstreamer.Values{valuePtr("n/a")})
+	stop()
+	s.publish("FOO", lightstreamer.Values{valuePtr("14.6"), valuePtr("24"), valuePtr("1")})
+
+	if len(updates) != 1 {
+		t.Fatalf("got %d updates, want 1", len(updates))
+	}
+	u := updates[0]
+	if u.Group != "FOO" || u.Value != 14.7 || u.Unit != "psi" || u.Status == nil || *u.Status != 24 || u.Timestamp.IsZero() {
+		t.Errorf("unexpected update: %+v", u)
+	}
+	if latest := c.Latest(); len(latest) != 1 || latest[0].Value != 14.6 {
+		t.Errorf("unexpected latest updates: %+v", latest)
+	}
+}
+
+func TestFeed(t *testing.T) {
+	c := newCollector(Config{}, slog.New(slog.DiscardHandler))
+	f := c.newFeed([]string{"A"}, 1)
+	defer f.stop()
+	for _, group := range []string{"A", "B", "A", "A"} {
+		c.listeners.notify(Update{Group: group})
+	}
+	if got := len(f.updates); got != 1 {
+		t.Errorf("got %d queued updates, want 1", got)
+	}
+	if got := f.lost.Load(); got != 2 {
+		t.Errorf("got %d lost updates, want 2", got)
+	}
+}
+
+func TestCollector_EventsHandler(t *testing.T) {
+	var s fakeSubscriber
+	c, err := NewCollector(t.Context(), Config{Groups: []GroupConfig{{ID: "A"}, {ID: "B"}}}, &s, slog.New(slog.DiscardHandler))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.publish("A", lightstreamer.Values{valuePtr("1")})
+
+	ctx, cancel := context.WithCancel(t.Context())
+	ts := httptest.NewServer(c.EventsHandler(ctx))
+	t.Cleanup(ts.Close)
+
+	resp, err := http.Get(ts.URL + "?group=A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if got := resp.Header.Get("Content-Type"); got != "text/event-stream" {
+		t.Errorf("got content type %q", got)
+	}
+	events := make(chan Update)
+	go func() {
+		defer close(events)
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+				var u Update
+				if err := json.Unmarshal([]byte(data), &u); err == nil {
+					events <- u
+				}
+			}
+		}
+	}()
+
+	// the stream starts with the last value
+	if u := <-events; u.Group != "A" || u.Value != 1 {
+		t.Errorf("unexpected first event: %+v", u)
+	}
+	// B isn't selected: the next event is A's update
+	s.publish("B", lightstreamer.Values{valuePtr("2")})
+	s.publish("A", lightstreamer.Values{valuePtr("3")})
+	select {
+	case u := <-events:
+		if u.Group != "A" || u.Value != 3 {
+			t.Errorf("unexpected event: %+v", u)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for update")
+	}
+
+	// canceling ctx ends the stream
+	cancel()
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Error("unexpected event")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("stream didn't end")
+	}
+}
@@ -0,0 +1,55 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// fetchStatus records the outcome of the last fetch of an external source, e.g. the crew, to report its health.
+type fetchStatus struct {
+	lock        sync.Mutex
+	lastSuccess time.Time
+	lastErr     error
+}
+
+// fetchDetails is the health report of an external source.
+type fetchDetails struct {
+	LastSuccess time.Time `json:"last_success,omitzero"`
+	LastError   string    `json:"last_error,omitempty"`
+}
+
+func (s *fetchStatus) record(err error, now time.Time) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.lastErr = err
+	if err == nil {
+		s.lastSuccess = now
+	}
+}
+
+// Healthy returns an error if the last fetch failed, or no fetch has completed yet.
+func (s *fetchStatus) Healthy(context.Context) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.lastErr != nil {
+		return fmt.Errorf("last fetch failed: %w", s.lastErr)
+	}
+	if s.lastSuccess.IsZero() {
+		return errors.New("not fetched yet")
+	}
+	return nil
+}
+
+// Details returns the time of the last successful fetch, and the error of the last fetch, if it failed.
+func (s *fetchStatus) Details() any {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	details := fetchDetails{LastSuccess: s.lastSuccess}
+	if s.lastErr != nil {
+		details.LastError = s.lastErr.Error()
+	}
+	return details
+}
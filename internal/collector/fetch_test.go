@@ -0,0 +1,28 @@
+package collector
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFetchStatus(t *testing.T) {
+	var s fetchStatus
+	if err := s.Healthy(t.Context()); err == nil {
+		t.Error("Healthy() should fail before the first fetch")
+	}
+
+	now := time.Date(2025, time.March, 1, 12, 0, 0, 0, time.UTC)
+	s.record(nil, now)
+	if err := s.Healthy(t.Context()); err != nil {
+		t.Errorf("Healthy() got %v", err)
+	}
+
+	s.record(errors.New("503 Service Unavailable"), now.Add(time.Hour))
+	if err := s.Healthy(t.Context()); err == nil {
+		t.Error("Healthy() should fail after a failed fetch")
+	}
+	if got := s.Details().(fetchDetails); !got.LastSuccess.Equal(now) || got.LastError != "503 Service Unavailable" {
+		t.Errorf("Details() got %+v", got)
+	}
+}
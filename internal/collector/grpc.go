@@ -0,0 +1,67 @@
+package collector
+
+import (
+	"context"
+	telemetryv1 "github.com/clambin/iss-exporter/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"net/http"
+)
+
+// GRPCHandler returns an http.Handler that serves the iss.telemetry.v1.Telemetry gRPC service (proto/telemetry.proto).
+// Subscribe streams the updates of the requested groups, like EventsHandler. Each stream has a bounded queue: if a
+// client falls behind, updates are dropped, and the next update reports how many were lost. Streams end when ctx is
+// canceled. The handler must be served over HTTP/2.
+func (c *Collector) GRPCHandler(ctx context.Context) http.Handler {
+	s := grpc.NewServer()
+	telemetryv1.RegisterTelemetryServer(s, &telemetryServer{collector: c, ctx: ctx})
+	return s
+}
+
+// telemetryServer implements the Telemetry service.
+type telemetryServer struct {
+	telemetryv1.UnimplementedTelemetryServer
+	collector *Collector
+	ctx       context.Context
+}
+
+func (s *telemetryServer) Subscribe(request *telemetryv1.SubscribeRequest, stream grpc.ServerStreamingServer[telemetryv1.Update]) error {
+	f := s.collector.newFeed(request.GetGroups(), feedSize)
+	defer f.stop()
+
+	for _, u := range s.collector.Latest() {
+		if f.wants(u.Group) {
+			if err := stream.Send(newGRPCUpdate(u, 0)); err != nil {
+				return err
+			}
+		}
+	}
+	for {
+		select {
+		case <-s.ctx.Done():
+			return status.Error(codes.Unavailable, "shutting down")
+		case <-stream.Context().Done():
+			return status.FromContextError(stream.Context().Err()).Err()
+		case u := <-f.updates:
+			if err := stream.Send(newGRPCUpdate(u, f.lost.Swap(0))); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// newGRPCUpdate returns an Update as a telemetryv1.Update message.
+func newGRPCUpdate(u Update, lost int64) *telemetryv1.Update {
+	return &telemetryv1.Update{
+		Group:     u.Group,
+		Value:     u.Value,
+		State:     u.State,
+		Status:    u.Status,
+		Unit:      u.Unit,
+		Timestamp: timestamppb.New(u.Timestamp),
+		Received:  timestamppb.New(u.Received),
+		Lost:      uint64(lost),
+	}
+}
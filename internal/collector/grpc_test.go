@@ -0,0 +1,84 @@
+package collector
+
+import (
+	"context"
+	"github.com/clambin/iss-exporter/lightstreamer"
+	telemetryv1 "github.com/clambin/iss-exporter/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCollector_GRPCHandler(t *testing.T) {
+	var s fakeSubscriber
+	c, err := NewCollector(t.Context(), Config{Groups: []GroupConfig{{ID: "A"}, {ID: "B"}}}, &s, slog.New(slog.DiscardHandler))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.publish("A", lightstreamer.Values{valuePtr("1")})
+
+	// serve the handler over HTTP/2 with prior knowledge, as main does without TLS
+	ctx, cancel := context.WithCancel(t.Context())
+	ts := httptest.NewUnstartedServer(c.GRPCHandler(ctx))
+	ts.Config.Protocols = new(http.Protocols)
+	ts.Config.Protocols.SetUnencryptedHTTP2(true)
+	ts.Start()
+	t.Cleanup(ts.Close)
+
+	conn, err := grpc.NewClient(ts.Listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	stream, err := telemetryv1.NewTelemetryClient(conn).Subscribe(t.Context(), &telemetryv1.SubscribeRequest{Groups: []string{"A"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the stream starts with the last update of the requested groups
+	u, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.GetGroup() != "A" || u.GetValue() != 1 {
+		t.Errorf("got %s=%v, want A=1", u.GetGroup(), u.GetValue())
+	}
+	s.publish("B", lightstreamer.Values{valuePtr("2")})
+	s.publish("A", lightstreamer.Values{valuePtr("3")})
+	if u, err = stream.Recv(); err != nil {
+		t.Fatal(err)
+	}
+	if u.GetGroup() != "A" || u.GetValue() != 3 {
+		t.Errorf("got %s=%v, want A=3", u.GetGroup(), u.GetValue())
+	}
+
+	// canceling ctx ends the stream
+	cancel()
+	if _, err = stream.Recv(); status.Code(err) != codes.Unavailable {
+		t.Errorf("got %v, want Unavailable", err)
+	}
+}
+
+func TestNewGRPCUpdate(t *testing.T) {
+	value := 24.0
+	timestamp := time.Date(2025, time.January, 1, 12, 0, 0, 500, time.UTC)
+	u := newGRPCUpdate(Update{Group: "A", Value: 1, Status: &value, Timestamp: timestamp}, 2)
+	if u.GetGroup() != "A" || u.GetValue() != 1 || u.GetLost() != 2 {
+		t.Errorf("unexpected update: %v", u)
+	}
+	if u.Status == nil || u.GetStatus() != 24 {
+		t.Errorf("got status %v, want 24", u.Status)
+	}
+	if !u.GetTimestamp().AsTime().Equal(timestamp) {
+		t.Errorf("got timestamp %v, want %v", u.GetTimestamp().AsTime(), timestamp)
+	}
+	if newGRPCUpdate(Update{Group: "A"}, 0).Status != nil {
+		t.Error("update without status shouldn't have a status")
+	}
+}
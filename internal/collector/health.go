@@ -0,0 +1,64 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"github.com/clambin/iss-exporter/internal/health"
+	"github.com/clambin/iss-exporter/lightstreamer"
+	"time"
+)
+
+// Health is the Collector's health report, returned by Details.
+type Health struct {
+	Session    lightstreamer.SessionState `json:"session"`
+	Reconnects int                        `json:"reconnects"`
+	Groups     map[string]GroupHealth     `json:"groups"`
+}
+
+// GroupHealth reports the state of a telemetry group. LastUpdateAgeSeconds is nil if the group hasn't been updated yet.
+type GroupHealth struct {
+	LastUpdate           time.Time `json:"last_update,omitzero"`
+	LastUpdateAgeSeconds *float64  `json:"last_update_age_seconds"`
+}
+
+// RegisterHealthChecks registers the Collector's health checks: the Lightstreamer session (required) and, if enabled,
+// the TLE and crew sources (optional). Call RegisterHealthChecks after EnableOrbit and EnableCrew.
+func (c *Collector) RegisterHealthChecks(r *health.Registry) {
+	r.Register("lightstreamer", c)
+	if c.tle != nil {
+		r.RegisterOptional("tle", &c.tle.status)
+	}
+	if c.crew != nil {
+		r.RegisterOptional("crew", &c.crew.status)
+	}
+}
+
+// Healthy returns an error if the Collector's session has no open stream connection.
+func (c *Collector) Healthy(context.Context) error {
+	if c.Subscriber.State().Connections == 0 {
+		return errors.New("no session")
+	}
+	return nil
+}
+
+// Details returns the Collector's Health.
+func (c *Collector) Details() any {
+	return c.health(time.Now())
+}
+
+func (c *Collector) health(now time.Time) Health {
+	h := Health{
+		Session:    c.Subscriber.State(),
+		Reconnects: c.Reconnects(),
+		Groups:     make(map[string]GroupHealth),
+	}
+	for id, updated := range c.LastUpdates() {
+		group := GroupHealth{LastUpdate: updated}
+		if !updated.IsZero() {
+			age := now.Sub(updated).Seconds()
+			group.LastUpdateAgeSeconds = &age
+		}
+		h.Groups[id] = group
+	}
+	return h
+}
@@ -0,0 +1,36 @@
+package collector
+
+import (
+	"github.com/clambin/iss-exporter/lightstreamer"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestCollector_Healthy(t *testing.T) {
+	var s fakeSubscriber
+	c := newCollector(Config{Groups: []GroupConfig{{ID: "A"}, {ID: "B"}}}, slog.New(slog.DiscardHandler))
+	c.Subscriber = &s
+	if err := c.Healthy(t.Context()); err == nil {
+		t.Error("Healthy() should fail without a session")
+	}
+	if err := c.connect(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Healthy(t.Context()); err != nil {
+		t.Errorf("Healthy() got %v", err)
+	}
+
+	now := time.Now()
+	c.signals[0].record(lightstreamer.Values{valuePtr("1")}, now.Add(-time.Minute))
+	h := c.health(now)
+	if h.Session.Connections != 1 || h.Reconnects != 0 || len(h.Groups) != 2 {
+		t.Fatalf("got %+v", h)
+	}
+	if age := h.Groups["A"].LastUpdateAgeSeconds; age == nil || *age != 60 {
+		t.Errorf("group A: got age %v, want 60", age)
+	}
+	if age := h.Groups["B"].LastUpdateAgeSeconds; age != nil {
+		t.Errorf("group B: got age %v, want none", *age)
+	}
+}
@@ -0,0 +1,149 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ring is a bounded buffer that keeps the most recent items added to it.
+type ring[T any] struct {
+	lock  sync.RWMutex
+	items []T
+	next  int
+	full  bool
+}
+
+func newRing[T any](size int) *ring[T] {
+	return &ring[T]{items: make([]T, size)}
+}
+
+// add adds an item, replacing the oldest one if the ring is full.
+func (r *ring[T]) add(item T) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.items[r.next] = item
+	if r.next = (r.next + 1) % len(r.items); r.next == 0 {
+		r.full = true
+	}
+}
+
+// all returns the items in the ring, oldest first.
+func (r *ring[T]) all() []T {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	if !r.full {
+		return append([]T(nil), r.items[:r.next]...)
+	}
+	return append(append(make([]T, 0, len(r.items)), r.items[r.next:]...), r.items[:r.next]...)
+}
+
+// LocationSample is the ISS location at a point in time.
+type LocationSample struct {
+	Timestamp time.Time `json:"timestamp"`
+	Latitude  float64   `json:"latitude"`
+	Longitude float64   `json:"longitude"`
+}
+
+// ValueSample is the value of a telemetry group at a point in time.
+type ValueSample struct {
+	Timestamp time.Time `json:"timestamp"`
+	Value     float64   `json:"value"`
+}
+
+// History is the recent history of the ISS location and of the telemetry groups, as reported by the history endpoint.
+type History struct {
+	Locations []LocationSample `json:"locations"`
+	// Telemetry maps the ID of each telemetry group to its values.
+	Telemetry map[string][]ValueSample `json:"telemetry"`
+}
+
+// history samples the ISS location and the telemetry values at a fixed interval.
+type history struct {
+	size      int
+	locations *ring[LocationSample]
+	lock      sync.RWMutex
+	telemetry map[string]*ring[ValueSample]
+}
+
+// values returns the history of a telemetry group, adding it if the group is new, e.g. after a Reload.
+func (h *history) values(id string) *ring[ValueSample] {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	values, ok := h.telemetry[id]
+	if !ok {
+		values = newRing[ValueSample](h.size)
+		h.telemetry[id] = values
+	}
+	return values
+}
+
+// EnableHistory keeps the ISS location and the value of each telemetry group, sampled every interval, for the last
+// window, until ctx is canceled. The history is served by HistoryHandler. Call EnableHistory before serving
+// HistoryHandler.
+//
+// An orbit takes about 92 minutes: a window of 2 hours, sampled every 30 seconds, covers the last orbit.
+func (c *Collector) EnableHistory(ctx context.Context, window time.Duration, interval time.Duration) {
+	size := max(1, int(window/interval))
+	c.history = &history{
+		size:      size,
+		locations: newRing[LocationSample](size),
+		telemetry: make(map[string]*ring[ValueSample]),
+	}
+	for _, s := range c.currentSignals() {
+		c.history.values(s.ID)
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				c.sample(now)
+			}
+		}
+	}()
+}
+
+// sample adds the current location and telemetry values to the history. Stale locations, and signals that don't
+// have a value, or haven't been updated for StaleAfter, are skipped.
+func (c *Collector) sample(now time.Time) {
+	if !c.position.stale(now, locationStaleAfter) {
+		if longitude, latitude, ok := c.position.location(); ok {
+			c.history.locations.add(LocationSample{Timestamp: now, Latitude: latitude, Longitude: longitude})
+		}
+	}
+	for _, s := range c.currentSignals() {
+		r := s.last()
+		if !r.hasValue || (c.StaleAfter > 0 && now.Sub(r.received) > c.StaleAfter) {
+			continue
+		}
+		c.history.values(s.ID).add(ValueSample{Timestamp: now, Value: r.value})
+	}
+}
+
+// HistoryHandler returns an http.Handler that reports the recent history of the ISS location and the telemetry
+// groups as JSON. If history isn't enabled, it returns 404 Not Found.
+func (c *Collector) HistoryHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if c.history == nil {
+			http.Error(w, "history not enabled", http.StatusNotFound)
+			return
+		}
+		h := History{
+			Locations: c.history.locations.all(),
+			Telemetry: make(map[string][]ValueSample),
+		}
+		c.history.lock.RLock()
+		for id, values := range c.history.telemetry {
+			h.Telemetry[id] = values.all()
+		}
+		c.history.lock.RUnlock()
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(h)
+	})
+}
@@ -0,0 +1,79 @@
+package collector
+
+import (
+	"encoding/json"
+	"github.com/clambin/iss-exporter/lightstreamer"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRing(t *testing.T) {
+	r := newRing[int](3)
+	tests := []struct {
+		add  int
+		want []int
+	}{
+		{add: 1, want: []int{1}},
+		{add: 2, want: []int{1, 2}},
+		{add: 3, want: []int{1, 2, 3}},
+		{add: 4, want: []int{2, 3, 4}},
+		{add: 5, want: []int{3, 4, 5}},
+	}
+	if got := r.all(); len(got) != 0 {
+		t.Errorf("got %v, want empty", got)
+	}
+	for _, tt := range tests {
+		r.add(tt.add)
+		if got := r.all(); !slices.Equal(got, tt.want) {
+			t.Errorf("add(%d): got %v, want %v", tt.add, got, tt.want)
+		}
+	}
+}
+
+func TestCollector_HistoryHandler(t *testing.T) {
+	c := newCollector(Config{Groups: []GroupConfig{{ID: "A"}, {ID: "B"}}}, slog.New(slog.DiscardHandler))
+	h := c.HistoryHandler()
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/history", nil))
+	if resp.Code != http.StatusNotFound {
+		t.Errorf("got %d without history, want %d", resp.Code, http.StatusNotFound)
+	}
+
+	c.EnableHistory(t.Context(), time.Hour, 30*time.Minute)
+	now := time.Now()
+	for i := range 3 {
+		now = now.Add(time.Minute)
+		for axis, value := range []float64{6778, 0, 0} {
+			c.position.update(axis, value, now)
+		}
+		c.signals[0].update(lightstreamer.Values{valuePtr(string(rune('1' + i)))}, now)
+		c.sample(now)
+	}
+
+	resp = httptest.NewRecorder()
+	h.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/history", nil))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("got %d, want %d", resp.Code, http.StatusOK)
+	}
+	var history History
+	if err := json.NewDecoder(resp.Body).Decode(&history); err != nil {
+		t.Fatal(err)
+	}
+	if len(history.Locations) != 2 {
+		t.Errorf("got %d locations, want 2", len(history.Locations))
+	}
+	var values []float64
+	for _, sample := range history.Telemetry["A"] {
+		values = append(values, sample.Value)
+	}
+	if want := []float64{2, 3}; !slices.Equal(values, want) {
+		t.Errorf("got values %v, want %v", values, want)
+	}
+	if got := history.Telemetry["B"]; len(got) != 0 {
+		t.Errorf("got %d values for B, want 0", len(got))
+	}
+}
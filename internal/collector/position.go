@@ -0,0 +1,74 @@
+package collector
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// positionGroups are the ISSLIVE groups with the ISS position, in km, in the J2000 (ECI) frame.
+var positionGroups = [3]string{
+	"USLAB000032", // X
+	"USLAB000033", // Y
+	"USLAB000034", // Z
+}
+
+// position caches the ISS state vector, as received from Lightstreamer.
+type position struct {
+	lock    sync.RWMutex
+	xyz     [3]float64
+	set     [3]bool
+	updated time.Time
+}
+
+// update sets the position along one axis (0: X, 1: Y, 2: Z).
+func (p *position) update(axis int, value float64, t time.Time) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.xyz[axis] = value
+	p.set[axis] = true
+	p.updated = t
+}
+
+// stale returns true if the position isn't known, or hasn't been updated for longer than maxAge.
+func (p *position) stale(now time.Time, maxAge time.Duration) bool {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.updated.IsZero() || now.Sub(p.updated) > maxAge
+}
+
+// location returns the ISS longitude and latitude, in degrees. ok is false if the position isn't known yet.
+func (p *position) location() (longitude float64, latitude float64, ok bool) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	if !p.set[0] || !p.set[1] || !p.set[2] {
+		return 0, 0, false
+	}
+	longitude, latitude = geocentric(p.xyz, p.updated)
+	return longitude, latitude, true
+}
+
+// vector returns the ISS position in the J2000 frame, and the time it was last updated. ok is false if the position
+// isn't known yet.
+func (p *position) vector() (xyz [3]float64, updated time.Time, ok bool) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.xyz, p.updated, p.set[0] && p.set[1] && p.set[2]
+}
+
+// geocentric converts a position in the J2000 frame at time t to geocentric longitude and latitude, in degrees.
+// It ignores precession and nutation, and uses a spherical Earth, which is accurate enough to put the ISS on a map.
+func geocentric(xyz [3]float64, t time.Time) (longitude float64, latitude float64) {
+	latitude = math.Atan2(xyz[2], math.Hypot(xyz[0], xyz[1])) * 180 / math.Pi
+	longitude = math.Atan2(xyz[1], xyz[0])*180/math.Pi - gmst(t)
+	longitude = math.Mod(longitude+540, 360) - 180
+	return longitude, latitude
+}
+
+var j2000 = time.Date(2000, time.January, 1, 12, 0, 0, 0, time.UTC)
+
+// gmst returns the Greenwich Mean Sidereal Time at t, in degrees.
+func gmst(t time.Time) float64 {
+	days := t.Sub(j2000).Hours() / 24
+	return math.Mod(280.46061837+360.98564736629*days, 360)
+}
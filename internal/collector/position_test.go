@@ -0,0 +1,65 @@
+package collector
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestPosition(t *testing.T) {
+	var p position
+	if _, _, ok := p.location(); ok {
+		t.Fatal("location() should fail without a position")
+	}
+	if !p.stale(j2000, time.Minute) {
+		t.Error("stale() should be true without a position")
+	}
+	p.update(0, 6778, j2000)
+	p.update(1, 0, j2000)
+	if _, _, ok := p.location(); ok {
+		t.Fatal("location() should fail with an incomplete position")
+	}
+	p.update(2, 0, j2000)
+	lon, lat, ok := p.location()
+	if !ok {
+		t.Fatal("location() failed")
+	}
+	if !near(lon, 79.53938163) || !near(lat, 0) {
+		t.Errorf("location() got (%v, %v)", lon, lat)
+	}
+	if p.stale(j2000.Add(time.Minute), time.Minute) {
+		t.Error("stale() should be false for a recent position")
+	}
+	if !p.stale(j2000.Add(time.Minute+time.Second), time.Minute) {
+		t.Error("stale() should be true for an old position")
+	}
+}
+
+func TestGeocentric(t *testing.T) {
+	// at this time, GMST is zero: longitude equals right ascension
+	t0 := j2000.Add(-time.Duration(gmst(j2000) / 360.98564736629 * float64(24*time.Hour)))
+	tests := []struct {
+		name    string
+		xyz     [3]float64
+		wantLon float64
+		wantLat float64
+	}{
+		{name: "greenwich", xyz: [3]float64{6778, 0, 0}, wantLon: 0, wantLat: 0},
+		{name: "east", xyz: [3]float64{0, 6778, 0}, wantLon: 90, wantLat: 0},
+		{name: "west", xyz: [3]float64{0, -6778, 0}, wantLon: -90, wantLat: 0},
+		{name: "north", xyz: [3]float64{4000, 0, 4000}, wantLon: 0, wantLat: 45},
+		{name: "south", xyz: [3]float64{-4000, 0, -4000}, wantLon: 180, wantLat: -45},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lon, lat := geocentric(tt.xyz, t0)
+			if !near(math.Mod(lon-tt.wantLon+540, 360), 180) || !near(lat, tt.wantLat) {
+				t.Errorf("geocentric() got (%v, %v), want (%v, %v)", lon, lat, tt.wantLon, tt.wantLat)
+			}
+		})
+	}
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-3
+}
@@ -0,0 +1,65 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"maps"
+	"slices"
+	"time"
+)
+
+var (
+	solarArrayPowerMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("iss", "solar_array", "power_watts"),
+		"power generated by a solar array wing, derived from its voltage and current",
+		[]string{"array"},
+		nil,
+	)
+
+	solarPowerMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("iss", "solar", "power_watts"),
+		"total power generated by the solar array wings with known voltage and current",
+		nil,
+		nil,
+	)
+)
+
+// solarArrays maps each solar array wing to the catalog groups with its voltage and current.
+var solarArrays = map[string][2]string{
+	"1A": {"S4000001", "S4000002"},
+	"3A": {"S4000004", "S4000005"},
+	"2A": {"P4000001", "P4000002"},
+	"4A": {"P4000004", "P4000005"},
+	"3B": {"S6000001", "S6000002"},
+	"1B": {"S6000004", "S6000005"},
+	"4B": {"P6000001", "P6000002"},
+	"2B": {"P6000004", "P6000005"},
+}
+
+// collectPower collects the power generated by each solar array wing whose voltage and current are subscribed to
+// and known, and their total. Stale readings are skipped.
+func (c *Collector) collectPower(ch chan<- prometheus.Metric, now time.Time) {
+	signals := c.currentSignals()
+	readings := make(map[string]float64, len(signals))
+	for _, s := range signals {
+		r := s.last()
+		if r.hasValue && (c.StaleAfter == 0 || now.Sub(r.received) <= c.StaleAfter) {
+			readings[s.ID] = r.value
+		}
+	}
+	var total float64
+	var found bool
+	for _, array := range slices.Sorted(maps.Keys(solarArrays)) {
+		groups := solarArrays[array]
+		voltage, ok1 := readings[groups[0]]
+		current, ok2 := readings[groups[1]]
+		if !ok1 || !ok2 {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(solarArrayPowerMetric, prometheus.GaugeValue, voltage*current, array)
+		total += voltage * current
+		found = true
+	}
+	if found {
+		ch <- prometheus.MustNewConstMetric(solarPowerMetric, prometheus.GaugeValue, total)
+	}
+}
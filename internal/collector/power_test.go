@@ -0,0 +1,65 @@
+package collector
+
+import (
+	"github.com/clambin/iss-exporter/lightstreamer"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSolarArrays(t *testing.T) {
+	for array, groups := range solarArrays {
+		for i, unit := range []string{"volts", "amperes"} {
+			entry, ok := catalog[groups[i]]
+			if !ok || entry.Subsystem != "EPS" || entry.Unit != unit || !strings.Contains(entry.Metric, strings.ToLower(array)) {
+				t.Errorf("%s: group %s is not the array's %s: %+v", array, groups[i], unit, entry)
+			}
+		}
+	}
+}
+
+func TestCollector_collectPower(t *testing.T) {
+	c := newCollector(Config{Subsystems: []string{"EPS"}}, slog.New(slog.DiscardHandler))
+	now := time.Now()
+	for _, s := range c.signals {
+		switch s.ID {
+		case "S4000001", "P4000001":
+			s.update(lightstreamer.Values{valuePtr("160")}, now)
+		case "S4000002", "P4000002":
+			s.update(lightstreamer.Values{valuePtr("50")}, now)
+		case "S6000001":
+			// no current: not exported
+			s.update(lightstreamer.Values{valuePtr("160")}, now)
+		}
+	}
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		c.collectPower(ch, now)
+		close(ch)
+	}()
+	got := make(map[string]float64)
+	for metric := range ch {
+		var m dto.Metric
+		if err := metric.Write(&m); err != nil {
+			t.Fatal(err)
+		}
+		key := "total"
+		if len(m.GetLabel()) > 0 {
+			key = m.GetLabel()[0].GetValue()
+		}
+		got[key] = m.GetGauge().GetValue()
+	}
+	want := map[string]float64{"1A": 8000, "2A": 8000, "total": 16000}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("%s: got %v, want %v", key, got[key], value)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"slices"
+)
+
+// Reload applies a new configuration, without re-establishing the Lightstreamer session: it subscribes to the groups
+// that were added, stops exporting the groups that were removed, and applies the new settings (metric names, states,
+// alert rules, etc.) of all other groups. Their last reading is kept. cfg must be valid.
+//
+// Reload can't change the server: it returns an error if cfg configures a different one. Groups that were removed
+// remain subscribed, and a group's new MaxFrequency isn't applied, until the session is re-established.
+func (c *Collector) Reload(ctx context.Context, cfg Config) error {
+	if !c.server.equal(cfg.Server) {
+		return errors.New("server configuration can't be reloaded")
+	}
+
+	signals := newSignals(cfg)
+	alerts := newAlerter(cfg, c.Logger)
+	c.lock.Lock()
+	for _, s := range signals {
+		if i := slices.IndexFunc(c.signals, func(old *signal) bool { return old.ID == s.ID }); i >= 0 {
+			s.reading = c.signals[i].last()
+		}
+	}
+	c.signals = signals
+	// keep the alert states if the webhook didn't change, so that firing alerts are resolved
+	if alerts == nil || c.alerts == nil || alerts.url != c.alerts.url || alerts.cooldown != c.alerts.cooldown {
+		c.alerts = alerts
+	}
+	c.lock.Unlock()
+
+	c.subscribeLock.Lock()
+	defer c.subscribeLock.Unlock()
+	if c.subscribed == nil {
+		// not connected yet: connect subscribes to all groups
+		return nil
+	}
+	var errs []error
+	for _, s := range signals {
+		if !c.subscribed[s.ID] {
+			errs = append(errs, c.subscribe(ctx, s))
+		}
+	}
+	return errors.Join(errs...)
+}
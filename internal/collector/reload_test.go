@@ -0,0 +1,95 @@
+package collector
+
+import (
+	"github.com/clambin/iss-exporter/lightstreamer"
+	"log/slog"
+	"testing"
+)
+
+func TestCollector_Reload(t *testing.T) {
+	var s fakeSubscriber
+	c, err := NewCollector(t.Context(), Config{Groups: []GroupConfig{{ID: "A"}, {ID: "B"}}}, &s, slog.New(slog.DiscardHandler))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.publish("A", lightstreamer.Values{valuePtr("1")})
+	s.publish("B", lightstreamer.Values{valuePtr("2")})
+
+	// remove B, add C, and give A a metric name
+	if err = c.Reload(t.Context(), Config{Groups: []GroupConfig{{ID: "A", Metric: "a"}, {ID: "C"}}}); err != nil {
+		t.Fatal(err)
+	}
+	signals := c.currentSignals()
+	if len(signals) != 2 || signals[0].ID != "A" || signals[1].ID != "C" {
+		t.Fatalf("unexpected signals after reload: %v", signals)
+	}
+	if r := signals[0].last(); !r.hasValue || r.value != 1 {
+		t.Errorf("A: reading not kept: %+v", r)
+	}
+	if got := signals[0].metricName(); got != "iss_a" {
+		t.Errorf("A: got metric %q, want iss_a", got)
+	}
+
+	// C is subscribed; updates for B are ignored
+	if !s.publish("C", lightstreamer.Values{valuePtr("3")}) {
+		t.Fatal("C not subscribed")
+	}
+	if r := signals[1].last(); r.value != 3 {
+		t.Errorf("C: got %v, want 3", r.value)
+	}
+	s.publish("B", lightstreamer.Values{valuePtr("4")})
+	if _, _, ok := c.lookup("B"); ok {
+		t.Error("B should no longer be configured")
+	}
+
+	// updates for A go to its new signal
+	s.publish("A", lightstreamer.Values{valuePtr("5")})
+	if r := c.currentSignals()[0].last(); r.value != 5 {
+		t.Errorf("A: got %v, want 5", r.value)
+	}
+}
+
+func TestCollector_Reload_Server(t *testing.T) {
+	var s fakeSubscriber
+	c, err := NewCollector(t.Context(), Config{Groups: []GroupConfig{{ID: "A"}}}, &s, slog.New(slog.DiscardHandler))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Reload(t.Context(), Config{Server: ServerConfig{AdapterSet: "ISSLIVE", DataAdapter: "DEFAULT"}, Groups: []GroupConfig{{ID: "A"}}}); err != nil {
+		t.Errorf("explicit defaults: unexpected error: %v", err)
+	}
+	if err = c.Reload(t.Context(), Config{Server: ServerConfig{AdapterSet: "OTHER"}, Groups: []GroupConfig{{ID: "A"}}}); err == nil {
+		t.Error("expected an error when changing the server")
+	}
+}
+
+func TestCollector_Reload_Alerts(t *testing.T) {
+	below := 10.0
+	cfg := Config{
+		Groups: []GroupConfig{{ID: "A", Alert: &AlertRule{Below: &below}}},
+		Alerts: AlertConfig{WebhookURL: "http://localhost/hook"},
+	}
+	c := newCollector(cfg, slog.New(slog.DiscardHandler))
+	alerts := c.alerts
+
+	if err := c.Reload(t.Context(), cfg); err != nil {
+		t.Fatal(err)
+	}
+	if c.alerts != alerts {
+		t.Error("alerter should be kept if the webhook didn't change")
+	}
+	cfg.Alerts.Cooldown = "1m"
+	if err := c.Reload(t.Context(), cfg); err != nil {
+		t.Fatal(err)
+	}
+	if c.alerts == alerts {
+		t.Error("alerter should be replaced if the webhook changed")
+	}
+	cfg.Alerts = AlertConfig{}
+	if err := c.Reload(t.Context(), cfg); err != nil {
+		t.Fatal(err)
+	}
+	if c.alerts != nil {
+		t.Error("alerter should be removed")
+	}
+}
@@ -0,0 +1,131 @@
+package collector
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"github.com/clambin/iss-exporter/lightstreamer"
+	"slices"
+)
+
+// issAdapterSet is the adapter set of the ISS telemetry, on the public Lightstreamer server.
+const issAdapterSet = "ISSLIVE"
+
+// ServerConfig configures the Lightstreamer server to subscribe to. The zero value subscribes to the ISS telemetry
+// (ISSLIVE), on the public Lightstreamer server.
+//
+// Other servers are supported as long as each group is an item with a numeric (or mapped) value field. The ISS
+// position and signal status are only exported for ISSLIVE.
+type ServerConfig struct {
+	// URL is the URL of the Lightstreamer server. Defaults to the public Lightstreamer server.
+	URL string `json:"url,omitempty"`
+	// AdapterSet is the adapter set to connect to. Defaults to "ISSLIVE".
+	AdapterSet string `json:"adapter_set,omitempty"`
+	// CID is the client ID sent to the server. Defaults to the ISSLIVE client ID.
+	CID string `json:"cid,omitempty"`
+	// DataAdapter is the data adapter of the groups. Defaults to "DEFAULT".
+	DataAdapter string `json:"data_adapter,omitempty"`
+	// Schema lists the fields to subscribe to. Defaults to "Value", "Status.Class" and "TimeStamp".
+	Schema []string `json:"schema,omitempty"`
+	// ValueField, StatusField and TimestampField name the fields of Schema with the value of a group, its status
+	// and the time of the reading, in hours since the start of the year (UTC). Only ValueField is required.
+	// They default to "Value", "Status.Class" and "TimeStamp".
+	ValueField     string `json:"value_field,omitempty"`
+	StatusField    string `json:"status_field,omitempty"`
+	TimestampField string `json:"timestamp_field,omitempty"`
+	// MaxItems caps the number of items of which each subscription keeps the last values, to decode updates. The least
+	// recently updated items are evicted. Zero, the default, keeps all items. See lightstreamer.WithMaxItems.
+	MaxItems int `json:"max_items,omitempty"`
+}
+
+// iss returns true if the server is the ISS telemetry feed.
+func (s ServerConfig) iss() bool {
+	return s.adapterSet() == issAdapterSet
+}
+
+func (s ServerConfig) adapterSet() string {
+	return cmp.Or(s.AdapterSet, issAdapterSet)
+}
+
+func (s ServerConfig) dataAdapter() string {
+	return cmp.Or(s.DataAdapter, "DEFAULT")
+}
+
+func (s ServerConfig) schema() []string {
+	if len(s.Schema) == 0 {
+		return schema
+	}
+	return s.Schema
+}
+
+// fields returns the names of the value, status and timestamp fields in the server's schema.
+func (s ServerConfig) fields() []string {
+	return []string{
+		cmp.Or(s.ValueField, schema[0]),
+		cmp.Or(s.StatusField, schema[1]),
+		cmp.Or(s.TimestampField, schema[2]),
+	}
+}
+
+// validate checks that the value field is part of the schema.
+func (s ServerConfig) validate() error {
+	if len(s.Schema) > 0 && slices.Contains(s.Schema, "") {
+		return errors.New("schema has an empty field")
+	}
+	if value := s.fields()[0]; !slices.Contains(s.schema(), value) {
+		return fmt.Errorf("value field %q not in schema", value)
+	}
+	if s.MaxItems < 0 {
+		return errors.New("max_items can't be negative")
+	}
+	return nil
+}
+
+// equal returns true if o configures the same server, with the same schema.
+func (s ServerConfig) equal(o ServerConfig) bool {
+	return s.URL == o.URL &&
+		s.adapterSet() == o.adapterSet() &&
+		s.CID == o.CID &&
+		s.dataAdapter() == o.dataAdapter() &&
+		slices.Equal(s.schema(), o.schema()) &&
+		slices.Equal(s.fields(), o.fields()) &&
+		s.MaxItems == o.MaxItems
+}
+
+// Options returns the options to create a ClientSession for the server.
+func (s ServerConfig) Options() []lightstreamer.ClientSessionOption {
+	options := []lightstreamer.ClientSessionOption{lightstreamer.WithAdapterSet(s.adapterSet())}
+	if s.URL != "" {
+		options = append(options, lightstreamer.WithServerURL(s.URL))
+	}
+	if s.CID != "" {
+		options = append(options, lightstreamer.WithCID(s.CID))
+	}
+	if s.MaxItems > 0 {
+		options = append(options, lightstreamer.WithMaxItems(s.MaxItems))
+	}
+	return options
+}
+
+// remap returns a function that converts an update in the server's schema to the ISSLIVE schema, so that all updates
+// can be processed the same way. Fields that aren't in the server's schema are nil. If the server uses the ISSLIVE
+// schema, updates are passed unchanged.
+func (s ServerConfig) remap() func(lightstreamer.Values) lightstreamer.Values {
+	serverSchema, fields := s.schema(), s.fields()
+	if slices.Equal(serverSchema, schema) && slices.Equal(fields, schema) {
+		return func(values lightstreamer.Values) lightstreamer.Values { return values }
+	}
+	indices := make([]int, len(fields))
+	for i, field := range fields {
+		indices[i] = slices.Index(serverSchema, field)
+	}
+	return func(values lightstreamer.Values) lightstreamer.Values {
+		remapped := make(lightstreamer.Values, len(indices))
+		for i, index := range indices {
+			if index >= 0 && index < len(values) {
+				remapped[i] = values[index]
+			}
+		}
+		return remapped
+	}
+}
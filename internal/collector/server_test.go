@@ -0,0 +1,91 @@
+package collector
+
+import (
+	"github.com/clambin/iss-exporter/lightstreamer"
+	"log/slog"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServerConfig_validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		server  ServerConfig
+		wantErr bool
+	}{
+		{name: "default"},
+		{name: "custom", server: ServerConfig{Schema: []string{"last", "time"}, ValueField: "last"}},
+		{name: "value not in schema", server: ServerConfig{Schema: []string{"last", "time"}}, wantErr: true},
+		{name: "empty field", server: ServerConfig{Schema: []string{"Value", ""}}, wantErr: true},
+		{name: "max items", server: ServerConfig{MaxItems: 100}},
+		{name: "negative max items", server: ServerConfig{MaxItems: -1}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.server.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestServerConfig_remap(t *testing.T) {
+	tests := []struct {
+		name   string
+		server ServerConfig
+		values lightstreamer.Values
+		want   string
+	}{
+		{name: "iss", values: lightstreamer.Values{valuePtr("1"), valuePtr("24"), valuePtr("2")}, want: "1,24,2"},
+		{
+			name:   "custom",
+			server: ServerConfig{Schema: []string{"time", "last"}, ValueField: "last"},
+			values: lightstreamer.Values{valuePtr("12:00"), valuePtr("42")},
+			want:   "42,<nil>,<nil>",
+		},
+		{
+			name:   "reordered",
+			server: ServerConfig{Schema: []string{"TimeStamp", "Value", "Status.Class"}},
+			values: lightstreamer.Values{valuePtr("2"), valuePtr("1"), valuePtr("24")},
+			want:   "1,24,2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.server.remap()(tt.values).String(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCollector_connect_Generic(t *testing.T) {
+	cfg := Config{
+		Server: ServerConfig{AdapterSet: "STOCKS", DataAdapter: "QUOTES", Schema: []string{"stock_name", "last_price"}, ValueField: "last_price"},
+		Groups: []GroupConfig{{ID: "item1", Metric: "stock_price"}},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	s := lightstreamer.NewServer("STOCKS", "cid", map[string]lightstreamer.AdapterSet{
+		"QUOTES": {"item1": lightstreamer.InjectAdapter{Name: "item1", Fields: 2}},
+	}, slog.New(slog.DiscardHandler))
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+
+	cfg.Server.URL, cfg.Server.CID = ts.URL, "cid"
+	c := newCollector(cfg, slog.New(slog.DiscardHandler))
+	session := lightstreamer.NewClientSession(append(cfg.Server.Options(), lightstreamer.WithHTTPClient(ts.Client()))...)
+	c.Subscriber = session
+	// the ISS position and signal status aren't subscribed to: the server would reject them
+	if err := c.connect(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(session.Disconnect)
+
+	eventually(t, func() bool {
+		s.Publish("STOCKS", "QUOTES", "item1", 1, lightstreamer.Values{valuePtr("Anduct"), valuePtr("3.04")})
+		r := c.signals[0].last()
+		return r.hasValue && r.value == 3.04
+	})
+}
@@ -0,0 +1,107 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"github.com/clambin/iss-exporter/lightstreamer"
+	"github.com/prometheus/client_golang/prometheus"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+)
+
+var _ Subscriber = &lightstreamer.ClientSession{}
+
+// fakeSubscriber is a Subscriber that doesn't connect to a server. Updates are injected with publish.
+type fakeSubscriber struct {
+	lock          sync.Mutex
+	state         lightstreamer.SessionState
+	done          chan struct{}
+	subscriptions map[string]func(int, lightstreamer.Values)
+	// reject lists the groups that can't be subscribed to.
+	reject map[string]bool
+}
+
+func (f *fakeSubscriber) ConnectWithSession(_ context.Context, _ time.Duration) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.state.Connections = 1
+	f.done = make(chan struct{})
+	f.subscriptions = make(map[string]func(int, lightstreamer.Values))
+	return nil
+}
+
+func (f *fakeSubscriber) Subscribe(_ context.Context, _ string, group string, _ []string, _ float64, fn func(int, lightstreamer.Values)) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.reject[group] {
+		return errors.New("invalid group")
+	}
+	f.subscriptions[group] = fn
+	return nil
+}
+
+func (f *fakeSubscriber) State() lightstreamer.SessionState {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.state
+}
+
+func (f *fakeSubscriber) Done() <-chan struct{} {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.done
+}
+
+// publish sends an update for a group. It returns false if the group isn't subscribed.
+func (f *fakeSubscriber) publish(group string, values lightstreamer.Values) bool {
+	f.lock.Lock()
+	fn, ok := f.subscriptions[group]
+	f.lock.Unlock()
+	if ok {
+		fn(1, values)
+	}
+	return ok
+}
+
+func TestNewCollector(t *testing.T) {
+	var s fakeSubscriber
+	c, err := NewCollector(t.Context(), Config{Groups: []GroupConfig{{ID: "USLAB000058"}}}, &s, slog.New(slog.DiscardHandler))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, group := range append([]string{"USLAB000058", aosGroup}, positionGroups[:]...) {
+		if !s.publish(group, lightstreamer.Values{valuePtr("760"), valuePtr("24"), valuePtr("1")}) {
+			t.Errorf("%s: not subscribed", group)
+		}
+	}
+	if r := c.signals[0].last(); r.value != 760 {
+		t.Errorf("got value %v, want 760", r.value)
+	}
+
+	r := prometheus.NewPedanticRegistry()
+	if err = r.Register(c); err != nil {
+		t.Fatal(err)
+	}
+	metrics, err := r.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make(map[string]bool)
+	for _, m := range metrics {
+		names[m.GetName()] = true
+	}
+	for _, want := range []string{"iss_cabin_pressure_mmhg", "iss_signal_acquired", "iss_latitude_degrees", "iss_lightstreamer_connection_count", "iss_lightstreamer_cached_items"} {
+		if !names[want] {
+			t.Errorf("missing metric %s", want)
+		}
+	}
+}
+
+func TestNewCollector_Failure(t *testing.T) {
+	s := fakeSubscriber{reject: map[string]bool{aosGroup: true}}
+	if _, err := NewCollector(t.Context(), DefaultConfig, &s, slog.New(slog.DiscardHandler)); err == nil {
+		t.Error("expected error")
+	}
+}
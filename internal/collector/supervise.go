@@ -0,0 +1,49 @@
+package collector
+
+import (
+	"context"
+	"time"
+)
+
+// reconnectInterval is how often the Collector tries to establish a new Lightstreamer session, once the session is lost.
+const reconnectInterval = 15 * time.Second
+
+// supervise establishes a new Lightstreamer session, and subscribes again, when the session ends, until ctx is
+// canceled. If the new session can't be established, supervise tries again every retry.
+func (c *Collector) supervise(ctx context.Context, retry time.Duration) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.Subscriber.Done():
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		c.Logger.Warn("lightstreamer session lost. reconnecting")
+		c.emit(EventSessionLost, "Lightstreamer session lost")
+		if !c.reconnect(ctx, retry) {
+			return
+		}
+		c.Logger.Info("lightstreamer session re-established")
+		c.emit(EventSessionEstablished, "Lightstreamer session re-established")
+	}
+}
+
+// reconnect establishes a new Lightstreamer session and subscribes again, trying every retry until it succeeds. It
+// returns false if ctx is canceled first.
+func (c *Collector) reconnect(ctx context.Context, retry time.Duration) bool {
+	for {
+		c.reconnects.Inc()
+		err := c.connect(ctx)
+		if err == nil {
+			return true
+		}
+		c.Logger.Error("failed to reconnect", "err", err)
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(retry):
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package collector
+
+import (
+	"github.com/clambin/iss-exporter/lightstreamer"
+	"log/slog"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCollector_supervise(t *testing.T) {
+	cfg := Config{Groups: []GroupConfig{{ID: "A"}}}
+	dataAdapter := make(lightstreamer.AdapterSet)
+	for _, group := range append([]string{"A", aosGroup}, positionGroups[:]...) {
+		dataAdapter[group] = lightstreamer.InjectAdapter{Name: group, Fields: len(schema)}
+	}
+	s := lightstreamer.NewServer("ISSLIVE", "cid", map[string]lightstreamer.AdapterSet{"DEFAULT": dataAdapter}, slog.New(slog.DiscardHandler))
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+
+	c := newCollector(cfg, slog.New(slog.DiscardHandler))
+	session := lightstreamer.NewClientSession(
+		lightstreamer.WithServerURL(ts.URL),
+		lightstreamer.WithHTTPClient(ts.Client()),
+		lightstreamer.WithAdapterSet("ISSLIVE"),
+		lightstreamer.WithCID("cid"),
+	)
+	c.Subscriber = session
+	if err := c.connect(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(session.Disconnect)
+	events := make(chan EventType, 10)
+	c.OnEvent(func(e Event) { events <- e.Type })
+	go c.supervise(t.Context(), 10*time.Millisecond)
+
+	// lose the session
+	ts.CloseClientConnections()
+	eventually(t, func() bool {
+		return c.Reconnects() > 0 && session.Connections.Load() > 0
+	})
+
+	// the new session is subscribed again
+	eventually(t, func() bool {
+		s.Publish("ISSLIVE", "DEFAULT", "A", 1, lightstreamer.Values{valuePtr("42"), valuePtr("24"), valuePtr("0")})
+		r := c.signals[0].last()
+		return r.hasValue && r.value == 42
+	})
+	for _, want := range []EventType{EventSessionLost, EventSessionEstablished} {
+		if got := <-events; got != want {
+			t.Errorf("got event %q, want %q", got, want)
+		}
+	}
+}
+
+func eventually(t *testing.T, f func() bool) {
+	t.Helper()
+	start := time.Now()
+	for !f() {
+		if time.Since(start) > 5*time.Second {
+			t.Fatal("timeout waiting for condition")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
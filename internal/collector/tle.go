@@ -0,0 +1,140 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"github.com/clambin/iss-exporter/internal/orbit"
+	"github.com/prometheus/client_golang/prometheus"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultTLEURL returns the current TLE of the ISS, from Celestrak.
+const DefaultTLEURL = "https://celestrak.org/NORAD/elements/gp.php?CATNR=25544&FORMAT=TLE"
+
+const (
+	// tleRefresh is how often the TLE is refreshed. Celestrak updates it a few times a day.
+	tleRefresh = 6 * time.Hour
+	// tleRetry is how long to wait before retrying a failed fetch.
+	tleRetry = time.Minute
+	// nextPassHorizon is how far ahead to look for the next pass.
+	nextPassHorizon = 24 * time.Hour
+	// nextPassElevation is the minimum elevation, in degrees, of a pass.
+	nextPassElevation = 10
+)
+
+var (
+	altitudeMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("iss", "", "altitude_kilometers"),
+		"ISS altitude, propagated from its TLE",
+		nil,
+		nil,
+	)
+
+	velocityMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("iss", "", "velocity_kms"),
+		"ISS velocity, in km/s, propagated from its TLE",
+		nil,
+		nil,
+	)
+
+	nextPassMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("iss", "", "next_pass_seconds"),
+		"time until the ISS is next visible from the observer location, 0 if it's visible now",
+		nil,
+		nil,
+	)
+
+	tleEpochMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("iss", "tle", "epoch_timestamp_seconds"),
+		"epoch of the TLE used to propagate the ISS orbit",
+		nil,
+		nil,
+	)
+)
+
+// tleSource periodically fetches the ISS TLE and exports orbital metrics propagated from it.
+type tleSource struct {
+	url        string
+	httpClient *http.Client
+	logger     *slog.Logger
+	// observer is the location for which to predict passes. If nil, no passes are predicted.
+	observer   *orbit.Location
+	propagator atomic.Pointer[orbit.Propagator]
+	status     fetchStatus
+}
+
+// run fetches the TLE, and refreshes it, until ctx is canceled. If a fetch fails, the previous TLE is kept.
+func (s *tleSource) run(ctx context.Context, refresh time.Duration, retry time.Duration) {
+	for {
+		wait := refresh
+		err := s.fetch(ctx)
+		s.status.record(err, time.Now())
+		if err != nil {
+			s.logger.Warn("failed to fetch TLE", "err", err)
+			wait = retry
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (s *tleSource) fetch(ctx context.Context) error {
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return errors.New(resp.Status)
+	}
+	tle, err := orbit.ReadTLE(resp.Body)
+	if err != nil {
+		return err
+	}
+	p, err := orbit.NewPropagator(tle)
+	if err != nil {
+		return err
+	}
+	s.propagator.Store(p)
+	s.logger.Debug("TLE updated", "name", tle.Name, "epoch", tle.Epoch)
+	return nil
+}
+
+func (s *tleSource) Describe(ch chan<- *prometheus.Desc) {
+	ch <- altitudeMetric
+	ch <- velocityMetric
+	ch <- nextPassMetric
+	ch <- tleEpochMetric
+}
+
+func (s *tleSource) Collect(ch chan<- prometheus.Metric) {
+	s.collect(ch, time.Now())
+}
+
+func (s *tleSource) collect(ch chan<- prometheus.Metric, now time.Time) {
+	p := s.propagator.Load()
+	if p == nil {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(tleEpochMetric, prometheus.GaugeValue, float64(p.TLE().Epoch.Unix()))
+	position, velocity, err := p.At(now)
+	if err != nil {
+		s.logger.Warn("failed to propagate orbit", "err", err)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(altitudeMetric, prometheus.GaugeValue, orbit.Geodetic(position, now).Altitude)
+	ch <- prometheus.MustNewConstMetric(velocityMetric, prometheus.GaugeValue, velocity.Norm())
+	if s.observer == nil {
+		return
+	}
+	if pass, ok := p.NextPass(*s.observer, now, nextPassHorizon, nextPassElevation); ok {
+		ch <- prometheus.MustNewConstMetric(nextPassMetric, prometheus.GaugeValue, pass.Sub(now).Seconds())
+	}
+}
@@ -0,0 +1,77 @@
+package collector
+
+import (
+	"github.com/clambin/iss-exporter/internal/orbit"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const (
+	testTLE1 = "1 88888U          80275.98708465  .00073094  13844-3  66816-4 0    8"
+	testTLE2 = "2 88888  72.8435 115.9689 0086731  52.6988 110.5714 16.05824518  105"
+)
+
+func TestTLESource(t *testing.T) {
+	var fail bool
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if fail {
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("TEST\r\n" + testTLE1 + "\r\n" + testTLE2 + "\r\n"))
+	}))
+	t.Cleanup(ts.Close)
+
+	s := tleSource{
+		url:        ts.URL,
+		httpClient: ts.Client(),
+		logger:     slog.New(slog.DiscardHandler),
+		observer:   &orbit.Location{Latitude: 50.85, Longitude: 4.35},
+	}
+	if got := collectTLE(&s, time.Now()); len(got) != 0 {
+		t.Errorf("got %d metrics without a TLE, want 0", len(got))
+	}
+	if err := s.fetch(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	// a failed fetch keeps the current TLE
+	fail = true
+	if err := s.fetch(t.Context()); err == nil {
+		t.Error("expected fetch to fail")
+	}
+
+	epoch := s.propagator.Load().TLE().Epoch
+	got := collectTLE(&s, epoch)
+	if len(got) != 4 {
+		t.Fatalf("got %d metrics, want 4", len(got))
+	}
+	if altitude := got[altitudeMetric.String()]; altitude < 150 || altitude > 400 {
+		t.Errorf("altitude: got %v", altitude)
+	}
+	if velocity := got[velocityMetric.String()]; velocity < 7.5 || velocity > 8 {
+		t.Errorf("velocity: got %v", velocity)
+	}
+	if nextPass := got[nextPassMetric.String()]; nextPass <= 0 || nextPass > nextPassHorizon.Seconds() {
+		t.Errorf("next pass: got %v", nextPass)
+	}
+}
+
+func collectTLE(s *tleSource, now time.Time) map[string]float64 {
+	ch := make(chan prometheus.Metric)
+	go func() {
+		s.collect(ch, now)
+		close(ch)
+	}()
+	metrics := make(map[string]float64)
+	for m := range ch {
+		var d dto.Metric
+		_ = m.Write(&d)
+		metrics[m.Desc().String()] = d.GetGauge().GetValue()
+	}
+	return metrics
+}
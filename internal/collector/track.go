@@ -0,0 +1,94 @@
+package collector
+
+import (
+	"encoding/json"
+	"github.com/clambin/iss-exporter/internal/orbit"
+	"math"
+	"net/http"
+	"time"
+)
+
+const (
+	// trackPasses is the number of passes reported by the position endpoint.
+	trackPasses = 3
+	// earthRadius is the mean radius of the Earth, in km.
+	earthRadius = 6371.0
+)
+
+// Track is the current ground track of the ISS, as reported by the position endpoint.
+type Track struct {
+	Timestamp time.Time `json:"timestamp"`
+	// Source is where the position comes from: "lightstreamer" for the live telemetry, "tle" if it's propagated from
+	// the ISS TLE because the live position isn't available.
+	Source    string  `json:"source"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	// Altitude is in km.
+	Altitude float64 `json:"altitude"`
+	// Footprint is the radius, in km, of the area on the ground from which the ISS is above the horizon.
+	Footprint float64 `json:"footprint"`
+	// Passes are the next passes over the observer, if orbital metrics are enabled for an observer location.
+	Passes []Pass `json:"passes,omitempty"`
+}
+
+// A Pass is a period during which the ISS is above nextPassElevation, as seen from the observer.
+type Pass struct {
+	Start        time.Time `json:"start"`
+	End          time.Time `json:"end"`
+	MaxElevation float64   `json:"max_elevation"`
+}
+
+// PositionHandler returns an http.Handler that reports the current ground track of the ISS, and its next passes over
+// the observer location, as JSON.
+func (c *Collector) PositionHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		track, ok := c.track(time.Now())
+		if !ok {
+			http.Error(w, "position not yet known", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(track)
+	})
+}
+
+// track returns the ground track of the ISS at now. It uses the live position, unless it's stale and orbital metrics
+// are enabled. ok is false if the position isn't known.
+func (c *Collector) track(now time.Time) (Track, bool) {
+	var propagator *orbit.Propagator
+	if c.tle != nil {
+		propagator = c.tle.propagator.Load()
+	}
+
+	var track Track
+	if xyz, updated, ok := c.position.vector(); ok && (propagator == nil || !c.position.stale(now, locationStaleAfter)) {
+		track = newTrack(orbit.Vector(xyz), updated, "lightstreamer")
+	} else if propagator == nil {
+		return Track{}, false
+	} else if position, _, err := propagator.At(now); err != nil {
+		return Track{}, false
+	} else {
+		track = newTrack(position, now, "tle")
+	}
+
+	if propagator != nil && c.tle.observer != nil {
+		for _, pass := range propagator.Passes(*c.tle.observer, now, nextPassHorizon, nextPassElevation, trackPasses) {
+			track.Passes = append(track.Passes, Pass{Start: pass.Start, End: pass.End, MaxElevation: pass.MaxElevation})
+		}
+	}
+	return track, true
+}
+
+// newTrack returns the ground track for a position at time t. The live position is in the J2000 frame, rather than
+// TEME, but the difference is negligible on the ground.
+func newTrack(position orbit.Vector, t time.Time, source string) Track {
+	location := orbit.Geodetic(position, t)
+	return Track{
+		Timestamp: t,
+		Source:    source,
+		Latitude:  location.Latitude,
+		Longitude: location.Longitude,
+		Altitude:  location.Altitude,
+		Footprint: earthRadius * math.Acos(earthRadius/(earthRadius+location.Altitude)),
+	}
+}
@@ -0,0 +1,74 @@
+package collector
+
+import (
+	"encoding/json"
+	"github.com/clambin/iss-exporter/internal/orbit"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCollector_PositionHandler(t *testing.T) {
+	c := newCollector(DefaultConfig, slog.New(slog.DiscardHandler))
+	h := c.PositionHandler()
+
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/position", nil))
+	if resp.Code != http.StatusServiceUnavailable {
+		t.Errorf("got %d without a position, want %d", resp.Code, http.StatusServiceUnavailable)
+	}
+
+	now := time.Now()
+	for axis, value := range []float64{6778, 0, 0} {
+		c.position.update(axis, value, now)
+	}
+	resp = httptest.NewRecorder()
+	h.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/position", nil))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("got %d, want %d", resp.Code, http.StatusOK)
+	}
+	var track Track
+	if err := json.NewDecoder(resp.Body).Decode(&track); err != nil {
+		t.Fatal(err)
+	}
+	if track.Source != "lightstreamer" {
+		t.Errorf("got source %q, want lightstreamer", track.Source)
+	}
+	if math.Abs(track.Altitude-(6778-6378.137)) > 0.1 || math.Abs(track.Latitude) > 0.1 {
+		t.Errorf("got altitude %v, latitude %v", track.Altitude, track.Latitude)
+	}
+	if track.Footprint < 2000 || track.Footprint > 2400 {
+		t.Errorf("got footprint %v", track.Footprint)
+	}
+}
+
+func TestCollector_track_TLE(t *testing.T) {
+	c := newCollector(DefaultConfig, slog.New(slog.DiscardHandler))
+	tle, _ := orbit.ParseTLE(testTLE1, testTLE2)
+	p, _ := orbit.NewPropagator(tle)
+	c.tle = &tleSource{observer: &orbit.Location{Latitude: 50.85, Longitude: 4.35}}
+	c.tle.propagator.Store(p)
+
+	// a stale live position is replaced by the propagated one
+	for axis, value := range []float64{6778, 0, 0} {
+		c.position.update(axis, value, tle.Epoch.Add(-time.Hour))
+	}
+	track, ok := c.track(tle.Epoch)
+	if !ok {
+		t.Fatal("no track")
+	}
+	if track.Source != "tle" {
+		t.Errorf("got source %q, want tle", track.Source)
+	}
+	if len(track.Passes) == 0 {
+		t.Fatal("no passes")
+	}
+	for _, pass := range track.Passes {
+		if !pass.End.After(pass.Start) || pass.MaxElevation < nextPassElevation {
+			t.Errorf("invalid pass: %+v", pass)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package collector
+
+import (
+	"time"
+)
+
+// EventType is the type of state transition reported by an Event.
+type EventType string
+
+const (
+	// EventSessionEstablished reports that the Lightstreamer session was re-established, after it was lost.
+	EventSessionEstablished EventType = "session_established"
+	// EventSessionLost reports that the Lightstreamer session was lost.
+	EventSessionLost EventType = "session_lost"
+	// EventSignalAcquired reports the acquisition of the ISS signal (AOS).
+	EventSignalAcquired EventType = "signal_acquired"
+	// EventSignalLost reports the loss of the ISS signal (LOS).
+	EventSignalLost EventType = "signal_lost"
+	// EventSubscriptionError reports that a group couldn't be subscribed to.
+	EventSubscriptionError EventType = "subscription_error"
+)
+
+// An Event is a state transition of the Collector.
+type Event struct {
+	Type EventType `json:"type"`
+	Time time.Time `json:"time"`
+	// Text describes the event, e.g. for a chat message.
+	Text string `json:"text"`
+	// Group is the group of a subscription error.
+	Group string `json:"group,omitempty"`
+	// Error is the error of a subscription error.
+	Error string `json:"error,omitempty"`
+}
+
+// OnEvent calls f for each state transition of the Collector, until the returned function is called: the
+// Lightstreamer session being lost and re-established, the ISS signal being acquired or lost, and subscription
+// errors. Only transitions are reported: the session established by NewCollector, or the signal status first
+// received, aren't. f may be called by the session's reader: it must not block.
+func (c *Collector) OnEvent(f func(Event)) (stop func()) {
+	return c.events.add(f)
+}
+
+// emit reports a state transition to the functions registered with OnEvent.
+func (c *Collector) emit(eventType EventType, text string) {
+	c.events.notify(Event{Type: eventType, Time: time.Now(), Text: text})
+}
+
+// emitSubscriptionError reports a group that couldn't be subscribed to.
+func (c *Collector) emitSubscriptionError(group string, err error) {
+	c.events.notify(Event{
+		Type:  EventSubscriptionError,
+		Time:  time.Now(),
+		Text:  "failed to subscribe to " + group + ": " + err.Error(),
+		Group: group,
+		Error: err.Error(),
+	})
+}
@@ -0,0 +1,42 @@
+package collector
+
+import (
+	"github.com/clambin/iss-exporter/lightstreamer"
+	"log/slog"
+	"slices"
+	"testing"
+)
+
+func TestCollector_OnEvent(t *testing.T) {
+	s := fakeSubscriber{reject: map[string]bool{"B": true}}
+	c, err := NewCollector(t.Context(), Config{Groups: []GroupConfig{{ID: "A"}}}, &s, slog.New(slog.DiscardHandler))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []Event
+	stop := c.OnEvent(func(e Event) { events = append(events, e) })
+
+	// the first signal status isn't a transition
+	for _, statusClass := range []string{"24", "0", "0", "24"} {
+		s.publish(aosGroup, lightstreamer.Values{valuePtr("0"), valuePtr(statusClass), valuePtr("1")})
+	}
+	if err = c.Reload(t.Context(), Config{Groups: []GroupConfig{{ID: "A"}, {ID: "B"}}}); err == nil {
+		t.Error("expected a subscription error")
+	}
+	stop()
+	s.publish(aosGroup, lightstreamer.Values{valuePtr("0"), valuePtr("0"), valuePtr("1")})
+
+	var got []EventType
+	for _, e := range events {
+		if e.Time.IsZero() || e.Text == "" {
+			t.Errorf("incomplete event: %+v", e)
+		}
+		got = append(got, e.Type)
+	}
+	if want := []EventType{EventSignalLost, EventSignalAcquired, EventSubscriptionError}; !slices.Equal(got, want) {
+		t.Fatalf("got events %v, want %v", got, want)
+	}
+	if e := events[2]; e.Group != "B" || e.Error != "invalid group" {
+		t.Errorf("unexpected subscription error: %+v", e)
+	}
+}
@@ -0,0 +1,126 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/coder/websocket"
+	"net/http"
+	"time"
+)
+
+// wsWriteTimeout is the time allowed to send a message to a WebSocket client, or for the client to answer a ping, before
+// the client is disconnected.
+const wsWriteTimeout = 10 * time.Second
+
+// A wsMessage is a message sent to a WebSocket client: an update, or the number of updates that were lost because
+// the client fell behind.
+type wsMessage struct {
+	Type string `json:"type"`
+	*Update
+	Lost int64 `json:"lost,omitempty"`
+}
+
+// A wsRequest is a message from a WebSocket client. It selects the groups the client receives.
+type wsRequest struct {
+	Groups []string `json:"groups"`
+}
+
+// WebSocketHandler returns an http.Handler that pushes telemetry updates to WebSocket clients. Each update is sent as
+// a JSON text message, e.g.
+//
+//	{"type":"update","group":"USLAB000058","value":14.7,"timestamp":"...","received":"..."}
+//
+// Clients select groups with one or more "group" query parameters, or by sending {"groups": ["USLAB000058"]} at any
+// time, which also sends the last update of the selected groups. Without a selection, clients receive all groups.
+//
+// Each client has a bounded queue. If a client falls behind, updates are dropped, and the client receives a
+// {"type":"lost","lost":n} message. Connections are closed when ctx is canceled.
+//
+// Cross-origin requests are rejected: browsers may only connect from pages served by the exporter.
+func (c *Collector) WebSocketHandler(ctx context.Context) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close(websocket.StatusGoingAway, "") }()
+		f := c.newFeed(r.URL.Query()["group"], feedSize)
+		defer f.stop()
+
+		// the reader handles group selections, and ends the connection when the client closes it. It reads until
+		// the connection is closed: canceling a read's context would close the connection without a close message.
+		closed := make(chan struct{})
+		go func() {
+			defer close(closed)
+			for {
+				_, message, err := conn.Read(context.Background())
+				if err != nil {
+					if websocket.CloseStatus(err) == -1 {
+						c.Logger.Debug("websocket read failed", "err", err)
+					}
+					return
+				}
+				var request wsRequest
+				if err = json.Unmarshal(message, &request); err != nil {
+					c.Logger.Debug("invalid websocket request", "err", err)
+					continue
+				}
+				f.setGroups(request.Groups)
+				c.sendLatest(conn, f)
+			}
+		}()
+
+		c.sendLatest(conn, f)
+		keepAlive := time.NewTicker(eventsKeepAlive)
+		defer keepAlive.Stop()
+		for err == nil {
+			select {
+			case <-ctx.Done():
+				_ = conn.Close(websocket.StatusGoingAway, "shutting down")
+				return
+			case <-closed:
+				return
+			case <-keepAlive.C:
+				err = pingWS(conn)
+			case u := <-f.updates:
+				if lost := f.lost.Swap(0); lost > 0 {
+					err = writeWSMessage(conn, wsMessage{Type: "lost", Lost: lost})
+				}
+				if err == nil {
+					err = writeWSMessage(conn, wsMessage{Type: "update", Update: &u})
+				}
+			}
+		}
+		c.Logger.Debug("websocket write failed", "err", err)
+	})
+}
+
+// sendLatest sends the last update of each of the feed's groups.
+func (c *Collector) sendLatest(conn *websocket.Conn, f *feed) {
+	for _, u := range c.Latest() {
+		if f.wants(u.Group) {
+			if err := writeWSMessage(conn, wsMessage{Type: "update", Update: &u}); err != nil {
+				c.Logger.Debug("websocket write failed", "err", err)
+				return
+			}
+		}
+	}
+}
+
+// writeWSMessage sends a message as JSON. If it takes longer than wsWriteTimeout, the connection is closed.
+func writeWSMessage(conn *websocket.Conn, message wsMessage) error {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), wsWriteTimeout)
+	defer cancel()
+	return conn.Write(ctx, websocket.MessageText, body)
+}
+
+// pingWS sends a ping, and waits for the pong. If it takes longer than wsWriteTimeout, the connection is closed.
+func pingWS(conn *websocket.Conn) error {
+	ctx, cancel := context.WithTimeout(context.Background(), wsWriteTimeout)
+	defer cancel()
+	return conn.Ping(ctx)
+}
@@ -0,0 +1,67 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/clambin/iss-exporter/lightstreamer"
+	"github.com/coder/websocket"
+	"log/slog"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCollector_WebSocketHandler(t *testing.T) {
+	var s fakeSubscriber
+	c, err := NewCollector(t.Context(), Config{Groups: []GroupConfig{{ID: "A"}, {ID: "B"}}}, &s, slog.New(slog.DiscardHandler))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.publish("A", lightstreamer.Values{valuePtr("1")})
+	s.publish("B", lightstreamer.Values{valuePtr("2")})
+
+	ctx, cancel := context.WithCancel(t.Context())
+	ts := httptest.NewServer(c.WebSocketHandler(ctx))
+	t.Cleanup(ts.Close)
+
+	conn, _, err := websocket.Dial(t.Context(), ts.URL+"?group=A", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.CloseNow() })
+	read := func() (wsMessage, error) {
+		t.Helper()
+		readCtx, readCancel := context.WithTimeout(t.Context(), time.Second)
+		defer readCancel()
+		var m wsMessage
+		_, message, err := conn.Read(readCtx)
+		if err == nil {
+			err = json.Unmarshal(message, &m)
+		}
+		return m, err
+	}
+
+	// the connection starts with the last value of the selected groups
+	if m, err := read(); err != nil || m.Type != "update" || m.Group != "A" || m.Value != 1 {
+		t.Errorf("unexpected message: %+v, err: %v", m, err)
+	}
+	s.publish("B", lightstreamer.Values{valuePtr("3")})
+	s.publish("A", lightstreamer.Values{valuePtr("4")})
+	if m, err := read(); err != nil || m.Group != "A" || m.Value != 4 {
+		t.Errorf("unexpected message: %+v, err: %v", m, err)
+	}
+
+	// select B instead: the client receives its last value
+	if err = conn.Write(t.Context(), websocket.MessageText, []byte(`{"groups":["B"]}`)); err != nil {
+		t.Fatal(err)
+	}
+	if m, err := read(); err != nil || m.Group != "B" || m.Value != 3 {
+		t.Errorf("unexpected message: %+v, err: %v", m, err)
+	}
+
+	// canceling ctx closes the connection
+	cancel()
+	if _, err = read(); websocket.CloseStatus(err) != websocket.StatusGoingAway {
+		t.Errorf("expected the connection to be closed, got %v", err)
+	}
+}
@@ -1,16 +1,88 @@
 package health
 
 import (
-	"github.com/clambin/iss-exporter/lightstreamer"
+	"context"
+	"encoding/json"
+	"errors"
 	"net/http"
+	"strings"
+	"time"
 )
 
-func Handler(session *lightstreamer.ClientSession) http.Handler {
+// A HealthChecker reports the health of a component. lightstreamer.ClientSession, lightstreamer.Server and
+// collector.Collector implement HealthChecker.
+type HealthChecker interface {
+	// Healthy returns an error if the component isn't healthy.
+	Healthy(ctx context.Context) error
+	// Details returns the component's state, for the JSON health report. It must be JSON-encodable.
+	Details() any
+}
+
+// Report is the health report, returned by Handler if JSON is requested.
+type Report struct {
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+	Details any    `json:"details,omitempty"`
+}
+
+// Handler reports whether checker is healthy. If the request accepts application/json, or sets format=json, the
+// response has a Report as body.
+func Handler(checker HealthChecker) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := checker.Healthy(r.Context())
+		code := http.StatusOK
+		if err != nil {
+			code = http.StatusServiceUnavailable
+		}
+		if !wantJSON(r) {
+			if code != http.StatusOK {
+				http.Error(w, http.StatusText(code), code)
+				return
+			}
+			w.WriteHeader(code)
+			return
+		}
+		report := Report{Status: "ok", Details: checker.Details()}
+		if err != nil {
+			report.Status, report.Error = "unavailable", err.Error()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		_ = json.NewEncoder(w).Encode(report)
+	})
+}
+
+func wantJSON(r *http.Request) bool {
+	return r.URL.Query().Get("format") == "json" || strings.Contains(r.Header.Get("Accept"), "application/json")
+}
+
+// Livez reports that the process is up and serving requests. Unlike Readyz, it does not depend on the Lightstreamer
+// server: a lost session is recovered without restarting the exporter.
+func Livez() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// Readyz reports whether the exporter is ready to be scraped, as determined by Ready.
+func Readyz(checker HealthChecker, lastUpdate func() time.Time, staleAfter time.Duration) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if session.Connections.Load() == 0 {
-			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		if err := Ready(r.Context(), checker, lastUpdate, staleAfter); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
 			return
 		}
 		w.WriteHeader(http.StatusOK)
 	})
 }
+
+// Ready returns an error if the exporter isn't ready: checker isn't healthy or, if staleAfter is not zero, lastUpdate
+// doesn't report an update received in the last staleAfter.
+func Ready(ctx context.Context, checker HealthChecker, lastUpdate func() time.Time, staleAfter time.Duration) error {
+	if err := checker.Healthy(ctx); err != nil {
+		return err
+	}
+	if staleAfter > 0 && time.Since(lastUpdate()) > staleAfter {
+		return errors.New("no recent updates")
+	}
+	return nil
+}
@@ -1,10 +1,14 @@
 package health
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"github.com/clambin/iss-exporter/lightstreamer"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 )
 
 func TestHealth(t *testing.T) {
@@ -34,3 +38,87 @@ func TestHealth(t *testing.T) {
 		t.Errorf("got %v want %v", resp.Code, http.StatusServiceUnavailable)
 	}
 }
+
+type fakeChecker struct {
+	err error
+}
+
+func (f fakeChecker) Healthy(context.Context) error { return f.err }
+func (f fakeChecker) Details() any                  { return map[string]int{"reconnects": 2} }
+
+func TestHandler_JSON(t *testing.T) {
+	tests := []struct {
+		name       string
+		target     string
+		accept     string
+		err        error
+		wantCode   int
+		wantStatus string
+	}{
+		{name: "accept", target: "/health", accept: "application/json", wantCode: http.StatusOK, wantStatus: "ok"},
+		{name: "format", target: "/health?format=json", wantCode: http.StatusOK, wantStatus: "ok"},
+		{name: "unhealthy", target: "/health?format=json", err: errors.New("no session"), wantCode: http.StatusServiceUnavailable, wantStatus: "unavailable"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			resp := httptest.NewRecorder()
+			Handler(fakeChecker{err: tt.err}).ServeHTTP(resp, req)
+			if resp.Code != tt.wantCode {
+				t.Fatalf("got %v want %v", resp.Code, tt.wantCode)
+			}
+			if got := resp.Header().Get("Content-Type"); got != "application/json" {
+				t.Errorf("got content type %q", got)
+			}
+			var report struct {
+				Report
+				Details map[string]int `json:"details"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+				t.Fatal(err)
+			}
+			if report.Status != tt.wantStatus || (tt.err != nil) != (report.Error != "") || report.Details["reconnects"] != 2 {
+				t.Errorf("got report %+v", report)
+			}
+		})
+	}
+}
+
+func TestLivez(t *testing.T) {
+	resp := httptest.NewRecorder()
+	Livez().ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/livez", nil))
+	if resp.Code != http.StatusOK {
+		t.Errorf("got %v want %v", resp.Code, http.StatusOK)
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	tests := []struct {
+		name        string
+		connections int32
+		lastUpdate  time.Time
+		staleAfter  time.Duration
+		want        int
+	}{
+		{name: "no session", lastUpdate: time.Now(), staleAfter: time.Minute, want: http.StatusServiceUnavailable},
+		{name: "no updates", connections: 1, staleAfter: time.Minute, want: http.StatusServiceUnavailable},
+		{name: "stale", connections: 1, lastUpdate: time.Now().Add(-time.Hour), staleAfter: time.Minute, want: http.StatusServiceUnavailable},
+		{name: "ready", connections: 1, lastUpdate: time.Now(), staleAfter: time.Minute, want: http.StatusOK},
+		{name: "staleness not checked", connections: 1, want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := lightstreamer.NewClientSession()
+			s.Connections.Store(tt.connections)
+			h := Readyz(s, func() time.Time { return tt.lastUpdate }, tt.staleAfter)
+			resp := httptest.NewRecorder()
+			h.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			if resp.Code != tt.want {
+				t.Errorf("got %v want %v", resp.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// A Registry is a HealthChecker that aggregates the named checks of several components, e.g. the Lightstreamer
+// session and the crew fetcher. It's healthy if all its required checks are healthy. Its details report the result of
+// each check.
+type Registry struct {
+	lock   sync.RWMutex
+	checks []check
+}
+
+type check struct {
+	name     string
+	checker  HealthChecker
+	optional bool
+}
+
+// Register adds a required check: if it fails, the Registry isn't healthy. Registering a name again replaces its check.
+func (r *Registry) Register(name string, checker HealthChecker) {
+	r.register(check{name: name, checker: checker})
+}
+
+// RegisterOptional adds an optional check: it's reported, but doesn't affect the health of the Registry.
+func (r *Registry) RegisterOptional(name string, checker HealthChecker) {
+	r.register(check{name: name, checker: checker, optional: true})
+}
+
+func (r *Registry) register(c check) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for i := range r.checks {
+		if r.checks[i].name == c.name {
+			r.checks[i] = c
+			return
+		}
+	}
+	r.checks = append(r.checks, c)
+}
+
+// Check returns the named check.
+func (r *Registry) Check(name string) (HealthChecker, bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	for _, c := range r.checks {
+		if c.name == name {
+			return c.checker, true
+		}
+	}
+	return nil, false
+}
+
+func (r *Registry) registered() []check {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return append([]check(nil), r.checks...)
+}
+
+// Healthy returns the errors of all required checks that fail.
+func (r *Registry) Healthy(ctx context.Context) error {
+	var errs []error
+	for _, c := range r.registered() {
+		if err := c.checker.Healthy(ctx); err != nil && !c.optional {
+			errs = append(errs, fmt.Errorf("%s: %w", c.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Details returns the Report of each check, by name.
+func (r *Registry) Details() any {
+	reports := make(map[string]Report)
+	for _, c := range r.registered() {
+		report := Report{Status: "ok", Details: c.checker.Details()}
+		if err := c.checker.Healthy(context.Background()); err != nil {
+			report.Status, report.Error = "unavailable", err.Error()
+		}
+		reports[c.name] = report
+	}
+	return reports
+}
+
+// Handler serves the health of the Registry, as Handler does. If the request sets check=<name>, it serves the health of
+// that check only, e.g. for a probe that only depends on the Lightstreamer session.
+func (r *Registry) Handler() http.Handler {
+	aggregate := Handler(r)
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		name := req.URL.Query().Get("check")
+		if name == "" {
+			aggregate.ServeHTTP(w, req)
+			return
+		}
+		c, ok := r.Check(name)
+		if !ok {
+			http.Error(w, fmt.Sprintf("unknown check %q", name), http.StatusNotFound)
+			return
+		}
+		Handler(c).ServeHTTP(w, req)
+	})
+}
@@ -0,0 +1,66 @@
+package health
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	var r Registry
+	r.Register("session", fakeChecker{})
+	r.RegisterOptional("crew", fakeChecker{err: errors.New("not fetched yet")})
+	if err := r.Healthy(t.Context()); err != nil {
+		t.Errorf("Healthy() got %v: optional checks shouldn't fail the registry", err)
+	}
+
+	r.Register("session", fakeChecker{err: errors.New("no session")})
+	if err := r.Healthy(t.Context()); err == nil || err.Error() != "session: no session" {
+		t.Errorf("Healthy() got %v", err)
+	}
+
+	reports := r.Details().(map[string]Report)
+	if len(reports) != 2 || reports["session"].Status != "unavailable" || reports["crew"].Error != "not fetched yet" {
+		t.Errorf("Details() got %+v", reports)
+	}
+}
+
+func TestRegistry_Handler(t *testing.T) {
+	var r Registry
+	r.Register("session", fakeChecker{})
+	r.Register("relay", fakeChecker{err: errors.New("down")})
+	tests := []struct {
+		name   string
+		target string
+		want   int
+	}{
+		{name: "aggregate", target: "/health", want: http.StatusServiceUnavailable},
+		{name: "healthy check", target: "/health?check=session", want: http.StatusOK},
+		{name: "failing check", target: "/health?check=relay", want: http.StatusServiceUnavailable},
+		{name: "unknown check", target: "/health?check=foo", want: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := httptest.NewRecorder()
+			r.Handler().ServeHTTP(resp, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			if resp.Code != tt.want {
+				t.Errorf("got %v want %v", resp.Code, tt.want)
+			}
+		})
+	}
+
+	resp := httptest.NewRecorder()
+	r.Handler().ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/health?format=json", nil))
+	var report struct {
+		Status  string            `json:"status"`
+		Details map[string]Report `json:"details"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Status != "unavailable" || report.Details["session"].Status != "ok" || report.Details["relay"].Error != "down" {
+		t.Errorf("got report %+v", report)
+	}
+}
@@ -0,0 +1,197 @@
+package orbit
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WGS-84 ellipsoid, for geodetic coordinates.
+const (
+	wgs84Radius     = 6378.137 // km
+	wgs84Flattening = 1 / 298.257223563
+)
+
+// A Location is a geodetic position on (or above) the Earth.
+type Location struct {
+	// Latitude and Longitude are in degrees.
+	Latitude  float64
+	Longitude float64
+	// Altitude is the height above the WGS-84 ellipsoid, in km.
+	Altitude float64
+}
+
+// ParseLocation parses a location, formatted as "latitude,longitude[,altitude]", with latitude and longitude in degrees
+// and altitude in km.
+func ParseLocation(s string) (Location, error) {
+	fields := strings.Split(s, ",")
+	if len(fields) < 2 || len(fields) > 3 {
+		return Location{}, fmt.Errorf("invalid location %q: expected latitude,longitude[,altitude]", s)
+	}
+	var values [3]float64
+	for i, field := range fields {
+		var err error
+		if values[i], err = strconv.ParseFloat(strings.TrimSpace(field), 64); err != nil {
+			return Location{}, fmt.Errorf("invalid location %q: %w", s, err)
+		}
+	}
+	if math.Abs(values[0]) > 90 || math.Abs(values[1]) > 180 {
+		return Location{}, fmt.Errorf("invalid location %q: out of range", s)
+	}
+	return Location{Latitude: values[0], Longitude: values[1], Altitude: values[2]}, nil
+}
+
+// Geodetic converts a position in the TEME frame at time t to a geodetic location.
+func Geodetic(position Vector, t time.Time) Location {
+	ecef := temeToECEF(position, t)
+	e2 := wgs84Flattening * (2 - wgs84Flattening)
+	r := math.Hypot(ecef[0], ecef[1])
+	longitude := math.Atan2(ecef[1], ecef[0])
+	latitude := math.Atan2(ecef[2], r)
+	var c float64
+	// iterate to the geodetic latitude: converges in a few iterations for low-earth orbits
+	for range 5 {
+		sinLat := math.Sin(latitude)
+		c = 1 / math.Sqrt(1-e2*sinLat*sinLat)
+		latitude = math.Atan2(ecef[2]+wgs84Radius*c*e2*sinLat, r)
+	}
+	altitude := r/math.Cos(latitude) - wgs84Radius*c
+	return Location{
+		Latitude:  latitude * 180 / math.Pi,
+		Longitude: longitude * 180 / math.Pi,
+		Altitude:  altitude,
+	}
+}
+
+// ecef returns the location in Earth-centered, Earth-fixed coordinates, in km.
+func (l Location) ecef() Vector {
+	e2 := wgs84Flattening * (2 - wgs84Flattening)
+	sinLat, cosLat := math.Sincos(l.Latitude * math.Pi / 180)
+	sinLon, cosLon := math.Sincos(l.Longitude * math.Pi / 180)
+	n := wgs84Radius / math.Sqrt(1-e2*sinLat*sinLat)
+	return Vector{
+		(n + l.Altitude) * cosLat * cosLon,
+		(n + l.Altitude) * cosLat * sinLon,
+		(n*(1-e2) + l.Altitude) * sinLat,
+	}
+}
+
+// Elevation returns the elevation of a position in the TEME frame at time t, as seen from the location, in degrees.
+func (l Location) Elevation(position Vector, t time.Time) float64 {
+	observer := l.ecef()
+	sat := temeToECEF(position, t)
+	rangeVector := Vector{sat[0] - observer[0], sat[1] - observer[1], sat[2] - observer[2]}
+	sinLat, cosLat := math.Sincos(l.Latitude * math.Pi / 180)
+	sinLon, cosLon := math.Sincos(l.Longitude * math.Pi / 180)
+	// the component of the range vector along the local vertical
+	up := cosLat*cosLon*rangeVector[0] + cosLat*sinLon*rangeVector[1] + sinLat*rangeVector[2]
+	return math.Asin(up/rangeVector.Norm()) * 180 / math.Pi
+}
+
+// NextPass returns the start of the first pass of the satellite over the location, i.e. the first time after from
+// that its elevation is above minElevation (in degrees), searching up to horizon ahead. If the satellite is already
+// above minElevation at from, NextPass returns from. ok is false if no pass was found.
+func (p *Propagator) NextPass(l Location, from time.Time, horizon time.Duration, minElevation float64) (time.Time, bool) {
+	visible := func(t time.Time) bool {
+		elevation, ok := p.elevation(l, t)
+		return ok && elevation >= minElevation
+	}
+	if visible(from) {
+		return from, true
+	}
+	for t := from.Add(passStep); t.Sub(from) <= horizon; t = t.Add(passStep) {
+		if visible(t) {
+			return bisect(t.Add(-passStep), t, visible), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// A Pass is a period during which a satellite is above a minimum elevation, as seen from a location.
+type Pass struct {
+	Start time.Time
+	End   time.Time
+	// MaxElevation is the highest elevation during the pass, in degrees.
+	MaxElevation float64
+}
+
+// Passes returns up to count passes of the satellite over the location that start within horizon after from.
+// A pass in progress at from starts at from.
+func (p *Propagator) Passes(l Location, from time.Time, horizon time.Duration, minElevation float64, count int) []Pass {
+	var passes []Pass
+	until := from.Add(horizon)
+	for len(passes) < count {
+		start, ok := p.NextPass(l, from, until.Sub(from), minElevation)
+		if !ok {
+			break
+		}
+		pass := Pass{Start: start, MaxElevation: minElevation}
+		t := start
+		for {
+			elevation, ok := p.elevation(l, t)
+			if !ok || elevation < minElevation {
+				break
+			}
+			pass.MaxElevation = max(pass.MaxElevation, elevation)
+			t = t.Add(passStep)
+		}
+		pass.End = bisect(t.Add(-passStep), t, func(t time.Time) bool {
+			elevation, ok := p.elevation(l, t)
+			return !ok || elevation < minElevation
+		}).Add(-time.Second)
+		passes = append(passes, pass)
+		from = t
+	}
+	return passes
+}
+
+// an ISS pass lasts several minutes: a 30s step won't miss one
+const passStep = 30 * time.Second
+
+// elevation returns the elevation of the satellite at time t, as seen from the location. ok is false if the orbit
+// can't be propagated to t.
+func (p *Propagator) elevation(l Location, t time.Time) (float64, bool) {
+	position, _, err := p.At(t)
+	if err != nil {
+		return 0, false
+	}
+	return l.Elevation(position, t), true
+}
+
+// bisect returns the first time, to the second, between before and after for which f is true. f must be false at
+// before and true at after.
+func bisect(before time.Time, after time.Time, f func(time.Time) bool) time.Time {
+	for after.Sub(before) > time.Second {
+		mid := before.Add(after.Sub(before) / 2)
+		if f(mid) {
+			after = mid
+		} else {
+			before = mid
+		}
+	}
+	return after
+}
+
+// temeToECEF rotates a position in the TEME frame at time t to the Earth-fixed frame, ignoring polar motion.
+func temeToECEF(position Vector, t time.Time) Vector {
+	sinG, cosG := math.Sincos(gstime(t))
+	return Vector{
+		cosG*position[0] + sinG*position[1],
+		-sinG*position[0] + cosG*position[1],
+		position[2],
+	}
+}
+
+// gstime returns the Greenwich Mean Sidereal Time at t, in radians.
+func gstime(t time.Time) float64 {
+	jd := float64(t.UnixNano())/float64(24*time.Hour) + 2440587.5
+	tut1 := (jd - 2451545.0) / 36525
+	seconds := -6.2e-6*tut1*tut1*tut1 + 0.093104*tut1*tut1 + (876600*3600+8640184.812866)*tut1 + 67310.54841
+	gst := math.Mod(seconds*math.Pi/180/240, twoPi)
+	if gst < 0 {
+		gst += twoPi
+	}
+	return gst
+}
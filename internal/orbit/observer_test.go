@@ -0,0 +1,139 @@
+package orbit
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestGstime(t *testing.T) {
+	j2000 := time.Date(2000, time.January, 1, 12, 0, 0, 0, time.UTC)
+	if got := gstime(j2000) * 180 / math.Pi; math.Abs(got-280.46061837) > 1e-6 {
+		t.Errorf("gstime(J2000) got %v", got)
+	}
+}
+
+func TestParseLocation(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Location
+		wantErr bool
+	}{
+		{input: "50.85,4.35", want: Location{Latitude: 50.85, Longitude: 4.35}},
+		{input: "50.85, 4.35, 0.1", want: Location{Latitude: 50.85, Longitude: 4.35, Altitude: 0.1}},
+		{input: "50.85", wantErr: true},
+		{input: "50.85,4.35,0,1", wantErr: true},
+		{input: "north,4.35", wantErr: true},
+		{input: "91,4.35", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseLocation(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseLocation() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseLocation() got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGeodetic(t *testing.T) {
+	now := time.Date(2025, time.March, 1, 12, 0, 0, 0, time.UTC)
+	for _, want := range []Location{
+		{Latitude: 0, Longitude: 0, Altitude: 420},
+		{Latitude: 51.6, Longitude: -120, Altitude: 415},
+		{Latitude: -51.6, Longitude: 170, Altitude: 0},
+	} {
+		got := Geodetic(ecefToTEME(want.ecef(), now), now)
+		if math.Abs(got.Latitude-want.Latitude) > 1e-6 || math.Abs(got.Longitude-want.Longitude) > 1e-6 || math.Abs(got.Altitude-want.Altitude) > 1e-6 {
+			t.Errorf("Geodetic() got %+v, want %+v", got, want)
+		}
+	}
+}
+
+func TestLocation_Elevation(t *testing.T) {
+	now := time.Date(2025, time.March, 1, 12, 0, 0, 0, time.UTC)
+	observer := Location{Latitude: 50.85, Longitude: 4.35}
+	overhead := observer
+	overhead.Altitude = 420
+	if got := observer.Elevation(ecefToTEME(overhead.ecef(), now), now); math.Abs(got-90) > 1e-6 {
+		t.Errorf("Elevation() overhead got %v, want 90", got)
+	}
+	antipode := Location{Latitude: -50.85, Longitude: -175.65, Altitude: 420}
+	// the geodetic verticals of antipodes aren't exactly aligned
+	if got := observer.Elevation(ecefToTEME(antipode.ecef(), now), now); got > -89.5 {
+		t.Errorf("Elevation() antipode got %v, want (nearly) -90", got)
+	}
+}
+
+func TestPropagator_NextPass(t *testing.T) {
+	tle, _ := ParseTLE(tle1, tle2)
+	p, _ := NewPropagator(tle)
+	observer := Location{Latitude: 50.85, Longitude: 4.35}
+
+	pass, ok := p.NextPass(observer, tle.Epoch, 24*time.Hour, 10)
+	if !ok {
+		t.Fatal("no pass found")
+	}
+	elevation := func(t time.Time) float64 {
+		position, _, _ := p.At(t)
+		return observer.Elevation(position, t)
+	}
+	if got := elevation(pass); got < 10 {
+		t.Errorf("elevation at start of pass: got %v", got)
+	}
+	if got := elevation(pass.Add(-time.Second)); got >= 10 {
+		t.Errorf("elevation before start of pass: got %v", got)
+	}
+	if again, _ := p.NextPass(observer, pass, time.Hour, 10); !again.Equal(pass) {
+		t.Errorf("NextPass() during a pass should return the start time: got %v, want %v", again, pass)
+	}
+	if _, ok = p.NextPass(observer, pass.Add(time.Hour), time.Minute, 10); ok {
+		t.Error("NextPass() should not find a pass within a minute")
+	}
+}
+
+func TestPropagator_Passes(t *testing.T) {
+	tle, _ := ParseTLE(tle1, tle2)
+	p, _ := NewPropagator(tle)
+	observer := Location{Latitude: 50.85, Longitude: 4.35}
+
+	passes := p.Passes(observer, tle.Epoch, 48*time.Hour, 10, 3)
+	if len(passes) != 3 {
+		t.Fatalf("got %d passes, want 3", len(passes))
+	}
+	for i, pass := range passes {
+		if i > 0 && !pass.Start.After(passes[i-1].End) {
+			t.Errorf("pass %d: starts before the end of the previous pass", i)
+		}
+		if d := pass.End.Sub(pass.Start); d <= 0 || d > 15*time.Minute {
+			t.Errorf("pass %d: invalid duration %v", i, d)
+		}
+		position, _, _ := p.At(pass.End)
+		if got := observer.Elevation(position, pass.End); got < 10 {
+			t.Errorf("pass %d: elevation at end of pass: got %v", i, got)
+		}
+		position, _, _ = p.At(pass.End.Add(time.Second))
+		if got := observer.Elevation(position, pass.End.Add(time.Second)); got >= 10 {
+			t.Errorf("pass %d: elevation after end of pass: got %v", i, got)
+		}
+		if pass.MaxElevation < 10 || pass.MaxElevation > 90 {
+			t.Errorf("pass %d: invalid max elevation %v", i, pass.MaxElevation)
+		}
+	}
+	if passes := p.Passes(observer, passes[0].End.Add(time.Minute), time.Minute, 10, 3); len(passes) != 0 {
+		t.Errorf("got %d passes within a minute, want 0", len(passes))
+	}
+}
+
+// ecefToTEME is the inverse of temeToECEF.
+func ecefToTEME(position Vector, t time.Time) Vector {
+	sinG, cosG := math.Sincos(gstime(t))
+	return Vector{
+		cosG*position[0] - sinG*position[1],
+		sinG*position[0] + cosG*position[1],
+		position[2],
+	}
+}
@@ -0,0 +1,115 @@
+package orbit
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// test case from Spacetrack Report #3
+const (
+	tle1 = "1 88888U          80275.98708465  .00073094  13844-3  66816-4 0    8"
+	tle2 = "2 88888  72.8435 115.9689 0086731  52.6988 110.5714 16.05824518  105"
+)
+
+func TestParseTLE(t *testing.T) {
+	tle, err := ParseTLE(tle1, tle2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(1980, time.October, 1, 23, 41, 24, 113760000, time.UTC); tle.Epoch.Sub(want).Abs() > time.Millisecond {
+		t.Errorf("epoch: got %v, want %v", tle.Epoch, want)
+	}
+	if tle.BStar != 0.66816e-4 {
+		t.Errorf("bstar: got %v", tle.BStar)
+	}
+	if tle.Eccentricity != 0.0086731 {
+		t.Errorf("eccentricity: got %v", tle.Eccentricity)
+	}
+	if got := tle.MeanMotion * 1440 / (2 * math.Pi); math.Abs(got-16.05824518) > 1e-9 {
+		t.Errorf("mean motion: got %v", got)
+	}
+
+	if _, err = ParseTLE(tle1[:40], tle2); err == nil {
+		t.Error("expected error for short line")
+	}
+	if _, err = ParseTLE(strings.Replace(tle1, "66816-4", "66816x4", 1), tle2); err == nil {
+		t.Error("expected error for invalid bstar")
+	}
+}
+
+func TestReadTLE(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		wantName string
+		wantErr  bool
+	}{
+		{name: "two lines", input: tle1 + "\n" + tle2 + "\n"},
+		{name: "three lines", input: "ISS (ZARYA)\r\n" + tle1 + "\r\n" + tle2 + "\r\n", wantName: "ISS (ZARYA)"},
+		{name: "missing line", input: tle1 + "\n", wantErr: true},
+		{name: "garbage", input: "foo\nbar\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tle, err := ReadTLE(strings.NewReader(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadTLE() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tle.Name != tt.wantName {
+				t.Errorf("name: got %q, want %q", tle.Name, tt.wantName)
+			}
+		})
+	}
+}
+
+func TestPropagator(t *testing.T) {
+	tle, _ := ParseTLE(tle1, tle2)
+	p, err := NewPropagator(tle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// expected results from Spacetrack Report #3. Its constants differ slightly from WGS-72, hence the tolerance (10 m).
+	tests := []struct {
+		tsince   float64
+		position Vector
+		velocity Vector
+	}{
+		{0, Vector{2328.97048951, -5995.22076416, 1719.97067261}, Vector{2.91207230, -0.98341546, -7.09081703}},
+		{360, Vector{2456.10705566, -6071.93853760, 1222.89727783}, Vector{2.67938992, -0.44829041, -7.22879231}},
+		{720, Vector{2567.56195068, -6112.50384522, 713.96397400}, Vector{2.44024599, 0.09810869, -7.31995916}},
+		{1080, Vector{2663.09078980, -6115.48229980, 196.39640427}, Vector{2.19611958, 0.65241995, -7.36282432}},
+		{1440, Vector{2742.55133057, -6079.67144775, -326.38095856}, Vector{1.94850229, 1.21106251, -7.35619372}},
+	}
+	for _, tt := range tests {
+		position, velocity, err := p.propagate(tt.tsince)
+		if err != nil {
+			t.Fatalf("%v: %v", tt.tsince, err)
+		}
+		for i := range 3 {
+			if math.Abs(position[i]-tt.position[i]) > 0.01 {
+				t.Errorf("%v: position got %v, want %v", tt.tsince, position, tt.position)
+				break
+			}
+			if math.Abs(velocity[i]-tt.velocity[i]) > 5e-5 {
+				t.Errorf("%v: velocity got %v, want %v", tt.tsince, velocity, tt.velocity)
+				break
+			}
+		}
+	}
+
+	// At measures time since the epoch
+	position, _, _ := p.At(tle.Epoch.Add(6 * time.Hour))
+	if math.Abs(position[0]-2456.10705566) > 0.01 {
+		t.Errorf("At(): got %v", position)
+	}
+}
+
+func TestNewPropagator_DeepSpace(t *testing.T) {
+	tle, _ := ParseTLE(tle1, tle2)
+	tle.MeanMotion = 2 * math.Pi / 1440 // geostationary
+	if _, err := NewPropagator(tle); err == nil {
+		t.Error("expected error for deep-space orbit")
+	}
+}
@@ -0,0 +1,269 @@
+package orbit
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+// WGS-72 constants, as used by SGP4.
+const (
+	earthRadius = 6378.135 // km
+	mu          = 398600.8 // km³/s²
+	j2          = 0.001082616
+	j3          = -0.00000253881
+	j4          = -0.00000165597
+	j3oj2       = j3 / j2
+	x2o3        = 2.0 / 3.0
+	twoPi       = 2 * math.Pi
+)
+
+var (
+	xke       = 60 / math.Sqrt(earthRadius*earthRadius*earthRadius/mu) // earth radii^1.5 per minute
+	vkmpersec = earthRadius * xke / 60
+)
+
+// ErrDecayed is returned when the propagated orbit is no longer valid, e.g. because the satellite has decayed.
+var ErrDecayed = errors.New("orbit decayed")
+
+// A Vector is a position (in km) or velocity (in km/s), in the TEME (true equator, mean equinox) frame.
+type Vector [3]float64
+
+// Norm returns the length of the vector.
+func (v Vector) Norm() float64 {
+	return math.Sqrt(v[0]*v[0] + v[1]*v[1] + v[2]*v[2])
+}
+
+// A Propagator computes the position and velocity of a satellite from its TLE, using the SGP4 model.
+// It only supports near-earth orbits (orbital period under 225 minutes), like the ISS's.
+type Propagator struct {
+	tle TLE
+	// un-Kozai'd mean motion
+	no                                                   float64
+	isimp                                                bool
+	eta, cc1, cc4, cc5, d2, d3, d4                       float64
+	t2cof, t3cof, t4cof, t5cof                           float64
+	mdot, argpdot, nodedot, omgcof, xmcof, nodecf, delmo float64
+	sinmao, con41, x1mth2, x7thm1, xlcof, aycof          float64
+}
+
+// NewPropagator initializes a Propagator for a TLE.
+func NewPropagator(tle TLE) (*Propagator, error) {
+	if 2*math.Pi/tle.MeanMotion >= 225 {
+		return nil, errors.New("deep-space orbits are not supported")
+	}
+	p := Propagator{tle: tle}
+	ecco, inclo, argpo, mo, bstar := tle.Eccentricity, tle.Inclination, tle.ArgumentOfPerigee, tle.MeanAnomaly, tle.BStar
+
+	// recover the original mean motion and semi-major axis from the TLE's (Kozai) mean motion
+	eccsq := ecco * ecco
+	omeosq := 1 - eccsq
+	rteosq := math.Sqrt(omeosq)
+	cosio := math.Cos(inclo)
+	cosio2 := cosio * cosio
+	ak := math.Pow(xke/tle.MeanMotion, x2o3)
+	d1 := 0.75 * j2 * (3*cosio2 - 1) / (rteosq * omeosq)
+	del := d1 / (ak * ak)
+	adel := ak * (1 - del*del - del*(1.0/3.0+134*del*del/81))
+	del = d1 / (adel * adel)
+	p.no = tle.MeanMotion / (1 + del)
+	ao := math.Pow(xke/p.no, x2o3)
+	sinio := math.Sin(inclo)
+	po := ao * omeosq
+	con42 := 1 - 5*cosio2
+	p.con41 = -con42 - cosio2 - cosio2
+	posq := po * po
+	rp := ao * (1 - ecco)
+
+	// perigee below 220 km: simplified drag model
+	p.isimp = rp < 220/earthRadius+1
+	sfour := 78/earthRadius + 1
+	qzms24 := math.Pow((120-78)/earthRadius, 4)
+	if perige := (rp - 1) * earthRadius; perige < 156 {
+		sfour = perige - 78
+		if perige < 98 {
+			sfour = 20
+		}
+		qzms24 = math.Pow((120-sfour)/earthRadius, 4)
+		sfour = sfour/earthRadius + 1
+	}
+	pinvsq := 1 / posq
+	tsi := 1 / (ao - sfour)
+	p.eta = ao * ecco * tsi
+	etasq := p.eta * p.eta
+	eeta := ecco * p.eta
+	psisq := math.Abs(1 - etasq)
+	coef := qzms24 * math.Pow(tsi, 4)
+	coef1 := coef / math.Pow(psisq, 3.5)
+	cc2 := coef1 * p.no * (ao*(1+1.5*etasq+eeta*(4+etasq)) + 0.375*j2*tsi/psisq*p.con41*(8+3*etasq*(8+etasq)))
+	p.cc1 = bstar * cc2
+	var cc3 float64
+	if ecco > 1e-4 {
+		cc3 = -2 * coef * tsi * j3oj2 * p.no * sinio / ecco
+	}
+	p.x1mth2 = 1 - cosio2
+	p.cc4 = 2 * p.no * coef1 * ao * omeosq * (p.eta*(2+0.5*etasq) + ecco*(0.5+2*etasq) -
+		j2*tsi/(ao*psisq)*(-3*p.con41*(1-2*eeta+etasq*(1.5-0.5*eeta))+0.75*p.x1mth2*(2*etasq-eeta*(1+etasq))*math.Cos(2*argpo)))
+	p.cc5 = 2 * coef1 * ao * omeosq * (1 + 2.75*(etasq+eeta) + eeta*etasq)
+	cosio4 := cosio2 * cosio2
+	temp1 := 1.5 * j2 * pinvsq * p.no
+	temp2 := 0.5 * temp1 * j2 * pinvsq
+	temp3 := -0.46875 * j4 * pinvsq * pinvsq * p.no
+	p.mdot = p.no + 0.5*temp1*rteosq*p.con41 + 0.0625*temp2*rteosq*(13-78*cosio2+137*cosio4)
+	p.argpdot = -0.5*temp1*con42 + 0.0625*temp2*(7-114*cosio2+395*cosio4) + temp3*(3-36*cosio2+49*cosio4)
+	xhdot1 := -temp1 * cosio
+	p.nodedot = xhdot1 + (0.5*temp2*(4-19*cosio2)+2*temp3*(3-7*cosio2))*cosio
+	p.omgcof = bstar * cc3 * math.Cos(argpo)
+	if ecco > 1e-4 {
+		p.xmcof = -x2o3 * coef * bstar / eeta
+	}
+	p.nodecf = 3.5 * omeosq * xhdot1 * p.cc1
+	p.t2cof = 1.5 * p.cc1
+	if math.Abs(cosio+1) > 1.5e-12 {
+		p.xlcof = -0.25 * j3oj2 * sinio * (3 + 5*cosio) / (1 + cosio)
+	} else {
+		p.xlcof = -0.25 * j3oj2 * sinio * (3 + 5*cosio) / 1.5e-12
+	}
+	p.aycof = -0.5 * j3oj2 * sinio
+	p.delmo = math.Pow(1+p.eta*math.Cos(mo), 3)
+	p.sinmao = math.Sin(mo)
+	p.x7thm1 = 7*cosio2 - 1
+
+	if !p.isimp {
+		cc1sq := p.cc1 * p.cc1
+		p.d2 = 4 * ao * tsi * cc1sq
+		temp := p.d2 * tsi * p.cc1 / 3
+		p.d3 = (17*ao + sfour) * temp
+		p.d4 = 0.5 * temp * ao * tsi * (221*ao + 31*sfour) * p.cc1
+		p.t3cof = p.d2 + 2*cc1sq
+		p.t4cof = 0.25 * (3*p.d3 + p.cc1*(12*p.d2+10*cc1sq))
+		p.t5cof = 0.2 * (3*p.d4 + 12*p.cc1*p.d3 + 6*p.d2*p.d2 + 15*cc1sq*(2*p.d2+cc1sq))
+	}
+	return &p, nil
+}
+
+// TLE returns the Propagator's TLE.
+func (p *Propagator) TLE() TLE {
+	return p.tle
+}
+
+// At returns the position (in km) and velocity (in km/s) of the satellite at time t, in the TEME frame.
+func (p *Propagator) At(t time.Time) (position Vector, velocity Vector, err error) {
+	return p.propagate(t.Sub(p.tle.Epoch).Minutes())
+}
+
+// propagate returns the position and velocity of the satellite, tsince minutes after the TLE's epoch.
+func (p *Propagator) propagate(tsince float64) (Vector, Vector, error) {
+	tle := p.tle
+
+	// secular gravity and atmospheric drag
+	xmdf := tle.MeanAnomaly + p.mdot*tsince
+	argpdf := tle.ArgumentOfPerigee + p.argpdot*tsince
+	nodedf := tle.RightAscension + p.nodedot*tsince
+	argpm := argpdf
+	mm := xmdf
+	t2 := tsince * tsince
+	nodem := nodedf + p.nodecf*t2
+	tempa := 1 - p.cc1*tsince
+	tempe := tle.BStar * p.cc4 * tsince
+	templ := p.t2cof * t2
+	if !p.isimp {
+		delomg := p.omgcof * tsince
+		delm := p.xmcof * (math.Pow(1+p.eta*math.Cos(xmdf), 3) - p.delmo)
+		temp := delomg + delm
+		mm = xmdf + temp
+		argpm = argpdf - temp
+		t3 := t2 * tsince
+		t4 := t3 * tsince
+		tempa = tempa - p.d2*t2 - p.d3*t3 - p.d4*t4
+		tempe = tempe + tle.BStar*p.cc5*(math.Sin(mm)-p.sinmao)
+		templ = templ + p.t3cof*t3 + t4*(p.t4cof+tsince*p.t5cof)
+	}
+	am := math.Pow(xke/p.no, x2o3) * tempa * tempa
+	nm := xke / math.Pow(am, 1.5)
+	em := tle.Eccentricity - tempe
+	if em >= 1 || em < -0.001 || am < 0.95 {
+		return Vector{}, Vector{}, ErrDecayed
+	}
+	em = max(em, 1e-6)
+	mm = mm + p.no*templ
+	xlm := mm + argpm + nodem
+	nodem = math.Mod(nodem, twoPi)
+	argpm = math.Mod(argpm, twoPi)
+	xlm = math.Mod(xlm, twoPi)
+	mm = math.Mod(xlm-argpm-nodem, twoPi)
+	sinim, cosim := math.Sincos(tle.Inclination)
+
+	// long period periodics
+	axnl := em * math.Cos(argpm)
+	temp := 1 / (am * (1 - em*em))
+	aynl := em*math.Sin(argpm) + temp*p.aycof
+	xl := mm + argpm + nodem + temp*p.xlcof*axnl
+
+	// solve Kepler's equation
+	u := math.Mod(xl-nodem, twoPi)
+	eo1 := u
+	var sineo1, coseo1 float64
+	tem5 := 9999.9
+	for ktr := 1; math.Abs(tem5) >= 1e-12 && ktr <= 10; ktr++ {
+		sineo1, coseo1 = math.Sincos(eo1)
+		tem5 = 1 - coseo1*axnl - sineo1*aynl
+		tem5 = (u - aynl*coseo1 + axnl*sineo1 - eo1) / tem5
+		if math.Abs(tem5) >= 0.95 {
+			tem5 = math.Copysign(0.95, tem5)
+		}
+		eo1 += tem5
+	}
+
+	// short period periodics
+	ecose := axnl*coseo1 + aynl*sineo1
+	esine := axnl*sineo1 - aynl*coseo1
+	el2 := axnl*axnl + aynl*aynl
+	pl := am * (1 - el2)
+	if pl < 0 {
+		return Vector{}, Vector{}, ErrDecayed
+	}
+	rl := am * (1 - ecose)
+	rdotl := math.Sqrt(am) * esine / rl
+	rvdotl := math.Sqrt(pl) / rl
+	betal := math.Sqrt(1 - el2)
+	temp = esine / (1 + betal)
+	sinu := am / rl * (sineo1 - aynl - axnl*temp)
+	cosu := am / rl * (coseo1 - axnl + aynl*temp)
+	su := math.Atan2(sinu, cosu)
+	sin2u := (cosu + cosu) * sinu
+	cos2u := 1 - 2*sinu*sinu
+	temp = 1 / pl
+	temp1 := 0.5 * j2 * temp
+	temp2 := temp1 * temp
+	mrt := rl*(1-1.5*temp2*betal*p.con41) + 0.5*temp1*p.x1mth2*cos2u
+	su = su - 0.25*temp2*p.x7thm1*sin2u
+	xnode := nodem + 1.5*temp2*cosim*sin2u
+	xinc := tle.Inclination + 1.5*temp2*cosim*sinim*cos2u
+	mvt := rdotl - nm*temp1*p.x1mth2*sin2u/xke
+	rvdot := rvdotl + nm*temp1*(p.x1mth2*cos2u+1.5*p.con41)/xke
+	if mrt < 1 {
+		return Vector{}, Vector{}, ErrDecayed
+	}
+
+	// orientation vectors
+	sinsu, cossu := math.Sincos(su)
+	snod, cnod := math.Sincos(xnode)
+	sini, cosi := math.Sincos(xinc)
+	xmx := -snod * cosi
+	xmy := cnod * cosi
+	ux := xmx*sinsu + cnod*cossu
+	uy := xmy*sinsu + snod*cossu
+	uz := sini * sinsu
+	vx := xmx*cossu - cnod*sinsu
+	vy := xmy*cossu - snod*sinsu
+	vz := sini * cossu
+
+	position := Vector{mrt * ux * earthRadius, mrt * uy * earthRadius, mrt * uz * earthRadius}
+	velocity := Vector{
+		(mvt*ux + rvdot*vx) * vkmpersec,
+		(mvt*uy + rvdot*vy) * vkmpersec,
+		(mvt*uz + rvdot*vz) * vkmpersec,
+	}
+	return position, velocity, nil
+}
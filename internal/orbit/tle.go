@@ -0,0 +1,122 @@
+// Package orbit propagates the orbit of a satellite from its two-line element set (TLE), using the SGP4 model.
+package orbit
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A TLE is a two-line element set, describing a satellite's orbit at its epoch.
+type TLE struct {
+	Name  string
+	Epoch time.Time
+	// BStar is the drag term, in inverse earth radii.
+	BStar float64
+	// Inclination, RightAscension, ArgumentOfPerigee and MeanAnomaly are in radians.
+	Inclination       float64
+	RightAscension    float64
+	Eccentricity      float64
+	ArgumentOfPerigee float64
+	MeanAnomaly       float64
+	// MeanMotion is in radians per minute.
+	MeanMotion float64
+}
+
+// ReadTLE reads the first TLE from r. The TLE may be preceded by a name line.
+func ReadTLE(r io.Reader) (TLE, error) {
+	var name string
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() && len(lines) < 2 {
+		line := strings.TrimRight(scanner.Text(), " \r")
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "1 ") && len(lines) == 0, strings.HasPrefix(line, "2 ") && len(lines) == 1:
+			lines = append(lines, line)
+		case len(lines) == 0:
+			name = strings.TrimSpace(line)
+		default:
+			return TLE{}, fmt.Errorf("unexpected line: %q", line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return TLE{}, err
+	}
+	if len(lines) != 2 {
+		return TLE{}, errors.New("no TLE found")
+	}
+	tle, err := ParseTLE(lines[0], lines[1])
+	tle.Name = name
+	return tle, err
+}
+
+// ParseTLE parses the two lines of a TLE.
+func ParseTLE(line1, line2 string) (TLE, error) {
+	if len(line1) < 61 || len(line2) < 63 {
+		return TLE{}, errors.New("TLE lines too short")
+	}
+	var tle TLE
+	var err error
+	field := func(line string, from, to int) string { return strings.TrimSpace(line[from-1 : to]) }
+	float := func(line string, from, to int) float64 {
+		var f float64
+		if err == nil {
+			if f, err = strconv.ParseFloat(field(line, from, to), 64); err != nil {
+				err = fmt.Errorf("columns %d-%d: %w", from, to, err)
+			}
+		}
+		return f
+	}
+	const deg2rad = math.Pi / 180
+
+	year := int(float(line1, 19, 20))
+	day := float(line1, 21, 32)
+	tle.BStar = exponential(field(line1, 54, 61), &err)
+	tle.Inclination = float(line2, 9, 16) * deg2rad
+	tle.RightAscension = float(line2, 18, 25) * deg2rad
+	tle.Eccentricity = float(line2, 27, 33) / 1e7
+	tle.ArgumentOfPerigee = float(line2, 35, 42) * deg2rad
+	tle.MeanAnomaly = float(line2, 44, 51) * deg2rad
+	tle.MeanMotion = float(line2, 53, 63) * 2 * math.Pi / 1440
+	if err != nil {
+		return TLE{}, err
+	}
+	if year < 57 {
+		year += 2000
+	} else {
+		year += 1900
+	}
+	tle.Epoch = time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration((day - 1) * float64(24*time.Hour)))
+	return tle, nil
+}
+
+// exponential parses a TLE field in "assumed decimal point" exponential notation, e.g. " 66816-4" is 0.66816e-4.
+func exponential(s string, err *error) float64 {
+	if *err != nil {
+		return 0
+	}
+	s = strings.ReplaceAll(s, " ", "")
+	if s == "" {
+		return 0
+	}
+	sign := ""
+	if s[0] == '-' || s[0] == '+' {
+		sign, s = s[:1], s[1:]
+	}
+	i := strings.LastIndexAny(s, "+-")
+	if i <= 0 {
+		*err = fmt.Errorf("invalid exponential field %q", s)
+		return 0
+	}
+	f, e := strconv.ParseFloat(sign+"0."+s[:i]+"e"+s[i:], 64)
+	if e != nil {
+		*err = fmt.Errorf("invalid exponential field %q: %w", s, e)
+	}
+	return f
+}
@@ -0,0 +1,140 @@
+// Package store is an embedded store of telemetry samples, kept for a retention period, so the short-term history
+// survives restarts without an external database server. Samples are kept in a SQLite database in a directory,
+// indexed by group and time, so queries only read the samples they return.
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	_ "modernc.org/sqlite"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// A Sample is a reading of a telemetry group.
+type Sample struct {
+	Group string
+	Time  time.Time
+	Value float64
+	// State is the state of an enumerated group, if the value maps to one.
+	State string
+	// Status is the Status.Class of the reading, if known.
+	Status *float64
+}
+
+// dbName is the name of the database file in the store's directory.
+const dbName = "history.db"
+
+// schema creates the samples table. Times are stored in milliseconds since the epoch.
+const schema = `
+CREATE TABLE IF NOT EXISTS samples (
+	grp    TEXT    NOT NULL,
+	time   INTEGER NOT NULL,
+	value  REAL    NOT NULL,
+	status REAL,
+	state  TEXT    NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS samples_grp_time ON samples (grp, time);
+CREATE INDEX IF NOT EXISTS samples_time ON samples (time);
+`
+
+// A Store stores samples in a directory. Its methods may be called concurrently.
+type Store struct {
+	db        *sql.DB
+	retention time.Duration
+}
+
+// Open opens the store in dir, creating dir if it doesn't exist. Samples older than retention are removed by Prune.
+// A zero retention keeps them forever.
+func Open(dir string, retention time.Duration) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("store: %w", err)
+	}
+	// in WAL mode, a crash loses at most the last transactions, and queries don't block appends
+	db, err := sql.Open("sqlite", "file:"+filepath.Join(dir, dbName)+"?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("store: %w", err)
+	}
+	if _, err = db.Exec(schema); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("store: %w", err)
+	}
+	return &Store{db: db, retention: retention}, nil
+}
+
+// Append adds samples to the store. They're written to disk when Append returns.
+func (s *Store) Append(samples ...Sample) error {
+	if len(samples) == 0 {
+		return nil
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("store: %w", err)
+	}
+	// Rollback is a no-op once the transaction is committed
+	defer func() { _ = tx.Rollback() }()
+	insert, err := tx.Prepare("INSERT INTO samples (grp, time, value, status, state) VALUES (?, ?, ?, ?, ?)")
+	if err != nil {
+		return fmt.Errorf("store: %w", err)
+	}
+	defer func() { _ = insert.Close() }()
+	for _, sample := range samples {
+		if _, err = insert.Exec(sample.Group, sample.Time.UnixMilli(), sample.Value, sample.Status, sample.State); err != nil {
+			return fmt.Errorf("store: %w", err)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("store: %w", err)
+	}
+	return nil
+}
+
+// Close closes the database.
+func (s *Store) Close() error {
+	if err := s.db.Close(); err != nil {
+		return fmt.Errorf("store: %w", err)
+	}
+	return nil
+}
+
+// Query returns the samples of a group with a time between from and to, inclusive, in chronological order.
+func (s *Store) Query(group string, from time.Time, to time.Time) ([]Sample, error) {
+	// samples are appended as they're received, which may not be the order of their timestamps. rowid keeps samples
+	// with the same time in the order they were appended
+	rows, err := s.db.Query("SELECT time, value, status, state FROM samples WHERE grp = ? AND time BETWEEN ? AND ? ORDER BY time, rowid",
+		group, from.UnixMilli(), to.UnixMilli())
+	if err != nil {
+		return nil, fmt.Errorf("store: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+	var samples []Sample
+	for rows.Next() {
+		sample := Sample{Group: group}
+		var ms int64
+		var status sql.NullFloat64
+		if err = rows.Scan(&ms, &sample.Value, &status, &sample.State); err != nil {
+			return nil, fmt.Errorf("store: %w", err)
+		}
+		sample.Time = time.UnixMilli(ms).UTC()
+		if status.Valid {
+			sample.Status = &status.Float64
+		}
+		samples = append(samples, sample)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("store: %w", err)
+	}
+	return samples, nil
+}
+
+// Prune removes the samples that are older than the retention period at now.
+func (s *Store) Prune(now time.Time) error {
+	if s.retention <= 0 {
+		return nil
+	}
+	if _, err := s.db.Exec("DELETE FROM samples WHERE time < ?", now.Add(-s.retention).UnixMilli()); err != nil {
+		return fmt.Errorf("store: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package store
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestStore(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir, 48*time.Hour)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	start := time.Date(2025, 1, 2, 23, 59, 59, 0, time.UTC)
+	status := 24.0
+	if err = s.Append(
+		Sample{Group: "USLAB000058", Time: start, Value: 760.5, Status: &status},
+		Sample{Group: "NODE3000005", Time: start, Value: 1, State: "OPEN, VENTING"},
+		// the next day
+		Sample{Group: "USLAB000058", Time: start.Add(2 * time.Second), Value: 761},
+		// received out of order
+		Sample{Group: "USLAB000058", Time: start.Add(time.Second), Value: 760.75},
+	); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	if err = s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// samples survive a restart
+	if s, err = Open(dir, 48*time.Hour); err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+
+	tests := []struct {
+		name  string
+		group string
+		from  time.Time
+		to    time.Time
+		want  []float64
+	}{
+		{"all", "USLAB000058", start.Add(-time.Hour), start.Add(time.Hour), []float64{760.5, 760.75, 761}},
+		{"inclusive range", "USLAB000058", start.Add(time.Second), start.Add(2 * time.Second), []float64{760.75, 761}},
+		{"one day", "USLAB000058", start, start, []float64{760.5}},
+		{"other group", "NODE3000005", time.Time{}, start.Add(24 * time.Hour), []float64{1}},
+		{"unknown group", "USLAB000059", time.Time{}, start.Add(24 * time.Hour), nil},
+		{"before", "USLAB000058", start.Add(-48 * time.Hour), start.Add(-time.Hour), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			samples, err := s.Query(tt.group, tt.from, tt.to)
+			if err != nil {
+				t.Fatalf("Query: %v", err)
+			}
+			var got []float64
+			for _, sample := range samples {
+				got = append(got, sample.Value)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("got %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+
+	samples, _ := s.Query("USLAB000058", start, start)
+	if len(samples) != 1 || samples[0].Status == nil || *samples[0].Status != 24 || !samples[0].Time.Equal(start) {
+		t.Errorf("unexpected sample: %+v", samples)
+	}
+	if samples, _ = s.Query("NODE3000005", start, start); len(samples) != 1 || samples[0].State != "OPEN, VENTING" || samples[0].Status != nil {
+		t.Errorf("unexpected sample: %+v", samples)
+	}
+}
+
+func TestStore_Prune(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir, 24*time.Hour)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	day := time.Date(2025, 1, 2, 12, 0, 0, 0, time.UTC)
+	for i := range 3 {
+		if err = s.Append(Sample{Group: "USLAB000058", Time: day.Add(time.Duration(i) * 24 * time.Hour), Value: float64(i)}); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want []float64
+	}{
+		// the sample at the retention limit is kept
+		{"none expired", day.Add(24 * time.Hour), []float64{0, 1, 2}},
+		{"first expired", day.Add(36 * time.Hour), []float64{1, 2}},
+		{"all expired", day.Add(96 * time.Hour), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := s.Prune(tt.now); err != nil {
+				t.Fatalf("Prune: %v", err)
+			}
+			samples, err := s.Query("USLAB000058", time.Time{}, day.Add(96*time.Hour))
+			if err != nil {
+				t.Fatalf("Query: %v", err)
+			}
+			var got []float64
+			for _, sample := range samples {
+				got = append(got, sample.Value)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,221 @@
+// Package util provides HTTP debugging helpers: a RoundTripper that logs the requests and responses of an
+// http.Client, e.g. of a lightstreamer.ClientSession.
+package util
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// LoggingRoundTripper logs the requests and responses of an http.Client, at debug level.
+type LoggingRoundTripper struct {
+	// Next performs the requests. Defaults to http.DefaultTransport.
+	Next   http.RoundTripper
+	Logger *slog.Logger
+	// MaxBodySize is the maximum number of bytes of a request or response body that is logged. Zero logs no bodies.
+	MaxBodySize int
+	// Redact lists the values that aren't logged. Defaults to DefaultRedaction.
+	Redact *Redaction
+}
+
+// Redaction lists the form fields and headers whose values are replaced by "REDACTED" when logged.
+type Redaction struct {
+	// FormFields are the names of form fields, in URL-encoded request bodies and query strings, e.g. "LS_password".
+	FormFields []string
+	// Headers are the names of request and response headers, e.g. "Authorization".
+	Headers []string
+}
+
+// DefaultRedaction redacts the Lightstreamer password, and the usual credential headers.
+var DefaultRedaction = Redaction{
+	FormFields: []string{"LS_password"},
+	Headers:    []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"},
+}
+
+const redacted = "REDACTED"
+
+// header returns a copy of h, with the values of the redacted headers replaced.
+func (r Redaction) header(h http.Header) http.Header {
+	h = h.Clone()
+	for _, name := range r.Headers {
+		if values := h.Values(name); len(values) > 0 {
+			h.Del(name)
+			for range values {
+				h.Add(name, redacted)
+			}
+		}
+	}
+	return h
+}
+
+// form returns an URL-encoded form, with the values of the redacted fields replaced. The order of the fields is kept.
+// A form truncated in the middle of a value is redacted too.
+func (r Redaction) form(form string) string {
+	if len(r.FormFields) == 0 || form == "" {
+		return form
+	}
+	fields := strings.Split(form, "&")
+	for i, field := range fields {
+		key, _, ok := strings.Cut(field, "=")
+		if name, err := url.QueryUnescape(key); err == nil && ok && slices.Contains(r.FormFields, name) {
+			fields[i] = key + "=" + redacted
+		}
+	}
+	return strings.Join(fields, "&")
+}
+
+func (l LoggingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	// a RoundTripper mustn't modify the request: dump a copy, whose body can be replaced
+	req = req.Clone(req.Context())
+	redact := DefaultRedaction
+	if l.Redact != nil {
+		redact = *l.Redact
+	}
+	DumpRequest(l.Logger, req, l.MaxBodySize, redact)
+	next := l.Next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	resp, err := next.RoundTrip(req)
+	if err != nil {
+		l.Logger.Debug("http request failed", "method", req.Method, "url", req.URL.String(), "err", err)
+		return resp, err
+	}
+	DumpResponse(l.Logger, resp, l.MaxBodySize, redact)
+	return resp, nil
+}
+
+// DumpRequest logs a request, including up to maxBody bytes of its body, with the values listed by redact replaced.
+// The body is left intact.
+func DumpRequest(logger *slog.Logger, req *http.Request, maxBody int, redact Redaction) {
+	if req == nil {
+		return
+	}
+	u := *req.URL
+	u.RawQuery = redact.form(u.RawQuery)
+	attrs := []any{"method", req.Method, "url", u.String(), "header", redact.header(req.Header)}
+	if maxBody > 0 && req.Body != nil && req.Body != http.NoBody {
+		var body []byte
+		body, req.Body = peek(req.Body, maxBody)
+		var format func(string) string
+		if mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type")); mediaType == "application/x-www-form-urlencoded" {
+			format = redact.form
+		}
+		attrs = append(attrs, bodyAttrs(body, maxBody, format)...)
+	}
+	logger.Debug("http request", attrs...)
+}
+
+// DumpResponse logs a response, including up to maxBody bytes of its body, with the headers listed by redact replaced.
+// The body is left intact.
+//
+// A streamed response (a TLCP stream, with content type text/enriched) is never read ahead: that would block until the
+// server sends enough data. Instead, its body logs the first maxBody bytes once they've been read, or the body is closed.
+func DumpResponse(logger *slog.Logger, resp *http.Response, maxBody int, redact Redaction) {
+	if resp == nil {
+		logger.Debug("http response", "response", nil)
+		return
+	}
+	attrs := []any{"status", resp.Status, "header", redact.header(resp.Header)}
+	if maxBody <= 0 || resp.Body == nil || resp.Body == http.NoBody {
+		logger.Debug("http response", attrs...)
+		return
+	}
+	if streaming(resp) {
+		logger.Debug("http response", append(attrs, "streaming", true)...)
+		resp.Body = &streamDump{ReadCloser: resp.Body, logger: logger, max: maxBody}
+		return
+	}
+	var body []byte
+	body, resp.Body = peek(resp.Body, maxBody)
+	logger.Debug("http response", append(attrs, bodyAttrs(body, maxBody, nil)...)...)
+}
+
+// streaming returns true if the response is a stream.
+func streaming(resp *http.Response) bool {
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return mediaType == "text/enriched"
+}
+
+// peek reads up to maxBody+1 bytes of body, to detect truncation, and returns them, with a body that returns the full
+// content and closes the original body.
+func peek(body io.ReadCloser, maxBody int) ([]byte, io.ReadCloser) {
+	buf, err := io.ReadAll(io.LimitReader(body, int64(maxBody)+1))
+	var rest io.Reader = body
+	if err != nil {
+		rest = &errReader{err: err}
+	}
+	return buf, readCloser{Reader: io.MultiReader(bytes.NewReader(buf), rest), Closer: body}
+}
+
+// bodyAttrs returns the log attributes of up to maxBody bytes of body. If format is not nil, it's applied to the logged
+// part of the body, e.g. to redact it.
+func bodyAttrs(body []byte, maxBody int, format func(string) string) []any {
+	truncated := len(body) > maxBody
+	logged := string(body[:min(len(body), maxBody)])
+	if format != nil {
+		logged = format(logged)
+	}
+	if truncated {
+		return []any{"body", logged, "truncated", true}
+	}
+	return []any{"body", logged}
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+type errReader struct {
+	err error
+}
+
+func (r *errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+// streamDump is a streamed body that logs the first max bytes read from it.
+type streamDump struct {
+	io.ReadCloser
+	logger *slog.Logger
+	max    int
+	lock   sync.Mutex
+	buf    []byte
+	logged bool
+}
+
+func (s *streamDump) Read(p []byte) (int, error) {
+	n, err := s.ReadCloser.Read(p)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if !s.logged {
+		s.buf = append(s.buf, p[:min(n, s.max-len(s.buf))]...)
+		if len(s.buf) >= s.max {
+			s.log(true)
+		}
+	}
+	return n, err
+}
+
+func (s *streamDump) Close() error {
+	s.lock.Lock()
+	if !s.logged {
+		s.log(false)
+	}
+	s.lock.Unlock()
+	return s.ReadCloser.Close()
+}
+
+func (s *streamDump) log(truncated bool) {
+	s.logged = true
+	s.logger.Debug("http response stream", "body", string(s.buf), "truncated", truncated)
+	s.buf = nil
+}
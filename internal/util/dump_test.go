@@ -0,0 +1,157 @@
+package util
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDumpResponse(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		maxBody     int
+		want        []string
+		wantNot     []string
+	}{
+		{name: "body", contentType: "text/plain", body: "REQOK,1", maxBody: 100, want: []string{`body=REQOK,1`}},
+		{name: "truncated", contentType: "text/plain", body: "REQOK,1", maxBody: 5, want: []string{`body=REQOK`, "truncated=true"}},
+		{name: "no body", contentType: "text/plain", body: "REQOK,1", wantNot: []string{"body="}},
+		{name: "stream", contentType: "text/enriched; charset=UTF-8", body: "CONOK,S1,50000,5000,*\r\nPROBE\r\n", maxBody: 5, want: []string{"streaming=true", `body=CONOK`, "truncated=true"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			logger := slog.New(slog.NewTextHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug}))
+			resp := &http.Response{
+				Status: "200 OK",
+				Header: http.Header{"Content-Type": []string{tt.contentType}},
+				Body:   io.NopCloser(strings.NewReader(tt.body)),
+			}
+			DumpResponse(logger, resp, tt.maxBody, DefaultRedaction)
+
+			// the body is left intact
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = resp.Body.Close()
+			if string(body) != tt.body {
+				t.Errorf("got body %q, want %q", string(body), tt.body)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("log does not contain %q:\n%s", want, out.String())
+				}
+			}
+			for _, want := range tt.wantNot {
+				if strings.Contains(out.String(), want) {
+					t.Errorf("log contains %q:\n%s", want, out.String())
+				}
+			}
+		})
+	}
+}
+
+func TestDumpResponse_Nil(t *testing.T) {
+	DumpResponse(slog.New(slog.DiscardHandler), nil, 100, DefaultRedaction)
+}
+
+func TestDumpRequest(t *testing.T) {
+	var out bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	req := httptest.NewRequest(http.MethodPost, "/lightstreamer/create_session.txt", strings.NewReader("LS_cid=foo&LS_adapter_set=ISSLIVE"))
+	DumpRequest(logger, req, 10, Redaction{})
+	if !strings.Contains(out.String(), `body="LS_cid=foo"`) || !strings.Contains(out.String(), "truncated=true") {
+		t.Errorf("unexpected log:\n%s", out.String())
+	}
+	body, _ := io.ReadAll(req.Body)
+	if string(body) != "LS_cid=foo&LS_adapter_set=ISSLIVE" {
+		t.Errorf("got body %q", string(body))
+	}
+}
+
+func TestDumpRequest_Redaction(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		maxBody int
+		redact  Redaction
+		want    string
+	}{
+		{name: "password", body: "LS_user=me&LS_password=secret&LS_cid=foo", maxBody: 100, redact: DefaultRedaction, want: `body="LS_user=me&LS_password=REDACTED&LS_cid=foo"`},
+		{name: "truncated", body: "LS_user=me&LS_password=secret&LS_cid=foo", maxBody: 26, redact: DefaultRedaction, want: `body="LS_user=me&LS_password=REDACTED" truncated=true`},
+		{name: "configured", body: "LS_user=me&LS_password=secret", maxBody: 100, redact: Redaction{FormFields: []string{"LS_user", "LS_password"}}, want: `body="LS_user=REDACTED&LS_password=REDACTED"`},
+		{name: "disabled", body: "LS_password=secret", maxBody: 100, want: `body="LS_password=secret"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			logger := slog.New(slog.NewTextHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug}))
+			req := httptest.NewRequest(http.MethodPost, "/lightstreamer/create_session.txt?LS_password=secret", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.Header.Set("Authorization", "Basic c2VjcmV0")
+			DumpRequest(logger, req, tt.maxBody, tt.redact)
+			if !strings.Contains(out.String(), tt.want) {
+				t.Errorf("log does not contain %q:\n%s", tt.want, out.String())
+			}
+			if len(tt.redact.Headers) > 0 && (strings.Contains(out.String(), "c2VjcmV0") || strings.Contains(out.String(), "LS_password=secret")) {
+				t.Errorf("log contains credentials:\n%s", out.String())
+			}
+		})
+	}
+}
+
+func TestDumpResponse_Redaction(t *testing.T) {
+	var out bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	resp := &http.Response{Status: "200 OK", Header: http.Header{"Set-Cookie": []string{"session=secret"}}, Body: http.NoBody}
+	DumpResponse(logger, resp, 100, DefaultRedaction)
+	if strings.Contains(out.String(), "secret") || !strings.Contains(out.String(), "Set-Cookie:[REDACTED]") {
+		t.Errorf("unexpected log:\n%s", out.String())
+	}
+	if got := resp.Header.Get("Set-Cookie"); got != "session=secret" {
+		t.Errorf("response header modified: %q", got)
+	}
+}
+
+type failingTransport struct{}
+
+func (failingTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestLoggingRoundTripper(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("REQOK,1"))
+	}))
+	t.Cleanup(ts.Close)
+
+	var out bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	c := http.Client{Transport: LoggingRoundTripper{Logger: logger, MaxBodySize: 100}}
+	resp, err := c.Post(ts.URL, "application/x-www-form-urlencoded", strings.NewReader("LS_op=add"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if string(body) != "REQOK,1" {
+		t.Errorf("got body %q", string(body))
+	}
+	if !strings.Contains(out.String(), `body="LS_op=add"`) || !strings.Contains(out.String(), "body=REQOK,1") {
+		t.Errorf("unexpected log:\n%s", out.String())
+	}
+
+	// a failed request has no response to dump
+	c.Transport = LoggingRoundTripper{Next: failingTransport{}, Logger: logger, MaxBodySize: 100}
+	if _, err = c.Get(ts.URL); err == nil {
+		t.Error("expected an error")
+	}
+}
@@ -0,0 +1,227 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"mime"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// har is an HTTP Archive (HAR 1.2) document. Only the fields the exporter records are set.
+type har struct {
+	Log harLog `json:"log"`
+}
+
+type harLog struct {
+	Version string     `json:"version"`
+	Creator harCreator `json:"creator"`
+	Entries []harEntry `json:"entries"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+	Comment         string      `json:"comment,omitempty"`
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	PostData    *harPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	Content     harContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	Comment  string `json:"comment,omitempty"`
+	// Chunks annotates the lines of a streamed body with the time they were received. HAR allows custom fields,
+	// prefixed with an underscore.
+	Chunks []harChunk `json:"_chunks,omitempty"`
+}
+
+type harChunk struct {
+	// Offset is the time the line was received, in milliseconds since the response.
+	Offset float64 `json:"offset"`
+	Text   string  `json:"text"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// WriteHAR converts a recording made by a RecordingRoundTripper to an HTTP Archive (HAR 1.2), e.g. to share a
+// capture in a browser's developer tools. Response bodies are truncated after maxBody bytes. Each line of a streamed
+// (text/enriched) body is annotated with the time it was received, in the entry's _chunks.
+func WriteHAR(w io.Writer, recording io.Reader, version string, maxBody int) error {
+	exchanges, err := readExchanges(recording)
+	if err != nil {
+		return err
+	}
+	doc := har{Log: harLog{
+		Version: "1.2",
+		Creator: harCreator{Name: "iss-exporter", Version: version},
+		Entries: make([]harEntry, 0, len(exchanges)),
+	}}
+	for _, x := range exchanges {
+		doc.Log.Entries = append(doc.Log.Entries, x.harEntry(maxBody))
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+func (x *exchange) harEntry(maxBody int) harEntry {
+	entry := harEntry{
+		StartedDateTime: x.request.Time,
+		Request: harRequest{
+			Method:      x.request.Method,
+			URL:         x.request.URL,
+			HTTPVersion: "HTTP/1.1",
+			Cookies:     []harNameValue{},
+			Headers:     harHeaders(x.request.Header),
+			QueryString: []harNameValue{},
+			HeadersSize: -1,
+			BodySize:    len(x.request.Body),
+		},
+		Response: harResponse{
+			HTTPVersion: "HTTP/1.1",
+			Cookies:     []harNameValue{},
+			Headers:     []harNameValue{},
+			HeadersSize: -1,
+			BodySize:    -1,
+		},
+	}
+	if u, err := url.Parse(x.request.URL); err == nil {
+		query := u.Query()
+		for _, name := range slices.Sorted(maps.Keys(query)) {
+			for _, value := range query[name] {
+				entry.Request.QueryString = append(entry.Request.QueryString, harNameValue{Name: name, Value: value})
+			}
+		}
+	}
+	if x.request.Body != "" {
+		entry.Request.PostData = &harPostData{MimeType: x.request.Header.Get("Content-Type"), Text: x.request.Body}
+	}
+	if x.err != nil {
+		entry.Comment = "request failed: " + x.err.Error
+		entry.Time = ms(x.err.Time.Sub(x.request.Time))
+		entry.Timings.Wait = entry.Time
+		return entry
+	}
+	if x.response == nil {
+		entry.Comment = "no response recorded"
+		return entry
+	}
+	entry.Response.Status = x.response.Status
+	entry.Response.StatusText = http.StatusText(x.response.Status)
+	entry.Response.Headers = harHeaders(x.response.Header)
+	entry.Response.Content = x.harContent(maxBody)
+	entry.Timings.Wait = ms(x.response.Time.Sub(x.request.Time))
+	end := x.response.Time
+	if len(x.lines) > 0 {
+		end = x.lines[len(x.lines)-1].Time
+	}
+	if x.end != nil {
+		end = x.end.Time
+	}
+	entry.Timings.Receive = ms(end.Sub(x.response.Time))
+	entry.Time = entry.Timings.Wait + entry.Timings.Receive
+	return entry
+}
+
+func (x *exchange) harContent(maxBody int) harContent {
+	content := harContent{MimeType: x.response.Header.Get("Content-Type")}
+	mediaType, _, _ := mime.ParseMediaType(content.MimeType)
+	streamed := mediaType == "text/enriched"
+	var text strings.Builder
+	var truncated bool
+	for _, line := range x.lines {
+		content.Size += len(line.Body) + 2
+		if truncated {
+			continue
+		}
+		if text.Len()+len(line.Body)+2 > maxBody {
+			truncated = true
+			continue
+		}
+		text.WriteString(line.Body + "\r\n")
+		if streamed {
+			content.Chunks = append(content.Chunks, harChunk{Offset: ms(line.Time.Sub(x.response.Time)), Text: line.Body})
+		}
+	}
+	content.Text = text.String()
+	var comments []string
+	if streamed {
+		comments = append(comments, fmt.Sprintf("streamed: %d lines", len(x.lines)))
+	}
+	if truncated {
+		comments = append(comments, fmt.Sprintf("truncated after %d of %d bytes", text.Len(), content.Size))
+	}
+	if x.end == nil {
+		comments = append(comments, "stream still open when the recording stopped")
+	} else if x.end.Error != "" {
+		comments = append(comments, "stream ended: "+x.end.Error)
+	}
+	content.Comment = strings.Join(comments, "; ")
+	return content
+}
+
+func harHeaders(h http.Header) []harNameValue {
+	headers := []harNameValue{}
+	for _, name := range slices.Sorted(maps.Keys(h)) {
+		for _, value := range h[name] {
+			headers = append(headers, harNameValue{Name: name, Value: value})
+		}
+	}
+	return headers
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,60 @@
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestWriteHAR(t *testing.T) {
+	recording := strings.Join([]string{
+		`{"time":"2025-03-01T12:00:00Z","type":"request","id":1,"method":"POST","url":"https://push.lightstreamer.com/lightstreamer/create_session.txt?LS_protocol=TLCP-2.5.0","header":{"Content-Type":["application/x-www-form-urlencoded"]},"body":"LS_cid=foo&LS_password=REDACTED"}`,
+		`{"time":"2025-03-01T12:00:00Z","type":"request","id":2,"method":"POST","url":"https://push.lightstreamer.com/lightstreamer/control.txt"}`,
+		`{"time":"2025-03-01T12:00:00.1Z","type":"response","id":1,"status":200,"header":{"Content-Type":["text/enriched; charset=UTF-8"]}}`,
+		`{"time":"2025-03-01T12:00:00.2Z","type":"line","id":1,"body":"CONOK,S1,50000,5000,*"}`,
+		`{"time":"2025-03-01T12:00:00.3Z","type":"error","id":2,"error":"connection refused"}`,
+		`{"time":"2025-03-01T12:00:01.1Z","type":"line","id":1,"body":"SUBOK,1,1,3"}`,
+		`{"time":"2025-03-01T12:00:05.1Z","type":"line","id":1,"body":"U,1,1,42|OK|1"}`,
+		`{"time":"2025-03-01T12:00:06Z","type":"end","id":1,"error":"context canceled"}`,
+	}, "\n")
+
+	var out bytes.Buffer
+	if err := WriteHAR(&out, strings.NewReader(recording), "test", 40); err != nil {
+		t.Fatal(err)
+	}
+	var doc har
+	if err := json.Unmarshal(out.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Log.Version != "1.2" || len(doc.Log.Entries) != 2 {
+		t.Fatalf("got %+v", doc.Log)
+	}
+
+	stream := doc.Log.Entries[0]
+	if stream.Request.PostData == nil || stream.Request.PostData.Text != "LS_cid=foo&LS_password=REDACTED" {
+		t.Errorf("got post data %+v", stream.Request.PostData)
+	}
+	if len(stream.Request.QueryString) != 1 || stream.Request.QueryString[0].Name != "LS_protocol" {
+		t.Errorf("got query string %+v", stream.Request.QueryString)
+	}
+	content := stream.Response.Content
+	if content.Text != "CONOK,S1,50000,5000,*\r\nSUBOK,1,1,3\r\n" {
+		t.Errorf("got content %q", content.Text)
+	}
+	if len(content.Chunks) != 2 || content.Chunks[1].Offset != 1000 {
+		t.Errorf("got chunks %+v", content.Chunks)
+	}
+	for _, want := range []string{"streamed: 3 lines", "truncated after 36 of 51 bytes", "stream ended: context canceled"} {
+		if !strings.Contains(content.Comment, want) {
+			t.Errorf("comment %q does not contain %q", content.Comment, want)
+		}
+	}
+	if stream.Timings.Wait != 100 || stream.Timings.Receive != 5900 || stream.Time != 6000 {
+		t.Errorf("got timings %+v, time %v", stream.Timings, stream.Time)
+	}
+
+	if failed := doc.Log.Entries[1]; failed.Comment != "request failed: connection refused" || failed.Time != 300 {
+		t.Errorf("got %+v", failed)
+	}
+}
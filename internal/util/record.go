@@ -0,0 +1,326 @@
+package util
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// A RecordingRoundTripper records the HTTP exchanges of an http.Client, e.g. the TLCP exchanges of a
+// lightstreamer.ClientSession, so they can be replayed by a ReplayRoundTripper.
+//
+// A recording has one JSON-encoded Event per line: the request, the response's status and headers, each line of the
+// response body, as it's read, and the end of the body or the request's error. Request bodies are recorded in full.
+// Credentials are redacted, as configured by Redact.
+type RecordingRoundTripper struct {
+	// Next performs the requests. Defaults to http.DefaultTransport.
+	Next http.RoundTripper
+	// Redact lists the values that aren't recorded. Defaults to DefaultRedaction.
+	Redact *Redaction
+	lock   sync.Mutex
+	enc    *json.Encoder
+	id     int
+}
+
+// NewRecordingRoundTripper returns a RecordingRoundTripper that writes its recording to w.
+func NewRecordingRoundTripper(w io.Writer) *RecordingRoundTripper {
+	return &RecordingRoundTripper{enc: json.NewEncoder(w)}
+}
+
+// An Event is an entry of a recording. ID identifies the exchange that the event is part of.
+type Event struct {
+	Time   time.Time   `json:"time"`
+	Type   EventType   `json:"type"`
+	ID     int         `json:"id"`
+	Method string      `json:"method,omitempty"`
+	URL    string      `json:"url,omitempty"`
+	Status int         `json:"status,omitempty"`
+	Header http.Header `json:"header,omitempty"`
+	// Body is the body of a request, or a line of a response body, without its line terminator.
+	Body  string `json:"body,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// EventType is the type of Event.
+type EventType string
+
+const (
+	EventRequest  EventType = "request"
+	EventResponse EventType = "response"
+	EventLine     EventType = "line"
+	EventEnd      EventType = "end"
+	EventError    EventType = "error"
+)
+
+func (r *RecordingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	redact := DefaultRedaction
+	if r.Redact != nil {
+		redact = *r.Redact
+	}
+	req = req.Clone(req.Context())
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		_ = req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	u := *req.URL
+	u.RawQuery = redact.form(u.RawQuery)
+
+	r.lock.Lock()
+	r.id++
+	id := r.id
+	r.lock.Unlock()
+	r.record(Event{Type: EventRequest, ID: id, Method: req.Method, URL: u.String(), Header: redact.header(req.Header), Body: redact.form(string(body))})
+
+	next := r.Next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	resp, err := next.RoundTrip(req)
+	if err != nil {
+		r.record(Event{Type: EventError, ID: id, Error: err.Error()})
+		return resp, err
+	}
+	r.record(Event{Type: EventResponse, ID: id, Status: resp.StatusCode, Header: redact.header(resp.Header)})
+	resp.Body = &lineRecorder{ReadCloser: resp.Body, recorder: r, id: id}
+	return resp, nil
+}
+
+func (r *RecordingRoundTripper) record(e Event) {
+	e.Time = time.Now()
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	_ = r.enc.Encode(e)
+}
+
+// lineRecorder is a response body that records each line as it's read.
+type lineRecorder struct {
+	io.ReadCloser
+	recorder *RecordingRoundTripper
+	lock     sync.Mutex
+	partial  []byte
+	id       int
+	ended    bool
+}
+
+func (l *lineRecorder) Read(p []byte) (int, error) {
+	n, err := l.ReadCloser.Read(p)
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.partial = append(l.partial, p[:n]...)
+	for {
+		i := bytes.IndexByte(l.partial, '\n')
+		if i < 0 {
+			break
+		}
+		l.recorder.record(Event{Type: EventLine, ID: l.id, Body: string(bytes.TrimSuffix(l.partial[:i], []byte("\r")))})
+		l.partial = l.partial[i+1:]
+	}
+	if err != nil {
+		l.end(err)
+	}
+	return n, err
+}
+
+func (l *lineRecorder) Close() error {
+	l.lock.Lock()
+	l.end(nil)
+	l.lock.Unlock()
+	return l.ReadCloser.Close()
+}
+
+// end records the last, unterminated, line, if any, and the end of the body. err is recorded, unless it's io.EOF.
+func (l *lineRecorder) end(err error) {
+	if l.ended {
+		return
+	}
+	l.ended = true
+	if len(l.partial) > 0 {
+		l.recorder.record(Event{Type: EventLine, ID: l.id, Body: string(l.partial)})
+	}
+	e := Event{Type: EventEnd, ID: l.id}
+	if err != nil && !errors.Is(err, io.EOF) {
+		e.Error = err.Error()
+	}
+	l.recorder.record(e)
+}
+
+// A ReplayRoundTripper serves the exchanges of a recording made by a RecordingRoundTripper. Each request is answered
+// by the next recorded exchange with the same method and URL path: query strings and bodies aren't compared. Response
+// lines are served on the schedule of the recording.
+type ReplayRoundTripper struct {
+	// Speed scales the time between response lines: 2 replays the recording twice as fast. Zero replays it in real time.
+	Speed     float64
+	lock      sync.Mutex
+	exchanges []*exchange
+}
+
+type exchange struct {
+	request  Event
+	response *Event
+	lines    []Event
+	end      *Event
+	err      *Event
+	replayed bool
+}
+
+// ReadRecording reads a recording made by a RecordingRoundTripper.
+func ReadRecording(r io.Reader) (*ReplayRoundTripper, error) {
+	exchanges, err := readExchanges(r)
+	if err != nil {
+		return nil, err
+	}
+	return &ReplayRoundTripper{exchanges: exchanges}, nil
+}
+
+// readExchanges reads the exchanges of a recording, in the order of their requests.
+func readExchanges(r io.Reader) ([]*exchange, error) {
+	byID := make(map[int]*exchange)
+	var exchanges []*exchange
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if e.Type == EventRequest {
+			x := &exchange{request: e}
+			byID[e.ID] = x
+			exchanges = append(exchanges, x)
+			continue
+		}
+		x, ok := byID[e.ID]
+		if !ok {
+			return nil, fmt.Errorf("line %d: %s event for unknown request %d", line, e.Type, e.ID)
+		}
+		switch e.Type {
+		case EventResponse:
+			x.response = &e
+		case EventLine:
+			x.lines = append(x.lines, e)
+		case EventEnd:
+			x.end = &e
+		case EventError:
+			x.err = &e
+		default:
+			return nil, fmt.Errorf("line %d: unknown event type %q", line, e.Type)
+		}
+	}
+	return exchanges, scanner.Err()
+}
+
+func (r *ReplayRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		_ = req.Body.Close()
+	}
+	x, err := r.next(req)
+	if err != nil {
+		return nil, err
+	}
+	if x.err != nil {
+		return nil, errors.New(x.err.Error)
+	}
+	if x.response == nil {
+		return nil, fmt.Errorf("%s %s: no recorded response", req.Method, req.URL.Path)
+	}
+	pr, pw := io.Pipe()
+	body := &replayBody{PipeReader: pr, closed: make(chan struct{})}
+	go r.serve(req, x, pw, body.closed)
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", x.response.Status, http.StatusText(x.response.Status)),
+		StatusCode:    x.response.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        x.response.Header.Clone(),
+		Body:          body,
+		ContentLength: -1,
+		Request:       req,
+	}, nil
+}
+
+// next returns the next exchange matching req, and marks it as replayed.
+func (r *ReplayRoundTripper) next(req *http.Request) (*exchange, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for _, x := range r.exchanges {
+		if x.replayed || x.request.Method != req.Method {
+			continue
+		}
+		if path, err := urlPath(x.request.URL); err != nil || path != req.URL.Path {
+			continue
+		}
+		x.replayed = true
+		return x, nil
+	}
+	return nil, fmt.Errorf("%s %s: no recorded exchange left", req.Method, req.URL.Path)
+}
+
+func urlPath(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	return u.Path, nil
+}
+
+// serve writes the response lines to w, on the schedule of the recording, until all lines are written, the request
+// is canceled or the body is closed.
+func (r *ReplayRoundTripper) serve(req *http.Request, x *exchange, w *io.PipeWriter, closed <-chan struct{}) {
+	start := time.Now()
+	for _, line := range x.lines {
+		delay := line.Time.Sub(x.response.Time)
+		if r.Speed > 0 {
+			delay = time.Duration(float64(delay) / r.Speed)
+		}
+		select {
+		case <-req.Context().Done():
+			_ = w.CloseWithError(req.Context().Err())
+			return
+		case <-closed:
+			return
+		case <-time.After(time.Until(start.Add(delay))):
+		}
+		if _, err := io.WriteString(w, line.Body+"\r\n"); err != nil {
+			return
+		}
+	}
+	if x.end == nil {
+		// the recording stopped while the body was still being read: keep the stream open, as the server did
+		select {
+		case <-req.Context().Done():
+			_ = w.CloseWithError(req.Context().Err())
+		case <-closed:
+		}
+		return
+	}
+	if x.end.Error != "" {
+		_ = w.CloseWithError(errors.New(x.end.Error))
+		return
+	}
+	_ = w.Close()
+}
+
+// replayBody is a replayed response body. closed is closed when the body is.
+type replayBody struct {
+	*io.PipeReader
+	closed chan struct{}
+	once   sync.Once
+}
+
+func (b *replayBody) Close() error {
+	b.once.Do(func() { close(b.closed) })
+	return b.PipeReader.Close()
+}
@@ -0,0 +1,118 @@
+package util
+
+import (
+	"bytes"
+	"github.com/clambin/iss-exporter/lightstreamer"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordingRoundTripper_Replay(t *testing.T) {
+	l := slog.New(slog.DiscardHandler)
+	s := lightstreamer.NewServer("set", "cid", map[string]lightstreamer.AdapterSet{"DEFAULT": {"A": lightstreamer.InjectAdapter{Name: "A", Fields: 1}}}, l)
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+
+	// record a session
+	var recording bytes.Buffer
+	recorder := NewRecordingRoundTripper(&recording)
+	recorder.Next = ts.Client().Transport
+	received := subscribe(t, ts.URL, recorder)
+	time.Sleep(100 * time.Millisecond)
+	value := lightstreamer.Value("42")
+	s.Publish("set", "DEFAULT", "A", 1, lightstreamer.Values{&value})
+	if got := <-received; got != "42" {
+		t.Fatalf("got %q, want 42", got)
+	}
+
+	if strings.Contains(recording.String(), "secret") {
+		t.Errorf("recording contains credentials:\n%s", recording.String())
+	}
+
+	// replay it, without the server
+	replay, err := ReadRecording(bytes.NewReader(recording.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	received = subscribe(t, "http://replay.invalid", replay)
+	select {
+	case got := <-received:
+		if got != "42" {
+			t.Errorf("got %q, want 42", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for replayed update")
+	}
+}
+
+// subscribe creates a session with the server at url, through transport, and subscribes to group A. Updates are sent
+// to the returned channel.
+func subscribe(t *testing.T, url string, transport http.RoundTripper) <-chan string {
+	t.Helper()
+	session := lightstreamer.NewClientSession(
+		lightstreamer.WithLogger(slog.New(slog.DiscardHandler)),
+		lightstreamer.WithServerURL(url),
+		lightstreamer.WithHTTPClient(&http.Client{Transport: transport}),
+		lightstreamer.WithAdapterSet("set"),
+		lightstreamer.WithCID("cid"),
+		lightstreamer.WithCredentials("user", "secret"),
+	)
+	if err := session.ConnectWithSession(t.Context(), time.Second); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(session.Disconnect)
+	received := make(chan string, 1)
+	err := session.Subscribe(t.Context(), "DEFAULT", "A", []string{"Value"}, 0, func(_ int, values lightstreamer.Values) {
+		if len(values) > 0 && values[0] != nil {
+			select {
+			case received <- string(*values[0]):
+			default:
+			}
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return received
+}
+
+func TestReadRecording_Invalid(t *testing.T) {
+	tests := []struct {
+		name      string
+		recording string
+	}{
+		{name: "invalid json", recording: "{"},
+		{name: "unknown request", recording: `{"type":"line","id":1,"body":"PROBE"}`},
+		{name: "unknown type", recording: `{"type":"request","id":1}` + "\n" + `{"type":"foo","id":1}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ReadRecording(strings.NewReader(tt.recording)); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestReplayRoundTripper_NoExchange(t *testing.T) {
+	replay, err := ReadRecording(strings.NewReader(`{"type":"request","id":1,"method":"GET","url":"http://localhost/foo"}` + "\n" + `{"type":"response","id":1,"status":200}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := http.Client{Transport: replay}
+	if _, err = c.Get("http://localhost/bar"); err == nil {
+		t.Error("expected an error for an unrecorded path")
+	}
+	resp, err := c.Get("http://localhost/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if _, err = c.Get("http://localhost/foo"); err == nil {
+		t.Error("expected an error once the exchange has been replayed")
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/clambin/iss-exporter/internal/collector"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// kafkaOutput produces telemetry updates to Kafka, as JSON messages keyed by their group, so a group's updates stay
+// in order in a single partition. Updates go to one topic, or to a topic per group, if the topic contains {group}.
+// The producer buffers up to batchSize updates, and waits at most linger for more updates before producing them.
+type kafkaOutput struct {
+	brokers   []string
+	options   []kgo.Opt
+	topic     string
+	batchSize int
+	linger    time.Duration
+	errors    prometheus.Counter
+	dropped   prometheus.Counter
+	logger    *slog.Logger
+}
+
+// validateKafkaTopic checks a topic pattern: a topic name, in which {group} is replaced by the group ID.
+func validateKafkaTopic(pattern string) error {
+	topic := strings.ReplaceAll(pattern, "{group}", "X")
+	if topic == "" || topic == "." || topic == ".." {
+		return errors.New("invalid topic name")
+	}
+	if len(topic) > 249 {
+		return errors.New("topic name too long")
+	}
+	for _, c := range topic {
+		if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (c < '0' || c > '9') && c != '.' && c != '_' && c != '-' {
+			return fmt.Errorf("invalid character %q: topic names may only contain letters, digits, '.', '_' and '-'", c)
+		}
+	}
+	return nil
+}
+
+// parseKafkaAcks returns the producer options of an acknowledgement level: none, leader or all. Idempotent writes
+// require all in-sync replicas to acknowledge, so they're disabled for the other levels.
+func parseKafkaAcks(s string) ([]kgo.Opt, error) {
+	switch s {
+	case "none":
+		return []kgo.Opt{kgo.RequiredAcks(kgo.NoAck()), kgo.DisableIdempotentWrite()}, nil
+	case "leader":
+		return []kgo.Opt{kgo.RequiredAcks(kgo.LeaderAck()), kgo.DisableIdempotentWrite()}, nil
+	case "all":
+		return []kgo.Opt{kgo.RequiredAcks(kgo.AllISRAcks())}, nil
+	default:
+		return nil, fmt.Errorf("%q: must be none, leader or all", s)
+	}
+}
+
+// kafkaDeliveryTimeout is the time allowed to produce an update, including retries, before it's dropped.
+const kafkaDeliveryTimeout = 30 * time.Second
+
+func (o *kafkaOutput) record(u collector.Update) *kgo.Record {
+	value, _ := json.Marshal(u)
+	return &kgo.Record{
+		Topic:     strings.ReplaceAll(o.topic, "{group}", u.Group),
+		Key:       []byte(u.Group),
+		Value:     value,
+		Timestamp: u.Timestamp,
+	}
+}
+
+// run produces the updates until ctx is canceled. If an update fails, the updates buffered by the producer are
+// dropped, and the producer reconnects to the cluster.
+func (o *kafkaOutput) run(ctx context.Context, updates <-chan collector.Update, retry time.Duration, maxRetry time.Duration) {
+	runOutput(ctx, "kafka", func(ctx context.Context) error {
+		client, err := kgo.NewClient(append([]kgo.Opt{
+			kgo.SeedBrokers(o.brokers...),
+			kgo.MaxBufferedRecords(o.batchSize),
+			kgo.ProducerLinger(o.linger),
+			kgo.RecordDeliveryTimeout(kafkaDeliveryTimeout),
+		}, o.options...)...)
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+		return o.produce(ctx, client, updates)
+	}, retry, maxRetry, o.errors, o.logger)
+}
+
+// produce produces the updates, until an update fails or ctx is canceled. The buffered updates are still produced
+// when ctx is canceled.
+func (o *kafkaOutput) produce(ctx context.Context, client *kgo.Client, updates <-chan collector.Update) error {
+	failed := make(chan error, 1)
+	promise := func(_ *kgo.Record, err error) {
+		if err == nil {
+			return
+		}
+		o.dropped.Inc()
+		select {
+		case failed <- err:
+		default:
+		}
+	}
+	// the producer fails buffered updates whose context is canceled: they're bounded by the delivery timeout instead
+	produceCtx := context.WithoutCancel(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(produceCtx, kafkaDeliveryTimeout)
+			defer cancel()
+			if err := client.Flush(flushCtx); err != nil {
+				o.logger.Warn("failed to produce the last updates", "err", err)
+			}
+			return ctx.Err()
+		case err := <-failed:
+			return fmt.Errorf("produce: %w", err)
+		case u := <-updates:
+			client.Produce(produceCtx, o.record(u), promise)
+		}
+	}
+}
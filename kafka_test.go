@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/binary"
+	"github.com/clambin/iss-exporter/internal/collector"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/twmb/franz-go/pkg/kmsg"
+	"io"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateKafkaTopic(t *testing.T) {
+	for topic, pass := range map[string]bool{"iss-telemetry": true, "iss.{group}": true, "": false, "..": false, "iss telemetry": false, "iss/x": false, strings.Repeat("x", 250): false} {
+		if err := validateKafkaTopic(topic); (err == nil) != pass {
+			t.Errorf("%q: got error %v, want pass %v", topic, err, pass)
+		}
+	}
+}
+
+func TestParseKafkaAcks(t *testing.T) {
+	for acks, pass := range map[string]bool{"none": true, "leader": true, "all": true, "": false, "1": false} {
+		if _, err := parseKafkaAcks(acks); (err == nil) != pass {
+			t.Errorf("%q: got error %v, want pass %v", acks, err, pass)
+		}
+	}
+}
+
+func TestKafkaOutput(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+	requests := make(chan int, 10)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveKafka(conn, requests)
+		}
+	}()
+
+	acks, _ := parseKafkaAcks("none")
+	o := kafkaOutput{
+		brokers:   []string{l.Addr().String()},
+		options:   acks,
+		topic:     "iss-{group}",
+		batchSize: 2,
+		linger:    10 * time.Millisecond,
+		errors:    prometheus.NewCounter(prometheus.CounterOpts{Name: "errors"}),
+		dropped:   prometheus.NewCounter(prometheus.CounterOpts{Name: "dropped"}),
+		logger:    slog.New(slog.DiscardHandler),
+	}
+	updates := make(chan collector.Update)
+	go o.run(t.Context(), updates, 10*time.Millisecond, 10*time.Millisecond)
+	// the updates are produced once the producer stops lingering, with a topic per group
+	updates <- collector.Update{Group: "A", Value: 1}
+	updates <- collector.Update{Group: "B", Value: 2}
+	for topics := 0; topics < 2; {
+		select {
+		case got := <-requests:
+			topics += got
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for a produce request")
+		}
+	}
+	var m dto.Metric
+	if _ = o.dropped.Write(&m); m.GetCounter().GetValue() != 0 {
+		t.Errorf("got %v dropped updates, want 0", m.GetCounter().GetValue())
+	}
+}
+
+// serveKafka answers the API versions, metadata and produce requests of a producer, as a single-broker cluster where
+// every topic has one partition. It reports the number of topics of each produce request.
+func serveKafka(conn net.Conn, requests chan<- int) {
+	defer func() { _ = conn.Close() }()
+	host, port, _ := net.SplitHostPort(conn.LocalAddr().String())
+	portNumber, _ := strconv.Atoi(port)
+	for {
+		var size [4]byte
+		if _, err := io.ReadFull(conn, size[:]); err != nil {
+			return
+		}
+		request := make([]byte, binary.BigEndian.Uint32(size[:]))
+		if _, err := io.ReadFull(conn, request); err != nil {
+			return
+		}
+		req := kmsg.RequestForKey(int16(binary.BigEndian.Uint16(request)))
+		if req == nil {
+			return
+		}
+		req.SetVersion(int16(binary.BigEndian.Uint16(request[2:])))
+		correlationID := request[4:8]
+		body := request[10+max(0, int16(binary.BigEndian.Uint16(request[8:]))):] // skip the client ID
+		if req.IsFlexible() {
+			body = body[1:] // no tagged fields
+		}
+		if err := req.ReadFrom(body); err != nil {
+			return
+		}
+		var resp kmsg.Response
+		switch req := req.(type) {
+		case *kmsg.ApiVersionsRequest:
+			r := kmsg.NewPtrApiVersionsResponse()
+			for key, maxVersion := range map[int16]int16{0: 7, 3: 4, 18: 3} { // produce, metadata, API versions
+				r.ApiKeys = append(r.ApiKeys, kmsg.ApiVersionsResponseApiKey{ApiKey: key, MaxVersion: maxVersion})
+			}
+			resp = r
+		case *kmsg.MetadataRequest:
+			r := kmsg.NewPtrMetadataResponse()
+			r.Brokers = []kmsg.MetadataResponseBroker{{NodeID: 1, Host: host, Port: int32(portNumber)}}
+			r.ControllerID = 1
+			for _, topic := range req.Topics {
+				t := kmsg.NewMetadataResponseTopic()
+				t.Topic = topic.Topic
+				t.Partitions = []kmsg.MetadataResponseTopicPartition{{Leader: 1, Replicas: []int32{1}, ISR: []int32{1}}}
+				r.Topics = append(r.Topics, t)
+			}
+			resp = r
+		case *kmsg.ProduceRequest: // acks=none, so no response
+			requests <- len(req.Topics)
+			continue
+		default:
+			return
+		}
+		resp.SetVersion(req.GetVersion())
+		response := append([]byte(nil), correlationID...)
+		if resp.IsFlexible() && req.Key() != kmsg.ApiVersions.Int16() {
+			response = append(response, 0) // no tagged fields
+		}
+		response = resp.AppendTo(response)
+		if _, err := conn.Write(append(binary.BigEndian.AppendUint32(nil, uint32(len(response))), response...)); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package lightstreamer
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// SessionInfo describes an active session.
+type SessionInfo struct {
+	Created       time.Time          `json:"created"`
+	LastWritten   time.Time          `json:"last_written"`
+	ID            string             `json:"id"`
+	AdapterSet    string             `json:"adapter_set"`
+	ClientIP      string             `json:"client_ip"`
+	Subscriptions []SubscriptionInfo `json:"subscriptions"`
+	Queued        int                `json:"queued"`
+	Bound         bool               `json:"bound"`
+}
+
+// SubscriptionInfo describes a subscription of an active session.
+type SubscriptionInfo struct {
+	DataAdapter string `json:"data_adapter"`
+	Group       string `json:"group"`
+	Mode        string `json:"mode"`
+	ID          int    `json:"id"`
+}
+
+// Healthy reports that the Server is serving. It never fails: a Server with no sessions is healthy too.
+func (s *Server) Healthy(context.Context) error {
+	return nil
+}
+
+// Details returns the active sessions.
+func (s *Server) Details() any {
+	return s.Sessions()
+}
+
+// Sessions returns all active sessions, ordered by session ID.
+func (s *Server) Sessions() []SessionInfo {
+	s.lock.Lock()
+	sessions := make([]*session, 0, len(s.sessions))
+	for _, sess := range s.sessions {
+		sessions = append(sessions, sess)
+	}
+	s.lock.Unlock()
+
+	infos := make([]SessionInfo, len(sessions))
+	for i, sess := range sessions {
+		infos[i] = sess.info()
+	}
+	slices.SortFunc(infos, func(a, b SessionInfo) int {
+		return cmp.Or(cmp.Compare(len(a.ID), len(b.ID)), cmp.Compare(a.ID, b.ID))
+	})
+	return infos
+}
+
+// CloseSession closes the session: the client receives an END message and the session can no longer be rebound.
+// It returns false if the session does not exist.
+func (s *Server) CloseSession(sessionID string) bool {
+	sess, ok := s.getSession(sessionID)
+	if ok {
+		sess.end(endCodeClosedByAdmin, "closed by administrator")
+		s.removeSession(sessionID)
+	}
+	return ok
+}
+
+// AdminHandler returns an http.Handler to manage the server's sessions:
+//
+//   - GET /sessions lists all active sessions
+//   - DELETE /sessions/{id} closes a session
+//
+// The handler is not protected in any way: only expose it on a trusted network.
+func (s *Server) AdminHandler() http.Handler {
+	m := http.NewServeMux()
+	m.HandleFunc("GET /sessions", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(s.Sessions())
+	})
+	m.HandleFunc("DELETE /sessions/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if !s.CloseSession(r.PathValue("id")) {
+			http.Error(w, "session not found", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return m
+}
+
+func (s *session) info() SessionInfo {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	info := SessionInfo{
+		ID:            s.sessionID,
+		AdapterSet:    s.adapterSet,
+		ClientIP:      s.clientIP,
+		Created:       s.created,
+		LastWritten:   s.lastWritten,
+		Bound:         s.stream != nil,
+		Queued:        s.queue.len(),
+		Subscriptions: make([]SubscriptionInfo, 0, len(s.subscriptions)),
+	}
+	for id, sub := range s.subscriptions {
+		info.Subscriptions = append(info.Subscriptions, SubscriptionInfo{
+			ID:          id,
+			DataAdapter: sub.dataAdapter,
+			Group:       sub.group,
+			Mode:        sub.mode,
+		})
+	}
+	slices.SortFunc(info.Subscriptions, func(a, b SubscriptionInfo) int { return a.ID - b.ID })
+	return info
+}
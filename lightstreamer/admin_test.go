@@ -0,0 +1,73 @@
+package lightstreamer
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestServer_AdminHandler(t *testing.T) {
+	s := NewServer("set", "cid", map[string]AdapterSet{"DEFAULT": {"1": &timedAdapter{}}}, slog.New(slog.DiscardHandler))
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+	admin := httptest.NewServer(s.AdminHandler())
+	t.Cleanup(admin.Close)
+
+	lines := postStream(t, t.Context(), ts.URL+"/create_session.txt", url.Values{"LS_adapter_set": {"set"}, "LS_cid": {"cid"}})
+	sessionID := strings.Split(nextLine(t, lines), ",")[1]
+	if err := s.subscribe(controlCommand{SessionID: sessionID, Group: "1", Mode: ModeMerge, Schema: "Value", SubId: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := http.Get(admin.URL + "/sessions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sessions []SessionInfo
+	err = json.NewDecoder(resp.Body).Decode(&sessions)
+	_ = resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 {
+		t.Fatalf("got %d sessions, want 1", len(sessions))
+	}
+	if got := sessions[0]; got.ID != sessionID || got.AdapterSet != "set" || got.ClientIP != "127.0.0.1" || !got.Bound || got.Created.IsZero() || got.LastWritten.IsZero() {
+		t.Errorf("unexpected session info: %+v", got)
+	}
+	if got := sessions[0].Subscriptions; len(got) != 1 || got[0] != (SubscriptionInfo{ID: 1, DataAdapter: "DEFAULT", Group: "1", Mode: ModeMerge}) {
+		t.Errorf("unexpected subscriptions: %+v", got)
+	}
+
+	req, _ := http.NewRequest(http.MethodDelete, admin.URL+"/sessions/"+sessionID, nil)
+	if resp, err = http.DefaultClient.Do(req); err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("got %d, want %d", resp.StatusCode, http.StatusNoContent)
+	}
+	var last string
+	for !strings.HasPrefix(last, "END,") {
+		last = nextLine(t, lines)
+	}
+	if want := "END," + strconv.Itoa(endCodeClosedByAdmin) + ",closed by administrator"; last != want {
+		t.Errorf("got %q, want %q", last, want)
+	}
+	if got := s.Sessions(); len(got) != 0 {
+		t.Errorf("got %d sessions, want 0", len(got))
+	}
+
+	if resp, err = http.DefaultClient.Do(req); err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("got %d, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+}
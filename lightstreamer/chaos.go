@@ -0,0 +1,41 @@
+package lightstreamer
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// Chaos configures faults injected by the server, to verify a client's resilience (reconnects, recovery, watchdogs)
+// against a misbehaving server. Chaos is intended for testing only. The zero value injects no faults.
+type Chaos struct {
+	// Latency delays every line sent to the client.
+	Latency time.Duration
+	// DisconnectInterval is the mean time after which the server abruptly closes a stream, without sending LOOP
+	// or END. The session itself remains available, so the client can bind a new stream.
+	DisconnectInterval time.Duration
+	// SubscriptionDelay delays the SUBOK message that confirms a subscription.
+	SubscriptionDelay time.Duration
+	// DropRate is the fraction, between 0 and 1, of updates that are silently dropped.
+	DropRate float64
+}
+
+// dropUpdate reports whether the next update should be dropped.
+func (c Chaos) dropUpdate() bool {
+	return c.DropRate > 0 && rand.Float64() < c.DropRate
+}
+
+// disconnectAfter returns the time after which a new stream should be disconnected. It returns zero if streams
+// shouldn't be disconnected. Disconnects are exponentially distributed, with DisconnectInterval as the mean.
+func (c Chaos) disconnectAfter() time.Duration {
+	if c.DisconnectInterval <= 0 {
+		return 0
+	}
+	return max(time.Duration(rand.ExpFloat64()*float64(c.DisconnectInterval)), time.Millisecond)
+}
+
+// WithChaos injects the configured faults. This is intended to test clients against a misbehaving server.
+func WithChaos(chaos Chaos) ServerOption {
+	return func(s *Server) {
+		s.chaos = chaos
+	}
+}
@@ -0,0 +1,124 @@
+package lightstreamer
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChaos_DropUpdate(t *testing.T) {
+	tests := []struct {
+		name string
+		rate float64
+		want bool
+	}{
+		{name: "never", rate: 0, want: false},
+		{name: "always", rate: 1, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Chaos{DropRate: tt.rate}
+			for range 100 {
+				if got := c.dropUpdate(); got != tt.want {
+					t.Fatalf("got %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestChaos_DisconnectAfter(t *testing.T) {
+	if got := (Chaos{}).disconnectAfter(); got != 0 {
+		t.Errorf("got %v, want 0", got)
+	}
+	c := Chaos{DisconnectInterval: time.Second}
+	for range 100 {
+		if got := c.disconnectAfter(); got <= 0 {
+			t.Fatalf("got %v, want > 0", got)
+		}
+	}
+}
+
+func TestServer_Chaos(t *testing.T) {
+	tests := []struct {
+		name  string
+		chaos Chaos
+		check func(t *testing.T, ts *httptest.Server)
+	}{
+		{
+			name:  "latency",
+			chaos: Chaos{Latency: 100 * time.Millisecond},
+			check: func(t *testing.T, ts *httptest.Server) {
+				start := time.Now()
+				_, lines := createSession(t, ts)
+				_ = nextLine(t, lines)
+				if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+					t.Errorf("got first two lines after %v, want at least 200ms", elapsed)
+				}
+			},
+		},
+		{
+			name:  "subscription delay",
+			chaos: Chaos{SubscriptionDelay: 200 * time.Millisecond},
+			check: func(t *testing.T, ts *httptest.Server) {
+				sessionID, lines := createSession(t, ts)
+				start := time.Now()
+				resp := post(t, t.Context(), ts.URL+"/control.txt", url.Values{
+					"LS_op": {"add"}, "LS_reqId": {"1"}, "LS_session": {sessionID}, "LS_subId": {"1"},
+					"LS_group": {"1"}, "LS_schema": {"Value"}, "LS_mode": {ModeMerge},
+				})
+				if body, _ := io.ReadAll(resp.Body); string(body) != "REQOK,1\n" {
+					t.Fatalf("got %q, want REQOK", string(body))
+				}
+				for line := nextLine(t, lines); !strings.HasPrefix(line, "SUBOK,"); line = nextLine(t, lines) {
+				}
+				if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+					t.Errorf("got SUBOK after %v, want at least 200ms", elapsed)
+				}
+			},
+		},
+		{
+			name:  "disconnect",
+			chaos: Chaos{DisconnectInterval: 50 * time.Millisecond},
+			check: func(t *testing.T, ts *httptest.Server) {
+				_, lines := createSession(t, ts)
+				start := time.Now()
+				for lines.Scan() {
+				}
+				if elapsed := time.Since(start); elapsed > 3*time.Second {
+					t.Errorf("stream closed after %v, want it to be disconnected", elapsed)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var a timedAdapter
+			s := NewServer("set", "cid", map[string]AdapterSet{"DEFAULT": {"1": &a}}, slog.New(slog.DiscardHandler), WithChaos(tt.chaos))
+			ts := httptest.NewServer(s)
+			t.Cleanup(ts.Close)
+			tt.check(t, ts)
+		})
+	}
+}
+
+// createSession creates a session and returns its ID and stream, positioned after the CONOK message.
+func createSession(t *testing.T, ts *httptest.Server) (string, *bufio.Scanner) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	t.Cleanup(cancel)
+	lines := postStream(t, ctx, ts.URL+"/create_session.txt", url.Values{"LS_adapter_set": {"set"}, "LS_cid": {"cid"}})
+	conOK := nextLine(t, lines)
+	parts := strings.Split(conOK, ",")
+	if parts[0] != "CONOK" || len(parts) < 2 {
+		t.Fatalf("got %q, want CONOK", conOK)
+	}
+	return parts[1], lines
+}
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"cmp"
+	"container/list"
 	"context"
 	"errors"
 	"fmt"
@@ -21,25 +22,37 @@ import (
 
 const (
 	serverURL  = "https://push.lightstreamer.com/lightstreamer"
-	defaultCID = "mgQkwtwdysogQz2BJ4Ji%20kOj2Bg"
 	lsProtocol = "TLCP-2.1.0"
 )
 
+// DefaultCID is the client ID sent by a ClientSession, unless configured otherwise with WithCID. It is the client ID
+// of the ISSLIVE feed on the public Lightstreamer server.
+const DefaultCID = "mgQkwtwdysogQz2BJ4Ji%20kOj2Bg"
+
 // A ClientSession establishes and manages a client session with a LightStreamer server.
 // Its main usage is to subscribe to one or more feeds from the server and receive updates for those subscriptions.
 type ClientSession struct {
 	sessionID           atomic.Value
 	sessionCreationTime atomic.Value
+	sessionStartTime    atomic.Value
 	httpClient          *http.Client
 	parameters          url.Values
-	cancelFunc          context.CancelFunc
 	logger              *slog.Logger
 	serverURL           string
 	subscriptions       subscriptions
+	messages            messageCounts
 	subscriptionID      atomic.Int32
 	requestID           atomic.Int32
 	Connections         atomic.Int32
 	timeDifference      atomic.Int32
+	pooledValues        bool
+	maxItems            int
+	evictions           atomic.Int64
+	// connLock guards the connection manager of the current session: cancelFunc stops it, and done is closed when it
+	// has stopped.
+	connLock   sync.Mutex
+	cancelFunc context.CancelFunc
+	done       chan struct{}
 }
 
 // NewClientSession returns a new client session with a LightStreamer server.
@@ -48,7 +61,7 @@ func NewClientSession(options ...ClientSessionOption) *ClientSession {
 	c := ClientSession{
 		serverURL:  serverURL,
 		httpClient: http.DefaultClient,
-		parameters: url.Values{"LS_cid": []string{defaultCID}},
+		parameters: url.Values{"LS_cid": []string{DefaultCID}},
 		logger:     slog.New(slog.DiscardHandler),
 	}
 	for _, o := range options {
@@ -61,20 +74,84 @@ func NewClientSession(options ...ClientSessionOption) *ClientSession {
 //
 // Note: on return, the session is still in an unbound state and calling Subscribe will fail.
 // Use SessionEstablished to wait for the session to be bound.
+//
+// Connect can be called again to replace a lost session (e.g. when Connections drops to zero). This closes the
+// current session and drops all its subscriptions: the caller must subscribe again once the new session is established.
 func (c *ClientSession) Connect(ctx context.Context) error {
-	ctx, c.cancelFunc = context.WithCancel(ctx)
+	c.connLock.Lock()
+	defer c.connLock.Unlock()
+	c.disconnect()
+	c.sessionID.Store("")
+	c.subscriptions.clear()
+	ctx, cancel := context.WithCancel(ctx)
 	r, err := c.createSession(ctx)
-	if err == nil {
-		go func() { _ = c.serve(ctx, r) }()
+	if err != nil {
+		cancel()
+		return err
 	}
-	return err
+	done := make(chan struct{})
+	c.cancelFunc, c.done = cancel, done
+	go func() {
+		defer close(done)
+		c.run(ctx, r)
+	}()
+	return nil
 }
 
-// Disconnect closes the connection to the LightStreamer server.
+// Disconnect closes the connection to the LightStreamer server, and waits for the session's updates to stop.
+// Don't call Disconnect, or Connect, from an UpdateFunc: it would wait for itself.
 func (c *ClientSession) Disconnect() {
-	if c.cancelFunc != nil {
-		c.cancelFunc()
+	c.connLock.Lock()
+	defer c.connLock.Unlock()
+	c.disconnect()
+}
+
+// disconnect stops the connection manager of the current session, if any. Call disconnect with connLock held.
+func (c *ClientSession) disconnect() {
+	if c.cancelFunc == nil {
+		return
 	}
+	c.cancelFunc()
+	<-c.done
+	c.cancelFunc, c.done = nil, nil
+}
+
+// Done returns a channel that's closed when the current session ends: the server closed it, it could not be rebound,
+// or the ClientSession was disconnected. If no session was connected, the channel is already closed.
+// Call Done again after Connect: each session has its own channel.
+func (c *ClientSession) Done() <-chan struct{} {
+	c.connLock.Lock()
+	defer c.connLock.Unlock()
+	if c.done == nil {
+		return closedChan
+	}
+	return c.done
+}
+
+// closedChan is returned by Done when no session is connected.
+var closedChan = func() chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}()
+
+// Destroy asks the server to close the session, so it frees the session's resources immediately, rather than when it
+// times out, and closes the connection. Destroy is a no-op if no session is established.
+func (c *ClientSession) Destroy(ctx context.Context) error {
+	defer c.Disconnect()
+	sessionID, _ := c.sessionID.Load().(string)
+	if sessionID == "" {
+		return nil
+	}
+	c.sessionID.Store("")
+	parameters := make(url.Values)
+	parameters.Set("LS_op", "destroy")
+	parameters.Set("LS_reqId", strconv.Itoa(int(c.requestID.Add(1))))
+	parameters.Set("LS_session", sessionID)
+	if err := c.control(ctx, parameters); err != nil {
+		return fmt.Errorf("destroy: %w", err)
+	}
+	return nil
 }
 
 // SessionEstablished waits for the session to be bound, or the context to be canceled.
@@ -104,52 +181,114 @@ func (c *ClientSession) ConnectWithSession(ctx context.Context, timeout time.Dur
 	return nil
 }
 
-func (c *ClientSession) serve(ctx context.Context, r io.ReadCloser) error {
+// connState is the state of the connection manager of a session.
+type connState int
+
+const (
+	// stateStreaming: the manager handles the messages of the session's stream connection.
+	stateStreaming connState = iota
+	// stateRebinding: the server asked to rebind the session (LOOP). The manager binds a new stream connection.
+	stateRebinding
+	// stateClosed: the session ended, or the ClientSession was disconnected.
+	stateClosed
+)
+
+// run is the connection manager of a session: it serves the session's stream connections, one at a time, starting
+// with r. When the server asks to rebind the session, e.g. because the stream reached its content length, run closes
+// the connection and binds a new one. run returns when the session ends, or ctx is canceled.
+func (c *ClientSession) run(ctx context.Context, r io.ReadCloser) {
+	var delay time.Duration
+	for state := stateStreaming; state != stateClosed; {
+		switch state {
+		case stateStreaming:
+			state = stateClosed
+			if loop, rebind := c.serve(ctx, r); rebind {
+				state, delay = stateRebinding, time.Duration(loop.ExpectedDelay)*time.Second
+			}
+		case stateRebinding:
+			c.logger.Debug("rebinding session", "delay", delay)
+			var err error
+			state = stateStreaming
+			if r, err = c.rebindAfter(ctx, delay); err != nil {
+				if ctx.Err() == nil {
+					c.logger.Warn("failed to rebind session", "err", err)
+				}
+				state = stateClosed
+			}
+		}
+	}
+}
+
+// serve handles the messages of a stream connection, until the connection is closed, ctx is canceled, or the server
+// asks to rebind the session. In the last case, serve returns the LOOP message. serve closes r and, before it
+// returns, waits for its reader to stop, so readers of consecutive connections never overlap.
+func (c *ClientSession) serve(ctx context.Context, r io.ReadCloser) (loop client.LOOPData, rebind bool) {
 	c.logger.Debug("serving connection", "count", c.Connections.Add(1))
+	messages := make(chan client.Message)
+	stop := make(chan struct{})
+	go readAllMessages(r, messages, stop)
 	defer func() {
-		c.logger.Debug("connection closed", "count", c.Connections.Add(-1))
+		close(stop)
 		_ = r.Close()
+		for range messages {
+		}
+		c.logger.Debug("connection closed", "count", c.Connections.Add(-1))
 	}()
-	ch := make(chan client.Message)
-	done := make(chan struct{})
-	// read messages in a separate go routine we can terminate when ctx is canceled.
-	// go routine stops when we close r
-	go readAllMessages(r, ch, done)
 	for {
 		select {
 		case <-ctx.Done():
-			return ctx.Err()
-		case <-done:
-			return nil
-		case msg := <-ch:
-			c.handleMessage(ctx, msg)
+			return loop, false
+		case msg, ok := <-messages:
+			if !ok {
+				return loop, false
+			}
+			c.messages.add(msg.MessageType)
+			if loop, ok = msg.Data.(client.LOOPData); ok {
+				return loop, true
+			}
+			c.handleMessage(msg)
 		}
 	}
 }
 
-func readAllMessages(r io.Reader, ch chan client.Message, done chan struct{}) {
+// readAllMessages sends the messages read from r to messages, until r is closed or stop is closed, and then closes
+// messages.
+func readAllMessages(r io.Reader, messages chan<- client.Message, stop <-chan struct{}) {
+	defer close(messages)
 	scanner := bufio.NewScanner(r)
 	for scanner.Scan() {
-		if msg, err := client.ParseSessionMessage(scanner.Text()); err == nil {
-			ch <- msg
+		msg, err := client.ParseSessionMessage(scanner.Text())
+		if err != nil {
+			continue
+		}
+		select {
+		case messages <- msg:
+		case <-stop:
+			return
 		}
 	}
-	done <- struct{}{}
 }
 
-func (c *ClientSession) handleMessage(ctx context.Context, msg client.Message) {
+func (c *ClientSession) handleMessage(msg client.Message) {
 	switch data := msg.Data.(type) {
 	case client.CONOKData:
 		c.sessionID.Store(data.SessionID)
 		c.logger.Debug("session established", "sessionID", data.SessionID)
+	case client.SUBOKData:
+		c.subscriptions.setFields(data.SubscriptionID, data.Fields)
+	case client.SUBCMDData:
+		c.subscriptions.setFields(data.SubscriptionID, data.Fields)
 	case client.PROGData, client.NOOPData, client.SERVNAMEData, client.CLIENTIPData, client.CONSData,
-		client.CONFData, client.SUBOKData, client.PROBEData:
+		client.CONFData, client.PROBEData:
 	case client.UData:
 		c.handleUpdate(data)
+	case client.OVData:
+		c.logger.Warn("server dropped updates", "subscriptionID", data.SubscriptionID, "item", data.Item, "lost", data.Lost)
+	case client.UNSUBData:
+		c.subscriptions.remove(data.SubscriptionID)
+		c.logger.Debug("subscription terminated by server", "subscriptionID", data.SubscriptionID)
 	case client.SYNCData:
 		c.handleSync(data)
-	case client.LOOPData:
-		go c.handleLoop(ctx, data)
 	case client.ENDData:
 		c.logger.Debug("connection closing", "data", data)
 	default:
@@ -157,20 +296,79 @@ func (c *ClientSession) handleMessage(ctx context.Context, msg client.Message) {
 	}
 }
 
-func (c *ClientSession) handleLoop(ctx context.Context, data client.LOOPData) {
-	c.logger.Debug("rebinding session", "delay", data.ExpectedDelay)
-	if data.ExpectedDelay > 0 {
+// rebindAfter binds a new stream connection to the session, after delay.
+func (c *ClientSession) rebindAfter(ctx context.Context, delay time.Duration) (io.ReadCloser, error) {
+	if delay > 0 {
 		select {
 		case <-ctx.Done():
-			return
-		case <-time.After(time.Duration(data.ExpectedDelay) * time.Second):
+			return nil, ctx.Err()
+		case <-time.After(delay):
 		}
 	}
-	if r, err := c.rebind(ctx, c.sessionID.Load().(string)); err == nil {
-		go func() { _ = c.serve(ctx, r) }()
+	sessionID, _ := c.sessionID.Load().(string)
+	return c.rebind(ctx, sessionID)
+}
+
+// SessionState is a snapshot of the state of a ClientSession.
+type SessionState struct {
+	// Connections is the number of open stream connections. Zero means the session is lost.
+	Connections int32 `json:"connections"`
+	// TimeDifference is the session's TimeDifference.
+	TimeDifference time.Duration `json:"time_difference"`
+	// SessionID is the ID of the current session, if any.
+	SessionID string `json:"session_id,omitempty"`
+	// Started is the time the current session was created. Unlike the connections, it's not reset by a rebind.
+	Started time.Time `json:"started,omitzero"`
+	// Subscriptions is the number of subscriptions in the session.
+	Subscriptions int `json:"subscriptions"`
+	// CachedItems is the number of items of which the subscriptions keep the last Values, to decode updates.
+	CachedItems int `json:"cached_items"`
+	// Evictions is the number of items evicted from the subscriptions' caches, as limited by WithMaxItems, since the
+	// ClientSession was created.
+	Evictions int64 `json:"evictions"`
+}
+
+// State returns the current state of the session.
+func (c *ClientSession) State() SessionState {
+	sessionID, _ := c.sessionID.Load().(string)
+	started, _ := c.sessionStartTime.Load().(time.Time)
+	return SessionState{
+		Connections:    c.Connections.Load(),
+		TimeDifference: c.TimeDifference(),
+		SessionID:      sessionID,
+		Started:        started,
+		Subscriptions:  c.subscriptions.len(),
+		CachedItems:    c.subscriptions.cachedItems(),
+		Evictions:      c.evictions.Load(),
 	}
 }
 
+// Messages returns the number of messages received from the server, by message type (e.g. "U" for an update, or
+// "PROBE"), since the ClientSession was created.
+func (c *ClientSession) Messages() map[string]int {
+	return c.messages.snapshot()
+}
+
+// Healthy returns an error if the session has no open stream connection.
+func (c *ClientSession) Healthy(context.Context) error {
+	if c.Connections.Load() == 0 {
+		return errors.New("no session")
+	}
+	return nil
+}
+
+// Details returns the current state of the session.
+func (c *ClientSession) Details() any {
+	return c.State()
+}
+
+// TimeDifference returns the difference between the server's and the client's view of the age of the session, as of
+// the last SYNC message. A negative value means the stream is running behind the server, e.g. because of network
+// delays.
+func (c *ClientSession) TimeDifference() time.Duration {
+	return time.Duration(c.timeDifference.Load()) * time.Second
+}
+
 func (c *ClientSession) handleSync(data client.SYNCData) {
 	var delta int
 	if cTime, ok := c.sessionCreationTime.Load().(time.Time); ok {
@@ -185,6 +383,7 @@ func (c *ClientSession) handleUpdate(data client.UData) {
 	sub, ok := c.subscriptions.get(data.SubscriptionID)
 	if !ok {
 		c.logger.Warn("no subscription found for update", "subscriptionID", data.SubscriptionID)
+		return
 	}
 	_ = sub.update(data.Item, data.Values)
 }
@@ -195,15 +394,49 @@ func (c *ClientSession) handleUpdate(data client.UData) {
 // If maxFrequency is non-zero, Subscribe asks for data to be sent at the specified maximum frequency (in updates per second).
 //
 // Notes:
-//   - all subscriptions are in "MERGE" mode.
+//   - the subscription is in "MERGE" mode. Use SubscribeWithMode for other modes.
 //   - adapter, group & schema are application-specific and not validated by ClientSession.
 //   - maxFrequency may be ignored by the server. ClientSession does not provide any throttling.
 func (c *ClientSession) Subscribe(ctx context.Context, adapter string, group string, schema []string, maxFrequency float64, f func(item int, values Values)) error {
-	if c.sessionID.Load() == nil {
+	return c.SubscribeWithMode(ctx, ModeMerge, adapter, group, schema, maxFrequency, f)
+}
+
+// SubscribeWithMode is Subscribe, with the specified subscription mode: ModeMerge, ModeDistinct, ModeRaw or ModeCommand.
+// Updates are passed as received, whatever the mode: in COMMAND mode, the key and command are fields of the schema.
+func (c *ClientSession) SubscribeWithMode(ctx context.Context, mode string, adapter string, group string, schema []string, maxFrequency float64, f func(item int, values Values)) error {
+	if sessionID, _ := c.sessionID.Load().(string); sessionID == "" {
 		return errors.New("no session")
 	}
 
-	subID, r, err := c.addSubscription(ctx, adapter, group, schema, maxFrequency)
+	// register the subscription before sending the request: the server may send updates before we read its response.
+	subID := int(c.subscriptionID.Add(1))
+	c.subscriptions.add(subID, &subscription{onUpdate: f, pooled: c.pooledValues, maxItems: c.maxItems, evictions: &c.evictions})
+	err := c.addSubscription(ctx, subID, mode, adapter, group, schema, maxFrequency)
+	if err != nil {
+		c.subscriptions.remove(subID)
+	}
+	return err
+}
+
+func (c *ClientSession) addSubscription(ctx context.Context, subID int, mode string, adapter string, group string, schema []string, maxFrequency float64) error {
+	parameters := make(url.Values)
+	parameters.Set("LS_op", "add")
+	parameters.Set("LS_reqId", strconv.Itoa(int(c.requestID.Add(1))))
+	parameters.Set("LS_session", c.sessionID.Load().(string))
+	parameters.Set("LS_subId", strconv.Itoa(subID))
+	parameters.Set("LS_data_adapter", adapter)
+	parameters.Set("LS_group", group)
+	parameters.Set("LS_schema", strings.Join(schema, " "))
+	parameters.Set("LS_mode", mode)
+	if maxFrequency > 0 {
+		parameters.Set("LS_requested_max_frequency", strconv.FormatFloat(maxFrequency, 'f', -1, 64))
+	}
+	return c.control(ctx, parameters)
+}
+
+// control sends a control request and checks the server's response.
+func (c *ClientSession) control(ctx context.Context, parameters url.Values) error {
+	r, err := c.call(ctx, "control", parameters)
 	if err != nil {
 		return err
 	}
@@ -219,19 +452,20 @@ func (c *ClientSession) Subscribe(ctx context.Context, adapter string, group str
 	}
 	switch data := msg.Data.(type) {
 	case client.REQOKData:
-		c.subscriptions.add(subID, &subscription{onUpdate: f})
 		return nil
 	case client.REQERRData:
 		return fmt.Errorf("%d: %s", data.ErrorCode, data.ErrorMessage)
 	default:
-		return fmt.Errorf("subscription failed: unexpected response type %q", msg.MessageType)
+		return fmt.Errorf("unexpected response type %q", msg.MessageType)
 	}
 }
 
 func (c *ClientSession) createSession(ctx context.Context) (io.ReadCloser, error) {
-	r, err := c.call(ctx, "create_session", c.parameters)
+	r, err := c.openStream(ctx, "create_session", c.parameters)
 	if err == nil {
-		c.sessionCreationTime.Store(time.Now())
+		now := time.Now()
+		c.sessionCreationTime.Store(now)
+		c.sessionStartTime.Store(now)
 	}
 	return r, err
 }
@@ -239,30 +473,33 @@ func (c *ClientSession) createSession(ctx context.Context) (io.ReadCloser, error
 func (c *ClientSession) rebind(ctx context.Context, sessionID string) (io.ReadCloser, error) {
 	parameters := make(url.Values)
 	parameters.Set("LS_session", sessionID)
-	r, err := c.call(ctx, "bind_session", parameters)
+	r, err := c.openStream(ctx, "bind_session", parameters)
 	if err == nil {
 		c.sessionCreationTime.Store(time.Now())
 	}
 	return r, err
 }
 
-func (c *ClientSession) addSubscription(ctx context.Context, adapter string, group string, schema []string, maxFrequency float64) (int, io.ReadCloser, error) {
-	subID := int(c.subscriptionID.Add(1))
-	parameters := make(url.Values)
-	parameters.Set("LS_op", "add")
-	parameters.Set("LS_reqId", strconv.Itoa(int(c.requestID.Add(1))))
-	parameters.Set("LS_session", c.sessionID.Load().(string))
-	parameters.Set("LS_subId", strconv.Itoa(subID))
-	parameters.Set("LS_data_adapter", adapter)
-	parameters.Set("LS_group", group)
-	parameters.Set("LS_schema", strings.Join(schema, " "))
-	parameters.Set("LS_mode", "MERGE")
-	if maxFrequency > 0 {
-		parameters.Set("LS_requested_max_frequency", strconv.FormatFloat(maxFrequency, 'f', -1, 64))
+// openStream opens a stream connection. Closing the connection cancels its request, which unblocks a pending read.
+func (c *ClientSession) openStream(ctx context.Context, endpoint string, values url.Values) (io.ReadCloser, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	r, err := c.call(ctx, endpoint, values)
+	if err != nil {
+		cancel()
+		return nil, err
 	}
+	return streamBody{ReadCloser: r, cancel: cancel}, nil
+}
 
-	r, err := c.call(ctx, "control", parameters)
-	return subID, r, err
+// streamBody is the body of a stream connection, with the function that cancels its request.
+type streamBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (s streamBody) Close() error {
+	s.cancel()
+	return s.ReadCloser.Close()
 }
 
 var encodedArgs = url.Values{"LS_protocol": []string{lsProtocol}}.Encode()
@@ -301,6 +538,16 @@ func lsError(resp *http.Response) error {
 type subscription struct {
 	last     map[int]Values
 	onUpdate UpdateFunc
+	pooled   bool
+	// fields is the number of fields of the subscription, as confirmed by the server.
+	fields int
+	// maxItems caps the number of items in last, if not zero. recent orders the items from the most to the least
+	// recently updated, and elements finds an item's element in recent.
+	maxItems  int
+	recent    *list.List
+	elements  map[int]*list.Element
+	items     atomic.Int64
+	evictions *atomic.Int64
 }
 
 // UpdateFunc is called for every update received from the server, with update's item number and its Values.
@@ -311,12 +558,71 @@ func (s *subscription) update(item int, values []string) error {
 	if s.last == nil {
 		s.last = make(map[int]Values)
 	}
-	next, err := s.last[item].Update(values)
-	if err == nil {
-		s.last[item] = next
-		s.onUpdate(item, next)
+	if !s.pooled {
+		next, err := s.previous(item).Update(values)
+		if err == nil {
+			s.store(item, next)
+			s.onUpdate(item, next)
+		}
+		return err
+	}
+
+	// pooled: the new Values reuse the memory of earlier updates. The callback must not keep them.
+	prev, dst := s.previous(item), getValues()
+	next, _, err := prev.update(dst, values, false)
+	if err != nil {
+		putValues(dst)
+		return err
+	}
+	s.store(item, next)
+	s.onUpdate(item, next)
+	if len(prev) == 0 || &prev[0] != &next[0] {
+		putValues(prev)
+	} else {
+		putValues(dst)
+	}
+	return nil
+}
+
+// previous returns the last Values of an item. An item without Values, because it wasn't updated yet or because it
+// was evicted, has all its fields set to nil: an update that doesn't send all fields can still be decoded.
+func (s *subscription) previous(item int) Values {
+	if values, ok := s.last[item]; ok {
+		return values
+	}
+	return make(Values, s.fields)
+}
+
+// store keeps the last Values of an item. If the subscription then keeps more than maxItems items, store evicts the
+// least recently updated one: its next update is decoded against nil fields, so fields that it doesn't send are nil.
+func (s *subscription) store(item int, values Values) {
+	if _, ok := s.last[item]; !ok {
+		s.items.Add(1)
+	}
+	s.last[item] = values
+	if s.maxItems <= 0 {
+		return
+	}
+	if s.recent == nil {
+		s.recent, s.elements = list.New(), make(map[int]*list.Element)
+	}
+	if e, ok := s.elements[item]; ok {
+		s.recent.MoveToFront(e)
+	} else {
+		s.elements[item] = s.recent.PushFront(item)
+	}
+	for s.recent.Len() > s.maxItems {
+		evicted := s.recent.Remove(s.recent.Back()).(int)
+		delete(s.elements, evicted)
+		if s.pooled {
+			putValues(s.last[evicted])
+		}
+		delete(s.last, evicted)
+		s.items.Add(-1)
+		if s.evictions != nil {
+			s.evictions.Add(1)
+		}
 	}
-	return err
 }
 
 type subscriptions struct {
@@ -333,6 +639,42 @@ func (s *subscriptions) add(item int, sub *subscription) {
 	s.items[item] = sub
 }
 
+func (s *subscriptions) remove(item int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.items, item)
+}
+
+func (s *subscriptions) clear() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	clear(s.items)
+}
+
+func (s *subscriptions) len() int {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return len(s.items)
+}
+
+// cachedItems returns the number of items of which the subscriptions keep the last Values.
+func (s *subscriptions) cachedItems() int {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	var items int64
+	for _, sub := range s.items {
+		items += sub.items.Load()
+	}
+	return int(items)
+}
+
+// setFields records the number of fields of a subscription, as confirmed by the server.
+func (s *subscriptions) setFields(item int, fields int) {
+	if sub, ok := s.get(item); ok {
+		sub.fields = fields
+	}
+}
+
 func (s *subscriptions) get(item int) (*subscription, bool) {
 	s.lock.RLock()
 	defer s.lock.RUnlock()
@@ -340,6 +682,30 @@ func (s *subscriptions) get(item int) (*subscription, bool) {
 	return sub, ok
 }
 
+type messageCounts struct {
+	counts map[client.MessageType]int
+	lock   sync.Mutex
+}
+
+func (m *messageCounts) add(messageType client.MessageType) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.counts == nil {
+		m.counts = make(map[client.MessageType]int)
+	}
+	m.counts[messageType]++
+}
+
+func (m *messageCounts) snapshot() map[string]int {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	counts := make(map[string]int, len(m.counts))
+	for messageType, count := range m.counts {
+		counts[string(messageType)] = count
+	}
+	return counts
+}
+
 ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
 
 // ClientSessionOption configures a ClientSession.
@@ -380,14 +746,31 @@ func WithCID(cid string) ClientSessionOption {
 	}
 }
 
-/*
-	func WithCredentials(username, password string) ClientSessionOption {
-		return func(c *ClientSession) {
-			c.loginArgs.Set("LS_user", username)
-			c.loginArgs.Set("LS_password", password)
-		}
+// WithPooledValues recycles the memory of the Values passed to an UpdateFunc, reducing allocations for wide schemas
+// or high update rates. The Values are then only valid until the UpdateFunc returns: use Values.Clone to keep them.
+func WithPooledValues() ClientSessionOption {
+	return func(c *ClientSession) {
+		c.pooledValues = true
 	}
-*/
+}
+
+// WithMaxItems caps the number of items of which each subscription keeps the last Values, needed to decode updates
+// that only send the fields that changed, e.g. for COMMAND or DISTINCT subscriptions with many items. When a
+// subscription exceeds it, the least recently updated item is evicted: fields that its next update doesn't send are
+// nil. The default, zero, keeps all items.
+func WithMaxItems(maxItems int) ClientSessionOption {
+	return func(c *ClientSession) {
+		c.maxItems = maxItems
+	}
+}
+
+// WithCredentials sets the username and password used to authenticate with the server when creating a session.
+func WithCredentials(username, password string) ClientSessionOption {
+	return func(c *ClientSession) {
+		c.parameters.Set("LS_user", username)
+		c.parameters.Set("LS_password", password)
+	}
+}
 
 func WithContentLength(length uint) ClientSessionOption {
 	return func(c *ClientSession) {
@@ -3,10 +3,12 @@ package lightstreamer
 import (
 	"bytes"
 	"context"
+	"github.com/clambin/iss-exporter/lightstreamer/internal/client"
 	"io"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
 	"sync/atomic"
 	"testing"
@@ -45,6 +47,110 @@ func TestClientSession_Connect(t *testing.T) {
 	if got := c.sessionID.Load().(string); got != "1" {
 		t.Errorf("got session ID %q, expected 1", got)
 	}
+	if got := c.State(); got.SessionID != "1" || got.Started.IsZero() || got.Subscriptions != 0 {
+		t.Errorf("got state %+v", got)
+	}
+	if got := c.Messages(); got["CONOK"] != 1 {
+		t.Errorf("got messages %v", got)
+	}
+}
+
+func TestClientSession_Reconnect(t *testing.T) {
+	var sessions atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("CONOK," + strconv.Itoa(int(sessions.Add(1))) + ",5000,50000,*\r\n"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	t.Cleanup(ts.Close)
+
+	c := NewClientSession(WithServerURL(ts.URL), WithHTTPClient(ts.Client()))
+	if err := c.ConnectWithSession(t.Context(), time.Second); err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	t.Cleanup(c.Disconnect)
+	c.subscriptions.add(1, &subscription{})
+
+	if err := c.ConnectWithSession(t.Context(), time.Second); err != nil {
+		t.Fatalf("failed to reconnect: %v", err)
+	}
+	if got := c.sessionID.Load().(string); got != "2" {
+		t.Errorf("got session ID %q, expected 2", got)
+	}
+	if _, ok := c.subscriptions.get(1); ok {
+		t.Error("subscription survived reconnect")
+	}
+	// the first session's stream is closed
+	start := time.Now()
+	for c.Connections.Load() != 1 {
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("got %d connections, expected 1", c.Connections.Load())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestClientSession_Done(t *testing.T) {
+	end := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("CONOK,S1,5000,50000,*\r\n"))
+		w.(http.Flusher).Flush()
+		<-end
+	}))
+	t.Cleanup(ts.Close)
+
+	c := NewClientSession(WithServerURL(ts.URL), WithHTTPClient(ts.Client()))
+	select {
+	case <-c.Done():
+	default:
+		t.Fatal("Done not closed before connecting")
+	}
+	if err := c.ConnectWithSession(t.Context(), time.Second); err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	t.Cleanup(c.Disconnect)
+	done := c.Done()
+	select {
+	case <-done:
+		t.Fatal("Done closed while the session is open")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// the server ends the session
+	close(end)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Done not closed after the session ended")
+	}
+}
+
+func TestClientSession_TimeDifference(t *testing.T) {
+	c := NewClientSession()
+	c.sessionCreationTime.Store(time.Now().Add(-10 * time.Second))
+	c.handleSync(client.SYNCData{SecondsSinceInitialHeader: 7})
+	if got := c.TimeDifference(); got != -3*time.Second {
+		t.Errorf("got %v, want -3s", got)
+	}
+	c.Connections.Add(1)
+	if got := c.State(); got.Connections != 1 || got.TimeDifference != -3*time.Second {
+		t.Errorf("got state %+v", got)
+	}
+}
+
+func TestClientSession_Healthy(t *testing.T) {
+	c := NewClientSession()
+	if err := c.Healthy(t.Context()); err == nil {
+		t.Error("Healthy() should fail without a connection")
+	}
+	c.Connections.Add(1)
+	if err := c.Healthy(t.Context()); err != nil {
+		t.Errorf("Healthy() got %v", err)
+	}
 }
 
 func TestClientSession_Connect_Timeout(t *testing.T) {
@@ -165,6 +271,13 @@ func TestClientSession_Subscribe(t *testing.T) {
 			if tt.wantErr != (err != nil) {
 				t.Errorf("got %v, wantErr %v", err, tt.wantErr)
 			}
+			want := 1
+			if err != nil {
+				want = 0
+			}
+			if got := clientSession.State().Subscriptions; got != want {
+				t.Errorf("got %d subscriptions, want %d", got, want)
+			}
 
 			if err != nil {
 				return
@@ -180,10 +293,102 @@ func TestClientSession_Subscribe(t *testing.T) {
 				case <-time.After(100 * time.Millisecond):
 				}
 			}
+			if got := clientSession.State().CachedItems; got != 1 {
+				t.Errorf("got %d cached items, want 1", got)
+			}
 		})
 	}
 }
 
+func TestClientSession_Destroy(t *testing.T) {
+	l := slog.New(slog.DiscardHandler)
+	s := NewServer("set", "cid", map[string]AdapterSet{"DEFAULT": {"1": &timedAdapter{}}}, l)
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+
+	c := NewClientSession(WithLogger(l), WithServerURL(ts.URL), WithAdapterSet("set"), WithCID("cid"))
+	if err := c.ConnectWithSession(t.Context(), time.Second); err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	if err := c.Destroy(t.Context()); err != nil {
+		t.Fatalf("failed to destroy: %v", err)
+	}
+	if got := len(s.Sessions()); got != 0 {
+		t.Errorf("got %d sessions, want 0", got)
+	}
+	if err := c.Subscribe(t.Context(), "DEFAULT", "1", []string{"Value"}, 0, func(int, Values) {}); err == nil {
+		t.Error("expected subscribe to fail after destroy")
+	}
+	// destroying a session that's gone is a no-op
+	if err := c.Destroy(t.Context()); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestClientSession_Rebind_Cycle(t *testing.T) {
+	// the server asks to rebind each stream connection, but doesn't close it: the client must close it
+	var binds atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+		if r.URL.Path == "/create_session.txt" {
+			_, _ = w.Write([]byte("CONOK,mySessionID,50000,5000,*\r\n"))
+		} else {
+			binds.Add(1)
+		}
+		_, _ = w.Write([]byte("SYNC,0\r\nLOOP,0\r\n"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	t.Cleanup(ts.Close)
+	c := NewClientSession(WithServerURL(ts.URL))
+	if err := c.ConnectWithSession(t.Context(), time.Second); err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+
+	start := time.Now()
+	for binds.Load() < 10 {
+		if got := c.Connections.Load(); got > 1 {
+			t.Fatalf("got %d connections, want at most 1", got)
+		}
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("timeout waiting for rebinds: got %d", binds.Load())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	c.Disconnect()
+	if got := c.Connections.Load(); got != 0 {
+		t.Errorf("got %d connections after disconnect, want 0", got)
+	}
+	if got := c.Messages()["LOOP"]; got < 10 {
+		t.Errorf("got %d LOOP messages, want at least 10", got)
+	}
+}
+
+func TestClientSession_SubscribeWithMode(t *testing.T) {
+	l := slog.New(slog.DiscardHandler)
+	s := NewServer("set", "cid", map[string]AdapterSet{"DEFAULT": {"1": &timedAdapter{}}}, l)
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+
+	c := NewClientSession(WithLogger(l), WithServerURL(ts.URL), WithAdapterSet("set"), WithCID("cid"))
+	if err := c.ConnectWithSession(t.Context(), time.Second); err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	t.Cleanup(c.Disconnect)
+
+	if err := c.SubscribeWithMode(t.Context(), ModeDistinct, "DEFAULT", "1", []string{"Value"}, 0, func(int, Values) {}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	// the server only accepts RAW subscriptions for adapters that support it
+	if err := c.SubscribeWithMode(t.Context(), ModeRaw, "DEFAULT", "1", []string{"Value"}, 0, func(int, Values) {}); err == nil {
+		t.Error("expected a RAW subscription to fail")
+	}
+	if got := c.State().Subscriptions; got != 1 {
+		t.Errorf("got %d subscriptions, want 1", got)
+	}
+}
+
 func TestClientSession_Subscribe_NoSession(t *testing.T) {
 	c := NewClientSession()
 	if err := c.Subscribe(t.Context(), "", "", nil, 0, nil); err == nil {
@@ -222,3 +427,77 @@ func Test_lsError(t *testing.T) {
 		})
 	}
 }
+
+func TestSubscription_Update_Pooled(t *testing.T) {
+	for _, pooled := range []bool{false, true} {
+		t.Run(strconv.FormatBool(pooled), func(t *testing.T) {
+			var got []string
+			s := subscription{pooled: pooled, onUpdate: func(item int, values Values) {
+				got = append(got, strconv.Itoa(item)+":"+values.String())
+			}}
+			for _, update := range []struct {
+				item   int
+				values string
+			}{
+				{1, "1|2|3"},
+				{2, "a|b|c"},
+				{1, "|4|"},
+				{1, "||"},
+				{1, "5|#|$"},
+				{2, "^2|d"},
+			} {
+				if err := s.update(update.item, strings.Split(update.values, "|")); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := s.update(1, []string{"1"}); err == nil {
+				t.Error("expected invalid update to fail")
+			}
+			want := []string{"1:1,2,3", "2:a,b,c", "1:1,4,3", "1:1,4,3", "1:5,<nil>,", "2:a,b,d"}
+			if strings.Join(got, " ") != strings.Join(want, " ") {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestSubscription_Update_MaxItems(t *testing.T) {
+	for _, pooled := range []bool{false, true} {
+		t.Run(strconv.FormatBool(pooled), func(t *testing.T) {
+			var evictions atomic.Int64
+			var got []string
+			s := subscription{pooled: pooled, fields: 2, maxItems: 2, evictions: &evictions, onUpdate: func(item int, values Values) {
+				got = append(got, strconv.Itoa(item)+":"+values.String())
+			}}
+			for _, update := range []struct {
+				item   int
+				values string
+			}{
+				{1, "1|2"},
+				{2, "a|b"},
+				{1, "|3"},
+				{3, "x|y"},
+				// item 2 was evicted: its unchanged field is nil
+				{2, "|c"},
+				{1, "|4"},
+				// item 3 was evicted: a skip over all fields still decodes
+				{3, "^2"},
+				{3, "^1|z"},
+			} {
+				if err := s.update(update.item, strings.Split(update.values, "|")); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want := []string{"1:1,2", "2:a,b", "1:1,3", "3:x,y", "2:<nil>,c", "1:<nil>,4", "3:<nil>,<nil>", "3:<nil>,z"}
+			if strings.Join(got, " ") != strings.Join(want, " ") {
+				t.Errorf("got %v, want %v", got, want)
+			}
+			if items := s.items.Load(); items != 2 || len(s.last) != 2 {
+				t.Errorf("got %d cached items, want 2", items)
+			}
+			if got := evictions.Load(); got != 4 {
+				t.Errorf("got %d evictions, want 4", got)
+			}
+		})
+	}
+}
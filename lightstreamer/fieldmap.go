@@ -0,0 +1,84 @@
+package lightstreamer
+
+import (
+	"bytes"
+	"encoding/json"
+	"iter"
+	"slices"
+	"strings"
+)
+
+// A FieldMap gives access to Values by field name, based on the schema of the subscription that received them.
+type FieldMap struct {
+	schema []string
+	values Values
+}
+
+// NewFieldMap returns a FieldMap for values received for the specified schema. If schema and values have
+// a different length, the extra fields or values are ignored.
+func NewFieldMap(schema []string, values Values) FieldMap {
+	n := min(len(schema), len(values))
+	return FieldMap{schema: schema[:n], values: values[:n]}
+}
+
+// Get returns the value of the field. It returns false if the field is not part of the schema.
+// The returned value is nil if the field's value is nil.
+func (f FieldMap) Get(field string) (*Value, bool) {
+	idx := slices.Index(f.schema, field)
+	if idx == -1 {
+		return nil, false
+	}
+	return f.values[idx], true
+}
+
+// Range iterates over all fields and their values, in schema order.
+func (f FieldMap) Range() iter.Seq2[string, *Value] {
+	return func(yield func(string, *Value) bool) {
+		for i, field := range f.schema {
+			if !yield(field, f.values[i]) {
+				return
+			}
+		}
+	}
+}
+
+// Len returns the number of fields.
+func (f FieldMap) Len() int {
+	return len(f.schema)
+}
+
+func (f FieldMap) String() string {
+	s := make([]string, 0, len(f.schema))
+	for field, value := range f.Range() {
+		v := "<nil>"
+		if value != nil {
+			v = string(*value)
+		}
+		s = append(s, field+"="+v)
+	}
+	return strings.Join(s, ",")
+}
+
+// MarshalJSON encodes the FieldMap as a JSON object, with its fields in schema order and null for nil values.
+func (f FieldMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, field := range f.schema {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, err := json.Marshal(field)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		value, err := json.Marshal(f.values[i])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
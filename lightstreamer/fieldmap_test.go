@@ -0,0 +1,82 @@
+package lightstreamer
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestFieldMap(t *testing.T) {
+	tests := []struct {
+		name     string
+		schema   []string
+		values   Values
+		wantLen  int
+		wantStr  string
+		wantJSON string
+	}{
+		{
+			name:     "populated",
+			schema:   []string{"Value", "Status", "TimeStamp"},
+			values:   Values{valuePtr("42"), nil, valuePtr("123.5")},
+			wantLen:  3,
+			wantStr:  "Value=42,Status=<nil>,TimeStamp=123.5",
+			wantJSON: `{"Value":"42","Status":null,"TimeStamp":"123.5"}`,
+		},
+		{
+			name:     "more fields than values",
+			schema:   []string{"Value", "Status"},
+			values:   Values{valuePtr("42")},
+			wantLen:  1,
+			wantStr:  "Value=42",
+			wantJSON: `{"Value":"42"}`,
+		},
+		{
+			name:     "empty",
+			wantStr:  "",
+			wantJSON: `{}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewFieldMap(tt.schema, tt.values)
+			if got := f.Len(); got != tt.wantLen {
+				t.Errorf("Len() = %d, want %d", got, tt.wantLen)
+			}
+			if got := f.String(); got != tt.wantStr {
+				t.Errorf("String() = %q, want %q", got, tt.wantStr)
+			}
+			got, err := json.Marshal(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.wantJSON {
+				t.Errorf("MarshalJSON() = %s, want %s", got, tt.wantJSON)
+			}
+		})
+	}
+}
+
+func TestFieldMap_Get(t *testing.T) {
+	f := NewFieldMap([]string{"Value", "Status"}, Values{valuePtr("42"), nil})
+
+	if v, ok := f.Get("Value"); !ok || v == nil || *v != "42" {
+		t.Errorf("Get(Value) = %v, %v, want 42, true", v, ok)
+	}
+	if v, ok := f.Get("Status"); !ok || v != nil {
+		t.Errorf("Get(Status) = %v, %v, want nil, true", v, ok)
+	}
+	if _, ok := f.Get("TimeStamp"); ok {
+		t.Error("Get(TimeStamp) should fail")
+	}
+
+	var fields []string
+	for field := range f.Range() {
+		fields = append(fields, field)
+		break
+	}
+	if strings.Join(fields, ",") != "Value" {
+		t.Errorf("Range() didn't stop: %v", fields)
+	}
+}
@@ -0,0 +1,114 @@
+package lightstreamer
+
+import (
+	"cmp"
+	"net/http"
+	"slices"
+	"strconv"
+)
+
+// An InjectAdapter is an Adapter that doesn't produce any updates itself: its subscribers only receive the updates
+// injected with Server.Publish, e.g. through the InjectHandler. This allows tests and demos to drive a stream
+// without writing a custom Adapter.
+type InjectAdapter struct {
+	Name   string
+	Items  int
+	Fields int
+}
+
+func (a InjectAdapter) Subscribe(_ chan<- AdapterUpdate, _ int, _ string, _ string) (int, int, error) {
+	return max(a.Items, 1), max(a.Fields, 1), nil
+}
+
+func (a InjectAdapter) Unsubscribe(_ chan<- AdapterUpdate, _ int) {}
+
+func (a InjectAdapter) String() string {
+	return a.Name
+}
+
+// Publish sends an update for an item of the group, served by a data adapter of an adapter set, to all sessions
+// subscribed to that group, as if the group's adapter had published it. item is the item's index in the group.
+// For item-list groups that include the group as an item, the update is sent for that item instead.
+//
+// Publish returns the number of subscriptions that received the update.
+func (s *Server) Publish(set string, dataAdapter string, group string, item int, values Values) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var count int
+	for _, sess := range s.sessions {
+		if sess.adapterSet == set {
+			count += sess.publish(dataAdapter, group, item, values)
+		}
+	}
+	return count
+}
+
+// InjectHandler returns an http.Handler to inject updates into the server's subscriptions:
+//
+//   - POST /test/update sends an update to all subscriptions of a group
+//
+// The request's form values specify the update: "set", "data_adapter" (default "DEFAULT"), "group", "item"
+// (default 1) and one "value" per field, e.g.
+//
+//	curl -d set=ISSLIVE -d group=USLAB000032 -d value=42 http://localhost:8080/test/update
+//
+// The handler responds with the number of subscriptions that received the update. The group must be registered
+// with the server. The handler is intended for testing: only expose it on a trusted network.
+func (s *Server) InjectHandler() http.Handler {
+	m := http.NewServeMux()
+	m.HandleFunc("POST /test/update", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		set, group := r.PostForm.Get("set"), r.PostForm.Get("group")
+		dataAdapter := cmp.Or(r.PostForm.Get("data_adapter"), defaultDataAdapter)
+		item, err := strconv.Atoi(cmp.Or(r.PostForm.Get("item"), "1"))
+		if err != nil || item < 1 {
+			http.Error(w, "invalid item", http.StatusBadRequest)
+			return
+		}
+		if !s.hasGroup(set, dataAdapter, group) {
+			http.Error(w, "group not found", http.StatusNotFound)
+			return
+		}
+		values := make(Values, len(r.PostForm["value"]))
+		for i, value := range r.PostForm["value"] {
+			values[i] = valuePtr(value)
+		}
+		count := s.Publish(set, dataAdapter, group, item, values)
+		_, _ = w.Write([]byte(strconv.Itoa(count) + "\n"))
+	})
+	return m
+}
+
+func (s *Server) hasGroup(set string, dataAdapter string, group string) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	_, ok := s.adapterSets[set][dataAdapter][group]
+	return ok
+}
+
+// publish queues an update for all subscriptions to the group. It returns the number of subscriptions.
+func (s *session) publish(dataAdapter string, group string, item int, values Values) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var count int
+	for subID, sub := range s.subscriptions {
+		if sub.dataAdapter != dataAdapter {
+			continue
+		}
+		update := AdapterUpdate{SubscriptionID: subID, Item: item, Values: values.Clone()}
+		if sub.items != nil {
+			if !slices.Contains(sub.items, group) {
+				continue
+			}
+			update.Item, update.ItemName = 0, group
+		} else if sub.group != group {
+			continue
+		}
+		s.queue.push(update)
+		count++
+	}
+	return count
+}
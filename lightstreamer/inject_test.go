@@ -0,0 +1,105 @@
+package lightstreamer
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServer_InjectHandler(t *testing.T) {
+	l := slog.New(slog.DiscardHandler)
+	s := NewServer("set", "cid", map[string]AdapterSet{"DEFAULT": {"1": InjectAdapter{Name: "1", Fields: 2}}}, l)
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+	inject := httptest.NewServer(s.InjectHandler())
+	t.Cleanup(inject.Close)
+
+	c := NewClientSession(WithLogger(l), WithServerURL(ts.URL), WithAdapterSet("set"), WithCID("cid"))
+	if err := c.ConnectWithSession(t.Context(), time.Second); err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	t.Cleanup(c.Disconnect)
+	received := make(chan string, 1)
+	if err := c.Subscribe(t.Context(), "DEFAULT", "1", []string{"Value", "Status"}, 0, func(_ int, values Values) {
+		received <- values.String()
+	}); err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		values     url.Values
+		wantStatus int
+		wantBody   string
+		wantUpdate string
+	}{
+		{
+			name:       "update",
+			values:     url.Values{"set": {"set"}, "group": {"1"}, "value": {"42", "OK"}},
+			wantStatus: http.StatusOK,
+			wantBody:   "1\n",
+			wantUpdate: "42,OK",
+		},
+		{
+			name:       "unknown group",
+			values:     url.Values{"set": {"set"}, "group": {"2"}, "value": {"42", "OK"}},
+			wantStatus: http.StatusNotFound,
+			wantBody:   "group not found\n",
+		},
+		{
+			name:       "invalid item",
+			values:     url.Values{"set": {"set"}, "group": {"1"}, "item": {"0"}, "value": {"42", "OK"}},
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "invalid item\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Post(inject.URL+"/test/update", "application/x-www-form-urlencoded", strings.NewReader(tt.values.Encode()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() { _ = resp.Body.Close() }()
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != tt.wantStatus || string(body) != tt.wantBody {
+				t.Errorf("got %d %q, want %d %q", resp.StatusCode, string(body), tt.wantStatus, tt.wantBody)
+			}
+			if tt.wantUpdate == "" {
+				return
+			}
+			select {
+			case got := <-received:
+				if got != tt.wantUpdate {
+					t.Errorf("got %q, want %q", got, tt.wantUpdate)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("timeout waiting for update")
+			}
+		})
+	}
+}
+
+func TestServer_Publish_ItemList(t *testing.T) {
+	s := NewServer("set", "cid", map[string]AdapterSet{"DEFAULT": {"a": InjectAdapter{Name: "a"}, "b": InjectAdapter{Name: "b"}}}, slog.New(slog.DiscardHandler))
+	sess, _ := s.addSession(sessionCommand{AdapterSet: "set"}, "")
+	if err := s.subscribe(controlCommand{SessionID: sess.sessionID, SubId: 1, Group: "a b", Mode: ModeDistinct, Schema: "Value"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := s.Publish("set", "DEFAULT", "b", 1, Values{valuePtr("42")}); got != 1 {
+		t.Fatalf("got %d subscriptions, want 1", got)
+	}
+	update, ok := sess.queue.pop()
+	if !ok {
+		t.Fatal("queue empty")
+	}
+	if update.Item != 2 || update.Values.String() != "42" {
+		t.Errorf("got item %d, values %q, want item 2, values %q", update.Item, update.Values.String(), "42")
+	}
+}
@@ -74,6 +74,24 @@ type SUBOKData struct {
 	Fields         int
 }
 
+type SUBCMDData struct {
+	SubscriptionID int
+	Items          int
+	Fields         int
+	KeyField       int
+	CommandField   int
+}
+
+type UNSUBData struct {
+	SubscriptionID int
+}
+
+type OVData struct {
+	SubscriptionID int
+	Item           int
+	Lost           int
+}
+
 type CONFData struct {
 	SubscriptionID int
 	MaxFrequency   float64
@@ -103,6 +121,9 @@ var (
 		"END":      parseEND,
 		"U":        parseU,
 		"SUBOK":    parseSUBOK,
+		"SUBCMD":   parseSUBCMD,
+		"UNSUB":    parseUNSUB,
+		"OV":       parseOV,
 		"CONF":     parseCONF,
 		"PROG":     parsePROG,
 	}
@@ -258,6 +279,60 @@ func parseSUBOK(parts []string) (any, error) {
 	return data, nil
 }
 
+func parseUNSUB(parts []string) (any, error) {
+	if len(parts) != 1 {
+		return nil, fmt.Errorf("expected 1 argument, got %d", len(parts))
+	}
+	var data UNSUBData
+	var err error
+	if data.SubscriptionID, err = strconv.Atoi(parts[0]); err != nil {
+		return nil, fmt.Errorf("invalid subscription ID %q: %w", parts[0], err)
+	}
+	return data, nil
+}
+
+func parseOV(parts []string) (any, error) {
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("expected 3 arguments, got %d", len(parts))
+	}
+	var data OVData
+	var err error
+	if data.SubscriptionID, err = strconv.Atoi(parts[0]); err != nil {
+		return nil, fmt.Errorf("invalid subscription ID %q: %w", parts[0], err)
+	}
+	if data.Item, err = strconv.Atoi(parts[1]); err != nil {
+		return nil, fmt.Errorf("invalid item %q: %w", parts[1], err)
+	}
+	if data.Lost, err = strconv.Atoi(parts[2]); err != nil {
+		return nil, fmt.Errorf("invalid lost count %q: %w", parts[2], err)
+	}
+	return data, nil
+}
+
+func parseSUBCMD(parts []string) (any, error) {
+	if len(parts) != 5 {
+		return nil, fmt.Errorf("expected 5 arguments, got %d", len(parts))
+	}
+	var data SUBCMDData
+	var err error
+	if data.SubscriptionID, err = strconv.Atoi(parts[0]); err != nil {
+		return nil, fmt.Errorf("invalid subscription ID %q: %w", parts[0], err)
+	}
+	if data.Items, err = strconv.Atoi(parts[1]); err != nil {
+		return nil, fmt.Errorf("invalid item %q: %w", parts[1], err)
+	}
+	if data.Fields, err = strconv.Atoi(parts[2]); err != nil {
+		return nil, fmt.Errorf("invalid field count %q: %w", parts[2], err)
+	}
+	if data.KeyField, err = strconv.Atoi(parts[3]); err != nil {
+		return nil, fmt.Errorf("invalid key field %q: %w", parts[3], err)
+	}
+	if data.CommandField, err = strconv.Atoi(parts[4]); err != nil {
+		return nil, fmt.Errorf("invalid command field %q: %w", parts[4], err)
+	}
+	return data, nil
+}
+
 func parseCONF(parts []string) (any, error) {
 	if len(parts) != 3 {
 		return nil, fmt.Errorf("expected 3 arguments, got %d", len(parts))
@@ -66,6 +66,18 @@ func TestParseSessionMessage(t *testing.T) {
 		{name: "SUBOK (invalid subscription ID)", line: "SUBOK,a,1,5", pass: false},
 		{name: "SUBOK (invalid items)", line: "SUBOK,1,a,5", pass: false},
 		{name: "SUBOK (invalid fields)", line: "SUBOK,1,1,a", pass: false},
+		{name: "SUBCMD", line: "SUBCMD,100,1,5,1,2", pass: true, want: Message{SUBCMDData{100, 1, 5, 1, 2}, "SUBCMD"}},
+		{name: "SUBCMD (too short)", line: "SUBCMD,100,1,5", pass: false},
+		{name: "SUBCMD (invalid key field)", line: "SUBCMD,100,1,5,a,2", pass: false},
+		{name: "SUBCMD (invalid command field)", line: "SUBCMD,100,1,5,1,a", pass: false},
+		{name: "UNSUB", line: "UNSUB,100", pass: true, want: Message{UNSUBData{100}, "UNSUB"}},
+		{name: "UNSUB (too short)", line: "UNSUB", pass: false},
+		{name: "UNSUB (invalid subscription ID)", line: "UNSUB,a", pass: false},
+		{name: "OV", line: "OV,100,1,5", pass: true, want: Message{OVData{100, 1, 5}, "OV"}},
+		{name: "OV (too short)", line: "OV,100,1", pass: false},
+		{name: "OV (invalid subscription ID)", line: "OV,a,1,5", pass: false},
+		{name: "OV (invalid item)", line: "OV,100,a,5", pass: false},
+		{name: "OV (invalid lost count)", line: "OV,100,1,a", pass: false},
 		{name: "CONF (filtered)", line: "CONF,100,100,filtered", pass: true, want: Message{CONFData{100, 100, true}, "CONF"}},
 		{name: "CONF (unfiltered)", line: "CONF,100,100,unfiltered", pass: true, want: Message{CONFData{100, 100, false}, "CONF"}},
 		{name: "CONF (unlimited)", line: "CONF,100,unlimited,unfiltered", pass: true, want: Message{CONFData{100, math.Inf(1), false}, "CONF"}},
@@ -0,0 +1,104 @@
+package lightstreamer
+
+import (
+	"cmp"
+	"context"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// A Middleware wraps the server's TLCP endpoints, e.g. to add authentication, metrics or logging.
+type Middleware func(next http.Handler) http.Handler
+
+// WithMiddleware wraps all TLCP endpoints with the specified middleware. The first middleware is the outermost one:
+// it sees the request first and the response last.
+func WithMiddleware(middleware ...Middleware) ServerOption {
+	return func(s *Server) {
+		s.middleware = append(s.middleware, middleware...)
+	}
+}
+
+// chain wraps h with the middleware, in reverse order, so the first middleware is the outermost one.
+func chain(h http.Handler, middleware []Middleware) http.Handler {
+	for _, m := range slices.Backward(middleware) {
+		h = m(h)
+	}
+	return h
+}
+
+// AccessLogger returns a Middleware that logs every request, once it's completed: the method, the TLCP endpoint,
+// the session, the HTTP status, the TLCP outcome (e.g. CONOK or REQERR) and the duration of the request.
+// For create_session and bind_session requests, the duration is the lifetime of the stream.
+func AccessLogger(logger *slog.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			var info requestInfo
+			rec := statusRecorder{ResponseWriter: w}
+			next.ServeHTTP(&rec, r.WithContext(context.WithValue(r.Context(), requestInfoKey{}, &info)))
+			logger.Info("request",
+				"method", r.Method,
+				"endpoint", r.URL.Path,
+				"session", info.sessionID,
+				"status", cmp.Or(rec.status, http.StatusOK),
+				"outcome", info.outcome,
+				"duration", time.Since(start),
+			)
+		})
+	}
+}
+
+type requestInfoKey struct{}
+
+// requestInfo records the outcome of a request, for the AccessLogger.
+type requestInfo struct {
+	sessionID string
+	outcome   string
+}
+
+// annotateRequest records the request's session and TLCP outcome, if the request is logged by an AccessLogger.
+// Empty values are ignored. An error outcome (CONERR or REQERR) isn't overwritten by a later successful one.
+func annotateRequest(r *http.Request, sessionID string, outcome string) {
+	info, ok := r.Context().Value(requestInfoKey{}).(*requestInfo)
+	if !ok {
+		return
+	}
+	if sessionID != "" {
+		info.sessionID = sessionID
+	}
+	if outcome != "" && info.outcome != "CONERR" && info.outcome != "REQERR" {
+		info.outcome = outcome
+	}
+}
+
+// statusRecorder records the HTTP status of a response. It supports streaming responses.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
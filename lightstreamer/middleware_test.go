@@ -0,0 +1,121 @@
+package lightstreamer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWithMiddleware(t *testing.T) {
+	var calls []string
+	record := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls = append(calls, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	deny := func(http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+		})
+	}
+	s := NewServer("set", "cid", nil, slog.New(slog.DiscardHandler), WithMiddleware(record("first"), record("second")), WithMiddleware(deny))
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+
+	resp := post(t, t.Context(), ts.URL+"/create_session.txt", url.Values{"LS_adapter_set": {"set"}, "LS_cid": {"cid"}})
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("got %d, want %d", resp.StatusCode, http.StatusForbidden)
+	}
+	if got := strings.Join(calls, ","); got != "first,second" {
+		t.Errorf("got %q, want %q", got, "first,second")
+	}
+}
+
+func TestAccessLogger(t *testing.T) {
+	var buf syncBuffer
+	l := slog.New(slog.NewJSONHandler(&buf, nil))
+	s := NewServer("set", "cid", nil, slog.New(slog.DiscardHandler), WithMiddleware(AccessLogger(l)))
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	lines := postStream(t, ctx, ts.URL+"/create_session.txt", url.Values{"LS_adapter_set": {"set"}, "LS_cid": {"cid"}})
+	sessionID := strings.Split(nextLine(t, lines), ",")[1]
+	cancel()
+
+	resp := post(t, t.Context(), ts.URL+"/control.txt", url.Values{"LS_op": {"force_rebind"}, "LS_reqId": {"1"}, "LS_session": {"unknown"}})
+	_, _ = io.ReadAll(resp.Body)
+	resp = post(t, t.Context(), ts.URL+"/heartbeat.txt", url.Values{"LS_reqId": {"1"}, "LS_session": {sessionID}})
+	_, _ = io.ReadAll(resp.Body)
+
+	want := map[string]struct {
+		session string
+		outcome string
+	}{
+		"/create_session.txt": {session: sessionID, outcome: "CONOK"},
+		"/control.txt":        {session: "unknown", outcome: "REQERR"},
+		"/heartbeat.txt":      {session: sessionID, outcome: "REQOK"},
+	}
+
+	// the create_session request is only logged when its stream is closed.
+	ctx, cancel = context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	for strings.Count(buf.String(), "\n") < len(want) {
+		select {
+		case <-ctx.Done():
+			t.Fatalf("timeout waiting for access logs: %s", buf.String())
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry struct {
+			Method   string `json:"method"`
+			Endpoint string `json:"endpoint"`
+			Session  string `json:"session"`
+			Outcome  string `json:"outcome"`
+			Status   int    `json:"status"`
+		}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatal(err)
+		}
+		w, ok := want[entry.Endpoint]
+		if !ok {
+			t.Errorf("unexpected log entry: %s", line)
+			continue
+		}
+		if entry.Method != http.MethodPost || entry.Status != http.StatusOK || entry.Session != w.session || entry.Outcome != w.outcome {
+			t.Errorf("got %s, want session %q, outcome %q", line, w.session, w.outcome)
+		}
+	}
+}
+
+// syncBuffer is a bytes.Buffer that is safe for concurrent use.
+type syncBuffer struct {
+	buf  bytes.Buffer
+	lock sync.Mutex
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.String()
+}
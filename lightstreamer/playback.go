@@ -0,0 +1,232 @@
+package lightstreamer
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/clambin/iss-exporter/lightstreamer/internal/client"
+)
+
+// PlaybackFormat is the format of a recording replayed by a PlaybackAdapter.
+type PlaybackFormat int
+
+const (
+	// PlaybackCSV records one update per line: the time of the update, the item and the item's values, e.g.
+	//
+	//	2025-01-02T15:04:05.5Z,1,42.0,OK
+	//
+	// The time is either an RFC 3339 timestamp or a number of seconds. The item is either an item index or an item name.
+	PlaybackCSV PlaybackFormat = iota
+	// PlaybackTLCP records a TLCP stream, with each line prefixed by the RFC 3339 timestamp at which it was received, e.g.
+	//
+	//	2025-01-02T15:04:05.5Z U,1,1,42.0|OK
+	//
+	// Only U messages are replayed. Their values are delta-encoded, as in the original stream.
+	PlaybackTLCP
+)
+
+// A PlaybackAdapter is an Adapter that replays recorded updates to its subscribers, on the schedule of the
+// recording. This allows deterministic tests and demos, without relying on a live feed.
+//
+// Updates recorded with an item name, rather than an item index, are published by name: register the adapter under
+// that name and subscribe to it as part of an item-list group.
+type PlaybackAdapter struct {
+	subscriptions map[playbackSubscription]struct{}
+	name          string
+	records       []playbackRecord
+	items         int
+	fields        int
+	// Speed scales the time between updates: 2 replays the recording twice as fast. Zero replays it in real time.
+	Speed float64
+	// Loop restarts the recording when all updates have been replayed.
+	Loop bool
+	lock sync.RWMutex
+}
+
+// playbackSubscription identifies a subscription: subscription IDs are only unique within a session.
+type playbackSubscription struct {
+	ch chan<- AdapterUpdate
+	id int
+}
+
+type playbackRecord struct {
+	itemName string
+	values   Values
+	offset   time.Duration
+	item     int
+}
+
+// NewPlaybackAdapter returns a PlaybackAdapter replaying the recording read from r.
+func NewPlaybackAdapter(name string, r io.Reader, format PlaybackFormat) (*PlaybackAdapter, error) {
+	var records []playbackRecord
+	var err error
+	switch format {
+	case PlaybackCSV:
+		records, err = readCSVRecording(r)
+	case PlaybackTLCP:
+		records, err = readTLCPRecording(r)
+	default:
+		err = fmt.Errorf("invalid playback format: %d", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, errors.New("recording contains no updates")
+	}
+
+	a := PlaybackAdapter{name: name, records: records}
+	itemNames := make(map[string]struct{})
+	start := records[0].offset
+	for i := range records {
+		records[i].offset -= start
+		a.items = max(a.items, records[i].item)
+		a.fields = max(a.fields, len(records[i].values))
+		if records[i].itemName != "" {
+			itemNames[records[i].itemName] = struct{}{}
+		}
+	}
+	a.items = max(a.items, len(itemNames), 1)
+	return &a, nil
+}
+
+func readCSVRecording(r io.Reader) ([]playbackRecord, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.Comment = '#'
+	var records []playbackRecord
+	for {
+		line, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("csv: %w", err)
+		}
+		if len(line) < 3 {
+			return nil, fmt.Errorf("csv: invalid record %q: expected time, item and values", strings.Join(line, ","))
+		}
+		var record playbackRecord
+		if record.offset, err = parsePlaybackTime(line[0]); err != nil {
+			return nil, fmt.Errorf("csv: %w", err)
+		}
+		if record.item, err = strconv.Atoi(line[1]); err != nil {
+			record.itemName = line[1]
+		}
+		record.values = make(Values, len(line)-2)
+		for i, value := range line[2:] {
+			record.values[i] = valuePtr(value)
+		}
+		records = append(records, record)
+	}
+}
+
+func readTLCPRecording(r io.Reader) ([]playbackRecord, error) {
+	var records []playbackRecord
+	last := make(map[int]Values)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		timestamp, line, ok := strings.Cut(scanner.Text(), " ")
+		if !ok || !strings.HasPrefix(line, "U,") {
+			continue
+		}
+		offset, err := parsePlaybackTime(timestamp)
+		if err != nil {
+			return nil, fmt.Errorf("tlcp: %w", err)
+		}
+		msg, err := client.ParseSessionMessage(line)
+		if err != nil {
+			return nil, fmt.Errorf("tlcp: %w", err)
+		}
+		update := msg.Data.(client.UData)
+		values, err := last[update.Item].Update(update.Values)
+		if err != nil {
+			return nil, fmt.Errorf("tlcp: invalid update %q: %w", line, err)
+		}
+		last[update.Item] = values
+		records = append(records, playbackRecord{offset: offset, item: update.Item, values: values})
+	}
+	return records, scanner.Err()
+}
+
+// parsePlaybackTime parses the time of a recorded update, either as an RFC 3339 timestamp or as a number of seconds.
+// It returns the time as an offset, which only makes sense relative to the other updates of the recording.
+func parsePlaybackTime(value string) (time.Duration, error) {
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+	timestamp, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", value)
+	}
+	return time.Duration(timestamp.UnixNano()), nil
+}
+
+func (a *PlaybackAdapter) Subscribe(ch chan<- AdapterUpdate, subId int, _ string, _ string) (int, int, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.subscriptions == nil {
+		a.subscriptions = make(map[playbackSubscription]struct{})
+	}
+	a.subscriptions[playbackSubscription{ch: ch, id: subId}] = struct{}{}
+	return a.items, a.fields, nil
+}
+
+func (a *PlaybackAdapter) Unsubscribe(ch chan<- AdapterUpdate, subId int) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	delete(a.subscriptions, playbackSubscription{ch: ch, id: subId})
+}
+
+// Run replays the recording until ctx is canceled or, unless Loop is set, until all updates have been replayed.
+func (a *PlaybackAdapter) Run(ctx context.Context) {
+	speed := a.Speed
+	if speed <= 0 {
+		speed = 1
+	}
+	for {
+		start := time.Now()
+		for _, record := range a.records {
+			wait := time.Until(start.Add(time.Duration(float64(record.offset) / speed)))
+			if wait > 0 {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(wait):
+				}
+			}
+			if !a.publish(ctx, record) {
+				return
+			}
+		}
+		if !a.Loop {
+			return
+		}
+	}
+}
+
+func (a *PlaybackAdapter) publish(ctx context.Context, record playbackRecord) bool {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+	for sub := range a.subscriptions {
+		update := AdapterUpdate{SubscriptionID: sub.id, Item: record.item, ItemName: record.itemName, Values: record.values.Clone()}
+		select {
+		case sub.ch <- update:
+		case <-ctx.Done():
+			return false
+		}
+	}
+	return true
+}
+
+func (a *PlaybackAdapter) String() string {
+	return a.name
+}
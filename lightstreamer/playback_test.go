@@ -0,0 +1,159 @@
+package lightstreamer
+
+import (
+	"log/slog"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNewPlaybackAdapter(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		format PlaybackFormat
+		pass   bool
+		items  int
+		fields int
+		want   []string
+	}{
+		{
+			name:   "csv",
+			input:  "# time,item,values\n10,1,a,b\n10.5,2,c,d\n11,1,e,\n",
+			format: PlaybackCSV,
+			pass:   true,
+			items:  2,
+			fields: 2,
+			want:   []string{"0s 1 a,b", "500ms 2 c,d", "1s 1 e,"},
+		},
+		{
+			name:   "csv with timestamps and item names",
+			input:  "2025-01-02T15:04:05Z,lon,1.0\n2025-01-02T15:04:06.25Z,lat,2.0\n",
+			format: PlaybackCSV,
+			pass:   true,
+			items:  2,
+			fields: 1,
+			want:   []string{"0s lon 1.0", "1.25s lat 2.0"},
+		},
+		{
+			name:   "tlcp",
+			input:  "2025-01-02T15:04:05Z CONOK,1,50000,5000,*\n2025-01-02T15:04:05Z U,1,1,a|b\n2025-01-02T15:04:07Z U,1,1,|c\n2025-01-02T15:04:08Z U,1,2,#|$\n",
+			format: PlaybackTLCP,
+			pass:   true,
+			items:  2,
+			fields: 2,
+			want:   []string{"0s 1 a,b", "2s 1 a,c", "3s 2 <nil>,"},
+		},
+		{name: "csv: invalid time", input: "foo,1,a\n", format: PlaybackCSV},
+		{name: "csv: no values", input: "1,1\n", format: PlaybackCSV},
+		{name: "tlcp: invalid update", input: "2025-01-02T15:04:05Z U,1,1,^3\n", format: PlaybackTLCP},
+		{name: "empty", input: "", format: PlaybackCSV},
+		{name: "invalid format", input: "1,1,a\n", format: PlaybackFormat(-1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := NewPlaybackAdapter("playback", strings.NewReader(tt.input), tt.format)
+			if tt.pass != (err == nil) {
+				t.Fatalf("got error %v, want pass %v", err, tt.pass)
+			}
+			if err != nil {
+				return
+			}
+			if a.items != tt.items || a.fields != tt.fields {
+				t.Errorf("got %d items, %d fields, want %d items, %d fields", a.items, a.fields, tt.items, tt.fields)
+			}
+			got := make([]string, len(a.records))
+			for i, record := range a.records {
+				item := record.itemName
+				if item == "" {
+					item = strconv.Itoa(record.item)
+				}
+				got[i] = record.offset.String() + " " + item + " " + record.values.String()
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlaybackAdapter_Run(t *testing.T) {
+	a, err := NewPlaybackAdapter("playback", strings.NewReader("0,1,a\n1,1,b\n2,1,c\n"), PlaybackCSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.Speed = 20
+
+	ch := make(chan AdapterUpdate)
+	if items, fields, err := a.Subscribe(ch, 5, ModeMerge, "Value"); err != nil || items != 1 || fields != 1 {
+		t.Fatalf("got %d items, %d fields, err %v, want 1 item, 1 field", items, fields, err)
+	}
+
+	start := time.Now()
+	go a.Run(t.Context())
+	for _, want := range []string{"a", "b", "c"} {
+		select {
+		case update := <-ch:
+			if update.SubscriptionID != 5 || update.Item != 1 || update.Values.String() != want {
+				t.Errorf("got %+v, want value %q for item 1 of subscription 5", update, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timeout waiting for %q", want)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("recording replayed in %v, want at least 100ms", elapsed)
+	}
+}
+
+func TestPlaybackAdapter_SessionEnds(t *testing.T) {
+	a, err := NewPlaybackAdapter("playback", strings.NewReader("0,1,a\n0.1,1,b\n"), PlaybackCSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.Speed, a.Loop = 10, true
+	s := NewServer("set", "cid", map[string]AdapterSet{"DEFAULT": {"playback": a}}, slog.New(slog.DiscardHandler))
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+	go a.Run(t.Context())
+
+	var received [2]atomic.Int32
+	var sessionID string
+	for i := range received {
+		c := NewClientSession(WithServerURL(ts.URL), WithAdapterSet("set"), WithCID("cid"))
+		if err = c.ConnectWithSession(t.Context(), time.Second); err != nil {
+			t.Fatalf("failed to connect: %v", err)
+		}
+		t.Cleanup(c.Disconnect)
+		if i == 0 {
+			sessionID = s.Sessions()[0].ID
+		}
+		if err = c.Subscribe(t.Context(), "DEFAULT", "playback", []string{"Value"}, 0, func(int, Values) {
+			received[i].Add(1)
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitFor := func(i int, count int32) {
+		t.Helper()
+		start := time.Now()
+		for received[i].Load() < count {
+			if time.Since(start) > 5*time.Second {
+				t.Fatalf("session %d: got %d records, want %d", i, received[i].Load(), count)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	waitFor(0, 2)
+	waitFor(1, 2)
+
+	// close the first session: the second one still receives the replay
+	if !s.CloseSession(sessionID) {
+		t.Fatal("session not found")
+	}
+	waitFor(1, received[1].Load()+10)
+}
@@ -0,0 +1,151 @@
+package lightstreamer
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+)
+
+// defaultQueueSize is the default number of updates a session buffers for its client.
+const defaultQueueSize = 1000
+
+// An updateQueue buffers a session's updates between the adapters and the session's stream, so a slow client
+// doesn't block the adapters.
+//
+// MERGE updates are conflated: the queue only keeps the most recent update for each item. DISTINCT and COMMAND
+// updates can't be conflated: if the queue is full, they are dropped and counted, so the client can be notified
+// with an OV message.
+type updateQueue struct {
+	ready    chan struct{}
+	merged   map[itemKey]*AdapterUpdate
+	modes    map[int]string
+	items    map[int]map[string]int
+	overflow map[itemKey]int
+	updates  []*AdapterUpdate
+	size     int
+	lock     sync.Mutex
+}
+
+type itemKey struct {
+	subID int
+	item  int
+}
+
+// itemOverflow records the number of updates lost for an item.
+type itemOverflow struct {
+	itemKey
+	lost int
+}
+
+func newUpdateQueue(size int) *updateQueue {
+	return &updateQueue{
+		ready:    make(chan struct{}, 1),
+		merged:   make(map[itemKey]*AdapterUpdate),
+		modes:    make(map[int]string),
+		items:    make(map[int]map[string]int),
+		overflow: make(map[itemKey]int),
+		size:     cmp.Or(size, defaultQueueSize),
+	}
+}
+
+// register registers the mode of a subscription and, for item-list groups, the names of its items.
+func (q *updateQueue) register(subID int, mode string, items []string) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.modes[subID] = mode
+	if len(items) == 0 {
+		delete(q.items, subID)
+		return
+	}
+	indexes := make(map[string]int, len(items))
+	for i, item := range items {
+		if _, ok := indexes[item]; !ok {
+			indexes[item] = i + 1
+		}
+	}
+	q.items[subID] = indexes
+}
+
+// remove unregisters a subscription.
+func (q *updateQueue) remove(subID int) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	delete(q.modes, subID)
+	delete(q.items, subID)
+}
+
+// push adds an update to the queue. It never blocks. Updates identified by item name are translated to the item's
+// index in the subscription; updates for items the subscription doesn't list are dropped.
+func (q *updateQueue) push(update AdapterUpdate) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if update.ItemName != "" {
+		item, ok := q.items[update.SubscriptionID][update.ItemName]
+		if !ok {
+			return
+		}
+		update.Item = item
+	}
+	key := itemKey{subID: update.SubscriptionID, item: update.Item}
+	merge := q.modes[update.SubscriptionID] == ModeMerge
+	if merge {
+		if pending, ok := q.merged[key]; ok {
+			pending.Values = update.Values
+			return
+		}
+		// conflated updates are bounded by the number of items, so we always queue them.
+	} else if len(q.updates) >= q.size {
+		q.overflow[key]++
+		return
+	}
+	q.updates = append(q.updates, &update)
+	if merge {
+		q.merged[key] = &update
+	}
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// pop returns the oldest update in the queue. It returns false if the queue is empty.
+func (q *updateQueue) pop() (AdapterUpdate, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if len(q.updates) == 0 {
+		return AdapterUpdate{}, false
+	}
+	update := q.updates[0]
+	q.updates[0] = nil
+	q.updates = q.updates[1:]
+	key := itemKey{subID: update.SubscriptionID, item: update.Item}
+	if q.merged[key] == update {
+		delete(q.merged, key)
+	}
+	return *update, true
+}
+
+// overflows returns, and resets, the number of updates that were dropped for each item.
+func (q *updateQueue) overflows() []itemOverflow {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if len(q.overflow) == 0 {
+		return nil
+	}
+	overflows := make([]itemOverflow, 0, len(q.overflow))
+	for key, lost := range q.overflow {
+		overflows = append(overflows, itemOverflow{itemKey: key, lost: lost})
+	}
+	clear(q.overflow)
+	slices.SortFunc(overflows, func(a, b itemOverflow) int {
+		return cmp.Or(cmp.Compare(a.subID, b.subID), cmp.Compare(a.item, b.item))
+	})
+	return overflows
+}
+
+// len returns the number of queued updates.
+func (q *updateQueue) len() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return len(q.updates)
+}
@@ -0,0 +1,132 @@
+package lightstreamer
+
+import (
+	"log/slog"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestUpdateQueue_Merge(t *testing.T) {
+	q := newUpdateQueue(2)
+	q.register(1, ModeMerge, nil)
+
+	for i := range 5 {
+		q.push(AdapterUpdate{SubscriptionID: 1, Item: 1, Values: Values{valuePtr(strconv.Itoa(i))}})
+		q.push(AdapterUpdate{SubscriptionID: 1, Item: 2, Values: Values{valuePtr(strconv.Itoa(10 + i))}})
+	}
+	if got := q.len(); got != 2 {
+		t.Fatalf("got %d queued updates, want 2", got)
+	}
+	for _, want := range []string{"4", "14"} {
+		update, ok := q.pop()
+		if !ok {
+			t.Fatal("queue empty")
+		}
+		if got := update.Values.String(); got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+	if overflows := q.overflows(); overflows != nil {
+		t.Errorf("unexpected overflows: %v", overflows)
+	}
+
+	// once popped, new updates are queued again
+	q.push(AdapterUpdate{SubscriptionID: 1, Item: 1, Values: Values{valuePtr("5")}})
+	if update, ok := q.pop(); !ok || update.Values.String() != "5" {
+		t.Errorf("got %v/%v, want 5", update.Values, ok)
+	}
+}
+
+func TestUpdateQueue_Distinct(t *testing.T) {
+	q := newUpdateQueue(2)
+	q.register(1, ModeDistinct, nil)
+
+	for i := range 5 {
+		q.push(AdapterUpdate{SubscriptionID: 1, Item: 1, Values: Values{valuePtr(strconv.Itoa(i))}})
+	}
+	q.push(AdapterUpdate{SubscriptionID: 1, Item: 2, Values: Values{valuePtr("10")}})
+
+	want := []itemOverflow{{itemKey: itemKey{subID: 1, item: 1}, lost: 3}, {itemKey: itemKey{subID: 1, item: 2}, lost: 1}}
+	if got := q.overflows(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got := q.overflows(); got != nil {
+		t.Errorf("overflows not reset: %v", got)
+	}
+	for _, want := range []string{"0", "1"} {
+		update, ok := q.pop()
+		if !ok {
+			t.Fatal("queue empty")
+		}
+		if got := update.Values.String(); got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+	if _, ok := q.pop(); ok {
+		t.Error("expected queue to be empty")
+	}
+}
+
+func TestUpdateQueue_ItemNames(t *testing.T) {
+	q := newUpdateQueue(10)
+	q.register(1, ModeDistinct, []string{"b", "a"})
+
+	q.push(AdapterUpdate{SubscriptionID: 1, ItemName: "a", Values: Values{valuePtr("1")}})
+	q.push(AdapterUpdate{SubscriptionID: 1, ItemName: "c", Values: Values{valuePtr("2")}})
+	q.push(AdapterUpdate{SubscriptionID: 1, ItemName: "b", Values: Values{valuePtr("3")}})
+
+	for _, want := range []int{2, 1} {
+		update, ok := q.pop()
+		if !ok {
+			t.Fatal("queue empty")
+		}
+		if update.Item != want {
+			t.Errorf("got item %d, want %d", update.Item, want)
+		}
+	}
+	if _, ok := q.pop(); ok {
+		t.Error("expected update for unknown item to be dropped")
+	}
+}
+
+func TestSession_SlowClient(t *testing.T) {
+	s := NewServer("set", "cid", nil, slog.New(slog.DiscardHandler), WithQueueSize(10))
+	sess, _ := s.addSession(sessionCommand{AdapterSet: "set"}, "")
+	t.Cleanup(func() { s.removeSession(sess.sessionID) })
+	sess.queue.register(1, ModeDistinct, nil)
+
+	// simulate a client that's stuck writing an update
+	sess.lock.Lock()
+	defer sess.lock.Unlock()
+
+	// the adapter is not blocked
+	for i := range 100 {
+		select {
+		case sess.update <- AdapterUpdate{SubscriptionID: 1, Item: 1, Values: Values{valuePtr(strconv.Itoa(i))}}:
+		case <-time.After(time.Second):
+			t.Fatalf("adapter blocked after %d updates", i)
+		}
+	}
+}
+
+func TestSession_Closed(t *testing.T) {
+	s := NewServer("set", "cid", nil, slog.New(slog.DiscardHandler))
+	sess, _ := s.addSession(sessionCommand{AdapterSet: "set"}, "")
+	sess.queue.register(1, ModeMerge, nil)
+
+	// once the session is canceled, an adapter that hasn't been unsubscribed yet is not blocked
+	sess.cancel()
+	for i := range 10 {
+		select {
+		case sess.update <- AdapterUpdate{SubscriptionID: 1, Item: 1, Values: Values{valuePtr(strconv.Itoa(i))}}:
+		case <-time.After(time.Second):
+			t.Fatalf("adapter blocked after %d updates", i)
+		}
+	}
+	if _, ok := sess.queue.pop(); ok {
+		t.Error("closed session queued an update")
+	}
+	s.removeSession(sess.sessionID)
+}
@@ -0,0 +1,29 @@
+package lightstreamer
+
+import "time"
+
+// A rateLimiter is a token bucket: it allows up to burst events at once, refilled at rate events per second.
+// rateLimiter is not safe for concurrent use.
+type rateLimiter struct {
+	last   time.Time
+	rate   float64
+	burst  float64
+	tokens float64
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	return &rateLimiter{rate: rate, burst: float64(max(burst, 1)), tokens: float64(max(burst, 1))}
+}
+
+// allow reports whether an event may happen at time now and, if so, consumes a token.
+func (l *rateLimiter) allow(now time.Time) bool {
+	if !l.last.IsZero() {
+		l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	}
+	l.last = now
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
@@ -0,0 +1,30 @@
+package lightstreamer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	l := newRateLimiter(2, 2)
+	now := time.Now()
+
+	for i, tt := range []struct {
+		delay time.Duration
+		want  bool
+	}{
+		{delay: 0, want: true},
+		{delay: 0, want: true},
+		{delay: 0, want: false},
+		{delay: 250 * time.Millisecond, want: false},
+		{delay: 250 * time.Millisecond, want: true},
+		{delay: 10 * time.Second, want: true},
+		{delay: 0, want: true},
+		{delay: 0, want: false},
+	} {
+		now = now.Add(tt.delay)
+		if got := l.allow(now); got != tt.want {
+			t.Errorf("%d: got %v, want %v", i, got, tt.want)
+		}
+	}
+}
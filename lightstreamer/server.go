@@ -2,14 +2,17 @@ package lightstreamer
 
 import (
 	"bufio"
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
 	"io"
 	"iter"
 	"log/slog"
+	"net"
 	"net/http"
 	"net/url"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -20,45 +23,211 @@ const (
 	keepAlivePeriodMilliSeconds = 5000
 )
 
+// Subscription modes, as requested by the client in LS_mode.
+const (
+	ModeMerge    = "MERGE"
+	ModeDistinct = "DISTINCT"
+	ModeCommand  = "COMMAND"
+	ModeRaw      = "RAW"
+)
+
+// REQERR error codes, as defined by TLCP.
+const (
+	errCodeGeneric         = 1
+	errCodeBadDataAdapter  = 17
+	errCodeSessionNotFound = 20
+	errCodeBadGroup        = 21
+	errCodeBadSchema       = 23
+	errCodeModeNotAllowed  = 24
+)
+
+// CONERR error codes, as defined by TLCP.
+const (
+	conErrCodeAuthFailed     = 1
+	conErrCodeRecoveryFailed = 4
+	conErrCodeMaxSessions    = 8
+)
+
+// A connectionError refuses a create_session or bind_session request. Its code is reported to the client in the CONERR message.
+type connectionError struct {
+	message string
+	code    int
+}
+
+func (e *connectionError) Error() string {
+	return e.message
+}
+
+// errRateLimited is returned when clients create sessions faster than the configured rate.
+var errRateLimited = errors.New("session creation rate exceeded")
+
+// An AuthFunc authenticates a client creating a session, based on the LS_user and LS_password parameters of the
+// create_session request and the client's IP address. If it returns an error, the session is refused.
+type AuthFunc func(user string, password string, clientIP string) error
+
 type AdapterSet map[string]Adapter
 
 type Adapter interface {
 	Subscribe(ch chan<- AdapterUpdate, subId int, mode string, schema string) (int, int, error)
+	// Unsubscribe ends a subscription, e.g. because its session was closed. Once Unsubscribe returns, the adapter
+	// must not send any more updates for the subscription.
+	Unsubscribe(ch chan<- AdapterUpdate, subId int)
 	fmt.Stringer
 }
 
+// A ModeAdapter is an Adapter that only supports some subscription modes.
+// Adapters that don't implement ModeAdapter accept MERGE, DISTINCT and COMMAND subscriptions.
+type ModeAdapter interface {
+	Adapter
+	SupportsMode(mode string) bool
+}
+
+// A SnapshotAdapter is an Adapter that knows the current values of its items. New subscriptions receive them as their
+// first updates, right after the subscription is confirmed, rather than waiting for the items to change.
+type SnapshotAdapter interface {
+	Adapter
+	// Snapshot returns an update with the current values of each of the adapter's items. Their SubscriptionID is ignored.
+	Snapshot() []AdapterUpdate
+}
+
+// A RequestError is a control request error. Its Code is reported to the client in the REQERR message.
+type RequestError struct {
+	Message string
+	Code    int
+}
+
+func (e *RequestError) Error() string {
+	return e.Message
+}
+
 type AdapterUpdate struct {
 	Values         Values
 	SubscriptionID int
 	Item           int
+	// ItemName identifies the item by name, rather than by index. If set, the server translates it to the item's
+	// index in the subscription's item list. Adapters serving items of a multi-item group should use ItemName.
+	ItemName string
 }
 
+// defaultDataAdapter is used when the client doesn't specify LS_data_adapter.
+const defaultDataAdapter = "DEFAULT"
+
 type Server struct {
 	http.Handler
-	adapterSets map[string]AdapterSet
-	sessions    map[string]*session
-	logger      *slog.Logger
-	set         string
-	cid         string
-	sessionID   int
-	lock        sync.Mutex
+	adapterSets      map[string]map[string]AdapterSet
+	sessions         map[string]*session
+	logger           *slog.Logger
+	cid              string
+	serverName       string
+	bandwidth        float64
+	sessionID        int
+	queueSize        int
+	flushInterval    time.Duration
+	auth             AuthFunc
+	middleware       []Middleware
+	corsOrigins      []string
+	chaos            Chaos
+	sessionRate      *rateLimiter
+	maxSessions      int
+	maxSessionsPerIP int
+	forwardedFor     bool
+	lock             sync.Mutex
 }
 
-func NewServer(set string, cid string, adapterSets map[string]AdapterSet, logger *slog.Logger) *Server {
+// NewServer returns a new Server, serving one adapter set, with the specified data adapters.
+// Use AddAdapterSet to serve additional adapter sets. Use ServerOption arguments to further configure the server.
+func NewServer(set string, cid string, adapterSets map[string]AdapterSet, logger *slog.Logger, options ...ServerOption) *Server {
 	s := Server{
-		adapterSets: adapterSets,
-		set:         set,
-		cid:         cid,
-		sessions:    make(map[string]*session),
-		logger:      logger,
+		adapterSets:   map[string]map[string]AdapterSet{set: adapterSets},
+		cid:           cid,
+		serverName:    "fake server",
+		sessions:      make(map[string]*session),
+		logger:        logger,
+		flushInterval: defaultFlushInterval,
+	}
+	for _, o := range options {
+		o(&s)
 	}
 	m := http.NewServeMux()
-	m.HandleFunc("POST /create_session.txt", s.session)
-	m.HandleFunc("POST /control.txt", s.control)
-	s.Handler = withProtocol(lsProtocol)(m)
+	for _, method := range []string{http.MethodPost, http.MethodGet} {
+		m.HandleFunc(method+" /create_session.txt", s.session)
+		m.HandleFunc(method+" /bind_session.txt", s.bind)
+		m.HandleFunc(method+" /control.txt", s.control)
+		m.HandleFunc(method+" /heartbeat.txt", s.heartbeat)
+	}
+	h := withProtocol(lsProtocol)(m)
+	if s.corsOrigins != nil {
+		h = withCORS(s.corsOrigins)(h)
+	}
+	s.Handler = chain(h, s.middleware)
 	return &s
 }
 
+// RegisterAdapter adds an adapter to the server, serving the specified group of a data adapter in an adapter set.
+// If the adapter set or data adapter doesn't exist, it is created.  RegisterAdapter can be called while the server is running.
+func (s *Server) RegisterAdapter(set string, dataAdapter string, group string, adapter Adapter) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.adapterSets[set] == nil {
+		s.adapterSets[set] = make(map[string]AdapterSet)
+	}
+	if s.adapterSets[set][dataAdapter] == nil {
+		s.adapterSets[set][dataAdapter] = make(AdapterSet)
+	}
+	s.adapterSets[set][dataAdapter][group] = adapter
+}
+
+// DeregisterAdapter removes the adapter serving the specified group of a data adapter in an adapter set.
+// Any existing subscriptions to that group are terminated: the server sends an UNSUB message to the client.
+func (s *Server) DeregisterAdapter(set string, dataAdapter string, group string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := s.adapterSets[set][dataAdapter][group]; !ok {
+		return
+	}
+	delete(s.adapterSets[set][dataAdapter], group)
+	for _, sess := range s.sessions {
+		if sess.adapterSet == set {
+			sess.unsubscribeGroup(dataAdapter, group)
+		}
+	}
+}
+
+// AddAdapterSet adds an adapter set, with the specified data adapters, to the server. If the adapter set already exists,
+// it is replaced.  Sessions are bound to the adapter set requested at creation time: subscriptions for a session
+// are only served by that adapter set's data adapters.
+func (s *Server) AddAdapterSet(set string, dataAdapters map[string]AdapterSet) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.adapterSets[set] = dataAdapters
+}
+
+// withCORS adds CORS headers for requests from the allowed origins and answers their preflight requests.
+func withCORS(origins []string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			allowed := origin != "" && (slices.Contains(origins, "*") || slices.Contains(origins, origin))
+			if allowed {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				w.Header().Add("Vary", "Origin")
+			}
+			if r.Method != http.MethodOptions {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !allowed {
+				http.Error(w, "origin not allowed", http.StatusForbidden)
+				return
+			}
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+			w.Header().Set("Access-Control-Max-Age", "3600")
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
+
 func withProtocol(want string) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -78,12 +247,13 @@ func (s *Server) session(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var cmdCount int
-	for cmd, err := range readSessionCommands(r.Body) {
+	var sessionCmd sessionCommand
+	for cmd, err := range readSessionCommands(requestBody(r)) {
 		if err != nil {
 			http.Error(w, "failed to read request: "+err.Error(), http.StatusBadRequest)
 			return
 		}
-		if cmd.AdapterSet != s.set {
+		if !s.hasAdapterSet(cmd.AdapterSet) {
 			http.Error(w, "invalid adapter set", http.StatusBadRequest)
 			return
 		}
@@ -91,54 +261,270 @@ func (s *Server) session(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "invalid cid", http.StatusBadRequest)
 			return
 		}
+		sessionCmd = cmd
+		cmdCount++
+	}
+	if cmdCount != 1 {
+		http.Error(w, "invalid number of commands", http.StatusBadRequest)
+		return
+	}
+	clientIP := s.clientIP(r)
+	if s.auth != nil {
+		if err := s.auth(sessionCmd.User, sessionCmd.Password, clientIP); err != nil {
+			s.logger.Debug("authentication failed", "user", sessionCmd.User, "clientIP", clientIP, "err", err)
+			annotateRequest(r, "", "CONERR")
+			writeConErr(w, conErrCodeAuthFailed, "User/password check failed")
+			return
+		}
+	}
+	sess, err := s.addSession(sessionCmd, clientIP)
+	if err != nil {
+		s.logger.Debug("session refused", "clientIP", clientIP, "err", err)
+		var connErr *connectionError
+		if errors.As(err, &connErr) {
+			annotateRequest(r, "", "CONERR")
+			writeConErr(w, connErr.code, connErr.message)
+		} else {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+		}
+		return
+	}
+	annotateRequest(r, sess.sessionID, "CONOK")
+	_ = sess.serve(r.Context(), w, sess.createPreamble(clientIP))
+	s.expireSession(sess)
+}
+
+// writeConErr refuses a create_session or bind_session request with a CONERR message.
+func writeConErr(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Content-Type", "text/enriched; charset=UTF-8")
+	w.Header().Set("Cache-Control", "no-store")
+	_, _ = io.WriteString(w, "CONERR,"+strconv.Itoa(code)+","+message+"\r\n")
+}
+
+func (s *Server) bind(w http.ResponseWriter, r *http.Request) {
+	// Check that the session is flushable
+	if _, ok := w.(http.Flusher); !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	var cmdCount int
+	var bindCmd bindCommand
+	for cmd, err := range readBindCommands(requestBody(r)) {
+		if err != nil {
+			http.Error(w, "failed to read request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		bindCmd = cmd
 		cmdCount++
 	}
 	if cmdCount != 1 {
 		http.Error(w, "invalid number of commands", http.StatusBadRequest)
 		return
 	}
-	if err := s.addSession(w).serve(r.Context(), r.Body); err != nil {
-		s.logger.Error("session error", "err", err)
+	annotateRequest(r, bindCmd.SessionID, "")
+	sess, ok := s.getSession(bindCmd.SessionID)
+	if !ok {
+		http.Error(w, "session not found", http.StatusBadRequest)
+		return
 	}
+	sess.touch()
+	annotateRequest(r, "", "CONOK")
+	if err := sess.serve(r.Context(), w, sess.bindPreamble(s.clientIP(r), bindCmd.Recover, bindCmd.RecoverFrom)); err != nil {
+		s.logger.Debug("bind refused", "sessionID", sess.sessionID, "err", err)
+		var connErr *connectionError
+		if errors.As(err, &connErr) {
+			annotateRequest(r, "", "CONERR")
+			writeConErr(w, connErr.code, connErr.message)
+		} else {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+	s.expireSession(sess)
+}
+
+// clientIP returns the IP address of the client. If the server is configured to trust X-Forwarded-For,
+// the first address in that header is used.
+func (s *Server) clientIP(r *http.Request) string {
+	if s.forwardedFor {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			ip, _, _ := strings.Cut(forwarded, ",")
+			return strings.TrimSpace(ip)
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func (s *Server) hasAdapterSet(set string) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	_, ok := s.adapterSets[set]
+	return ok
 }
 
-func (s *Server) addSession(w http.ResponseWriter) *session {
+// addSession creates a new session for the client at clientIP. It returns an error if the server's session limits
+// are exceeded.
+func (s *Server) addSession(cmd sessionCommand, clientIP string) (*session, error) {
 	s.lock.Lock()
 	defer s.lock.Unlock()
+	if err := s.checkSessionLimits(clientIP); err != nil {
+		return nil, err
+	}
 	// we're just using an increasing number, though it can be a random, unique string
 	s.sessionID++
 	sessionID := strconv.Itoa(s.sessionID)
+	ctx, cancel := context.WithCancel(context.Background())
 	sess := session{
-		w:         lineWriter{ResponseWriter: w},
-		sessionID: sessionID,
-		created:   time.Now(),
-		server:    s,
-		update:    make(chan AdapterUpdate),
-		logger:    s.logger.With("sessionID", sessionID),
+		cancel:       cancel,
+		bandwidth:    s.bandwidth,
+		inactivity:   cmd.Inactivity,
+		lastActivity: time.Now(),
+		adapterSet:   cmd.AdapterSet,
+		sessionID:    sessionID,
+		remoteIP:     clientIP,
+		clientIP:     clientIP,
+		created:      time.Now(),
+		server:       s,
+		update:       make(chan AdapterUpdate),
+		unsubscribed: make(chan struct{}),
+		queue:        newUpdateQueue(s.queueSize),
+		logger:       s.logger.With("sessionID", sessionID),
 	}
 	s.sessions[sessionID] = &sess
-	return &sess
+	go sess.receive(ctx)
+	go sess.run(ctx)
+	return &sess, nil
+}
+
+// checkSessionLimits returns an error if the client at clientIP may not create a new session.
+// The caller must hold s.lock.
+func (s *Server) checkSessionLimits(clientIP string) error {
+	if s.maxSessions > 0 && len(s.sessions) >= s.maxSessions {
+		return &connectionError{code: conErrCodeMaxSessions, message: "Configured maximum number of sessions reached"}
+	}
+	if s.maxSessionsPerIP > 0 {
+		var count int
+		for _, sess := range s.sessions {
+			if sess.remoteIP == clientIP {
+				count++
+			}
+		}
+		if count >= s.maxSessionsPerIP {
+			return &connectionError{code: conErrCodeMaxSessions, message: "Configured maximum number of sessions per client reached"}
+		}
+	}
+	if s.sessionRate != nil && !s.sessionRate.allow(time.Now()) {
+		return errRateLimited
+	}
+	return nil
+}
+
+func (s *Server) getSession(sessionID string) (*session, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	sess, ok := s.sessions[sessionID]
+	return sess, ok
+}
+
+// expireSession closes the session if the client doesn't bind a new stream to it within the session timeout.
+func (s *Server) expireSession(sess *session) {
+	streams := sess.streamCount()
+	time.AfterFunc(sessionTimeout, func() {
+		if sess.unbound(streams) {
+			s.removeSession(sess.sessionID)
+		}
+	})
+}
+
+func (s *Server) removeSession(sessionID string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if sess, ok := s.sessions[sessionID]; ok {
+		sess.close()
+		delete(s.sessions, sessionID)
+		sess.logger.Debug("session closed")
+	}
 }
 
 func (s *Server) control(w http.ResponseWriter, r *http.Request) {
-	for cmd, err := range readControlCommands(r.Body) {
+	for cmd, err := range readControlCommands(requestBody(r)) {
 		if err != nil {
 			http.Error(w, "invalid control request: "+err.Error(), http.StatusBadRequest)
 			return
 		}
 		switch cmd.CommandType {
 		case addCommand:
-			if err = s.subscribe(cmd); err == nil {
-				_, _ = io.WriteString(w, "REQOK,"+cmd.RequestID+"\n")
-			} else {
-				_, _ = io.WriteString(w, "REQERR,"+cmd.RequestID+",1,"+err.Error()+"\n")
-			}
+			err = s.subscribe(cmd)
+		case constrainCommand:
+			err = s.withSession(cmd.SessionID, func(sess *session) { sess.constrain(cmd.Bandwidth) })
+		case forceRebindCommand:
+			err = s.withSession(cmd.SessionID, (*session).forceRebind)
+		case destroyCommand:
+			err = s.destroy(cmd)
 			// this is already handled by err != nil
 			//default:
 			//	http.Error(w, "unsupported operation: "+string(cmd.CommandType), http.StatusBadRequest)
 			//	return
 		}
+		if err == nil {
+			annotateRequest(r, cmd.SessionID, "REQOK")
+			_, _ = io.WriteString(w, "REQOK,"+cmd.RequestID+"\n")
+		} else {
+			annotateRequest(r, cmd.SessionID, "REQERR")
+			_, _ = io.WriteString(w, "REQERR,"+cmd.RequestID+","+strconv.Itoa(requestErrorCode(err))+","+err.Error()+"\n")
+		}
+	}
+}
+
+// withSession calls f for the specified session.
+func (s *Server) withSession(sessionID string, f func(*session)) error {
+	sess, ok := s.getSession(sessionID)
+	if !ok {
+		return &RequestError{Code: errCodeSessionNotFound, Message: "session not found"}
+	}
+	sess.touch()
+	f(sess)
+	return nil
+}
+
+// heartbeat handles the client's reverse heartbeats, which keep the session alive if it requested LS_inactivity_millis.
+func (s *Server) heartbeat(w http.ResponseWriter, r *http.Request) {
+	for cmd, err := range readHeartbeatCommands(requestBody(r)) {
+		if err != nil {
+			http.Error(w, "invalid heartbeat request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		sess, ok := s.getSession(cmd.SessionID)
+		if ok {
+			sess.touch()
+		}
+		annotateRequest(r, cmd.SessionID, "")
+		switch {
+		case cmd.RequestID == "":
+		case ok:
+			annotateRequest(r, "", "REQOK")
+			_, _ = io.WriteString(w, "REQOK,"+cmd.RequestID+"\n")
+		default:
+			annotateRequest(r, "", "REQERR")
+			_, _ = io.WriteString(w, "REQERR,"+cmd.RequestID+","+strconv.Itoa(errCodeSessionNotFound)+",session not found\n")
+		}
+	}
+}
+
+// destroy closes the session at the client's request. The client receives an END message with the cause it requested.
+func (s *Server) destroy(cmd controlCommand) error {
+	sess, ok := s.getSession(cmd.SessionID)
+	if !ok {
+		return &RequestError{Code: errCodeSessionNotFound, Message: "session not found"}
 	}
+	sess.end(cmd.CauseCode, cmd.CauseMessage)
+	s.removeSession(cmd.SessionID)
+	return nil
 }
 
 func (s *Server) subscribe(cmd controlCommand) error {
@@ -146,137 +532,170 @@ func (s *Server) subscribe(cmd controlCommand) error {
 	defer s.lock.Unlock()
 	sess, ok := s.sessions[cmd.SessionID]
 	if !ok {
-		return errors.New("session not found")
+		return &RequestError{Code: errCodeSessionNotFound, Message: "session not found"}
 	}
-	adapterSet, ok := s.adapterSets[cmd.DataAdapter]
+	sess.touch()
+	cmd.DataAdapter = cmp.Or(cmd.DataAdapter, defaultDataAdapter)
+	adapterSet, ok := s.adapterSets[sess.adapterSet][cmd.DataAdapter]
 	if !ok {
-		return errors.New("data adapter not found")
+		return &RequestError{Code: errCodeBadDataAdapter, Message: "data adapter not found"}
 	}
-	group, ok := adapterSet[cmd.Group]
-	if !ok {
-		return errors.New("group not found")
+	adapters, items, err := resolveGroup(adapterSet, cmd.Group)
+	if err != nil {
+		return err
+	}
+	for _, adapter := range adapters {
+		if !supportsMode(adapter, cmd.Mode) {
+			return &RequestError{Code: errCodeModeNotAllowed, Message: "mode not allowed: " + cmd.Mode}
+		}
+	}
+	return sess.subscribe(adapters, items, cmd)
+}
+
+// resolveGroup returns the adapters serving a group. If the adapter set has an adapter for the group itself,
+// that adapter serves the whole group. Otherwise, the group is a space-separated list of item names, each served by
+// the adapter registered under that name. In that case, resolveGroup also returns the item names, in order.
+// An adapter registered under several of the listed names is subscribed once for each of them.
+func resolveGroup(adapterSet AdapterSet, group string) ([]Adapter, []string, error) {
+	if adapter, ok := adapterSet[group]; ok {
+		return []Adapter{adapter}, nil, nil
+	}
+	items := strings.Fields(group)
+	if len(items) == 0 {
+		return nil, nil, &RequestError{Code: errCodeBadGroup, Message: "group not found"}
+	}
+	adapters := make([]Adapter, 0, len(items))
+	for i, item := range items {
+		adapter, ok := adapterSet[item]
+		if !ok {
+			return nil, nil, &RequestError{Code: errCodeBadGroup, Message: "item not found: " + item}
+		}
+		if !slices.Contains(items[:i], item) {
+			adapters = append(adapters, adapter)
+		}
+	}
+	return adapters, items, nil
+}
+
+func supportsMode(group Adapter, mode string) bool {
+	if a, ok := group.(ModeAdapter); ok {
+		return a.SupportsMode(mode)
+	}
+	switch mode {
+	case ModeMerge, ModeDistinct, ModeCommand:
+		return true
+	default:
+		return false
+	}
+}
+
+func requestErrorCode(err error) int {
+	var reqErr *RequestError
+	if errors.As(err, &reqErr) {
+		return reqErr.Code
 	}
-	return sess.subscribe(group, cmd.SubId, cmd.Mode, cmd.Schema)
+	return errCodeGeneric
 }
 
 ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
 
-type session struct {
-	created   time.Time
-	update    chan AdapterUpdate
-	server    *Server
-	logger    *slog.Logger
-	sessionID string
-	w         lineWriter
-}
-
-func (s *session) serve(ctx context.Context, r io.ReadCloser) error {
-	defer func() { _ = r.Close() }()
-
-	s.w.WriteHeader(http.StatusOK)
-
-	s.w.Header().Set("Content-Type", "text/enriched; charset=UTF-8")
-	s.w.Header().Add("Cache-Control", "no-store")
-	s.w.Header().Add("Cache-Control", "no-transform")
-	s.w.Header().Add("Cache-Control", "no-cache")
-	s.w.Header().Add("Pragma", "no-cache")
-	s.w.Header().Set("Transfer-Encoding", "chunked")
-
-	_ = s.write("CONOK", s.sessionID, "5000", strconv.Itoa(keepAlivePeriodMilliSeconds), "*")
-	_ = s.write("SERVNAME", "fake server")
-	_ = s.write("CONS", "unlimited")
-
-	syncTicker := time.NewTicker(20 * time.Second)
-	defer syncTicker.Stop()
-
-	probeTicker := time.NewTicker(5 * time.Second)
-	defer probeTicker.Stop()
-
-	for {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		case <-syncTicker.C:
-			s.sendSync()
-		case <-probeTicker.C:
-			if time.Since(s.w.LastWritten()) > keepAlivePeriodMilliSeconds*time.Millisecond {
-				s.sendProbe()
-			}
-		case update := <-s.update:
-			s.sendUpdate(update)
-		}
+// ServerOption configures a Server.
+type ServerOption func(*Server)
+
+// WithServerName sets the server name sent to clients in the SERVNAME message. The default is "fake server".
+func WithServerName(name string) ServerOption {
+	return func(s *Server) {
+		s.serverName = name
 	}
 }
 
-func (s *session) sendProbe() {
-	_ = s.write("PROBE")
+// WithBandwidth sets the maximum bandwidth (in kbps) sent to clients in the CONS message.
+// The default is zero, meaning the bandwidth is unlimited.
+func WithBandwidth(bandwidth float64) ServerOption {
+	return func(s *Server) {
+		s.bandwidth = bandwidth
+	}
 }
 
-func (s *session) sendSync() {
-	age := time.Since(s.created)
-	_ = s.write("SYNC", strconv.Itoa(int(age.Seconds())))
+// WithQueueSize sets the number of updates each session buffers for its client. If a client can't keep up,
+// MERGE updates are conflated, while DISTINCT and COMMAND updates are dropped, and the client receives an OV message.
+// The default is 1000.
+func WithQueueSize(size int) ServerOption {
+	return func(s *Server) {
+		s.queueSize = size
+	}
 }
 
-func (s *session) sendUpdate(update AdapterUpdate) {
-	_ = s.write("U", strconv.Itoa(update.SubscriptionID), strconv.Itoa(update.Item), update.Values.String())
+// WithFlushInterval sets how long streams buffer lines before sending them to the client. Batching lines reduces
+// the cost of writing to the client at high update rates. Lines are sent immediately once all queued updates
+// have been written. Zero sends every line immediately. The default is 10ms.
+func WithFlushInterval(interval time.Duration) ServerOption {
+	return func(s *Server) {
+		s.flushInterval = interval
+	}
 }
 
-func (s *session) write(elements ...string) error {
-	line := strings.Join(elements, ",")
-	s.logger.Debug("send", "line", line)
-	s.w.WriteLine(line)
-	return nil
+// WithForwardedFor determines the client IP address, sent to clients in the CLIENTIP message, from the X-Forwarded-For
+// header, if present. Only use this if the server runs behind a trusted reverse proxy.
+func WithForwardedFor() ServerOption {
+	return func(s *Server) {
+		s.forwardedFor = true
+	}
+}
+
+// WithAuth authenticates clients with the specified AuthFunc when they create a session. Clients that fail
+// authentication receive a CONERR message. By default, all clients are accepted.
+func WithAuth(auth AuthFunc) ServerOption {
+	return func(s *Server) {
+		s.auth = auth
+	}
 }
 
-func (s *session) subscribe(group Adapter, subId int, mode string, schema string) error {
-	items, fields, err := group.Subscribe(s.update, subId, mode, schema)
-	if err == nil {
-		_ = s.write("SUBOK", strconv.Itoa(subId), strconv.Itoa(items), strconv.Itoa(fields))
+// WithMaxSessions limits the number of concurrent sessions. Clients creating a session beyond the limit receive
+// a CONERR message. The default is zero, meaning the number of sessions is unlimited.
+func WithMaxSessions(limit int) ServerOption {
+	return func(s *Server) {
+		s.maxSessions = limit
 	}
-	s.logger.Debug("subscription requested", "subID", subId, "group", group.String(), "err", err)
-	return err
 }
 
-type lineWriter struct {
-	http.ResponseWriter
-	lastWritten time.Time
-	lock        sync.RWMutex
+// WithMaxSessionsPerIP limits the number of concurrent sessions created from the same client IP address.
+// Clients creating a session beyond the limit receive a CONERR message. The default is zero, meaning unlimited.
+func WithMaxSessionsPerIP(limit int) ServerOption {
+	return func(s *Server) {
+		s.maxSessionsPerIP = limit
+	}
 }
 
-func (w *lineWriter) WriteLine(s string) {
-	w.lock.Lock()
-	defer w.lock.Unlock()
-	_, _ = io.WriteString(w.ResponseWriter, s+"\r\n")
-	w.ResponseWriter.(http.Flusher).Flush()
-	w.lastWritten = time.Now()
+// WithSessionRate limits the rate at which sessions can be created to rate sessions per second, with bursts of up
+// to burst sessions. Requests exceeding the rate receive an HTTP 429 (Too Many Requests) response.
+// By default, the rate is unlimited.
+func WithSessionRate(rate float64, burst int) ServerOption {
+	return func(s *Server) {
+		s.sessionRate = newRateLimiter(rate, burst)
+	}
 }
 
-func (w *lineWriter) LastWritten() time.Time {
-	w.lock.RLock()
-	defer w.lock.RUnlock()
-	return w.lastWritten
+// WithCORS allows browser clients from the specified origins to connect to the server. Use "*" to allow all origins.
+// By default, no CORS headers are sent.
+func WithCORS(origins ...string) ServerOption {
+	return func(s *Server) {
+		s.corsOrigins = append(make([]string, 0, len(origins)), origins...)
+	}
 }
 
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
 type sessionCommand struct {
 	AdapterSet string
 	CID        string
+	User       string
+	Password   string
+	Inactivity time.Duration
 }
 
 func readSessionCommands(r io.ReadCloser) iter.Seq2[sessionCommand, error] {
-	return func(yield func(sessionCommand, error) bool) {
-		for values, err := range readCommands(r) {
-			var cmd sessionCommand
-			if err == nil {
-				cmd, err = parseSessionCommand(values)
-			}
-			if !yield(cmd, err) {
-				return
-			}
-			if err != nil {
-				return
-			}
-		}
-	}
+	return readParsedCommands(r, parseSessionCommand)
 }
 
 func parseSessionCommand(values url.Values) (cmd sessionCommand, err error) {
@@ -286,41 +705,67 @@ func parseSessionCommand(values url.Values) (cmd sessionCommand, err error) {
 	if cmd.CID = values.Get("LS_cid"); cmd.CID == "" {
 		return cmd, errors.New("missing requested LS_cid")
 	}
+	cmd.User, cmd.Password = values.Get("LS_user"), values.Get("LS_password")
+	if inactivity := values.Get("LS_inactivity_millis"); inactivity != "" {
+		millis, err := strconv.Atoi(inactivity)
+		if err != nil || millis < 0 {
+			return cmd, fmt.Errorf("invalid LS_inactivity_millis: %q", inactivity)
+		}
+		cmd.Inactivity = time.Duration(millis) * time.Millisecond
+	}
 	return cmd, nil
 }
 
-type controlCommand struct {
-	CommandType commandType
+type bindCommand struct {
 	SessionID   string
-	RequestID   string
-	DataAdapter string
-	Group       string
-	Mode        string
-	Schema      string
-	SubId       int
+	RecoverFrom int
+	Recover     bool
+}
+
+func readBindCommands(r io.ReadCloser) iter.Seq2[bindCommand, error] {
+	return readParsedCommands(r, parseBindCommand)
+}
+
+func parseBindCommand(values url.Values) (cmd bindCommand, err error) {
+	if cmd.SessionID = values.Get("LS_session"); cmd.SessionID == "" {
+		return cmd, errors.New("missing LS_session")
+	}
+	if recoverFrom := values.Get("LS_recovery_from"); recoverFrom != "" {
+		if cmd.RecoverFrom, err = strconv.Atoi(recoverFrom); err != nil {
+			return cmd, fmt.Errorf("invalid LS_recovery_from: %w", err)
+		}
+		cmd.Recover = true
+	}
+	return cmd, nil
+}
+
+type controlCommand struct {
+	CommandType  commandType
+	SessionID    string
+	RequestID    string
+	DataAdapter  string
+	Group        string
+	Mode         string
+	Schema       string
+	SubId        int
+	Bandwidth    float64
+	MaxFrequency float64
+	Unfiltered   bool
+	CauseCode    int
+	CauseMessage string
 }
 
 type commandType string
 
 const (
-	addCommand commandType = "add"
+	addCommand         commandType = "add"
+	constrainCommand   commandType = "constrain"
+	forceRebindCommand commandType = "force_rebind"
+	destroyCommand     commandType = "destroy"
 )
 
 func readControlCommands(r io.ReadCloser) iter.Seq2[controlCommand, error] {
-	return func(yield func(controlCommand, error) bool) {
-		for values, err := range readCommands(r) {
-			var cmd controlCommand
-			if err == nil {
-				cmd, err = parseControlCommand(values)
-			}
-			if !yield(cmd, err) {
-				return
-			}
-			if err != nil {
-				return
-			}
-		}
-	}
+	return readParsedCommands(r, parseControlCommand)
 }
 
 func parseControlCommand(values url.Values) (cmd controlCommand, err error) {
@@ -341,12 +786,100 @@ func parseControlCommand(values url.Values) (cmd controlCommand, err error) {
 		}
 		cmd.Schema = values.Get("LS_schema")
 		cmd.Mode = values.Get("LS_mode")
+		if cmd.MaxFrequency, cmd.Unfiltered, err = parseFrequency(values.Get("LS_requested_max_frequency")); err != nil {
+			return cmd, fmt.Errorf("invalid LS_requested_max_frequency: %w", err)
+		}
+	case constrainCommand:
+		if cmd.Bandwidth, err = parseBandwidth(values.Get("LS_requested_max_bandwidth")); err != nil {
+			return cmd, fmt.Errorf("invalid LS_requested_max_bandwidth: %w", err)
+		}
+	case forceRebindCommand:
+	case destroyCommand:
+		cmd.CauseCode, cmd.CauseMessage = endCodeClosedByAdmin, "closed by client"
+		if code := values.Get("LS_cause_code"); code != "" {
+			if cmd.CauseCode, err = strconv.Atoi(code); err != nil || cmd.CauseCode > 0 {
+				return cmd, fmt.Errorf("invalid LS_cause_code: %q", code)
+			}
+		}
+		cmd.CauseMessage = cmp.Or(values.Get("LS_cause_message"), cmd.CauseMessage)
 	default:
 		return cmd, fmt.Errorf("missing/unsupported command type: %q", cmd.CommandType)
 	}
 	return cmd, nil
 }
 
+// parseBandwidth parses a bandwidth, as requested by the client. "unlimited" is returned as zero.
+func parseBandwidth(value string) (float64, error) {
+	if value == "unlimited" {
+		return 0, nil
+	}
+	bandwidth, err := strconv.ParseFloat(value, 64)
+	if err == nil && bandwidth <= 0 {
+		err = errors.New("bandwidth must be positive")
+	}
+	return bandwidth, err
+}
+
+// parseFrequency parses LS_requested_max_frequency. Zero means the frequency is unlimited. "unfiltered" also
+// requests that no updates are filtered.
+func parseFrequency(value string) (float64, bool, error) {
+	switch value {
+	case "", "unlimited":
+		return 0, false, nil
+	case "unfiltered":
+		return 0, true, nil
+	}
+	frequency, err := strconv.ParseFloat(value, 64)
+	if err == nil && frequency <= 0 {
+		err = errors.New("frequency must be positive")
+	}
+	return frequency, false, err
+}
+
+type heartbeatCommand struct {
+	SessionID string
+	RequestID string
+}
+
+func readHeartbeatCommands(r io.ReadCloser) iter.Seq2[heartbeatCommand, error] {
+	return readParsedCommands(r, parseHeartbeatCommand)
+}
+
+func parseHeartbeatCommand(values url.Values) (cmd heartbeatCommand, err error) {
+	if cmd.SessionID = values.Get("LS_session"); cmd.SessionID == "" {
+		return cmd, errors.New("missing LS_session")
+	}
+	cmd.RequestID = values.Get("LS_reqId")
+	return cmd, nil
+}
+
+// requestBody returns the commands of a request. POST requests send their commands in the body, one per line.
+// GET requests send a single command as query parameters.
+func requestBody(r *http.Request) io.ReadCloser {
+	if r.Method == http.MethodGet {
+		return io.NopCloser(strings.NewReader(r.URL.RawQuery))
+	}
+	return r.Body
+}
+
+// readParsedCommands parses each command in r. It stops at the first invalid command.
+func readParsedCommands[T any](r io.ReadCloser, parse func(url.Values) (T, error)) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for values, err := range readCommands(r) {
+			var cmd T
+			if err == nil {
+				cmd, err = parse(values)
+			}
+			if !yield(cmd, err) {
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
 func readCommands(r io.ReadCloser) iter.Seq2[url.Values, error] {
 	return func(yield func(url.Values, error) bool) {
 		defer func() { _ = r.Close() }()
@@ -1,8 +1,11 @@
 package lightstreamer
 
 import (
+	"bufio"
 	"cmp"
 	"context"
+	"errors"
+	"io"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
@@ -37,7 +40,7 @@ func TestServer_Connect(t *testing.T) {
 		},
 		{
 			name:   "invalid method",
-			method: http.MethodGet,
+			method: http.MethodPut,
 			path:   "/create_session.txt",
 			args:   url.Values{"LS_protocol": []string{"TLCP-2.1.0"}},
 			parms: url.Values{
@@ -87,6 +90,18 @@ func TestServer_Connect(t *testing.T) {
 			},
 			want: http.StatusBadRequest,
 		},
+		{
+			name:   "invalid inactivity",
+			method: http.MethodPost,
+			path:   "/create_session.txt",
+			args:   url.Values{"LS_protocol": []string{"TLCP-2.1.0"}},
+			parms: url.Values{
+				"LS_adapter_set":       []string{"set"},
+				"LS_cid":               []string{"cid"},
+				"LS_inactivity_millis": []string{"a"},
+			},
+			want: http.StatusBadRequest,
+		},
 		{
 			name:   "invalid cid",
 			method: http.MethodPost,
@@ -200,6 +215,289 @@ func TestServer_Subscribe(t *testing.T) {
 	}
 }
 
+func TestServer_Subscribe_Mode(t *testing.T) {
+	tests := []struct {
+		name     string
+		adapter  Adapter
+		mode     string
+		schema   string
+		wantCode int
+	}{
+		{name: "merge", adapter: &timedAdapter{}, mode: ModeMerge, schema: "Value"},
+		{name: "distinct", adapter: &timedAdapter{}, mode: ModeDistinct, schema: "Value"},
+		{name: "command", adapter: &timedAdapter{}, mode: ModeCommand, schema: "key command Value"},
+		{name: "command without key", adapter: &timedAdapter{}, mode: ModeCommand, schema: "command Value", wantCode: errCodeBadSchema},
+		{name: "raw", adapter: &timedAdapter{}, mode: ModeRaw, schema: "Value", wantCode: errCodeModeNotAllowed},
+		{name: "missing mode", adapter: &timedAdapter{}, schema: "Value", wantCode: errCodeModeNotAllowed},
+		{name: "mode not supported by adapter", adapter: &mergeOnlyAdapter{}, mode: ModeDistinct, schema: "Value", wantCode: errCodeModeNotAllowed},
+		{name: "mode supported by adapter", adapter: &mergeOnlyAdapter{}, mode: ModeMerge, schema: "Value"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer("set", "cid", map[string]AdapterSet{"DEFAULT": {"1": tt.adapter}}, slog.New(slog.DiscardHandler))
+			sess, _ := s.addSession(sessionCommand{AdapterSet: "set"}, "")
+			err := s.subscribe(controlCommand{SessionID: sess.sessionID, DataAdapter: "DEFAULT", Group: "1", Mode: tt.mode, Schema: tt.schema, SubId: 1})
+			if tt.wantCode == 0 {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if got := requestErrorCode(err); got != tt.wantCode {
+				t.Errorf("got code %d, want %d (err: %v)", got, tt.wantCode, err)
+			}
+		})
+	}
+}
+
+func TestServer_AddAdapterSet(t *testing.T) {
+	s := NewServer("set1", "cid", map[string]AdapterSet{"DEFAULT": {"1": &timedAdapter{}}}, slog.New(slog.DiscardHandler))
+	s.AddAdapterSet("set2", map[string]AdapterSet{"DEFAULT": {"2": &timedAdapter{}}})
+
+	tests := []struct {
+		name     string
+		set      string
+		group    string
+		wantCode int
+	}{
+		{name: "set1", set: "set1", group: "1"},
+		{name: "set1: group from other set", set: "set1", group: "2", wantCode: errCodeBadGroup},
+		{name: "set2", set: "set2", group: "2"},
+		{name: "set2: group from other set", set: "set2", group: "1", wantCode: errCodeBadGroup},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !s.hasAdapterSet(tt.set) {
+				t.Fatalf("adapter set %q not found", tt.set)
+			}
+			sess, _ := s.addSession(sessionCommand{AdapterSet: tt.set}, "")
+			err := s.subscribe(controlCommand{SessionID: sess.sessionID, Group: tt.group, Mode: ModeMerge, Schema: "Value", SubId: 1})
+			if tt.wantCode == 0 {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if got := requestErrorCode(err); got != tt.wantCode {
+				t.Errorf("got code %d, want %d (err: %v)", got, tt.wantCode, err)
+			}
+		})
+	}
+}
+
+func TestServer_DeregisterAdapter(t *testing.T) {
+	var a timedAdapter
+	go a.Run(t.Context(), 100*time.Millisecond)
+
+	l := slog.New(slog.DiscardHandler)
+	s := NewServer("set", "cid", nil, l)
+	s.RegisterAdapter("set", "DEFAULT", "1", &a)
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+
+	c := NewClientSession(WithLogger(l), WithServerURL(ts.URL), WithAdapterSet("set"), WithCID("cid"))
+	if err := c.ConnectWithSession(t.Context(), time.Second); err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	t.Cleanup(c.Disconnect)
+	if err := c.Subscribe(t.Context(), "DEFAULT", "1", []string{"Value"}, 0, func(int, Values) {}); err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+
+	s.DeregisterAdapter("set", "DEFAULT", "1")
+
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Second)
+	defer cancel()
+	for {
+		if _, ok := c.subscriptions.get(1); !ok {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatal("timeout waiting for UNSUB")
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	if err := c.Subscribe(t.Context(), "DEFAULT", "1", []string{"Value"}, 0, func(int, Values) {}); err == nil {
+		t.Error("expected subscription to deregistered adapter to fail")
+	}
+}
+
+func TestServer_Auth(t *testing.T) {
+	auth := func(user, password, clientIP string) error {
+		if user != "user" || password != "secret" || clientIP != "127.0.0.1" {
+			return errors.New("invalid credentials")
+		}
+		return nil
+	}
+	s := NewServer("set", "cid", nil, slog.New(slog.DiscardHandler), WithAuth(auth))
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+
+	tests := []struct {
+		name     string
+		password string
+		want     string
+	}{
+		{name: "valid", password: "secret", want: "CONOK,"},
+		{name: "invalid", password: "wrong", want: "CONERR,1,User/password check failed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(t.Context())
+			defer cancel()
+			lines := postStream(t, ctx, ts.URL+"/create_session.txt", url.Values{
+				"LS_adapter_set": {"set"},
+				"LS_cid":         {"cid"},
+				"LS_user":        {"user"},
+				"LS_password":    {tt.password},
+			})
+			if got := nextLine(t, lines); !strings.HasPrefix(got, tt.want) {
+				t.Errorf("got %q, want prefix %q", got, tt.want)
+			}
+		})
+	}
+
+	c := NewClientSession(WithLogger(slog.New(slog.DiscardHandler)), WithServerURL(ts.URL), WithAdapterSet("set"), WithCID("cid"), WithCredentials("user", "secret"))
+	if err := c.ConnectWithSession(t.Context(), time.Second); err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	c.Disconnect()
+}
+
+func TestServer_SessionLimits(t *testing.T) {
+	tests := []struct {
+		name   string
+		option ServerOption
+		want   string
+	}{
+		{name: "max sessions", option: WithMaxSessions(2), want: "CONERR,8,Configured maximum number of sessions reached"},
+		{name: "max sessions per IP", option: WithMaxSessionsPerIP(2), want: "CONERR,8,Configured maximum number of sessions per client reached"},
+		{name: "session rate", option: WithSessionRate(0.1, 2), want: "429"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer("set", "cid", nil, slog.New(slog.DiscardHandler), tt.option)
+			ts := httptest.NewServer(s)
+			t.Cleanup(ts.Close)
+
+			values := url.Values{"LS_adapter_set": {"set"}, "LS_cid": {"cid"}}
+			for range 2 {
+				lines := postStream(t, t.Context(), ts.URL+"/create_session.txt", values)
+				if got := nextLine(t, lines); !strings.HasPrefix(got, "CONOK,") {
+					t.Fatalf("got %q, want CONOK", got)
+				}
+			}
+
+			resp := post(t, t.Context(), ts.URL+"/create_session.txt", values)
+			got := strconv.Itoa(resp.StatusCode)
+			if resp.StatusCode == http.StatusOK {
+				got = nextLine(t, bufio.NewScanner(resp.Body))
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServer_GET(t *testing.T) {
+	s := NewServer("set", "cid", nil, slog.New(slog.DiscardHandler))
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+
+	query := url.Values{"LS_protocol": {lsProtocol}, "LS_adapter_set": {"set"}, "LS_cid": {"cid"}}
+	req, _ := http.NewRequestWithContext(t.Context(), http.MethodGet, ts.URL+"/create_session.txt?"+query.Encode(), nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = resp.Body.Close() })
+	lines := bufio.NewScanner(resp.Body)
+	conOK := nextLine(t, lines)
+	if !strings.HasPrefix(conOK, "CONOK,") {
+		t.Fatalf("got %q, want CONOK", conOK)
+	}
+
+	query = url.Values{"LS_protocol": {lsProtocol}, "LS_reqId": {"1"}, "LS_session": {strings.Split(conOK, ",")[1]}}
+	resp, err = http.Get(ts.URL + "/heartbeat.txt?" + query.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if body, _ := io.ReadAll(resp.Body); string(body) != "REQOK,1\n" {
+		t.Errorf("got %q, want REQOK", string(body))
+	}
+}
+
+func TestServer_CORS(t *testing.T) {
+	s := NewServer("set", "cid", nil, slog.New(slog.DiscardHandler), WithCORS("https://example.com"))
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+
+	tests := []struct {
+		name       string
+		method     string
+		origin     string
+		wantStatus int
+		wantOrigin string
+	}{
+		{name: "preflight", method: http.MethodOptions, origin: "https://example.com", wantStatus: http.StatusNoContent, wantOrigin: "https://example.com"},
+		{name: "preflight: origin not allowed", method: http.MethodOptions, origin: "https://example.org", wantStatus: http.StatusForbidden},
+		{name: "request", method: http.MethodPost, origin: "https://example.com", wantStatus: http.StatusOK, wantOrigin: "https://example.com"},
+		{name: "request: origin not allowed", method: http.MethodPost, origin: "https://example.org", wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := url.Values{"LS_reqId": {"1"}, "LS_session": {"unknown"}}
+			req, _ := http.NewRequestWithContext(t.Context(), tt.method, ts.URL+"/heartbeat.txt?LS_protocol="+lsProtocol, strings.NewReader(body.Encode()))
+			req.Header.Set("Origin", tt.origin)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("got status %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if got := resp.Header.Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("got origin %q, want %q", got, tt.wantOrigin)
+			}
+		})
+	}
+}
+
+func TestServer_Control_RequestError(t *testing.T) {
+	s := NewServer("set", "cid", nil, slog.New(slog.DiscardHandler))
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+
+	body := url.Values{
+		"LS_op":           []string{"add"},
+		"LS_reqId":        []string{"1"},
+		"LS_session":      []string{"unknown"},
+		"LS_subId":        []string{"1"},
+		"LS_data_adapter": []string{"DEFAULT"},
+		"LS_group":        []string{"1"},
+		"LS_mode":         []string{ModeMerge},
+	}
+	resp, err := http.Post(ts.URL+"/control.txt?LS_protocol="+lsProtocol, "application/x-www-form-urlencoded", strings.NewReader(body.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	got, _ := io.ReadAll(resp.Body)
+	if want := "REQERR,1,20,session not found\n"; string(got) != want {
+		t.Errorf("got %q, want %q", string(got), want)
+	}
+}
+
 ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
 
 func TestAdapter_Run(t *testing.T) {
@@ -256,6 +554,14 @@ func (t *timedAdapter) Run(ctx context.Context, interval time.Duration) {
 	}
 }
 
+func (t *timedAdapter) Unsubscribe(ch chan<- AdapterUpdate, subId int) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.subscriptions[subId] == ch {
+		delete(t.subscriptions, subId)
+	}
+}
+
 func (t *timedAdapter) publish(values Values) {
 	t.lock.RLock()
 	defer t.lock.RUnlock()
@@ -267,3 +573,126 @@ func (t *timedAdapter) publish(values Values) {
 func (t *timedAdapter) String() string {
 	return "timedAdapter"
 }
+
+var _ ModeAdapter = &mergeOnlyAdapter{}
+
+type mergeOnlyAdapter struct {
+	timedAdapter
+}
+
+func (m *mergeOnlyAdapter) SupportsMode(mode string) bool {
+	return mode == ModeMerge
+}
+
+func TestServer_Subscribe_ItemList(t *testing.T) {
+	a, b := namedAdapter{name: "a"}, namedAdapter{name: "b"}
+	l := slog.New(slog.DiscardHandler)
+	s := NewServer("set", "cid", nil, l)
+	s.RegisterAdapter("set", "DEFAULT", "a", &a)
+	s.RegisterAdapter("set", "DEFAULT", "b", &b)
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+
+	c := NewClientSession(WithLogger(l), WithServerURL(ts.URL), WithAdapterSet("set"), WithCID("cid"))
+	if err := c.ConnectWithSession(t.Context(), time.Second); err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	t.Cleanup(c.Disconnect)
+
+	if err := c.Subscribe(t.Context(), "DEFAULT", "a c", []string{"Value"}, 0, func(int, Values) {}); err == nil {
+		t.Error("expected subscription to unknown item to fail")
+	}
+
+	received := make(chan string)
+	err := c.Subscribe(t.Context(), "DEFAULT", "b a", []string{"Value"}, 0, func(item int, values Values) {
+		received <- strconv.Itoa(item) + ":" + values.String()
+	})
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+
+	for _, tt := range []struct {
+		adapter *namedAdapter
+		value   string
+		want    string
+	}{
+		{adapter: &a, value: "1", want: "2:1"},
+		{adapter: &b, value: "2", want: "1:2"},
+	} {
+		value := Value(tt.value)
+		tt.adapter.publish(Values{&value})
+		select {
+		case got := <-received:
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timeout waiting for update from %s", tt.adapter.name)
+		}
+	}
+}
+
+// namedAdapter serves a single item of an item-list group and publishes its updates by item name.
+type namedAdapter struct {
+	timedAdapter
+	name string
+}
+
+func (n *namedAdapter) publish(values Values) {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+	for id, ch := range n.subscriptions {
+		ch <- AdapterUpdate{SubscriptionID: id, ItemName: n.name, Values: values}
+	}
+}
+
+func TestServer_Subscribe_Snapshot(t *testing.T) {
+	value := Value("42")
+	a := snapshotAdapter{InjectAdapter: InjectAdapter{Name: "a", Fields: 1}, values: Values{&value}}
+	l := slog.New(slog.DiscardHandler)
+	s := NewServer("set", "cid", map[string]AdapterSet{"DEFAULT": {"a": &a, "b": InjectAdapter{Name: "b", Fields: 1}}}, l)
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+
+	c := NewClientSession(WithLogger(l), WithServerURL(ts.URL), WithAdapterSet("set"), WithCID("cid"))
+	if err := c.ConnectWithSession(t.Context(), time.Second); err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	t.Cleanup(c.Disconnect)
+
+	for _, tt := range []struct {
+		group string
+		want  string
+	}{
+		{group: "a", want: "1:42"},
+		{group: "b a", want: "2:42"},
+	} {
+		t.Run(tt.group, func(t *testing.T) {
+			received := make(chan string, 1)
+			err := c.Subscribe(t.Context(), "DEFAULT", tt.group, []string{"Value"}, 0, func(item int, values Values) {
+				received <- strconv.Itoa(item) + ":" + values.String()
+			})
+			if err != nil {
+				t.Fatalf("failed to subscribe: %v", err)
+			}
+			select {
+			case got := <-received:
+				if got != tt.want {
+					t.Errorf("got %q, want %q", got, tt.want)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("timeout waiting for the snapshot")
+			}
+		})
+	}
+}
+
+// snapshotAdapter is an InjectAdapter that reports the current values of its single item.
+type snapshotAdapter struct {
+	InjectAdapter
+	values Values
+}
+
+func (a *snapshotAdapter) Snapshot() []AdapterUpdate {
+	return []AdapterUpdate{{Item: 1, Values: a.values}}
+}
@@ -0,0 +1,732 @@
+package lightstreamer
+
+import (
+	"bufio"
+	"context"
+	"log/slog"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// END cause codes, as defined by TLCP.
+const (
+	// endCodeClosedByAdmin is also sent when the client destroys its own session, unless it requested another code.
+	endCodeClosedByAdmin = 31
+	endCodeInactive      = 39
+)
+
+const (
+	// sessionTimeout is how long a session is kept after its stream is closed, waiting for the client to rebind.
+	sessionTimeout = 15 * time.Second
+	// historySize is the number of data notifications kept for recovery.
+	historySize = 1000
+	// defaultFlushInterval is how long a stream buffers lines before sending them to the client.
+	defaultFlushInterval = 10 * time.Millisecond
+	// streamBufferSize is the size of a stream's write buffer. Larger batches are sent in multiple writes.
+	streamBufferSize = 16 * 1024
+)
+
+// errCannotRecover refuses a bind_session request whose data notifications can't be recovered.
+var errCannotRecover = &connectionError{code: conErrCodeRecoveryFailed, message: "Recovery not possible"}
+
+// A session is created by create_session and served by one stream at a time. When a stream is closed, the client
+// can bind a new stream to the session with bind_session. Any data notifications sent while the session was unbound
+// can be recovered by setting LS_recovery_from.
+type session struct {
+	created       time.Time
+	lastActivity  time.Time
+	lastWritten   time.Time
+	update        chan AdapterUpdate
+	unsubscribed  chan struct{}
+	queue         *updateQueue
+	server        *Server
+	logger        *slog.Logger
+	subscriptions map[int]*sessionSubscription
+	stream        *stream
+	cancel        context.CancelFunc
+	adapterSet    string
+	sessionID     string
+	remoteIP      string
+	clientIP      string
+	history       history
+	bandwidth     float64
+	inactivity    time.Duration
+	progressive   int
+	streams       int
+	lock          sync.Mutex
+}
+
+// run sends all notifications to the session's current stream, until the session is closed.
+func (s *session) run(ctx context.Context) {
+	syncTicker := time.NewTicker(20 * time.Second)
+	defer syncTicker.Stop()
+
+	probeTicker := time.NewTicker(5 * time.Second)
+	defer probeTicker.Stop()
+
+	// if the client requested LS_inactivity_millis, close the session if the client is silent for too long.
+	var inactivityCheck <-chan time.Time
+	if s.inactivity > 0 {
+		inactivityTicker := time.NewTicker(max(s.inactivity/4, 10*time.Millisecond))
+		defer inactivityTicker.Stop()
+		inactivityCheck = inactivityTicker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-syncTicker.C:
+			s.sendSync()
+		case <-probeTicker.C:
+			s.sendProbe()
+		case <-inactivityCheck:
+			if s.inactive() {
+				s.logger.Debug("closing inactive session", "inactivity", s.inactivity)
+				s.end(endCodeInactive, "client inactive")
+				s.server.removeSession(s.sessionID)
+				return
+			}
+		case <-s.queue.ready:
+			s.sendQueued()
+		}
+	}
+}
+
+// receive moves all updates from the adapters to the session's queue. Since it never waits for the client,
+// a slow client can't block the adapters. Once the session is closed, receive discards the updates, until the session
+// has been unsubscribed from all its adapters: an adapter never blocks sending to a closed session.
+func (s *session) receive(ctx context.Context) {
+	for {
+		select {
+		case <-s.unsubscribed:
+			return
+		case update := <-s.update:
+			if ctx.Err() != nil || s.server.chaos.dropUpdate() {
+				continue
+			}
+			s.queue.push(update)
+		}
+	}
+}
+
+// sendQueued sends all queued updates to the client. If any updates were lost, the client is notified first.
+func (s *session) sendQueued() {
+	for {
+		for _, overflow := range s.queue.overflows() {
+			_ = s.writeData("OV", strconv.Itoa(overflow.subID), strconv.Itoa(overflow.item), strconv.Itoa(overflow.lost))
+		}
+		update, ok := s.queue.pop()
+		if !ok {
+			// the queue is drained: don't wait for the flush interval to send the updates.
+			s.flush()
+			return
+		}
+		s.sendUpdate(update)
+	}
+}
+
+// constrain sets the session's bandwidth. The granted bandwidth never exceeds the server's bandwidth, if configured.
+// The granted bandwidth is sent to the client in a CONS message.
+func (s *session) constrain(bandwidth float64) {
+	if limit := s.server.bandwidth; limit > 0 && (bandwidth <= 0 || bandwidth > limit) {
+		bandwidth = limit
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.bandwidth = bandwidth
+	s.writeLocked("CONS", formatBandwidth(bandwidth))
+}
+
+// forceRebind asks the client to bind a new stream to the session, by sending LOOP and closing the current stream.
+func (s *session) forceRebind() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.stream != nil {
+		s.writeLocked("LOOP", "0")
+		s.stream.close()
+	}
+}
+
+// touch records that the client sent a request for the session.
+func (s *session) touch() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.lastActivity = time.Now()
+}
+
+// inactive returns true if the client hasn't sent a request within the inactivity period it requested.
+func (s *session) inactive() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.inactivity > 0 && time.Since(s.lastActivity) > s.inactivity
+}
+
+// end sends END to the client and closes its stream.
+func (s *session) end(code int, message string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.writeLocked("END", strconv.Itoa(code), message)
+	if s.stream != nil {
+		s.stream.close()
+	}
+}
+
+// close ends the session and unsubscribes it from its adapters. Afterward, it closes unsubscribed.
+func (s *session) close() {
+	s.cancel()
+	s.lock.Lock()
+	subscriptions := s.subscriptions
+	s.subscriptions = nil
+	if s.stream != nil {
+		s.stream.close()
+	}
+	s.lock.Unlock()
+	for subID, sub := range subscriptions {
+		sub.unsubscribe(s.update, subID)
+	}
+	close(s.unsubscribed)
+}
+
+// serve binds the response to the session and blocks until the stream is closed, either by the client or because
+// a new stream was bound to the session. The preamble returns the messages to send before any notifications.
+func (s *session) serve(ctx context.Context, w http.ResponseWriter, preamble func() ([]string, error)) error {
+	s.lock.Lock()
+	lines, err := preamble()
+	if err != nil {
+		s.lock.Unlock()
+		return err
+	}
+
+	w.Header().Set("Content-Type", "text/enriched; charset=UTF-8")
+	w.Header().Add("Cache-Control", "no-store")
+	w.Header().Add("Cache-Control", "no-transform")
+	w.Header().Add("Cache-Control", "no-cache")
+	w.Header().Add("Pragma", "no-cache")
+	w.Header().Set("Transfer-Encoding", "chunked")
+	w.WriteHeader(http.StatusOK)
+
+	st := newStream(w, s.server.chaos.Latency, s.server.flushInterval)
+	for _, line := range lines {
+		st.WriteLine(line)
+	}
+	st.Flush()
+	if s.stream != nil {
+		s.stream.close()
+	}
+	s.stream = st
+	s.streams++
+	s.lock.Unlock()
+
+	if d := s.server.chaos.disconnectAfter(); d > 0 {
+		disconnect := time.AfterFunc(d, st.close)
+		defer disconnect.Stop()
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-st.done:
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.stream == st {
+		s.stream = nil
+	}
+	st.finish()
+	return nil
+}
+
+// createPreamble returns the messages that start the first stream of the session.
+func (s *session) createPreamble(clientIP string) func() ([]string, error) {
+	return func() ([]string, error) {
+		s.clientIP = clientIP
+		return []string{
+			"CONOK," + s.sessionID + ",5000," + strconv.Itoa(keepAlivePeriodMilliSeconds) + ",*",
+			"SERVNAME," + s.server.serverName,
+			"CLIENTIP," + clientIP,
+			"CONS," + formatBandwidth(s.bandwidth),
+		}, nil
+	}
+}
+
+// bindPreamble returns the messages that start a rebound stream. If recover is true, it includes all data notifications
+// after progressive number recoverFrom. CLIENTIP is only sent if the client's IP address changed.
+func (s *session) bindPreamble(clientIP string, recover bool, recoverFrom int) func() ([]string, error) {
+	return func() ([]string, error) {
+		prog := s.progressive
+		var missed []string
+		if recover {
+			var ok bool
+			if missed, ok = s.history.since(recoverFrom, s.progressive); !ok {
+				return nil, errCannotRecover
+			}
+			prog = recoverFrom
+		}
+		lines := make([]string, 0, 3+len(missed))
+		lines = append(lines, "CONOK,"+s.sessionID+",5000,"+strconv.Itoa(keepAlivePeriodMilliSeconds)+",*")
+		if clientIP != s.clientIP {
+			s.clientIP = clientIP
+			lines = append(lines, "CLIENTIP,"+clientIP)
+		}
+		lines = append(lines, "PROG,"+strconv.Itoa(prog))
+		return append(lines, missed...), nil
+	}
+}
+
+// formatBandwidth returns the bandwidth, as sent in a CONS message. Zero means unlimited.
+func formatBandwidth(bandwidth float64) string {
+	if bandwidth <= 0 || math.IsInf(bandwidth, 1) {
+		return "unlimited"
+	}
+	return strconv.FormatFloat(bandwidth, 'f', -1, 64)
+}
+
+// unbound returns true if no stream has been bound to the session since the specified stream count.
+func (s *session) unbound(streams int) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.stream == nil && s.streams == streams
+}
+
+func (s *session) streamCount() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.streams
+}
+
+func (s *session) sendProbe() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.stream != nil && time.Since(s.stream.LastWritten()) > keepAlivePeriodMilliSeconds*time.Millisecond {
+		s.writeLocked("PROBE")
+	}
+}
+
+func (s *session) sendSync() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.stream != nil {
+		age := time.Since(s.stream.started)
+		s.writeLocked("SYNC", strconv.Itoa(int(age.Seconds())))
+	}
+}
+
+func (s *session) sendUpdate(update AdapterUpdate) {
+	s.lock.Lock()
+	sub, ok := s.subscriptions[update.SubscriptionID]
+	s.lock.Unlock()
+	if !ok {
+		s.logger.Debug("dropping update for unknown subscription", "subID", update.SubscriptionID)
+		return
+	}
+	if update, ok = sub.process(update); !ok {
+		s.logger.Debug("dropping invalid update", "subID", update.SubscriptionID, "mode", sub.mode)
+		return
+	}
+	_ = s.writeData("U", strconv.Itoa(update.SubscriptionID), strconv.Itoa(update.Item), sub.encode(update))
+}
+
+// writeData sends a data notification. Data notifications are numbered and kept, so they can be recovered
+// if the client rebinds the session.
+func (s *session) writeData(elements ...string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.progressive++
+	line := s.writeLocked(elements...)
+	s.history.add(s.progressive, line)
+	return nil
+}
+
+// writeLocked sends a message to the session's stream, if it has one. The caller must hold the session's lock.
+func (s *session) writeLocked(elements ...string) string {
+	line := strings.Join(elements, ",")
+	s.logger.Debug("send", "line", line)
+	if s.stream != nil {
+		s.stream.WriteLine(line)
+		s.lastWritten = time.Now()
+	}
+	return line
+}
+
+// flush sends any buffered lines to the client.
+func (s *session) flush() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.stream != nil {
+		s.stream.Flush()
+	}
+}
+
+// subscribe subscribes to the adapters serving a group. items lists the group's item names if the group is an item
+// list, in which case each adapter serves one of the items.
+func (s *session) subscribe(adapters []Adapter, items []string, cmd controlCommand) error {
+	subId, mode, schema := cmd.SubId, cmd.Mode, cmd.Schema
+	sub, err := newSessionSubscription(mode, schema)
+	if err != nil {
+		return err
+	}
+	sub.dataAdapter, sub.group, sub.items = cmd.DataAdapter, cmd.Group, items
+	sub.adapters = adapters
+	// register the subscription before subscribing to the adapter, so we don't drop the first updates.
+	s.lock.Lock()
+	if s.subscriptions == nil {
+		s.subscriptions = make(map[int]*sessionSubscription)
+	}
+	s.subscriptions[subId] = sub
+	s.lock.Unlock()
+	s.queue.register(subId, mode, items)
+
+	itemCount, fields, err := subscribeAdapters(adapters, s.update, subId, mode, schema)
+	if items != nil {
+		itemCount = len(items)
+	}
+	if err == nil {
+		confirm := func() {
+			_ = s.writeData(sub.subOK(subId, itemCount, fields)...)
+			_ = s.writeData("CONF", strconv.Itoa(subId), formatFrequency(cmd.MaxFrequency), sub.filtering(cmd.Unfiltered))
+			for _, update := range snapshot(adapters, items, subId) {
+				s.queue.push(update)
+			}
+		}
+		if delay := s.server.chaos.SubscriptionDelay; delay > 0 {
+			time.AfterFunc(delay, confirm)
+		} else {
+			confirm()
+		}
+	} else {
+		s.lock.Lock()
+		delete(s.subscriptions, subId)
+		s.lock.Unlock()
+		s.queue.remove(subId)
+	}
+	s.logger.Debug("subscription requested", "subID", subId, "group", cmd.Group, "mode", mode, "err", err)
+	return err
+}
+
+// subscribeAdapters subscribes to each adapter and returns the number of items and fields reported by the adapters.
+// All adapters must report the same number of fields. If any adapter fails, the others are unsubscribed.
+func subscribeAdapters(adapters []Adapter, ch chan<- AdapterUpdate, subId int, mode string, schema string) (int, int, error) {
+	var items, fields int
+	for i, adapter := range adapters {
+		n, f, err := adapter.Subscribe(ch, subId, mode, schema)
+		if err == nil && i > 0 && f != fields {
+			adapter.Unsubscribe(ch, subId)
+			err = &RequestError{Code: errCodeBadSchema, Message: "items have different number of fields"}
+		}
+		if err != nil {
+			for _, subscribed := range adapters[:i] {
+				subscribed.Unsubscribe(ch, subId)
+			}
+			return 0, 0, err
+		}
+		items, fields = items+n, f
+	}
+	return items, fields, nil
+}
+
+// snapshot returns the current values of the adapters that implement SnapshotAdapter, as updates for a subscription.
+// items lists the group's item names if the group is an item list, in which case each adapter serves one of the items,
+// as returned by resolveGroup.
+func snapshot(adapters []Adapter, items []string, subId int) []AdapterUpdate {
+	var names []string
+	for i, item := range items {
+		if !slices.Contains(items[:i], item) {
+			names = append(names, item)
+		}
+	}
+	var updates []AdapterUpdate
+	for i, adapter := range adapters {
+		a, ok := adapter.(SnapshotAdapter)
+		if !ok {
+			continue
+		}
+		for _, update := range a.Snapshot() {
+			update.SubscriptionID = subId
+			if items != nil {
+				update.Item, update.ItemName = 0, names[i]
+			}
+			updates = append(updates, update)
+		}
+	}
+	return updates
+}
+
+// unsubscribeGroup terminates all subscriptions for the specified group.
+func (s *session) unsubscribeGroup(dataAdapter string, group string) {
+	s.lock.Lock()
+	terminated := make(map[int]*sessionSubscription)
+	for subID, sub := range s.subscriptions {
+		if sub.dataAdapter == dataAdapter && (sub.group == group || slices.Contains(sub.items, group)) {
+			delete(s.subscriptions, subID)
+			terminated[subID] = sub
+		}
+	}
+	s.lock.Unlock()
+	for subID, sub := range terminated {
+		sub.unsubscribe(s.update, subID)
+		s.queue.remove(subID)
+		_ = s.writeData("UNSUB", strconv.Itoa(subID))
+		s.logger.Debug("subscription terminated", "subID", subID, "group", group)
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// history keeps the most recent data notifications of a session.
+type history struct {
+	lines []string
+	first int
+}
+
+// add records the data notification with the specified progressive number.
+func (h *history) add(progressive int, line string) {
+	if len(h.lines) == 0 {
+		h.first = progressive
+	}
+	h.lines = append(h.lines, line)
+	if len(h.lines) > historySize {
+		h.lines = h.lines[1:]
+		h.first++
+	}
+}
+
+// since returns all data notifications after progressive number from, up to last. It returns false if any of these
+// are no longer available.
+func (h *history) since(from int, last int) ([]string, bool) {
+	switch {
+	case from < 0 || from > last:
+		return nil, false
+	case from == last:
+		return nil, true
+	case len(h.lines) == 0 || from+1 < h.first:
+		return nil, false
+	}
+	return slices.Clone(h.lines[from+1-h.first:]), true
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// sessionSubscription holds the mode-specific state of a subscription.
+//
+// MERGE and DISTINCT updates are sent as-is: the server does not conflate updates, so the only difference
+// between the two is what the client does with them. In COMMAND mode, the server tracks the keys of each item,
+// so that ADD, UPDATE and DELETE commands are consistent with the rows the client has seen.
+type sessionSubscription struct {
+	keys        map[int]map[Value]struct{}
+	last        map[int]Values
+	dataAdapter string
+	group       string
+	items       []string
+	adapters    []Adapter
+	mode        string
+	keyIdx      int
+	commandIdx  int
+}
+
+// unsubscribe unsubscribes the subscription from its adapters.
+func (s *sessionSubscription) unsubscribe(ch chan<- AdapterUpdate, subId int) {
+	for _, adapter := range s.adapters {
+		adapter.Unsubscribe(ch, subId)
+	}
+}
+
+// subOK returns the message confirming the subscription: SUBCMD for COMMAND subscriptions, SUBOK otherwise.
+func (s *sessionSubscription) subOK(subId int, items int, fields int) []string {
+	if s.mode == ModeCommand {
+		return []string{"SUBCMD", strconv.Itoa(subId), strconv.Itoa(items), strconv.Itoa(fields), strconv.Itoa(s.keyIdx + 1), strconv.Itoa(s.commandIdx + 1)}
+	}
+	return []string{"SUBOK", strconv.Itoa(subId), strconv.Itoa(items), strconv.Itoa(fields)}
+}
+
+// filtering returns the filtering reported in the CONF message. RAW subscriptions are never filtered.
+func (s *sessionSubscription) filtering(unfiltered bool) string {
+	if unfiltered || s.mode == ModeRaw {
+		return "unfiltered"
+	}
+	return "filtered"
+}
+
+// formatFrequency returns the TLCP representation of an update frequency: zero means the frequency is unlimited.
+func formatFrequency(frequency float64) string {
+	if frequency == 0 {
+		return "unlimited"
+	}
+	return strconv.FormatFloat(frequency, 'f', -1, 64)
+}
+
+func newSessionSubscription(mode string, schema string) (*sessionSubscription, error) {
+	sub := sessionSubscription{mode: mode, keyIdx: -1, commandIdx: -1}
+	if mode != ModeCommand {
+		return &sub, nil
+	}
+	for i, field := range strings.Fields(schema) {
+		switch field {
+		case "key":
+			sub.keyIdx = i
+		case "command":
+			sub.commandIdx = i
+		}
+	}
+	if sub.keyIdx == -1 || sub.commandIdx == -1 {
+		return nil, &RequestError{Code: errCodeBadSchema, Message: "COMMAND mode requires key and command fields"}
+	}
+	sub.keys = make(map[int]map[Value]struct{})
+	return &sub, nil
+}
+
+// encode returns the wire representation of the update, delta-encoded against the last update sent for the same item.
+// COMMAND mode updates relate to a key, rather than an item, so these are always sent in full.
+func (s *sessionSubscription) encode(update AdapterUpdate) string {
+	if s.mode == ModeCommand {
+		return update.Values.Encode(nil)
+	}
+	if s.last == nil {
+		s.last = make(map[int]Values)
+	}
+	encoded := update.Values.Encode(s.last[update.Item])
+	s.last[update.Item] = slices.Clone(update.Values)
+	return encoded
+}
+
+// process applies the subscription's mode to the update. It returns false if the update should not be sent.
+func (s *sessionSubscription) process(update AdapterUpdate) (AdapterUpdate, bool) {
+	if s.mode != ModeCommand {
+		return update, true
+	}
+	if len(update.Values) <= max(s.keyIdx, s.commandIdx) || update.Values[s.keyIdx] == nil || update.Values[s.commandIdx] == nil {
+		return update, false
+	}
+	key := *update.Values[s.keyIdx]
+	keys, ok := s.keys[update.Item]
+	if !ok {
+		keys = make(map[Value]struct{})
+		s.keys[update.Item] = keys
+	}
+	_, exists := keys[key]
+
+	switch *update.Values[s.commandIdx] {
+	case "ADD", "UPDATE":
+		// an ADD for an existing key is an UPDATE, and vice versa.
+		command := Value("ADD")
+		if exists {
+			command = "UPDATE"
+		}
+		keys[key] = struct{}{}
+		values := make(Values, len(update.Values))
+		copy(values, update.Values)
+		values[s.commandIdx] = &command
+		update.Values = values
+	case "DELETE":
+		if !exists {
+			return update, false
+		}
+		delete(keys, key)
+		// a DELETE only carries the key & command fields
+		values := make(Values, len(update.Values))
+		values[s.keyIdx] = update.Values[s.keyIdx]
+		values[s.commandIdx] = update.Values[s.commandIdx]
+		update.Values = values
+	default:
+		return update, false
+	}
+	return update, true
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// A stream is the HTTP response that a session sends its notifications on.
+type stream struct {
+	started time.Time
+	done    chan struct{}
+	lineWriter
+	once sync.Once
+}
+
+func newStream(w http.ResponseWriter, latency time.Duration, flushInterval time.Duration) *stream {
+	return &stream{
+		lineWriter: lineWriter{ResponseWriter: w, buf: bufio.NewWriterSize(w, streamBufferSize), latency: latency, flushInterval: flushInterval},
+		started:    time.Now(),
+		done:       make(chan struct{}),
+	}
+}
+
+func (s *stream) close() {
+	s.once.Do(func() { close(s.done) })
+}
+
+// A lineWriter buffers the lines written to a stream. Buffered lines are flushed to the client when Flush is called,
+// or at the latest flushInterval after the first buffered line. A flushInterval of zero flushes every line.
+type lineWriter struct {
+	http.ResponseWriter
+	lastWritten   time.Time
+	buf           *bufio.Writer
+	flushTimer    *time.Timer
+	latency       time.Duration
+	flushInterval time.Duration
+	closed        bool
+	lock          sync.RWMutex
+}
+
+func (w *lineWriter) WriteLine(s string) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.closed {
+		return
+	}
+	if w.latency > 0 {
+		time.Sleep(w.latency)
+	}
+	_, _ = w.buf.WriteString(s)
+	_, _ = w.buf.WriteString("\r\n")
+	w.lastWritten = time.Now()
+	switch {
+	case w.flushInterval <= 0:
+		w.flushLocked()
+	case w.flushTimer == nil:
+		w.flushTimer = time.AfterFunc(w.flushInterval, w.Flush)
+	}
+}
+
+// Flush sends all buffered lines to the client.
+func (w *lineWriter) Flush() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if !w.closed {
+		w.flushLocked()
+	}
+}
+
+func (w *lineWriter) flushLocked() {
+	if w.flushTimer != nil {
+		w.flushTimer.Stop()
+		w.flushTimer = nil
+	}
+	if w.buf.Buffered() == 0 {
+		return
+	}
+	_ = w.buf.Flush()
+	w.ResponseWriter.(http.Flusher).Flush()
+}
+
+// finish flushes any buffered lines. Afterward, the lineWriter no longer writes to its http.ResponseWriter,
+// so the stream's handler can return.
+func (w *lineWriter) finish() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if !w.closed {
+		w.flushLocked()
+		w.closed = true
+	}
+}
+
+func (w *lineWriter) LastWritten() time.Time {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+	return w.lastWritten
+}
@@ -0,0 +1,511 @@
+package lightstreamer
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestServer_Preamble(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []ServerOption
+		header  http.Header
+		want    []string
+	}{
+		{
+			name: "default",
+			want: []string{"SERVNAME,fake server", "CLIENTIP,127.0.0.1", "CONS,unlimited"},
+		},
+		{
+			name:    "configured",
+			options: []ServerOption{WithServerName("my server"), WithBandwidth(40.5)},
+			want:    []string{"SERVNAME,my server", "CLIENTIP,127.0.0.1", "CONS,40.5"},
+		},
+		{
+			name:   "forwarded for: ignored",
+			header: http.Header{"X-Forwarded-For": {"10.0.0.1"}},
+			want:   []string{"SERVNAME,fake server", "CLIENTIP,127.0.0.1", "CONS,unlimited"},
+		},
+		{
+			name:    "forwarded for",
+			options: []ServerOption{WithForwardedFor()},
+			header:  http.Header{"X-Forwarded-For": {"10.0.0.1, 10.0.0.2"}},
+			want:    []string{"SERVNAME,fake server", "CLIENTIP,10.0.0.1", "CONS,unlimited"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer("set", "cid", nil, slog.New(slog.DiscardHandler), tt.options...)
+			ts := httptest.NewServer(s)
+			t.Cleanup(ts.Close)
+
+			body := url.Values{"LS_adapter_set": {"set"}, "LS_cid": {"cid"}}
+			req, _ := http.NewRequestWithContext(t.Context(), http.MethodPost, ts.URL+"/create_session.txt?LS_protocol="+lsProtocol, strings.NewReader(body.Encode()))
+			for key, values := range tt.header {
+				req.Header[key] = values
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { _ = resp.Body.Close() })
+			lines := bufio.NewScanner(resp.Body)
+
+			if got := nextLine(t, lines); !strings.HasPrefix(got, "CONOK,") {
+				t.Errorf("got %q, want CONOK", got)
+			}
+			for _, want := range tt.want {
+				if got := nextLine(t, lines); got != want {
+					t.Errorf("got %q, want %q", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestServer_Bind_Recovery(t *testing.T) {
+	var a timedAdapter
+	go a.Run(t.Context(), 50*time.Millisecond)
+	s := NewServer("set", "cid", map[string]AdapterSet{"DEFAULT": {"1": &a}}, slog.New(slog.DiscardHandler))
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+
+	// create a session & subscribe
+	ctx, cancel := context.WithCancel(t.Context())
+	lines := postStream(t, ctx, ts.URL+"/create_session.txt", url.Values{"LS_adapter_set": {"set"}, "LS_cid": {"cid"}})
+	conok := nextLine(t, lines)
+	sessionID := strings.Split(conok, ",")[1]
+	if err := s.subscribe(controlCommand{SessionID: sessionID, Group: "1", Mode: ModeMerge, Schema: "Value", SubId: 1}); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	// data notifications: SUBOK is #1, CONF is #2, updates are #3 and up
+	var received []string
+	for len(received) < 4 {
+		if line := nextLine(t, lines); strings.HasPrefix(line, "SUBOK,") || strings.HasPrefix(line, "CONF,") || strings.HasPrefix(line, "U,") {
+			received = append(received, line)
+		}
+	}
+	// drop the connection
+	cancel()
+
+	// rebind, recovering everything after the SUBOK
+	lines = postStream(t, t.Context(), ts.URL+"/bind_session.txt", url.Values{"LS_session": {sessionID}, "LS_recovery_from": {"1"}})
+	if got := nextLine(t, lines); !strings.HasPrefix(got, "CONOK,"+sessionID+",") {
+		t.Errorf("got %q, want CONOK", got)
+	}
+	if got := nextLine(t, lines); got != "PROG,1" {
+		t.Errorf("got %q, want PROG,1", got)
+	}
+	for _, want := range received[1:] {
+		if got := nextLine(t, lines); got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+
+	// recovering notifications that were never sent fails
+	lines = postStream(t, t.Context(), ts.URL+"/bind_session.txt", url.Values{"LS_session": {sessionID}, "LS_recovery_from": {"100000"}})
+	if got := nextLine(t, lines); got != "CONERR,4,Recovery not possible" {
+		t.Errorf("got %q, want CONERR", got)
+	}
+	// binding an unknown session fails
+	resp := post(t, t.Context(), ts.URL+"/bind_session.txt", url.Values{"LS_session": {"unknown"}})
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("got %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+}
+
+func TestServer_Bind_RecoveryExpired(t *testing.T) {
+	s := NewServer("set", "cid", map[string]AdapterSet{"DEFAULT": {"1": InjectAdapter{Name: "1"}}}, slog.New(slog.DiscardHandler), WithQueueSize(2*historySize))
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	lines := postStream(t, ctx, ts.URL+"/create_session.txt", url.Values{"LS_adapter_set": {"set"}, "LS_cid": {"cid"}})
+	sessionID := strings.Split(nextLine(t, lines), ",")[1]
+	if err := s.subscribe(controlCommand{SessionID: sessionID, Group: "1", Mode: ModeDistinct, Schema: "Value", SubId: 1}); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	// send more updates than the session keeps for recovery
+	for i := range historySize + 10 {
+		s.Publish("set", "DEFAULT", "1", 1, Values{valuePtr(strconv.Itoa(i))})
+	}
+	want := "U,1,1," + strconv.Itoa(historySize+9)
+	for line := ""; line != want; {
+		line = nextLine(t, lines)
+	}
+	cancel()
+
+	// the first notifications have expired
+	lines = postStream(t, t.Context(), ts.URL+"/bind_session.txt", url.Values{"LS_session": {sessionID}, "LS_recovery_from": {"1"}})
+	if got := nextLine(t, lines); got != "CONERR,4,Recovery not possible" {
+		t.Errorf("got %q, want CONERR", got)
+	}
+	// the session is still available for a bind without recovery
+	lines = postStream(t, t.Context(), ts.URL+"/bind_session.txt", url.Values{"LS_session": {sessionID}})
+	if got := nextLine(t, lines); !strings.HasPrefix(got, "CONOK,"+sessionID+",") {
+		t.Errorf("got %q, want CONOK", got)
+	}
+}
+
+func TestServer_SubscriptionConfirmation(t *testing.T) {
+	tests := []struct {
+		name      string
+		mode      string
+		schema    string
+		frequency string
+		want      []string
+	}{
+		{name: "merge", mode: ModeMerge, schema: "Value", want: []string{"SUBOK,1,1,1", "CONF,1,unlimited,filtered"}},
+		{name: "max frequency", mode: ModeMerge, schema: "Value", frequency: "0.5", want: []string{"SUBOK,1,1,1", "CONF,1,0.5,filtered"}},
+		{name: "unfiltered", mode: ModeDistinct, schema: "Value", frequency: "unfiltered", want: []string{"SUBOK,1,1,1", "CONF,1,unlimited,unfiltered"}},
+		{name: "command", mode: ModeCommand, schema: "key command Value", want: []string{"SUBCMD,1,1,1,1,2", "CONF,1,unlimited,filtered"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var a timedAdapter
+			s := NewServer("set", "cid", map[string]AdapterSet{"DEFAULT": {"1": &a}}, slog.New(slog.DiscardHandler))
+			ts := httptest.NewServer(s)
+			t.Cleanup(ts.Close)
+
+			sessionID, lines := createSession(t, ts)
+			values := url.Values{
+				"LS_op": {"add"}, "LS_reqId": {"1"}, "LS_session": {sessionID}, "LS_subId": {"1"},
+				"LS_group": {"1"}, "LS_schema": {tt.schema}, "LS_mode": {tt.mode},
+			}
+			if tt.frequency != "" {
+				values.Set("LS_requested_max_frequency", tt.frequency)
+			}
+			if body, _ := io.ReadAll(post(t, t.Context(), ts.URL+"/control.txt", values).Body); string(body) != "REQOK,1\n" {
+				t.Fatalf("got %q, want REQOK", string(body))
+			}
+			var got []string
+			for len(got) < len(tt.want) {
+				if line := nextLine(t, lines); strings.HasPrefix(line, "SUB") || strings.HasPrefix(line, "CONF,") {
+					got = append(got, line)
+				}
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServer_Bind_NoRecovery(t *testing.T) {
+	s := NewServer("set", "cid", nil, slog.New(slog.DiscardHandler))
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	lines := postStream(t, ctx, ts.URL+"/create_session.txt", url.Values{"LS_adapter_set": {"set"}, "LS_cid": {"cid"}})
+	sessionID := strings.Split(nextLine(t, lines), ",")[1]
+	cancel()
+
+	lines = postStream(t, t.Context(), ts.URL+"/bind_session.txt", url.Values{"LS_session": {sessionID}})
+	_ = nextLine(t, lines)
+	if got := nextLine(t, lines); got != "PROG,0" {
+		t.Errorf("got %q, want PROG,0", got)
+	}
+}
+
+func TestServer_Inactivity(t *testing.T) {
+	s := NewServer("set", "cid", nil, slog.New(slog.DiscardHandler))
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+
+	lines := postStream(t, t.Context(), ts.URL+"/create_session.txt", url.Values{"LS_adapter_set": {"set"}, "LS_cid": {"cid"}, "LS_inactivity_millis": {"200"}})
+	sessionID := strings.Split(nextLine(t, lines), ",")[1]
+
+	// heartbeats keep the session alive
+	for range 5 {
+		resp := post(t, t.Context(), ts.URL+"/heartbeat.txt", url.Values{"LS_session": {sessionID}, "LS_reqId": {"1"}})
+		if body, _ := io.ReadAll(resp.Body); string(body) != "REQOK,1\n" {
+			t.Fatalf("got %q, want REQOK", string(body))
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if _, ok := s.getSession(sessionID); !ok {
+		t.Fatal("session closed while client was sending heartbeats")
+	}
+
+	// without heartbeats, the session is closed
+	var got string
+	for !strings.HasPrefix(got, "END,") {
+		got = nextLine(t, lines)
+	}
+	if want := "END," + strconv.Itoa(endCodeInactive) + ",client inactive"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if _, ok := s.getSession(sessionID); ok {
+		t.Error("inactive session not removed")
+	}
+
+	resp := post(t, t.Context(), ts.URL+"/heartbeat.txt", url.Values{"LS_session": {sessionID}, "LS_reqId": {"2"}})
+	if body, _ := io.ReadAll(resp.Body); string(body) != "REQERR,2,20,session not found\n" {
+		t.Errorf("got %q, want REQERR", string(body))
+	}
+}
+
+func TestServer_Constrain(t *testing.T) {
+	tests := []struct {
+		name      string
+		options   []ServerOption
+		bandwidth string
+		want      string
+	}{
+		{name: "constrained", bandwidth: "10.5", want: "CONS,10.5"},
+		{name: "unlimited", bandwidth: "unlimited", want: "CONS,unlimited"},
+		{name: "server limit", options: []ServerOption{WithBandwidth(5)}, bandwidth: "10", want: "CONS,5"},
+		{name: "server limit: unlimited", options: []ServerOption{WithBandwidth(5)}, bandwidth: "unlimited", want: "CONS,5"},
+		{name: "below server limit", options: []ServerOption{WithBandwidth(5)}, bandwidth: "2", want: "CONS,2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer("set", "cid", nil, slog.New(slog.DiscardHandler), tt.options...)
+			ts := httptest.NewServer(s)
+			t.Cleanup(ts.Close)
+
+			lines := postStream(t, t.Context(), ts.URL+"/create_session.txt", url.Values{"LS_adapter_set": {"set"}, "LS_cid": {"cid"}})
+			sessionID := strings.Split(nextLine(t, lines), ",")[1]
+			for range 3 {
+				_ = nextLine(t, lines)
+			}
+
+			resp := post(t, t.Context(), ts.URL+"/control.txt", url.Values{"LS_op": {"constrain"}, "LS_reqId": {"1"}, "LS_session": {sessionID}, "LS_requested_max_bandwidth": {tt.bandwidth}})
+			if body, _ := io.ReadAll(resp.Body); string(body) != "REQOK,1\n" {
+				t.Fatalf("got %q, want REQOK", string(body))
+			}
+			if got := nextLine(t, lines); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServer_ForceRebind(t *testing.T) {
+	var a timedAdapter
+	go a.Run(t.Context(), 50*time.Millisecond)
+	l := slog.New(slog.DiscardHandler)
+	s := NewServer("set", "cid", map[string]AdapterSet{"DEFAULT": {"1": &a}}, l)
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+
+	c := NewClientSession(WithLogger(l), WithServerURL(ts.URL), WithAdapterSet("set"), WithCID("cid"))
+	if err := c.ConnectWithSession(t.Context(), time.Second); err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	t.Cleanup(c.Disconnect)
+	var received atomic.Int32
+	if err := c.Subscribe(t.Context(), "DEFAULT", "1", []string{"Value"}, 0, func(int, Values) { received.Add(1) }); err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+
+	sessionID := c.sessionID.Load().(string)
+	resp := post(t, t.Context(), ts.URL+"/control.txt", url.Values{"LS_op": {"force_rebind"}, "LS_reqId": {"1"}, "LS_session": {sessionID}})
+	if body, _ := io.ReadAll(resp.Body); string(body) != "REQOK,1\n" {
+		t.Fatalf("got %q, want REQOK", string(body))
+	}
+
+	// wait for the client to rebind
+	sess, _ := s.getSession(sessionID)
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	for sess.streamCount() < 2 {
+		select {
+		case <-ctx.Done():
+			t.Fatal("timeout waiting for rebind")
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+
+	// the client keeps receiving updates on the new stream
+	count := received.Load()
+	for received.Load() <= count {
+		select {
+		case <-ctx.Done():
+			t.Fatal("timeout waiting for updates after rebind")
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+func TestHistory_Since(t *testing.T) {
+	var h history
+	for i := range historySize + 10 {
+		h.add(i+1, strconv.Itoa(i+1))
+	}
+	last := historySize + 10
+
+	tests := []struct {
+		name string
+		from int
+		pass bool
+		want int
+	}{
+		{name: "all caught up", from: last, pass: true, want: 0},
+		{name: "recent", from: last - 5, pass: true, want: 5},
+		{name: "oldest", from: 10, pass: true, want: historySize},
+		{name: "too old", from: 9, pass: false},
+		{name: "future", from: last + 1, pass: false},
+		{name: "negative", from: -1, pass: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := h.since(tt.from, last)
+			if ok != tt.pass {
+				t.Fatalf("got %v, want %v", ok, tt.pass)
+			}
+			if len(got) != tt.want {
+				t.Fatalf("got %d lines, want %d", len(got), tt.want)
+			}
+			if len(got) > 0 && got[0] != strconv.Itoa(tt.from+1) {
+				t.Errorf("got first line %q, want %q", got[0], strconv.Itoa(tt.from+1))
+			}
+		})
+	}
+}
+
+func TestSessionSubscription_Command(t *testing.T) {
+	sub, err := newSessionSubscription(ModeCommand, "key command Value")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		values Values
+		pass   bool
+		want   string
+	}{
+		{"add", Values{valuePtr("a"), valuePtr("ADD"), valuePtr("1")}, true, "a,ADD,1"},
+		{"add existing key", Values{valuePtr("a"), valuePtr("ADD"), valuePtr("2")}, true, "a,UPDATE,2"},
+		{"update", Values{valuePtr("a"), valuePtr("UPDATE"), valuePtr("3")}, true, "a,UPDATE,3"},
+		{"update unknown key", Values{valuePtr("b"), valuePtr("UPDATE"), valuePtr("4")}, true, "b,ADD,4"},
+		{"delete", Values{valuePtr("a"), valuePtr("DELETE"), valuePtr("5")}, true, "a,DELETE,<nil>"},
+		{"delete unknown key", Values{valuePtr("a"), valuePtr("DELETE"), valuePtr("6")}, false, ""},
+		{"invalid command", Values{valuePtr("a"), valuePtr("FOO"), valuePtr("7")}, false, ""},
+		{"missing key", Values{nil, valuePtr("ADD"), valuePtr("8")}, false, ""},
+		{"too few values", Values{valuePtr("a")}, false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := sub.process(AdapterUpdate{SubscriptionID: 1, Item: 1, Values: tt.values})
+			if ok != tt.pass {
+				t.Fatalf("got %v, want %v", ok, tt.pass)
+			}
+			if ok && got.Values.String() != tt.want {
+				t.Errorf("got %q, want %q", got.Values.String(), tt.want)
+			}
+		})
+	}
+}
+
+func post(t *testing.T, ctx context.Context, target string, values url.Values) *http.Response {
+	t.Helper()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, target+"?LS_protocol="+lsProtocol, strings.NewReader(values.Encode()))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = resp.Body.Close() })
+	return resp
+}
+
+func postStream(t *testing.T, ctx context.Context, target string, values url.Values) *bufio.Scanner {
+	t.Helper()
+	resp := post(t, ctx, target, values)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	return bufio.NewScanner(resp.Body)
+}
+
+func nextLine(t *testing.T, lines *bufio.Scanner) string {
+	t.Helper()
+	if !lines.Scan() {
+		t.Fatalf("stream closed: %v", lines.Err())
+	}
+	return lines.Text()
+}
+
+func TestLineWriter(t *testing.T) {
+	rec := httptest.NewRecorder()
+	st := newStream(rec, 0, time.Hour)
+
+	st.WriteLine("PROBE")
+	st.WriteLine("PROBE")
+	if rec.Body.Len() != 0 || rec.Flushed {
+		t.Fatalf("lines not buffered: %q", rec.Body.String())
+	}
+	st.Flush()
+	if got := rec.Body.String(); got != "PROBE\r\nPROBE\r\n" || !rec.Flushed {
+		t.Fatalf("got %q, want two PROBE lines", got)
+	}
+
+	st.WriteLine("LOOP,0")
+	st.finish()
+	st.WriteLine("PROBE")
+	st.Flush()
+	if got := rec.Body.String(); got != "PROBE\r\nPROBE\r\nLOOP,0\r\n" {
+		t.Errorf("got %q, want buffered LOOP to be flushed and no further lines", got)
+	}
+}
+
+func TestLineWriter_FlushInterval(t *testing.T) {
+	rec := httptest.NewRecorder()
+	st := newStream(rec, 0, 10*time.Millisecond)
+	st.WriteLine("PROBE")
+
+	ctx, cancel := context.WithTimeout(t.Context(), time.Second)
+	defer cancel()
+	for {
+		st.lock.RLock()
+		flushed := rec.Flushed
+		st.lock.RUnlock()
+		if flushed {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatal("timeout waiting for flush")
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+}
+
+func BenchmarkLineWriter(b *testing.B) {
+	line := "U,1,1," + strings.Repeat("x", 40)
+	for _, interval := range []time.Duration{0, defaultFlushInterval} {
+		b.Run("interval="+interval.String(), func(b *testing.B) {
+			st := newStream(slowFlusher{}, 0, interval)
+			b.ReportAllocs()
+			for b.Loop() {
+				st.WriteLine(line)
+			}
+			st.finish()
+		})
+	}
+}
+
+// slowFlusher is an http.ResponseWriter that discards its output, but simulates the cost of flushing a response
+// to the network.
+type slowFlusher struct{}
+
+func (slowFlusher) Header() http.Header         { return http.Header{} }
+func (slowFlusher) Write(b []byte) (int, error) { return len(b), nil }
+func (slowFlusher) WriteHeader(int)             {}
+func (slowFlusher) Flush()                      { time.Sleep(time.Microsecond) }
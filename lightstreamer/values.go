@@ -1,11 +1,14 @@
 package lightstreamer
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
-	"net/url"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"unicode/utf8"
 )
 
 type Value string
@@ -23,47 +26,288 @@ func (v Values) String() string {
 	return strings.Join(s, ",")
 }
 
+// MarshalJSON encodes a Value as a JSON string.
+func (v Value) MarshalJSON() ([]byte, error) {
+	return json.Marshal(string(v))
+}
+
+// UnmarshalJSON decodes a Value from a JSON string. For convenience, it also accepts JSON numbers and booleans,
+// which are stored as their literal text.
+func (v *Value) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*v = Value(s)
+		return nil
+	}
+	var literal any
+	if err := json.Unmarshal(data, &literal); err != nil {
+		return err
+	}
+	switch literal.(type) {
+	case float64, bool:
+		*v = Value(data)
+		return nil
+	default:
+		return fmt.Errorf("invalid value: %s", data)
+	}
+}
+
+// MarshalJSON encodes Values as a JSON array, with null for nil values. Empty Values are encoded as an empty array.
+func (v Values) MarshalJSON() ([]byte, error) {
+	if v == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal([]*Value(v))
+}
+
+// UnmarshalJSON decodes Values from a JSON array. null elements are decoded as nil values.
+func (v *Values) UnmarshalJSON(data []byte) error {
+	var values []*Value
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+	*v = values
+	return nil
+}
+
+// Floats returns the numeric fields of the Values, keyed by their name in schema. Nil values, and values that can't be
+// parsed as a float, are skipped, as are values without a matching field in schema.
+//
+// Floats is the fast path for consumers that only need numbers, e.g. to export them as metrics.
+func (v Values) Floats(schema []string) map[string]float64 {
+	floats := make(map[string]float64, min(len(v), len(schema)))
+	for i, field := range schema[:min(len(v), len(schema))] {
+		if v[i] == nil {
+			continue
+		}
+		if f, err := strconv.ParseFloat(string(*v[i]), 64); err == nil {
+			floats[field] = f
+		}
+	}
+	return floats
+}
+
+// reuseValues returns dst, resized to n Values, if it has sufficient capacity. Otherwise, it allocates new Values.
+func reuseValues(dst Values, n int) Values {
+	if cap(dst) >= n {
+		return dst[:n]
+	}
+	return make(Values, n)
+}
+
+// valuesPool recycles Values for clients that opt in with WithPooledValues.
+var valuesPool sync.Pool
+
+// getValues returns pooled Values, or nil if the pool is empty.
+func getValues() Values {
+	if v, ok := valuesPool.Get().(*Values); ok {
+		return *v
+	}
+	return nil
+}
+
+// putValues returns Values to the pool. The caller must no longer use them.
+func putValues(v Values) {
+	if cap(v) == 0 {
+		return
+	}
+	v = v[:cap(v)]
+	clear(v)
+	valuesPool.Put(&v)
+}
+
+// Clone returns a copy of the Values. Since a Value is never modified once created, the copy shares the Values' Value
+// pointers.
+func (v Values) Clone() Values {
+	return slices.Clone(v)
+}
+
+// Update applies a TLCP update to the Values and returns the resulting Values.
+//
+// Update has copy-on-write semantics: it never modifies the receiver. If the update changes any field, Update returns
+// a new Values. Otherwise, it returns the receiver. This means callers can safely keep the previous Values.
 func (v Values) Update(values []string) (Values, error) {
-	if len(v) == 0 {
-		v = make(Values, len(values))
+	v, _, err := v.update(nil, values, false)
+	return v, err
+}
+
+// UpdateDiff is Update, but also returns the indexes of the fields whose value changed, in ascending order.
+func (v Values) UpdateDiff(values []string) (Values, []int, error) {
+	return v.update(nil, values, true)
+}
+
+// update implements Update and UpdateDiff. If the update changes any field, the new Values are written to dst,
+// if it has sufficient capacity. Otherwise, update allocates new Values. If diff is false, update doesn't return
+// the changed fields.
+func (v Values) update(dst Values, values []string, diff bool) (Values, []int, error) {
+	// owned is true once v no longer shares its backing array with the receiver.
+	owned := len(v) == 0
+	if owned {
+		v = reuseValues(dst, len(values))
+		clear(v)
 	}
 
+	var changed []int
+	set := func(idx int, value *Value) {
+		if !owned {
+			v, owned = append(reuseValues(dst, len(v))[:0], v...), true
+		}
+		v[idx] = value
+		if diff {
+			changed = append(changed, idx)
+		}
+	}
 	var idx int
 	for _, value := range values {
 		if idx > len(v)-1 {
-			return Values{}, errors.New("too many values in update")
+			return Values{}, nil, errors.New("too many values in update")
 		}
 		switch {
 		case value == "":
 		case value == "#":
-			v[idx] = nil
+			if v[idx] != nil {
+				set(idx, nil)
+			}
 		case value == "$":
-			v[idx] = valuePtr("")
+			if v[idx] == nil || *v[idx] != "" {
+				set(idx, valuePtr(""))
+			}
 		case value[0] == '^':
 			step, err := strconv.Atoi(value[1:])
 			if err != nil {
-				return Values{}, fmt.Errorf("invalid step value: %w", err)
+				return Values{}, nil, fmt.Errorf("invalid step value: %w", err)
+			}
+			if step < 1 || idx+step > len(v) {
+				return Values{}, nil, fmt.Errorf("invalid step value: %d", step)
 			}
 			idx += step - 1
 		default:
-			// don't unescape if we don't need to.
-			if strings.ContainsRune(value, '%') {
-				if v2, err := url.PathUnescape(value); err == nil {
-					value = v2
-				}
+			value, err := unescapeValue(value)
+			if err != nil {
+				return Values{}, nil, err
 			}
 			// don't change the value if we don't need to.
 			if v[idx] == nil || *(v[idx]) != Value(value) {
-				v[idx] = valuePtr(value)
+				set(idx, valuePtr(value))
 			}
 		}
 		idx++
 	}
 	if idx != len(v) {
-		return Values{}, errors.New("not enough values in update")
+		return Values{}, nil, errors.New("not enough values in update")
+	}
+
+	return v, changed, nil
+}
+
+// Encode returns the pipe-separated TLCP representation of the Values, as an update of previous: unchanged fields are
+// sent as an empty string (or "^N" for a run of N unchanged fields), nil values as "#" and empty values as "$".
+// It is the inverse of Update: previous.Update(strings.Split(v.Encode(previous), "|")) returns v.
+//
+// If previous is empty, or has a different number of fields, all fields are encoded.
+func (v Values) Encode(previous Values) string {
+	full := len(previous) != len(v)
+	fields := make([]string, 0, len(v))
+	var unchanged int
+	for i, value := range v {
+		if !full && sameValue(previous[i], value) {
+			unchanged++
+			continue
+		}
+		fields = appendUnchanged(fields, unchanged)
+		unchanged = 0
+		switch {
+		case value == nil:
+			fields = append(fields, "#")
+		case *value == "":
+			fields = append(fields, "$")
+		default:
+			fields = append(fields, escapeValue(string(*value)))
+		}
+	}
+	fields = appendUnchanged(fields, unchanged)
+	return strings.Join(fields, "|")
+}
+
+func appendUnchanged(fields []string, count int) []string {
+	// only use "^N" if it's shorter than sending N empty fields
+	if step := "^" + strconv.Itoa(count); len(step) < count {
+		return append(fields, step)
+	}
+	for range count {
+		fields = append(fields, "")
+	}
+	return fields
+}
+
+func sameValue(a, b *Value) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// unescapeValue decodes a TLCP field value. TLCP percent-encodes the UTF-8 bytes of reserved characters (e.g. '%', '|',
+// control characters or a leading '#', '$' or '^'). Any other character, including '+', is sent as-is. Characters
+// outside the Basic Multilingual Plane (sent as UTF-16 surrogate pairs by some clients) are sent as their 4-byte UTF-8
+// sequence: lone surrogates are invalid UTF-8 and rejected.
+func unescapeValue(value string) (string, error) {
+	// don't unescape if we don't need to.
+	if !strings.ContainsRune(value, '%') {
+		return value, nil
+	}
+	b := make([]byte, 0, len(value))
+	for i := 0; i < len(value); i++ {
+		if value[i] != '%' {
+			b = append(b, value[i])
+			continue
+		}
+		if i+2 >= len(value) {
+			return "", fmt.Errorf("invalid escape sequence in %q", value)
+		}
+		hi, ok1 := unhex(value[i+1])
+		lo, ok2 := unhex(value[i+2])
+		if !ok1 || !ok2 {
+			return "", fmt.Errorf("invalid escape sequence %q in %q", value[i:i+3], value)
+		}
+		b = append(b, hi<<4|lo)
+		i += 2
 	}
+	if !utf8.Valid(b) {
+		return "", fmt.Errorf("invalid UTF-8 in %q", value)
+	}
+	return string(b), nil
+}
+
+func unhex(c byte) (byte, bool) {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0', true
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10, true
+	case 'A' <= c && c <= 'F':
+		return c - 'A' + 10, true
+	}
+	return 0, false
+}
 
-	return v, nil
+// escapeValue percent-encodes all characters that have a special meaning in a U message.
+func escapeValue(value string) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c == '%', c == '|', c == ',', c < 0x20, c == 0x7f,
+			i == 0 && (c == '#' || c == '$' || c == '^'):
+			b.WriteByte('%')
+			b.WriteByte(hex[c>>4])
+			b.WriteByte(hex[c&0xf])
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
 }
 
 func valuePtr(v string) *Value {
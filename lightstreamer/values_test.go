@@ -1,6 +1,9 @@
 package lightstreamer
 
 import (
+	"encoding/json"
+	"maps"
+	"slices"
 	"strconv"
 	"strings"
 	"testing"
@@ -25,6 +28,153 @@ func TestValues_String(t *testing.T) {
 	}
 }
 
+func TestValues_UpdateDiff(t *testing.T) {
+	tests := []struct {
+		name    string
+		current string
+		updated string
+		want    []int
+	}{
+		{"all new values", "1|2|3", "4|5|6", []int{0, 1, 2}},
+		{"blank: no change", "1|2|3", "4||6", []int{0, 2}},
+		{"same value: no change", "1|2|3", "1|5|3", []int{1}},
+		{"hash sign", "1|2|3", "#|#|3", []int{0, 1}},
+		{"hash sign: already null", "#|2", "#|", nil},
+		{"dollar sign", "1|$", "$|$", []int{0}},
+		{"skip fields", "1|2|3|4", "^3|5", []int{3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			current, err := Values{}.Update(strings.Split(tt.current, "|"))
+			if err != nil {
+				t.Fatalf("Values.Update(tt.current) error = %v", err)
+			}
+			_, changed, err := current.UpdateDiff(strings.Split(tt.updated, "|"))
+			if err != nil {
+				t.Fatalf("Values.UpdateDiff() error = %v", err)
+			}
+			if !slices.Equal(changed, tt.want) {
+				t.Errorf("Values.UpdateDiff() = %v, want %v", changed, tt.want)
+			}
+		})
+	}
+}
+
+func TestValues_Update_CopyOnWrite(t *testing.T) {
+	previous, err := Values{}.Update([]string{"1", "2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	next, err := previous.Update([]string{"", "3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := previous.String(); got != "1,2" {
+		t.Errorf("previous values modified: got %q, want %q", got, "1,2")
+	}
+	if got := next.String(); got != "1,3" {
+		t.Errorf("got %q, want %q", got, "1,3")
+	}
+
+	if _, err = previous.Update([]string{"4", "5", "6"}); err == nil {
+		t.Fatal("expected update to fail")
+	}
+	if got := previous.String(); got != "1,2" {
+		t.Errorf("previous values modified by failed update: got %q, want %q", got, "1,2")
+	}
+
+	unchanged, err := next.Update([]string{"", ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if &unchanged[0] != &next[0] {
+		t.Error("unchanged update should return the receiver")
+	}
+}
+
+func TestValues_Floats(t *testing.T) {
+	tests := []struct {
+		name   string
+		values Values
+		schema []string
+		want   map[string]float64
+	}{
+		{"numeric", Values{valuePtr("1.5"), valuePtr("-2")}, []string{"Value", "Offset"}, map[string]float64{"Value": 1.5, "Offset": -2}},
+		{"skip nil", Values{nil, valuePtr("2")}, []string{"Value", "Offset"}, map[string]float64{"Offset": 2}},
+		{"skip non-numeric", Values{valuePtr("1"), valuePtr("OK"), valuePtr("")}, []string{"Value", "Status", "Empty"}, map[string]float64{"Value": 1}},
+		{"more values than fields", Values{valuePtr("1"), valuePtr("2")}, []string{"Value"}, map[string]float64{"Value": 1}},
+		{"more fields than values", Values{valuePtr("1")}, []string{"Value", "Offset"}, map[string]float64{"Value": 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.values.Floats(tt.schema); !maps.Equal(got, tt.want) {
+				t.Errorf("Values.Floats() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValues_JSON(t *testing.T) {
+	tests := []struct {
+		name   string
+		values Values
+		want   string
+	}{
+		{name: "populated", values: Values{valuePtr("1"), nil, valuePtr("")}, want: `["1",null,""]`},
+		{name: "empty", values: Values{}, want: `[]`},
+		{name: "nil", values: nil, want: `[]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(tt.values)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Fatalf("got %s, want %s", got, tt.want)
+			}
+			var decoded Values
+			if err = json.Unmarshal(got, &decoded); err != nil {
+				t.Fatal(err)
+			}
+			if decoded.String() != tt.values.String() {
+				t.Errorf("got %q, want %q", decoded.String(), tt.values.String())
+			}
+		})
+	}
+}
+
+func TestValues_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		pass  bool
+		want  string
+	}{
+		{name: "strings", input: `["a", null, "c"]`, pass: true, want: "a,<nil>,c"},
+		{name: "literals", input: `[42.50, true, -1e3]`, pass: true, want: "42.50,true,-1e3"},
+		{name: "object", input: `[{"a": 1}]`, pass: false},
+		{name: "not an array", input: `"a"`, pass: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var values Values
+			err := json.Unmarshal([]byte(tt.input), &values)
+			if tt.pass != (err == nil) {
+				t.Fatalf("got error %v, want pass %v", err, tt.pass)
+			}
+			if err == nil && values.String() != tt.want {
+				t.Errorf("got %q, want %q", values.String(), tt.want)
+			}
+		})
+	}
+}
+
 func TestValues_Update(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -44,6 +194,8 @@ func TestValues_Update(t *testing.T) {
 		{"update not enough values", "1|2|3", "1|2", false, ""},
 		{"skip too far", "1|2|3", "^6|4", false, ""},
 		{"skip invalid", "1|2|3", "^A|4", false, ""},
+		{"skip backwards", "a|b|c", "^-2|x|y|z", false, ""},
+		{"skip past last field", "1|2|3", "^4", false, ""},
 	}
 
 	for _, tt := range tests {
@@ -63,10 +215,106 @@ func TestValues_Update(t *testing.T) {
 	}
 }
 
+func Test_unescapeValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		pass  bool
+		want  string
+	}{
+		{"plain", "foo bar", true, "foo bar"},
+		{"percent", "100%25", true, "100%"},
+		{"pipe", "a%7Cb", true, "a|b"},
+		{"lower case hex", "a%7cb", true, "a|b"},
+		{"plus is not a space", "a+b%2Bc", true, "a+b+c"},
+		{"control characters", "line1%0D%0Aline2%09", true, "line1\r\nline2\t"},
+		{"leading hash", "%23", true, "#"},
+		{"leading dollar", "%24", true, "$"},
+		{"leading caret", "%5E3", true, "^3"},
+		{"multi-byte UTF-8", "caf%C3%A9", true, "café"},
+		{"raw UTF-8", "café", true, "café"},
+		{"surrogate pair", "%F0%9F%9A%80", true, "🚀"},
+		{"lone surrogate", "%ED%A0%80", false, ""},
+		{"invalid UTF-8", "%C3", false, ""},
+		{"truncated escape", "100%2", false, ""},
+		{"trailing percent", "100%", false, ""},
+		{"invalid hex", "%zz", false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := unescapeValue(tt.input)
+			if tt.pass != (err == nil) {
+				t.Fatalf("unescapeValue() error = %v, want pass %v", err, tt.pass)
+			}
+			if got != tt.want {
+				t.Errorf("unescapeValue() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_escapeValue_RoundTrip(t *testing.T) {
+	for _, value := range []string{
+		"", "plain", "100%", "a|b", "a,b", "a+b", "#", "$", "^3", "a#b$c^d", "line1\r\nline2\t", "\x7f", "café", "🚀", "%25",
+	} {
+		escaped := escapeValue(value)
+		got, err := unescapeValue(escaped)
+		if err != nil {
+			t.Errorf("%q: unescapeValue(%q) error = %v", value, escaped, err)
+			continue
+		}
+		if got != value {
+			t.Errorf("%q: round trip via %q returned %q", value, escaped, got)
+		}
+	}
+}
+
+func TestValues_Encode(t *testing.T) {
+	tests := []struct {
+		name string
+		prev Values
+		next Values
+		want string
+	}{
+		{"no previous values", nil, Values{valuePtr("1"), nil, valuePtr("")}, "1|#|$"},
+		{"unchanged", Values{valuePtr("1"), valuePtr("2")}, Values{valuePtr("1"), valuePtr("2")}, "|"},
+		{"changed", Values{valuePtr("1"), valuePtr("2")}, Values{valuePtr("1"), valuePtr("3")}, "|3"},
+		{"to nil", Values{valuePtr("1"), valuePtr("2")}, Values{nil, valuePtr("2")}, "#|"},
+		{"to empty", Values{valuePtr("1"), valuePtr("2")}, Values{valuePtr(""), valuePtr("2")}, "$|"},
+		{"short run", Values{valuePtr("1"), valuePtr("2"), valuePtr("3")}, Values{valuePtr("1"), valuePtr("2"), valuePtr("4")}, "||4"},
+		{"long run", Values{valuePtr("1"), valuePtr("2"), valuePtr("3"), valuePtr("4")}, Values{valuePtr("1"), valuePtr("2"), valuePtr("3"), valuePtr("5")}, "^3|5"},
+		{"field count changed", Values{valuePtr("1")}, Values{valuePtr("1"), valuePtr("2")}, "1|2"},
+		{"escaped", nil, Values{valuePtr("a|b,c%d"), valuePtr("#1"), valuePtr("$2"), valuePtr("^3"), valuePtr("4#")}, "a%7Cb%2Cc%25d|%231|%242|%5E3|4#"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.next.Encode(tt.prev)
+			if got != tt.want {
+				t.Errorf("Values.Encode() = %q, want %q", got, tt.want)
+			}
+			if len(tt.prev) > 0 && len(tt.prev) != len(tt.next) {
+				return
+			}
+			// decoding the encoded update should give us the original values
+			decoded, err := tt.prev.Update(strings.Split(got, "|"))
+			if err != nil {
+				t.Fatalf("Values.Update() error = %v", err)
+			}
+			if decoded.String() != tt.next.String() {
+				t.Errorf("Values.Update() = %q, want %q", decoded.String(), tt.next.String())
+			}
+		})
+	}
+}
+
 // Before:
 // BenchmarkValues_Update/current-16                  47793             25013 ns/op           16000 B/op       1000 allocs/op
 // Current:
 // BenchmarkValues_Update/current-16                 187110              6410 ns/op               0 B/op          0 allocs/op
+// BenchmarkValues_Update/changed-16                 150404              8028 ns/op            9792 B/op        101 allocs/op
+// BenchmarkValues_Update/changed/pooled-16          151093              7158 ns/op            1624 B/op        101 allocs/op
 func BenchmarkValues_Update(b *testing.B) {
 	const size = 1_000
 	orig := make(Values, size)
@@ -90,4 +338,30 @@ func BenchmarkValues_Update(b *testing.B) {
 			b.Errorf("unexpected result")
 		}
 	})
+
+	// every update changes one field in ten, so Update needs to return new Values.
+	changed := make([]string, size)
+	for i := range size {
+		if i%10 == 0 {
+			changed[i] = "x"
+		}
+	}
+	b.Run("changed", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			if _, err := orig.Update(changed); err != nil {
+				b.Fatalf("Values.Update() error = %v", err)
+			}
+		}
+	})
+	b.Run("changed/pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			next, _, err := orig.update(getValues(), changed, false)
+			if err != nil {
+				b.Fatalf("Values.Update() error = %v", err)
+			}
+			putValues(next)
+		}
+	})
 }
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestNewLogHandler(t *testing.T) {
+	tests := []struct {
+		format  string
+		wantErr bool
+		want    string
+	}{
+		{format: "text", want: "level=INFO msg=hello"},
+		{format: "json", want: `"level":"INFO","msg":"hello"`},
+		{format: "xml", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			h, err := newLogHandler(&buf, tt.format, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newLogHandler() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			slog.New(h).Info("hello")
+			if got := buf.String(); !strings.Contains(got, tt.want) {
+				t.Errorf("got %q, want it to contain %q", got, tt.want)
+			}
+		})
+	}
+}
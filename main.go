@@ -2,61 +2,612 @@ package main
 
 import (
 	"context"
-	"errors"
+	"crypto/tls"
+	"expvar"
 	"flag"
+	"fmt"
 	"github.com/clambin/iss-exporter/internal/collector"
 	"github.com/clambin/iss-exporter/internal/health"
+	"github.com/clambin/iss-exporter/internal/orbit"
+	"github.com/clambin/iss-exporter/internal/store"
+	"github.com/clambin/iss-exporter/internal/util"
+	"github.com/clambin/iss-exporter/lightstreamer"
+	"github.com/nats-io/nats.go"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/twmb/franz-go/pkg/kgo"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"runtime"
+	"slices"
+	"strings"
 	"syscall"
+	"time"
 )
 
 var (
-	version    = "change-me"
-	addr       = flag.String("addr", ":9090", "prometheus metrics address")
-	healthAddr = flag.String("health", ":8080", "prometheus metrics address")
-	debug      = flag.Bool("debug", false, "log debug messages")
+	version        = "change-me"
+	showVersion    = flag.Bool("version", false, "print the version and exit")
+	checkOnly      = flag.Bool("check-config", false, "check the configuration and exit. Exits with status 1 if the configuration has problems")
+	checkConnect   = flag.Bool("check-config.connect", false, "with -check-config, also check that the configured groups can be subscribed to")
+	addr           = flag.String("addr", ":9090", "prometheus metrics address")
+	healthAddr     = flag.String("health", ":8080", "health endpoint address. If empty, /health is served on -addr")
+	debug          = flag.Bool("debug", false, "log debug messages")
+	logFormat      = flag.String("log.format", "text", "log format: text or json")
+	debugHTTP      = flag.Bool("debug.http", false, "log the requests to and responses from the Lightstreamer server at debug level")
+	debugRecord    = flag.String("debug.record", "", "record the exchanges with the Lightstreamer server to this file, for replay in tests")
+	debugHAR       = flag.String("debug.record.har", "", "on shutdown, also write the recording of -debug.record to this file as an HTTP Archive (HAR)")
+	debugPprof     = flag.Bool("debug.pprof", false, "serve pprof (/debug/pprof/) and expvar (/debug/vars), including counters of the exporter's internal state, on the health address")
+	locationLabels = flag.Bool("location-labels", false, "also export the location as labels of iss_location (deprecated)")
+	timestamps     = flag.Bool("timestamps", false, "export telemetry with the time of the reading, rather than the scrape time")
+	staleAfter     = flag.Duration("stale-after", 0, "remove telemetry metrics that haven't been updated for this long (0: never)")
+	configFile     = flag.String("config", "", "configuration file (JSON, or YAML if its extension is .yaml or .yml): the server, telemetry groups and frequencies, and the log, listen and TLS settings. Flags take precedence over its settings. Uses the built-in groups if empty")
+	orbitMetrics   = flag.Bool("orbit", false, "export orbital metrics, propagated from the ISS TLE")
+	tleURL         = flag.String("tle-url", collector.DefaultTLEURL, "URL of the ISS TLE")
+	observer       = flag.String("observer", "", "observer location (latitude,longitude[,altitude in km]) to predict ISS passes for")
+	crewMetrics    = flag.Bool("crew", false, "export the ISS crew")
+	crewURL        = flag.String("crew-url", collector.DefaultCrewURL, "URL of the open-notify astros.json endpoint")
+	subsystems     = flag.String("subsystems", "", "comma-separated list of subsystems to export in full (e.g. ECLSS,EPS)")
+	include        = flag.String("telemetry.include", "", "only subscribe to groups whose ID or metric name matches this regular expression")
+	exclude        = flag.String("telemetry.exclude", "", "don't subscribe to groups whose ID or metric name matches this regular expression")
+	historyWindow  = flag.Duration("history", 0, "keep the location and telemetry history for this long, served on /history (0: disabled)")
+	historyStep    = flag.Duration("history-interval", 30*time.Second, "interval at which the history is sampled")
+	historyDir     = flag.String("history.store", "", "keep every telemetry update in a SQLite database (history.db) in this directory, served on /api/v1/history?group=&from=&to=, so the history survives restarts. Disabled if empty")
+	historyKeep    = flag.Duration("history.retention", 7*24*time.Hour, "remove telemetry updates from the store after this long (0: never)")
+	tlsCert        = flag.String("tls-cert", "", "TLS certificate file. Serves all endpoints over HTTPS if set, with -tls-key")
+	tlsKey         = flag.String("tls-key", "", "TLS private key file")
+	authUsername   = flag.String("basic-auth-username", "", "require HTTP basic authentication with this username")
+	authPassword   = flag.String("basic-auth-password", "", "password for HTTP basic authentication. Prefer setting "+envName("basic-auth-password"))
+	webConfigPath  = flag.String("web.config.file", "", "web configuration file (JSON, exporter-toolkit layout) with TLS and HTTP server settings")
+	readyAfter     = flag.Duration("ready-stale-after", 5*time.Minute, "report not ready on /readyz if no telemetry update was received for this long (0: only check the session)")
+	probeModules   = flag.String("probe.modules", "", "probe modules configuration file (JSON). If set, /probe?module=<module>&target=<url> exports the telemetry of other Lightstreamer servers")
+	probeIdle      = flag.Duration("probe.idle-timeout", 10*time.Minute, "close the session with a probe target that hasn't been probed for this long")
+	scrapeOffset   = flag.Duration("probe.timeout-offset", 500*time.Millisecond, "subtract this from the scrape timeout sent by Prometheus, to bound the time a probe may take")
+	otlpEndpoint   = flag.String("otlp.endpoint", "", "OpenTelemetry collector to push metrics to, using OTLP over HTTP (e.g. http://otel-collector:4318). Disabled if empty")
+	otlpInterval   = flag.Duration("otlp.interval", 30*time.Second, "interval at which metrics are pushed to the OpenTelemetry collector")
+	gracePeriod    = flag.Duration("shutdown-timeout", 10*time.Second, "time allowed for in-flight requests to complete on shutdown")
+	maxFrequency   = flag.Float64("max-frequency", 0, "maximum number of updates per second requested for groups that don't configure their own (0: use the configuration's)")
+	startupWait    = flag.Duration("startup-timeout", time.Minute, "wait for a first update of each group, for at most this long, before exporting telemetry (0: don't wait)")
+	relayAddr      = flag.String("relay", "", "re-publish the subscribed telemetry to downstream Lightstreamer clients on this address. Disabled if empty")
+	replayFile     = flag.String("replay", "", "replay this recording, made by -record.dir (CSV) or -debug.record, instead of subscribing to the live feed")
+	replaySpeed    = flag.Float64("replay.speed", 1, "speed of the replay: 2 replays the recording twice as fast")
+	replayLoop     = flag.Bool("replay.loop", false, "restart the replay when the recording ends. CSV recordings only")
+	recordDir      = flag.String("record.dir", "", "record every telemetry update to files in this directory, for offline analysis or to build playback fixtures. Disabled if empty")
+	recordFormat   = flag.String("record.format", "csv", "format of the recordings: csv (replayable by lsserve) or parquet. Parquet files are only readable once rotated, or on shutdown")
+	recordRotate   = flag.Duration("record.rotate", time.Hour, "start a new recording file at this interval (0: one file per run)")
+	grpcAddr       = flag.String("grpc.addr", "", "serve the gRPC telemetry API (proto/telemetry.proto) on this address. Disabled if empty")
+	mqttBroker     = flag.String("mqtt.broker", "", "MQTT broker to publish telemetry updates to (e.g. tcp://mosquitto:1883 or ssl://broker:8883). Disabled if empty")
+	mqttTopic      = flag.String("mqtt.topic", "iss/telemetry/{group}", "MQTT topic of a group's updates. {group} is replaced by the group ID")
+	mqttQoS        = flag.Int("mqtt.qos", 0, "MQTT QoS of the published updates: 0 or 1")
+	mqttRetain     = flag.Bool("mqtt.retain", false, "publish updates as retained MQTT messages, so new subscribers receive the last value right away")
+	mqttClientID   = flag.String("mqtt.client-id", "iss-exporter", "MQTT client ID")
+	mqttUsername   = flag.String("mqtt.username", "", "MQTT username")
+	mqttPassword   = flag.String("mqtt.password", "", "MQTT password. Prefer setting "+envName("mqtt.password"))
+	mqttDiscovery  = flag.String("mqtt.discovery-prefix", "", "announce the groups as Home Assistant sensors, using MQTT discovery with this prefix (e.g. homeassistant). Disabled if empty")
+	natsURL        = flag.String("nats.url", "", "NATS server to publish telemetry updates to (e.g. nats://nats:4222 or tls://nats:4222). Disabled if empty")
+	natsSubject    = flag.String("nats.subject", "iss.telemetry.{group}", "NATS subject of a group's updates. {group} is replaced by the group ID")
+	natsJetStream  = flag.Bool("nats.jetstream", false, "publish updates to the JetStream stream capturing their subject, and wait for the stream to acknowledge them")
+	natsUser       = flag.String("nats.user", "", "NATS user")
+	natsPassword   = flag.String("nats.password", "", "NATS password. Prefer setting "+envName("nats.password"))
+	kafkaBrokers   = flag.String("kafka.brokers", "", "comma-separated Kafka bootstrap brokers to produce telemetry updates to (e.g. kafka-1:9092,kafka-2:9092). Disabled if empty")
+	kafkaTopic     = flag.String("kafka.topic", "iss-telemetry", "Kafka topic of the updates, keyed by group. If it contains {group}, each group has its own topic")
+	kafkaAcks      = flag.String("kafka.acks", "all", "acknowledgement required for produced updates: none, leader or all")
+	kafkaRetries   = flag.Int("kafka.retries", 3, "number of retries of an update that failed with a retriable error. Unless -kafka.acks is all, retries may duplicate updates")
+	kafkaBatchSize = flag.Int("kafka.batch-size", 100, "maximum number of updates buffered by the producer. Once it's reached, the buffered updates are produced without waiting for -kafka.linger")
+	kafkaLinger    = flag.Duration("kafka.linger", 500*time.Millisecond, "maximum time to wait for more updates before producing the buffered ones. At most 1m")
+	kafkaTLS       = flag.Bool("kafka.tls", false, "connect to the Kafka brokers over TLS")
+	influxEndpoint = flag.String("influx.url", "", "InfluxDB v2 or Telegraf to write telemetry updates to, in line protocol (e.g. http://influxdb:8086). Disabled if empty")
+	influxOrg      = flag.String("influx.org", "", "InfluxDB organization")
+	influxBucket   = flag.String("influx.bucket", "iss", "InfluxDB bucket")
+	influxToken    = flag.String("influx.token", "", "InfluxDB API token. Prefer setting "+envName("influx.token"))
+	influxBatch    = flag.Int("influx.batch-size", 1000, "maximum number of points written in one request")
+	influxInterval = flag.Duration("influx.interval", 10*time.Second, "interval at which queued points are written")
+	grafanaURL     = flag.String("grafana.url", "", "Grafana to push telemetry updates to, with Grafana Live (e.g. http://grafana:3000). Disabled if empty")
+	grafanaStream  = flag.String("grafana.stream", "iss", "Grafana Live stream ID. Updates are published to the channels stream/<stream>/<metric>")
+	grafanaToken   = flag.String("grafana.token", "", "Grafana service account token, with the Editor role. Prefer setting "+envName("grafana.token"))
+	eventsWebhook  = flag.String("events.webhook", "", "webhook to POST state transitions to (session lost or re-established, AOS/LOS, subscription errors), as JSON. Disabled if empty. Prefer setting "+envName("events.webhook"))
 )
 
 func main() {
+	flag.Usage = func() {
+		_, _ = fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s:\n", os.Args[0])
+		flag.PrintDefaults()
+		_, _ = fmt.Fprintf(flag.CommandLine.Output(), "\nEach flag can also be set by an environment variable, e.g. %s for -addr.\n", envName("addr"))
+		_, _ = fmt.Fprintln(flag.CommandLine.Output(), "Environment variables take precedence over the -config file, which takes precedence over the flag defaults.")
+	}
 	flag.Parse()
+	if err := setFromEnv(flag.CommandLine, os.LookupEnv); err != nil {
+		fatal("invalid environment", err)
+	}
+	// an invalid configuration is reported once the logger is set up, or by -check-config
+	if cfg, err := loadConfig(); err == nil {
+		if err = setFromConfig(flag.CommandLine, cfg); err != nil {
+			fatal("invalid configuration", err)
+		}
+	}
+	if *showVersion {
+		fmt.Printf("iss-exporter %s (revision %s, %s)\n", version, revision(), runtime.Version())
+		return
+	}
 
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
-	var opts slog.HandlerOptions
+	if *checkOnly {
+		var subscribe func(context.Context, collector.Config) error
+		if *checkConnect {
+			subscribe = checkSubscribe
+		}
+		if !checkConfig(ctx, os.Stdout, loadConfig, loadWebConfig, *probeModules, subscribe) {
+			cancel()
+			os.Exit(1)
+		}
+		return
+	}
+	web, err := loadWebConfig()
+	if err != nil {
+		fatal("invalid web configuration", err)
+	}
+
+	defaultLevel := slog.LevelInfo
 	if *debug {
-		opts.Level = slog.LevelDebug
+		defaultLevel = slog.LevelDebug
 	}
-	l := slog.New(slog.NewTextHandler(os.Stderr, &opts))
+	var level slog.LevelVar
+	level.Set(defaultLevel)
+	h, err := newLogHandler(os.Stderr, *logFormat, &slog.HandlerOptions{Level: &level})
+	if err != nil {
+		fatal("invalid log format", err)
+	}
+	l := slog.New(h)
 	l.Info("Starting iss-exporter", "version", version)
 
-	c, err := collector.NewCollector(ctx, l)
+	cfg, err := loadConfig()
 	if err != nil {
-		panic(err)
+		fatal("invalid configuration", err)
+	}
+	if cfgLevel, ok := cfg.Level(); ok {
+		level.Set(cfgLevel)
 	}
-	prometheus.MustRegister(c)
 
+	errorsTotal := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "iss",
+		Subsystem: "exporter",
+		Name:      "errors_total",
+		Help:      "number of runtime errors, by component",
+	}, []string{"component"})
+	droppedTotal := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "iss",
+		Subsystem: "exporter",
+		Name:      "output_dropped_total",
+		Help:      "number of telemetry updates dropped by an output that fell behind, by output",
+	}, []string{"output"})
+
+	sessionOptions := append(cfg.Server.Options(), lightstreamer.WithLogger(l))
+	var transport http.RoundTripper = http.DefaultTransport
+	var replay *replayer
+	if *replayFile != "" {
+		rt, r, err := openReplay(*replayFile, *replaySpeed, *replayLoop)
+		if err != nil {
+			fatal("invalid replay", err)
+		}
+		if rt != nil {
+			transport = rt
+		}
+		replay = r
+		l.Info("replaying recording", "file", *replayFile, "speed", *replaySpeed)
+	}
+	if *debugRecord != "" {
+		f, err := os.Create(*debugRecord)
+		if err != nil {
+			logFatal(l, "failed to create recording", err)
+		}
+		defer func() { _ = f.Close() }()
+		recording := util.NewRecordingRoundTripper(f)
+		recording.Next = transport
+		transport = recording
+	}
+	if *debugHTTP {
+		transport = util.LoggingRoundTripper{Next: transport, Logger: l, MaxBodySize: 4096}
+	}
+	if transport != http.DefaultTransport {
+		sessionOptions = append(sessionOptions, lightstreamer.WithHTTPClient(&http.Client{Transport: transport}))
+	}
+	session := lightstreamer.NewClientSession(sessionOptions...)
+	var subscriber collector.Subscriber = session
+	if replay != nil {
+		subscriber = replay
+	}
+	var relayServer *lightstreamer.Server
+	if *relayAddr != "" {
+		rl := newRelay(subscriber, cfg.Server, l)
+		subscriber, relayServer = rl, rl.server
+	}
+	var rec *recorder
+	if *recordDir != "" {
+		if rec, err = newRecorder(subscriber, *recordDir, *recordFormat, *recordRotate, errorsTotal.WithLabelValues("recorder"), l); err != nil {
+			fatal("invalid recording configuration", err)
+		}
+		subscriber = rec
+		go rec.run(ctx, time.Second)
+	}
+	var vars *debugVars
+	if *debugPprof {
+		vars = newDebugVars(subscriber, session)
+		subscriber = vars
+	}
+	c, err := collector.NewCollector(ctx, cfg, subscriber, l)
+	if err != nil {
+		logFatal(l, "failed to subscribe to telemetry", err)
+	}
+	if replay != nil {
+		go replay.run(ctx)
+	}
+	if vars != nil {
+		vars.watchCollector(c)
+		expvar.Publish("iss_exporter", vars.vars)
+	}
+	if *orbitMetrics {
+		var location *orbit.Location
+		if *observer != "" {
+			loc, err := orbit.ParseLocation(*observer)
+			if err != nil {
+				logFatal(l, "invalid observer location", err)
+			}
+			location = &loc
+		}
+		c.EnableOrbit(ctx, *tleURL, location)
+	}
+	if *crewMetrics {
+		c.EnableCrew(ctx, *crewURL)
+	}
+	if *historyWindow > 0 {
+		c.EnableHistory(ctx, *historyWindow, *historyStep)
+	}
+	c.LocationLabels = *locationLabels
+	c.StaleAfter = *staleAfter
+	c.Timestamps = *timestamps
+	prometheus.MustRegister(newBuildInfo(version), errorsTotal, droppedTotal)
+	queue := func(output string) (<-chan collector.Update, func()) {
+		updates, stop := queueUpdates(c, droppedTotal.WithLabelValues(output))
+		if vars != nil {
+			vars.watchQueue(output, updates)
+		}
+		return updates, stop
+	}
+	// don't export the telemetry until each group has reported, so the first scrapes don't see a wall of zero values
 	go func() {
-		s := http.Server{
-			Addr:    *healthAddr,
-			Handler: health.Handler(c.ClientSession),
+		if *startupWait > 0 {
+			if pending := c.WaitForUpdates(ctx, *startupWait); len(pending) > 0 && ctx.Err() == nil {
+				l.Warn("no updates received for some groups. exporting telemetry anyway", "groups", pending)
+			}
 		}
-		if err := s.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
-			panic(err)
+		if err := prometheus.Register(c); err != nil {
+			fatal("failed to register the telemetry collector", err)
 		}
 	}()
+	if *otlpEndpoint != "" {
+		u, err := otlpURL(*otlpEndpoint)
+		if err != nil {
+			fatal("invalid OTLP endpoint", err)
+		}
+		e := otlpExporter{
+			url:        u,
+			gatherer:   prometheus.DefaultGatherer,
+			version:    version,
+			start:      time.Now(),
+			httpClient: &http.Client{Timeout: 10 * time.Second},
+			logger:     l,
+		}
+		go e.run(ctx, *otlpInterval)
+	}
+	if *mqttBroker != "" {
+		if err := validateMQTTTopic(*mqttTopic); err != nil {
+			fatal("invalid MQTT topic", err)
+		}
+		if *mqttQoS < 0 || *mqttQoS > 1 {
+			fatal("invalid MQTT QoS", fmt.Errorf("%d: must be 0 or 1", *mqttQoS))
+		}
+		o := mqttOutput{
+			url:             *mqttBroker,
+			clientID:        *mqttClientID,
+			username:        *mqttUsername,
+			password:        *mqttPassword,
+			topic:           *mqttTopic,
+			qos:             byte(*mqttQoS),
+			retain:          *mqttRetain,
+			discoveryPrefix: *mqttDiscovery,
+			version:         version,
+			group:           c.Group,
+			errors:          errorsTotal.WithLabelValues("mqtt"),
+			logger:          l,
+		}
+		updates, stop := queue("mqtt")
+		defer stop()
+		go o.run(ctx, updates, time.Second, time.Minute)
+	}
+	if *natsURL != "" {
+		if err := validateNATSSubject(*natsSubject); err != nil {
+			fatal("invalid NATS subject", err)
+		}
+		o := natsOutput{
+			url:       *natsURL,
+			options:   []nats.Option{nats.Name("iss-exporter"), nats.UserInfo(*natsUser, *natsPassword)},
+			subject:   *natsSubject,
+			jetStream: *natsJetStream,
+			errors:    errorsTotal.WithLabelValues("nats"),
+			logger:    l,
+		}
+		updates, stop := queue("nats")
+		defer stop()
+		go o.run(ctx, updates, time.Second, time.Minute)
+	}
+	if *kafkaBrokers != "" {
+		if err := validateKafkaTopic(*kafkaTopic); err != nil {
+			fatal("invalid Kafka topic", err)
+		}
+		acks, err := parseKafkaAcks(*kafkaAcks)
+		if err != nil {
+			fatal("invalid Kafka acks", err)
+		}
+		if *kafkaBatchSize < 1 {
+			fatal("invalid Kafka batch size", fmt.Errorf("%d: must be at least 1", *kafkaBatchSize))
+		}
+		if *kafkaLinger < 0 || *kafkaLinger > time.Minute {
+			fatal("invalid Kafka linger", fmt.Errorf("%s: must be between 0 and 1m", *kafkaLinger))
+		}
+		options := append([]kgo.Opt{kgo.ClientID("iss-exporter"), kgo.RecordRetries(*kafkaRetries)}, acks...)
+		if *kafkaTLS {
+			options = append(options, kgo.DialTLSConfig(&tls.Config{}))
+		}
+		o := kafkaOutput{
+			brokers:   strings.Split(*kafkaBrokers, ","),
+			options:   options,
+			topic:     *kafkaTopic,
+			batchSize: *kafkaBatchSize,
+			linger:    *kafkaLinger,
+			errors:    errorsTotal.WithLabelValues("kafka"),
+			dropped:   droppedTotal.WithLabelValues("kafka"),
+			logger:    l,
+		}
+		updates, stop := queue("kafka")
+		defer stop()
+		go o.run(ctx, updates, time.Second, time.Minute)
+	}
+	if *influxEndpoint != "" {
+		u, err := influxURL(*influxEndpoint, *influxOrg, *influxBucket)
+		if err != nil {
+			fatal("invalid InfluxDB URL", err)
+		}
+		if *influxBatch < 1 {
+			fatal("invalid InfluxDB batch size", fmt.Errorf("%d: must be at least 1", *influxBatch))
+		}
+		w := influxWriter{
+			url:        u,
+			token:      *influxToken,
+			batchSize:  *influxBatch,
+			group:      c.Group,
+			httpClient: &http.Client{Timeout: 10 * time.Second},
+			errors:     errorsTotal.WithLabelValues("influx"),
+			dropped:    droppedTotal.WithLabelValues("influx"),
+			logger:     l,
+		}
+		updates, stop := queue("influx")
+		defer stop()
+		go w.run(ctx, updates, *influxInterval)
+	}
+	if *grafanaURL != "" {
+		u, err := grafanaLiveURL(*grafanaURL, *grafanaStream)
+		if err != nil {
+			fatal("invalid Grafana Live configuration", err)
+		}
+		o := grafanaOutput{
+			url:    u,
+			token:  *grafanaToken,
+			group:  c.Group,
+			errors: errorsTotal.WithLabelValues("grafana"),
+			logger: l,
+		}
+		updates, stop := queue("grafana")
+		defer stop()
+		go o.run(ctx, updates, time.Second, time.Minute)
+	}
+	if *eventsWebhook != "" {
+		w := eventWebhook{
+			url:        *eventsWebhook,
+			httpClient: &http.Client{Timeout: 10 * time.Second},
+			errors:     errorsTotal.WithLabelValues("webhook"),
+			logger:     l,
+		}
+		events, stop := queueEvents(c, droppedTotal.WithLabelValues("webhook"))
+		defer stop()
+		go w.run(ctx, events)
+	}
+	var history *historyStore
+	if *historyDir != "" {
+		s, err := store.Open(*historyDir, *historyKeep)
+		if err != nil {
+			logFatal(l, "failed to open history store", err)
+		}
+		defer func() { _ = s.Close() }()
+		history = &historyStore{
+			store:   s,
+			group:   c.Group,
+			errors:  errorsTotal.WithLabelValues("history"),
+			dropped: droppedTotal.WithLabelValues("history"),
+			logger:  l,
+		}
+		updates, stop := queue("history")
+		defer stop()
+		go history.run(ctx, updates, time.Second)
+	}
 
-	http.Handle("/metrics", promhttp.Handler())
-	go func() {
-		if err = http.ListenAndServe(*addr, nil); !errors.Is(err, http.ErrServerClosed) {
-			panic(err)
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.Handle("/position", c.PositionHandler())
+	mux.Handle("/history", c.HistoryHandler())
+	mux.Handle("/events", c.EventsHandler(ctx))
+	mux.Handle("/ws", c.WebSocketHandler(ctx))
+	mux.Handle("/api/", c.APIHandler())
+	links := []string{"/metrics", "/position", "/history", "/events", "/ws", "/api/v1/telemetry"}
+	if history != nil {
+		mux.Handle("GET /api/v1/history", history)
+	}
+
+	if *probeModules != "" {
+		modules, err := loadProbeModules(*probeModules)
+		if err != nil {
+			fatal("invalid probe modules", err)
 		}
-	}()
+		p := newProber(modules, *probeIdle, l)
+		go p.run(ctx)
+		mux.Handle("/probe", withScrapeTimeout(*scrapeOffset)(p))
+	}
+
+	// reload the configuration on SIGHUP, or on a POST to /-/reload
+	r := reloader{load: loadConfig, target: c, level: &level, defaultLevel: defaultLevel, logger: l}
+	mux.Handle("/-/reload", &r)
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go r.run(ctx, hup)
+	servers := []*http.Server{{Addr: *addr, Handler: mux}}
+	checks := new(health.Registry)
+	c.RegisterHealthChecks(checks)
+	if relayServer != nil {
+		checks.RegisterOptional("relay", relayServer)
+	}
+	healthMux := mux
+	if *healthAddr == "" {
+		// single-port mode: serve everything from -addr
+		mux.Handle("/health", checks.Handler())
+		links = append(links, "/health", "/livez", "/readyz")
+	} else {
+		healthMux = http.NewServeMux()
+		healthMux.Handle("/", checks.Handler())
+		servers = append(servers, &http.Server{Addr: *healthAddr, Handler: healthMux})
+	}
+	healthMux.Handle("/livez", health.Livez())
+	healthMux.Handle("/readyz", health.Readyz(c, c.LastUpdate, *readyAfter))
+	if *debugPprof {
+		handleDebug(healthMux)
+	}
+	mux.Handle("GET /{$}", landingPage(version, links...))
+	for _, s := range servers {
+		go web.serve(ctx, s, time.Second, time.Minute, errorsTotal.WithLabelValues("http"), l)
+	}
+	relayHTTP := &http.Server{Addr: *relayAddr, Handler: relayServer}
+	if relayServer != nil {
+		// TLCP clients authenticate with LS_user/LS_password, not basic authentication
+		relayWeb := web
+		relayWeb.username, relayWeb.password = "", ""
+		go relayWeb.serve(ctx, relayHTTP, time.Second, time.Minute, errorsTotal.WithLabelValues("relay"), l)
+	}
+	grpcHTTP := &http.Server{Addr: *grpcAddr, Handler: c.GRPCHandler(ctx), Protocols: new(http.Protocols)}
+	if *grpcAddr != "" {
+		// gRPC requires HTTP/2. without TLS, clients use HTTP/2 with prior knowledge
+		grpcHTTP.Protocols.SetHTTP1(true)
+		grpcHTTP.Protocols.SetHTTP2(true)
+		grpcHTTP.Protocols.SetUnencryptedHTTP2(true)
+		grpcWeb := web
+		grpcWeb.http1Only = false
+		go grpcWeb.serve(ctx, grpcHTTP, time.Second, time.Minute, errorsTotal.WithLabelValues("grpc"), l)
+	}
+
+	// tell systemd we're up. keep its watchdog, if enabled, informed as long as the stream is live
+	notifySocket := os.Getenv("NOTIFY_SOCKET")
+	if err = sdNotify(notifySocket, "READY=1"); err != nil {
+		l.Warn("failed to notify systemd", "err", err)
+	}
+	if interval, ok := watchdogInterval(os.LookupEnv, os.Getpid()); ok {
+		healthy := func() bool { return health.Ready(ctx, c, c.LastUpdate, *readyAfter) == nil }
+		go runWatchdog(ctx, notifySocket, interval, healthy, l)
+	}
 
 	<-ctx.Done()
+	l.Info("Shutting down")
+	_ = sdNotify(notifySocket, "STOPPING=1")
+
+	// let in-flight scrapes finish, then free the session on the Lightstreamer server
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), *gracePeriod)
+	defer shutdownCancel()
+	for _, s := range servers {
+		if err := s.Shutdown(shutdownCtx); err != nil {
+			l.Warn("failed to shut down HTTP server", "addr", s.Addr, "err", err)
+		}
+	}
+	// downstream stream connections never go idle: close them rather than wait for the grace period
+	_ = relayHTTP.Close()
+	if err := grpcHTTP.Shutdown(shutdownCtx); err != nil {
+		l.Warn("failed to shut down gRPC server", "addr", grpcHTTP.Addr, "err", err)
+	}
+	if err := session.Destroy(shutdownCtx); err != nil {
+		l.Warn("failed to destroy Lightstreamer session", "err", err)
+	}
+	if rec != nil {
+		if err := rec.Close(); err != nil {
+			l.Warn("failed to close recording", "err", err)
+		}
+	}
+	if *debugRecord != "" && *debugHAR != "" {
+		if err := writeHAR(*debugHAR, *debugRecord); err != nil {
+			l.Warn("failed to write HAR file", "err", err)
+		}
+	}
+}
+
+// loadConfig loads the configuration file, or the default configuration if none is specified, and applies the flags
+// that override it.
+func loadConfig() (collector.Config, error) {
+	cfg := collector.DefaultConfig
+	if *configFile != "" {
+		var err error
+		if cfg, err = collector.LoadConfig(*configFile); err != nil {
+			return cfg, err
+		}
+	}
+	if *subsystems != "" {
+		cfg.Subsystems = append(slices.Clone(cfg.Subsystems), strings.Split(*subsystems, ",")...)
+	}
+	if *include != "" {
+		cfg.Include = *include
+	}
+	if *exclude != "" {
+		cfg.Exclude = *exclude
+	}
+	if *maxFrequency > 0 {
+		cfg.MaxFrequency = *maxFrequency
+	}
+	return cfg, cfg.Validate()
+}
+
+// loadWebConfig returns the web configuration set by the flags and, if specified, the web configuration file.
+func loadWebConfig() (webConfig, error) {
+	web := webConfig{certFile: *tlsCert, keyFile: *tlsKey, username: *authUsername, password: *authPassword}
+	if *webConfigPath != "" {
+		var err error
+		if web, err = readWebConfigFile(*webConfigPath, web); err != nil {
+			return web, err
+		}
+	}
+	return web, web.validate()
+}
+
+// writeHAR converts the recording at recordingPath to an HTTP Archive, written to path.
+func writeHAR(path string, recordingPath string) error {
+	recording, err := os.Open(recordingPath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = recording.Close() }()
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err = util.WriteHAR(f, recording, version, 64*1024); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
 }
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/clambin/iss-exporter/internal/collector"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/prometheus/client_golang/prometheus"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// mqttOutput publishes telemetry updates to an MQTT broker. Each update is published as JSON to the topic of its
+// group. If discoveryPrefix is set, each group is also announced to Home Assistant, through MQTT discovery, so it
+// shows up as a sensor.
+type mqttOutput struct {
+	url             string
+	clientID        string
+	username        string
+	password        string
+	topic           string
+	qos             byte
+	retain          bool
+	discoveryPrefix string
+	version         string
+	group           func(id string) (collector.GroupConfig, bool)
+	errors          prometheus.Counter
+	logger          *slog.Logger
+}
+
+// validateMQTTTopic checks a topic pattern: a topic name, in which {group} is replaced by the group ID.
+func validateMQTTTopic(pattern string) error {
+	if pattern == "" {
+		return errors.New("topic can't be empty")
+	}
+	if strings.ContainsAny(pattern, "+#") {
+		return errors.New("topic can't contain wildcards")
+	}
+	return nil
+}
+
+func (o *mqttOutput) topicOf(group string) string {
+	return strings.ReplaceAll(o.topic, "{group}", group)
+}
+
+// run publishes the updates until ctx is canceled, reconnecting to the broker if the connection is lost.
+func (o *mqttOutput) run(ctx context.Context, updates <-chan collector.Update, retry time.Duration, maxRetry time.Duration) {
+	runOutput(ctx, "mqtt", func(ctx context.Context) error {
+		lost := make(chan error, 1)
+		client := mqtt.NewClient(o.clientOptions(lost))
+		if err := waitMQTT(ctx, client.Connect()); err != nil {
+			return err
+		}
+		defer client.Disconnect(250)
+		return o.publish(ctx, client, lost, updates)
+	}, retry, maxRetry, o.errors, o.logger)
+}
+
+// clientOptions returns the options of a connection to the broker. The connection isn't re-established by the client:
+// if it's lost, the reason is sent to lost, and run reconnects, with backoff.
+func (o *mqttOutput) clientOptions(lost chan<- error) *mqtt.ClientOptions {
+	return mqtt.NewClientOptions().
+		AddBroker(o.url).
+		SetClientID(o.clientID).
+		SetUsername(o.username).
+		SetPassword(o.password).
+		SetCleanSession(true).
+		SetConnectTimeout(10 * time.Second).
+		SetAutoReconnect(false).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			lost <- err
+		})
+}
+
+// publish publishes the updates over a connection, until the connection fails or ctx is canceled. Groups are announced
+// to Home Assistant with their first update on each connection.
+func (o *mqttOutput) publish(ctx context.Context, client mqtt.Client, lost <-chan error, updates <-chan collector.Update) error {
+	announced := make(map[string]bool)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-lost:
+			return fmt.Errorf("connection lost: %w", err)
+		case u := <-updates:
+			if o.discoveryPrefix != "" && !announced[u.Group] {
+				if err := o.announce(ctx, client, u.Group); err != nil {
+					return fmt.Errorf("discovery: %w", err)
+				}
+				announced[u.Group] = true
+			}
+			payload, _ := json.Marshal(u)
+			if err := o.send(ctx, client, o.topicOf(u.Group), payload, o.retain); err != nil {
+				return fmt.Errorf("publish: %w", err)
+			}
+		}
+	}
+}
+
+func (o *mqttOutput) send(ctx context.Context, client mqtt.Client, topic string, payload []byte, retain bool) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	return waitMQTT(ctx, client.Publish(topic, o.qos, retain, payload))
+}
+
+// waitMQTT waits for an MQTT operation to complete, and returns its error, or ctx's error if ctx is canceled first.
+func waitMQTT(ctx context.Context, token mqtt.Token) error {
+	select {
+	case <-token.Done():
+		return token.Error()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// announce publishes the Home Assistant discovery configuration of a group, as a retained message.
+func (o *mqttOutput) announce(ctx context.Context, client mqtt.Client, id string) error {
+	group, ok := o.group(id)
+	if !ok {
+		group = collector.GroupConfig{ID: id}
+	}
+	topic, payload := haDiscovery(o.discoveryPrefix, o.topicOf(id), group, o.version)
+	body, _ := json.Marshal(payload)
+	return o.send(ctx, client, topic, body, true)
+}
+
+// haDiscoveryConfig is the Home Assistant MQTT discovery configuration of a sensor.
+type haDiscoveryConfig struct {
+	Name                string   `json:"name"`
+	UniqueID            string   `json:"unique_id"`
+	ObjectID            string   `json:"object_id"`
+	StateTopic          string   `json:"state_topic"`
+	ValueTemplate       string   `json:"value_template"`
+	JSONAttributesTopic string   `json:"json_attributes_topic"`
+	UnitOfMeasurement   string   `json:"unit_of_measurement,omitempty"`
+	StateClass          string   `json:"state_class,omitempty"`
+	Device              haDevice `json:"device"`
+}
+
+type haDevice struct {
+	Identifiers []string `json:"identifiers"`
+	Name        string   `json:"name"`
+	Model       string   `json:"model"`
+	SWVersion   string   `json:"sw_version"`
+}
+
+// haUnits translates the units of the catalog to the units used by Home Assistant.
+var haUnits = map[string]string{
+	"amperes":           "A",
+	"celsius":           "°C",
+	"degrees":           "°",
+	"kilometers":        "km",
+	"meters_per_second": "m/s",
+	"mmhg":              "mmHg",
+	"percent":           "%",
+	"pounds_per_day":    "lb/d",
+	"volts":             "V",
+}
+
+// haDiscovery returns the discovery topic and configuration of a group, whose updates are published to stateTopic.
+// Enumerated groups report their state, others their value.
+func haDiscovery(prefix string, stateTopic string, group collector.GroupConfig, version string) (string, haDiscoveryConfig) {
+	objectID := "iss_" + strings.ToLower(group.ID)
+	cfg := haDiscoveryConfig{
+		Name:                group.ID,
+		UniqueID:            objectID,
+		ObjectID:            objectID,
+		StateTopic:          stateTopic,
+		ValueTemplate:       "{{ value_json.value }}",
+		JSONAttributesTopic: stateTopic,
+		Device: haDevice{
+			Identifiers: []string{"iss-exporter"},
+			Name:        "International Space Station",
+			Model:       "ISSLIVE telemetry",
+			SWVersion:   version,
+		},
+	}
+	if group.Help != "" {
+		cfg.Name = group.Help
+	}
+	if len(group.States) > 0 {
+		cfg.ValueTemplate = "{{ value_json.state }}"
+	} else {
+		cfg.StateClass = "measurement"
+		cfg.UnitOfMeasurement = group.Unit
+		if unit, ok := haUnits[group.Unit]; ok {
+			cfg.UnitOfMeasurement = unit
+		}
+	}
+	return prefix + "/sensor/iss_exporter/" + objectID + "/config", cfg
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"github.com/clambin/iss-exporter/internal/collector"
+	"github.com/prometheus/client_golang/prometheus"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestValidateMQTTTopic(t *testing.T) {
+	for topic, pass := range map[string]bool{"iss/telemetry/{group}": true, "iss": true, "": false, "iss/+/x": false, "iss/#": false} {
+		if err := validateMQTTTopic(topic); (err == nil) != pass {
+			t.Errorf("%q: got error %v, want pass %v", topic, err, pass)
+		}
+	}
+}
+
+func TestHADiscovery(t *testing.T) {
+	topic, cfg := haDiscovery("homeassistant", "iss/telemetry/USLAB000058", collector.GroupConfig{ID: "USLAB000058", Help: "cabin pressure", Unit: "mmhg"}, "v1")
+	if topic != "homeassistant/sensor/iss_exporter/iss_uslab000058/config" {
+		t.Errorf("got topic %q", topic)
+	}
+	if cfg.Name != "cabin pressure" || cfg.UnitOfMeasurement != "mmHg" || cfg.ValueTemplate != "{{ value_json.value }}" || cfg.StateClass != "measurement" {
+		t.Errorf("unexpected configuration: %+v", cfg)
+	}
+
+	_, cfg = haDiscovery("homeassistant", "iss/telemetry/X", collector.GroupConfig{ID: "X", States: map[string]string{"1": "on"}}, "v1")
+	if cfg.Name != "X" || cfg.ValueTemplate != "{{ value_json.state }}" || cfg.UnitOfMeasurement != "" || cfg.StateClass != "" {
+		t.Errorf("unexpected configuration: %+v", cfg)
+	}
+}
+
+func TestMQTTOutput(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+	topics := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveMQTT(conn, topics)
+		}
+	}()
+
+	o := mqttOutput{
+		url:             "tcp://" + l.Addr().String(),
+		topic:           "iss/{group}",
+		discoveryPrefix: "homeassistant",
+		group:           func(string) (collector.GroupConfig, bool) { return collector.GroupConfig{}, false },
+		errors:          prometheus.NewCounter(prometheus.CounterOpts{Name: "errors"}),
+		logger:          slog.New(slog.DiscardHandler),
+	}
+	updates := make(chan collector.Update)
+	go o.run(t.Context(), updates, 10*time.Millisecond, 10*time.Millisecond)
+	updates <- collector.Update{Group: "A", Value: 1}
+	updates <- collector.Update{Group: "A", Value: 2}
+	for _, want := range []string{"homeassistant/sensor/iss_exporter/iss_a/config", "iss/A", "iss/A"} {
+		select {
+		case got := <-topics:
+			if got != want {
+				t.Errorf("got topic %q, want %q", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timeout waiting for %q", want)
+		}
+	}
+}
+
+// serveMQTT accepts an MQTT connection, and reports the topic of each QoS 0 message it receives.
+func serveMQTT(conn net.Conn, topics chan<- string) {
+	defer func() { _ = conn.Close() }()
+	r := bufio.NewReader(conn)
+	for first := true; ; first = false {
+		header, err := r.ReadByte()
+		if err != nil {
+			return
+		}
+		var length, shift int
+		for {
+			b, err := r.ReadByte()
+			if err != nil {
+				return
+			}
+			length |= int(b&0x7F) << shift
+			if shift += 7; b&0x80 == 0 {
+				break
+			}
+		}
+		body := make([]byte, length)
+		if _, err = io.ReadFull(r, body); err != nil {
+			return
+		}
+		if first {
+			_, _ = conn.Write([]byte{0x20, 2, 0, 0})
+			continue
+		}
+		if header>>4 == 3 {
+			n := binary.BigEndian.Uint16(body)
+			topic := string(body[2 : 2+n])
+			if !json.Valid(body[2+n:]) {
+				topic = "invalid payload for " + topic
+			}
+			topics <- topic
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/clambin/iss-exporter/internal/collector"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/prometheus/client_golang/prometheus"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// natsOutput publishes telemetry updates to a NATS server. Each update is published as JSON to the subject of its
+// group. With jetStream set, updates are published to the JetStream stream that captures the subject, and each
+// update waits for the stream's acknowledgement, so an update that isn't stored fails the connection.
+type natsOutput struct {
+	url       string
+	options   []nats.Option
+	subject   string
+	jetStream bool
+	errors    prometheus.Counter
+	logger    *slog.Logger
+}
+
+// validateNATSSubject checks a subject pattern: a subject, in which {group} is replaced by the group ID.
+func validateNATSSubject(pattern string) error {
+	if pattern == "" {
+		return errors.New("subject can't be empty")
+	}
+	if strings.ContainsAny(pattern, "*> \t") {
+		return errors.New("subject can't contain wildcards or whitespace")
+	}
+	if strings.HasPrefix(pattern, ".") || strings.HasSuffix(pattern, ".") || strings.Contains(pattern, "..") {
+		return errors.New("subject can't contain empty tokens")
+	}
+	return nil
+}
+
+func (o *natsOutput) subjectOf(group string) string {
+	return strings.ReplaceAll(o.subject, "{group}", group)
+}
+
+// run publishes the updates until ctx is canceled, reconnecting to the server if the connection is lost.
+func (o *natsOutput) run(ctx context.Context, updates <-chan collector.Update, retry time.Duration, maxRetry time.Duration) {
+	runOutput(ctx, "nats", func(ctx context.Context) error {
+		// the connection isn't re-established by the client: if it's lost, the reason is sent to lost, and run
+		// reconnects, with backoff.
+		lost := make(chan error, 1)
+		options := append([]nats.Option{
+			nats.Timeout(10 * time.Second),
+			nats.NoReconnect(),
+			nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+				select {
+				case lost <- cmp.Or(err, nats.ErrConnectionClosed):
+				default:
+				}
+			}),
+		}, o.options...)
+		conn, err := nats.Connect(o.url, options...)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		js, err := jetstream.New(conn)
+		if err != nil {
+			return err
+		}
+		return o.publish(ctx, conn, js, lost, updates)
+	}, retry, maxRetry, o.errors, o.logger)
+}
+
+// publish publishes the updates over a connection, until the connection fails or ctx is canceled.
+func (o *natsOutput) publish(ctx context.Context, conn *nats.Conn, js jetstream.JetStream, lost <-chan error, updates <-chan collector.Update) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-lost:
+			return fmt.Errorf("connection lost: %w", err)
+		case u := <-updates:
+			payload, _ := json.Marshal(u)
+			if err := o.send(ctx, conn, js, o.subjectOf(u.Group), payload); err != nil {
+				return fmt.Errorf("publish: %w", err)
+			}
+		}
+	}
+}
+
+func (o *natsOutput) send(ctx context.Context, conn *nats.Conn, js jetstream.JetStream, subject string, payload []byte) error {
+	if !o.jetStream {
+		return conn.Publish(subject, payload)
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	_, err := js.Publish(ctx, subject, payload)
+	return err
+}
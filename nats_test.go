@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"github.com/clambin/iss-exporter/internal/collector"
+	"github.com/prometheus/client_golang/prometheus"
+	"io"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateNATSSubject(t *testing.T) {
+	for subject, pass := range map[string]bool{"iss.telemetry.{group}": true, "iss": true, "": false, "iss.*": false, "iss.>": false, "iss..x": false, "iss.": false} {
+		if err := validateNATSSubject(subject); (err == nil) != pass {
+			t.Errorf("%q: got error %v, want pass %v", subject, err, pass)
+		}
+	}
+}
+
+func TestNATSOutput(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+	subjects := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveNATS(conn, subjects)
+		}
+	}()
+
+	for _, jetStream := range []bool{false, true} {
+		o := natsOutput{
+			url:       "nats://" + l.Addr().String(),
+			subject:   "iss.{group}",
+			jetStream: jetStream,
+			errors:    prometheus.NewCounter(prometheus.CounterOpts{Name: "errors"}),
+			logger:    slog.New(slog.DiscardHandler),
+		}
+		updates := make(chan collector.Update)
+		go o.run(t.Context(), updates, 10*time.Millisecond, 10*time.Millisecond)
+		updates <- collector.Update{Group: "A", Value: 1}
+		updates <- collector.Update{Group: "B", Value: 2}
+		for _, want := range []string{"iss.A", "iss.B"} {
+			select {
+			case got := <-subjects:
+				if got != want {
+					t.Errorf("jetstream %v: got subject %q, want %q", jetStream, got, want)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("jetstream %v: timeout waiting for %q", jetStream, want)
+			}
+		}
+	}
+}
+
+// serveNATS accepts a NATS connection, acknowledges messages with a reply subject, and reports the subject of each
+// message it receives.
+func serveNATS(conn net.Conn, subjects chan<- string) {
+	defer func() { _ = conn.Close() }()
+	_, _ = conn.Write([]byte(`INFO {"max_payload":1048576}` + "\r\n"))
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		switch fields[0] {
+		case "PING":
+			_, _ = conn.Write([]byte("PONG\r\n"))
+		case "PUB":
+			size, _ := strconv.Atoi(fields[len(fields)-1])
+			payload := make([]byte, size+2)
+			if _, err = io.ReadFull(r, payload); err != nil {
+				return
+			}
+			subject := fields[1]
+			if !json.Valid(payload[:size]) {
+				subject = "invalid payload for " + subject
+			}
+			if len(fields) == 4 {
+				ack := `{"stream":"ISS","seq":1}`
+				_, _ = conn.Write([]byte("MSG " + fields[2] + " 1 " + strconv.Itoa(len(ack)) + "\r\n" + ack + "\r\n"))
+			}
+			subjects <- subject
+		}
+	}
+}
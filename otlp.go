@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// otlpExporter periodically pushes the metrics of a Gatherer to an OpenTelemetry collector, using OTLP over HTTP,
+// with JSON encoding. Counters, histograms and summaries are pushed as cumulative values since start.
+type otlpExporter struct {
+	url        string
+	gatherer   prometheus.Gatherer
+	version    string
+	start      time.Time
+	httpClient *http.Client
+	logger     *slog.Logger
+}
+
+// otlpURL returns the URL to push metrics to. If endpoint has no path, the default OTLP metrics path is used.
+func otlpURL(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", errors.New("endpoint must be an http or https URL")
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/v1/metrics"
+	}
+	return u.String(), nil
+}
+
+// run pushes the metrics every interval, until ctx is canceled.
+func (e *otlpExporter) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := e.push(ctx); err != nil {
+				e.logger.Warn("failed to push metrics", "err", err)
+			}
+		}
+	}
+}
+
+// push gathers the metrics and pushes them.
+func (e *otlpExporter) push(ctx context.Context) error {
+	families, err := e.gatherer.Gather()
+	if err != nil {
+		return fmt.Errorf("gather: %w", err)
+	}
+	body, err := json.Marshal(toOTLP(families, e.version, time.Now(), e.start))
+	if err != nil {
+		return err
+	}
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return errors.New(resp.Status)
+	}
+	return nil
+}
+
+// The OTLP types below are the subset of the OTLP protobuf messages used to push metrics, in their JSON encoding:
+// 64-bit integers are encoded as strings.
+
+type otlpRequest struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResource       `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlpScope    `json:"scope"`
+	Metrics []otlpMetric `json:"metrics"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string `json:"key"`
+	Value struct {
+		StringValue string `json:"stringValue"`
+	} `json:"value"`
+}
+
+type otlpMetric struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Gauge       *otlpGauge     `json:"gauge,omitempty"`
+	Sum         *otlpSum       `json:"sum,omitempty"`
+	Histogram   *otlpHistogram `json:"histogram,omitempty"`
+	Summary     *otlpSummary   `json:"summary,omitempty"`
+}
+
+// otlpCumulative is the cumulative aggregation temporality.
+const otlpCumulative = 2
+
+type otlpGauge struct {
+	DataPoints []otlpNumberDataPoint `json:"dataPoints"`
+}
+
+type otlpSum struct {
+	DataPoints             []otlpNumberDataPoint `json:"dataPoints"`
+	AggregationTemporality int                   `json:"aggregationTemporality"`
+	IsMonotonic            bool                  `json:"isMonotonic"`
+}
+
+type otlpHistogram struct {
+	DataPoints             []otlpHistogramDataPoint `json:"dataPoints"`
+	AggregationTemporality int                      `json:"aggregationTemporality"`
+}
+
+type otlpSummary struct {
+	DataPoints []otlpSummaryDataPoint `json:"dataPoints"`
+}
+
+type otlpDataPoint struct {
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano,omitempty"`
+	TimeUnixNano      string          `json:"timeUnixNano"`
+}
+
+type otlpNumberDataPoint struct {
+	otlpDataPoint
+	AsDouble float64 `json:"asDouble"`
+}
+
+type otlpHistogramDataPoint struct {
+	otlpDataPoint
+	Count          string    `json:"count"`
+	Sum            float64   `json:"sum"`
+	BucketCounts   []string  `json:"bucketCounts"`
+	ExplicitBounds []float64 `json:"explicitBounds"`
+}
+
+type otlpSummaryDataPoint struct {
+	otlpDataPoint
+	Count          string              `json:"count"`
+	Sum            float64             `json:"sum"`
+	QuantileValues []otlpQuantileValue `json:"quantileValues"`
+}
+
+type otlpQuantileValue struct {
+	Quantile float64 `json:"quantile"`
+	Value    float64 `json:"value"`
+}
+
+// toOTLP converts gathered metrics to an OTLP request. Metrics without a timestamp are reported at now. Cumulative
+// metrics are reported since start. Untyped metrics are reported as gauges.
+func toOTLP(families []*dto.MetricFamily, version string, now time.Time, start time.Time) otlpRequest {
+	metrics := make([]otlpMetric, 0, len(families))
+	for _, family := range families {
+		metric := otlpMetric{Name: family.GetName(), Description: family.GetHelp()}
+		for _, m := range family.GetMetric() {
+			point := otlpDataPoint{Attributes: otlpAttributes(m.GetLabel()), TimeUnixNano: unixNano(now)}
+			if m.TimestampMs != nil {
+				point.TimeUnixNano = unixNano(time.UnixMilli(m.GetTimestampMs()))
+			}
+			switch family.GetType() {
+			case dto.MetricType_COUNTER:
+				point.StartTimeUnixNano = unixNano(start)
+				if metric.Sum == nil {
+					metric.Sum = &otlpSum{AggregationTemporality: otlpCumulative, IsMonotonic: true}
+				}
+				metric.Sum.DataPoints = append(metric.Sum.DataPoints, otlpNumberDataPoint{otlpDataPoint: point, AsDouble: m.GetCounter().GetValue()})
+			case dto.MetricType_HISTOGRAM:
+				point.StartTimeUnixNano = unixNano(start)
+				if metric.Histogram == nil {
+					metric.Histogram = &otlpHistogram{AggregationTemporality: otlpCumulative}
+				}
+				metric.Histogram.DataPoints = append(metric.Histogram.DataPoints, otlpHistogramPoint(point, m.GetHistogram()))
+			case dto.MetricType_SUMMARY:
+				point.StartTimeUnixNano = unixNano(start)
+				if metric.Summary == nil {
+					metric.Summary = new(otlpSummary)
+				}
+				metric.Summary.DataPoints = append(metric.Summary.DataPoints, otlpSummaryPoint(point, m.GetSummary()))
+			case dto.MetricType_GAUGE:
+				if metric.Gauge == nil {
+					metric.Gauge = new(otlpGauge)
+				}
+				metric.Gauge.DataPoints = append(metric.Gauge.DataPoints, otlpNumberDataPoint{otlpDataPoint: point, AsDouble: m.GetGauge().GetValue()})
+			default:
+				if metric.Gauge == nil {
+					metric.Gauge = new(otlpGauge)
+				}
+				metric.Gauge.DataPoints = append(metric.Gauge.DataPoints, otlpNumberDataPoint{otlpDataPoint: point, AsDouble: m.GetUntyped().GetValue()})
+			}
+		}
+		metrics = append(metrics, metric)
+	}
+	return otlpRequest{ResourceMetrics: []otlpResourceMetrics{{
+		Resource:     otlpResource{Attributes: []otlpAttribute{otlpStringAttribute("service.name", "iss-exporter"), otlpStringAttribute("service.version", version)}},
+		ScopeMetrics: []otlpScopeMetrics{{Scope: otlpScope{Name: "iss-exporter", Version: version}, Metrics: metrics}},
+	}}}
+}
+
+// otlpHistogramPoint converts a Prometheus histogram, with cumulative buckets, to an OTLP data point, which counts
+// the observations per bucket. The last OTLP bucket holds the observations above the highest bound.
+func otlpHistogramPoint(point otlpDataPoint, h *dto.Histogram) otlpHistogramDataPoint {
+	p := otlpHistogramDataPoint{otlpDataPoint: point, Count: strconv.FormatUint(h.GetSampleCount(), 10), Sum: h.GetSampleSum()}
+	var previous uint64
+	for _, bucket := range h.GetBucket() {
+		if math.IsInf(bucket.GetUpperBound(), +1) {
+			continue
+		}
+		p.ExplicitBounds = append(p.ExplicitBounds, bucket.GetUpperBound())
+		p.BucketCounts = append(p.BucketCounts, strconv.FormatUint(bucket.GetCumulativeCount()-previous, 10))
+		previous = bucket.GetCumulativeCount()
+	}
+	p.BucketCounts = append(p.BucketCounts, strconv.FormatUint(h.GetSampleCount()-previous, 10))
+	return p
+}
+
+func otlpSummaryPoint(point otlpDataPoint, s *dto.Summary) otlpSummaryDataPoint {
+	p := otlpSummaryDataPoint{otlpDataPoint: point, Count: strconv.FormatUint(s.GetSampleCount(), 10), Sum: s.GetSampleSum()}
+	for _, q := range s.GetQuantile() {
+		p.QuantileValues = append(p.QuantileValues, otlpQuantileValue{Quantile: q.GetQuantile(), Value: q.GetValue()})
+	}
+	return p
+}
+
+func otlpAttributes(labels []*dto.LabelPair) []otlpAttribute {
+	attributes := make([]otlpAttribute, len(labels))
+	for i, label := range labels {
+		attributes[i] = otlpStringAttribute(label.GetName(), label.GetValue())
+	}
+	return attributes
+}
+
+func otlpStringAttribute(key, value string) otlpAttribute {
+	a := otlpAttribute{Key: key}
+	a.Value.StringValue = value
+	return a
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/prometheus/client_golang/prometheus"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestOtlpURL(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		want     string
+		wantErr  bool
+	}{
+		{endpoint: "http://collector:4318", want: "http://collector:4318/v1/metrics"},
+		{endpoint: "https://collector:4318/", want: "https://collector:4318/v1/metrics"},
+		{endpoint: "http://collector:4318/custom/path", want: "http://collector:4318/custom/path"},
+		{endpoint: "collector:4318", wantErr: true},
+		{endpoint: "ftp://collector", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.endpoint, func(t *testing.T) {
+			got, err := otlpURL(tt.endpoint)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("otlpURL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToOTLP(t *testing.T) {
+	r := prometheus.NewRegistry()
+	gauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "gauge", Help: "a gauge"}, []string{"group"})
+	gauge.WithLabelValues("A").Set(4)
+	counter := prometheus.NewCounter(prometheus.CounterOpts{Name: "counter_total", Help: "a counter"})
+	counter.Add(2)
+	histogram := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "histogram", Help: "a histogram", Buckets: []float64{1, 10}})
+	for _, value := range []float64{0.5, 5, 5, 50} {
+		histogram.Observe(value)
+	}
+	r.MustRegister(gauge, counter, histogram)
+	families, err := r.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Unix(1000, 0)
+	now := time.Unix(2000, 0)
+	req := toOTLP(families, "v1", now, start)
+	metrics := req.ResourceMetrics[0].ScopeMetrics[0].Metrics
+	if len(metrics) != 3 {
+		t.Fatalf("got %d metrics, want 3", len(metrics))
+	}
+	byName := make(map[string]otlpMetric)
+	for _, m := range metrics {
+		byName[m.Name] = m
+	}
+
+	g := byName["gauge"]
+	if g.Gauge == nil || len(g.Gauge.DataPoints) != 1 || g.Gauge.DataPoints[0].AsDouble != 4 || g.Description != "a gauge" {
+		t.Fatalf("unexpected gauge: %+v", g)
+	}
+	if p := g.Gauge.DataPoints[0]; len(p.Attributes) != 1 || p.Attributes[0].Key != "group" || p.Attributes[0].Value.StringValue != "A" || p.TimeUnixNano != "2000000000000" {
+		t.Errorf("unexpected gauge data point: %+v", p)
+	}
+
+	c := byName["counter_total"]
+	if c.Sum == nil || !c.Sum.IsMonotonic || c.Sum.AggregationTemporality != otlpCumulative || c.Sum.DataPoints[0].AsDouble != 2 || c.Sum.DataPoints[0].StartTimeUnixNano != "1000000000000" {
+		t.Errorf("unexpected counter: %+v", c.Sum)
+	}
+
+	h := byName["histogram"]
+	if h.Histogram == nil {
+		t.Fatalf("unexpected histogram: %+v", h)
+	}
+	p := h.Histogram.DataPoints[0]
+	if p.Count != "4" || p.Sum != 60.5 || !slices.Equal(p.ExplicitBounds, []float64{1, 10}) || !slices.Equal(p.BucketCounts, []string{"1", "2", "1"}) {
+		t.Errorf("unexpected histogram data point: %+v", p)
+	}
+}
+
+func TestOtlpExporter_push(t *testing.T) {
+	var received otlpRequest
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			http.Error(w, "unsupported content type", http.StatusUnsupportedMediaType)
+			return
+		}
+		_ = json.NewDecoder(r.Body).Decode(&received)
+	}))
+	t.Cleanup(ts.Close)
+
+	r := prometheus.NewRegistry()
+	r.MustRegister(newBuildInfo("v1"))
+	e := otlpExporter{url: ts.URL, gatherer: r, version: "v1", start: time.Now(), httpClient: http.DefaultClient, logger: slog.New(slog.DiscardHandler)}
+	if err := e.push(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	if len(received.ResourceMetrics) != 1 {
+		t.Fatalf("unexpected request: %+v", received)
+	}
+	if metrics := received.ResourceMetrics[0].ScopeMetrics[0].Metrics; len(metrics) != 1 || metrics[0].Name != "iss_build_info" {
+		t.Errorf("unexpected metrics: %+v", metrics)
+	}
+
+	notFound := httptest.NewServer(http.NotFoundHandler())
+	t.Cleanup(notFound.Close)
+	e.url = notFound.URL
+	if err := e.push(t.Context()); err == nil {
+		t.Error("expected an error")
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"github.com/clambin/iss-exporter/internal/collector"
+	"github.com/prometheus/client_golang/prometheus"
+	"log/slog"
+	"time"
+)
+
+// outputQueueSize is the number of updates queued for an output before new updates are dropped.
+const outputQueueSize = 1024
+
+// queueUpdates returns a channel that receives the telemetry updates processed by c, until stop is called. Outputs
+// send the updates over the network, so they may fall behind: if the channel is full, new updates are dropped, and
+// counted by dropped.
+func queueUpdates(c *collector.Collector, dropped prometheus.Counter) (<-chan collector.Update, func()) {
+	updates := make(chan collector.Update, outputQueueSize)
+	stop := c.Listen(func(u collector.Update) {
+		select {
+		case updates <- u:
+		default:
+			dropped.Inc()
+		}
+	})
+	return updates, stop
+}
+
+// runOutput sends the updates to an output until ctx is canceled. connect connects to the output, and sends updates
+// until the connection fails. runOutput then reconnects, with a delay that doubles after each failed attempt, from
+// retry up to maxRetry. Failures are counted by errs. Updates received while disconnected are dropped.
+func runOutput(ctx context.Context, name string, connect func(context.Context) error, retry time.Duration, maxRetry time.Duration, errs prometheus.Counter, logger *slog.Logger) {
+	delay := retry
+	for {
+		start := time.Now()
+		err := connect(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		// a connection that lasted a while isn't a failed attempt: start over with the shortest delay
+		if time.Since(start) > maxRetry {
+			delay = retry
+		}
+		errs.Inc()
+		logger.Warn("output failed. reconnecting", "output", name, "err", err, "retry", delay)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(2*delay, maxRetry)
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/clambin/iss-exporter/internal/collector"
+	"github.com/clambin/iss-exporter/lightstreamer"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+// defaultProbeModule is the module used by probes that don't specify one.
+const defaultProbeModule = "default"
+
+// loadProbeModules loads the probe modules: a JSON object that maps the name of each module to its configuration.
+func loadProbeModules(path string) (map[string]collector.Config, error) {
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var modules map[string]collector.Config
+	if err = json.Unmarshal(body, &modules); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(modules) == 0 {
+		return nil, fmt.Errorf("%s: no modules configured", path)
+	}
+	for name, cfg := range modules {
+		if err = cfg.Validate(); err != nil {
+			return nil, fmt.Errorf("%s: module %s: %w", path, name, err)
+		}
+	}
+	return modules, nil
+}
+
+// prober implements the multi-target exporter pattern: /probe?module=<module>&target=<url> exports the telemetry of
+// the Lightstreamer server at target, as configured by module. The module's server URL is replaced by the target.
+//
+// The first probe of a target creates a session, and subscribes to the module's groups. The session is reused by
+// later probes, and closed if the target hasn't been probed for idleTimeout. As the first probe only subscribes,
+// it exports no telemetry yet.
+type prober struct {
+	modules     map[string]collector.Config
+	idleTimeout time.Duration
+	logger      *slog.Logger
+	lock        sync.Mutex
+	targets     map[probeKey]*probeTarget
+}
+
+type probeKey struct {
+	module string
+	target string
+}
+
+// probeTarget is the session with a probed target. ready is closed once the session is established, or failed to.
+type probeTarget struct {
+	ready      chan struct{}
+	err        error
+	collector  *collector.Collector
+	session    *lightstreamer.ClientSession
+	cancel     context.CancelFunc
+	lastProbed time.Time
+}
+
+func newProber(modules map[string]collector.Config, idleTimeout time.Duration, logger *slog.Logger) *prober {
+	return &prober{
+		modules:     modules,
+		idleTimeout: idleTimeout,
+		logger:      logger,
+		targets:     make(map[probeKey]*probeTarget),
+	}
+}
+
+func (p *prober) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := probeKey{module: cmp.Or(r.URL.Query().Get("module"), defaultProbeModule), target: r.URL.Query().Get("target")}
+	if _, ok := p.modules[key.module]; !ok {
+		http.Error(w, fmt.Sprintf("unknown module %q", key.module), http.StatusBadRequest)
+		return
+	}
+	if u, err := url.Parse(key.target); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		http.Error(w, fmt.Sprintf("invalid target %q", key.target), http.StatusBadRequest)
+		return
+	}
+	t, err := p.get(r.Context(), key)
+	if err != nil {
+		http.Error(w, "probe failed: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(t.collector)
+	promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+}
+
+// get returns the session with a target, creating it if it doesn't exist yet.
+func (p *prober) get(ctx context.Context, key probeKey) (*probeTarget, error) {
+	p.lock.Lock()
+	t, ok := p.targets[key]
+	if !ok {
+		t = &probeTarget{ready: make(chan struct{})}
+		p.targets[key] = t
+		go p.connect(key, t)
+	}
+	t.lastProbed = time.Now()
+	p.lock.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-t.ready:
+		return t, t.err
+	}
+}
+
+// connect establishes the session with a target. If it fails, the target is removed, so the next probe tries again.
+func (p *prober) connect(key probeKey, t *probeTarget) {
+	defer close(t.ready)
+	cfg := p.modules[key.module]
+	cfg.Server.URL = key.target
+	logger := p.logger.With("module", key.module, "target", key.target)
+	var ctx context.Context
+	ctx, t.cancel = context.WithCancel(context.Background())
+	t.session = lightstreamer.NewClientSession(append(cfg.Server.Options(), lightstreamer.WithLogger(logger))...)
+	if t.collector, t.err = collector.NewCollector(ctx, cfg, t.session, logger); t.err != nil {
+		t.cancel()
+		p.lock.Lock()
+		delete(p.targets, key)
+		p.lock.Unlock()
+		logger.Warn("failed to connect to probe target", "err", t.err)
+		return
+	}
+	logger.Info("connected to probe target")
+}
+
+// run closes the sessions of targets that haven't been probed for idleTimeout, until ctx is canceled. It then closes
+// all sessions.
+func (p *prober) run(ctx context.Context) {
+	ticker := time.NewTicker(p.idleTimeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			p.close(func(*probeTarget) bool { return true })
+			return
+		case now := <-ticker.C:
+			p.close(func(t *probeTarget) bool { return now.Sub(t.lastProbed) > p.idleTimeout })
+		}
+	}
+}
+
+// close closes the sessions of all established targets that match f.
+func (p *prober) close(f func(*probeTarget) bool) {
+	var closed []*probeTarget
+	p.lock.Lock()
+	for key, t := range p.targets {
+		select {
+		case <-t.ready:
+		default:
+			continue
+		}
+		if t.err == nil && f(t) {
+			delete(p.targets, key)
+			closed = append(closed, t)
+			p.logger.Debug("closing probe session", "module", key.module, "target", key.target)
+		}
+	}
+	p.lock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var errs []error
+	for _, t := range closed {
+		t.cancel()
+		errs = append(errs, t.session.Destroy(ctx))
+	}
+	if err := errors.Join(errs...); err != nil {
+		p.logger.Warn("failed to destroy probe sessions", "err", err)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"github.com/clambin/iss-exporter/internal/collector"
+	"github.com/clambin/iss-exporter/lightstreamer"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadProbeModules(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{name: "valid", content: `{"default": {"groups": [{"id": "A"}]}, "other": {"server": {"adapter_set": "OTHER"}, "groups": [{"id": "B"}]}}`},
+		{name: "empty", content: `{}`, wantErr: true},
+		{name: "invalid module", content: `{"default": {"groups": []}}`, wantErr: true},
+		{name: "invalid json", content: `{`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "modules.json")
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := loadProbeModules(path); (err != nil) != tt.wantErr {
+				t.Errorf("loadProbeModules() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProber(t *testing.T) {
+	s := lightstreamer.NewServer("FEED", "cid", map[string]lightstreamer.AdapterSet{
+		"DEFAULT": {"A": lightstreamer.InjectAdapter{Name: "A", Fields: 3}},
+	}, slog.New(slog.DiscardHandler))
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+
+	p := newProber(map[string]collector.Config{
+		"default": {Server: collector.ServerConfig{AdapterSet: "FEED", CID: "cid"}, Groups: []collector.GroupConfig{{ID: "A"}}},
+	}, time.Hour, slog.New(slog.DiscardHandler))
+	t.Cleanup(func() { p.close(func(*probeTarget) bool { return true }) })
+	probe := httptest.NewServer(p)
+	t.Cleanup(probe.Close)
+
+	tests := []struct {
+		name  string
+		query url.Values
+		want  int
+	}{
+		{name: "unknown module", query: url.Values{"module": {"foo"}, "target": {ts.URL}}, want: http.StatusBadRequest},
+		{name: "missing target", query: url.Values{}, want: http.StatusBadRequest},
+		{name: "invalid target", query: url.Values{"target": {"localhost:1234"}}, want: http.StatusBadRequest},
+		{name: "unreachable target", query: url.Values{"target": {"http://127.0.0.1:1"}}, want: http.StatusBadGateway},
+		{name: "valid", query: url.Values{"target": {ts.URL}}, want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Get(probe.URL + "/probe?" + tt.query.Encode())
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Errorf("got %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+
+	// the session is reused by later probes
+	s.Publish("FEED", "DEFAULT", "A", 1, lightstreamer.Values{valuePtr("42"), valuePtr("24"), valuePtr("0")})
+	start := time.Now()
+	for {
+		resp, err := http.Get(probe.URL + "/probe?target=" + url.QueryEscape(ts.URL))
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if strings.Contains(string(body), `iss_telemetry_metric{group="A"} 42`) {
+			break
+		}
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("probe does not report the update:\n%s", string(body))
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if got := len(s.Sessions()); got != 1 {
+		t.Errorf("got %d sessions, want 1", got)
+	}
+
+	// idle targets are closed
+	p.close(func(*probeTarget) bool { return true })
+	if got := len(p.targets); got != 0 {
+		t.Errorf("got %d targets, want 0", got)
+	}
+	if got := len(s.Sessions()); got != 0 {
+		t.Errorf("got %d sessions, want 0", got)
+	}
+}
+
+func valuePtr(s string) *lightstreamer.Value {
+	v := lightstreamer.Value(s)
+	return &v
+}
@@ -0,0 +1,4 @@
+// Package telemetryv1 contains the Go code generated from telemetry.proto, the telemetry API of iss-exporter.
+package telemetryv1
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative telemetry.proto
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"github.com/clambin/iss-exporter/internal/collector"
+	"github.com/clambin/iss-exporter/lightstreamer"
+	"github.com/parquet-go/parquet-go"
+	"github.com/prometheus/client_golang/prometheus"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// recorder is a collector.Subscriber that appends the updates it receives to files in a directory, for offline
+// analysis, or to build playback fixtures. A new file is started every rotate interval, or once per run if rotate is
+// zero. Files are named after the start of their interval, e.g. iss-20250102T150000Z.csv.
+//
+// CSV files are recordings in the lightstreamer.PlaybackCSV format: each line holds the time the update was received,
+// the item and its values, in the order of the subscription's schema. The item is the name of the item within its
+// group, i.e. the group ID for ISSLIVE's single-item groups. A comment line lists the schema of each subscription, e.g.
+//
+//	# USLAB000058: Value,Status.Class,TimeStamp
+//	2025-01-02T15:04:05.5Z,USLAB000058,760.1,24,2.5
+//
+// Parquet files hold one row per field of an update: its receipt time, group, item, field and value. Null values are
+// skipped. Parquet files are only readable once complete: on rotation, or on shutdown.
+//
+// In both formats, the server's timestamp is the TimeStamp field of the ISSLIVE schema.
+type recorder struct {
+	collector.Subscriber
+	dir           string
+	format        string
+	rotate        time.Duration
+	errors        prometheus.Counter
+	logger        *slog.Logger
+	lock          sync.Mutex
+	subscriptions []recordedSubscription
+	file          *os.File
+	w             recordWriter
+	period        time.Time
+	failed        bool
+	closed        bool
+}
+
+type recordedSubscription struct {
+	group  string
+	schema []string
+}
+
+// A recordWriter writes the updates of a file in one of the recording formats.
+type recordWriter interface {
+	// subscribed records the schema of a subscription.
+	subscribed(s recordedSubscription) error
+	write(received time.Time, group string, item string, schema []string, values lightstreamer.Values) error
+	// flush writes buffered updates to the file, if the format allows it.
+	flush() error
+	close() error
+}
+
+// recordingFormats are the supported formats, and their file extension.
+var recordingFormats = map[string]string{"csv": ".csv", "parquet": ".parquet"}
+
+// newRecorder returns a recorder of the subscriptions of subscriber, writing files in the given format (csv or
+// parquet) to dir. It creates dir if it doesn't exist.
+func newRecorder(subscriber collector.Subscriber, dir string, format string, rotate time.Duration, errors prometheus.Counter, logger *slog.Logger) (*recorder, error) {
+	if _, ok := recordingFormats[format]; !ok {
+		return nil, fmt.Errorf("%q: must be csv or parquet", format)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &recorder{Subscriber: subscriber, dir: dir, format: format, rotate: rotate, errors: errors, logger: logger}, nil
+}
+
+// Subscribe subscribes to a group, and records its updates.
+func (r *recorder) Subscribe(ctx context.Context, dataAdapter string, group string, schema []string, maxFrequency float64, f func(item int, values lightstreamer.Values)) error {
+	r.subscribed(recordedSubscription{group: group, schema: slices.Clone(schema)})
+	items := strings.Fields(group)
+	return r.Subscriber.Subscribe(ctx, dataAdapter, group, schema, maxFrequency, func(item int, values lightstreamer.Values) {
+		f(item, values)
+		itemName := strconv.Itoa(item)
+		if item > 0 && item <= len(items) {
+			itemName = items[item-1]
+		}
+		r.record(time.Now(), group, itemName, schema, values)
+	})
+}
+
+func (r *recorder) subscribed(s recordedSubscription) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if slices.ContainsFunc(r.subscriptions, func(known recordedSubscription) bool {
+		return known.group == s.group && slices.Equal(known.schema, s.schema)
+	}) {
+		return
+	}
+	r.subscriptions = append(r.subscriptions, s)
+	if r.w != nil {
+		r.check(r.w.subscribed(s))
+	}
+}
+
+func (r *recorder) record(received time.Time, group string, item string, schema []string, values lightstreamer.Values) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.closed {
+		return
+	}
+	if !r.check(r.rotateAt(received)) {
+		return
+	}
+	r.check(r.w.write(received, group, item, schema, values))
+}
+
+// rotateAt starts a new file if the current one doesn't cover the receipt time of an update.
+func (r *recorder) rotateAt(received time.Time) error {
+	period := received
+	if r.rotate > 0 {
+		period = received.Truncate(r.rotate)
+	}
+	if r.w != nil && (r.rotate <= 0 || period.Equal(r.period)) {
+		return nil
+	}
+	if r.w != nil {
+		r.check(r.closeFile())
+	}
+	if err := r.openFile(period); err != nil {
+		return err
+	}
+	r.period = period
+	for _, s := range r.subscriptions {
+		if err := r.w.subscribed(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// openFile creates the file of a period. If the file already exists, e.g. after a restart, it adds a sequence number
+// to its name, rather than overwrite it.
+func (r *recorder) openFile(period time.Time) error {
+	name := "iss-" + period.UTC().Format("20060102T150405Z")
+	ext := recordingFormats[r.format]
+	for i := 1; ; i++ {
+		path := filepath.Join(r.dir, name+ext)
+		if i > 1 {
+			path = filepath.Join(r.dir, name+"-"+strconv.Itoa(i)+ext)
+		}
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		w, err := newRecordWriter(f, r.format)
+		if err != nil {
+			_ = f.Close()
+			return err
+		}
+		r.file, r.w = f, w
+		r.logger.Debug("recording telemetry", "file", path)
+		return nil
+	}
+}
+
+func (r *recorder) closeFile() error {
+	if r.w == nil {
+		return nil
+	}
+	err := r.w.close()
+	if closeErr := r.file.Close(); err == nil {
+		err = closeErr
+	}
+	r.file, r.w = nil, nil
+	return err
+}
+
+// check counts and logs an error. It only logs the first of consecutive errors, so a full disk doesn't flood the log.
+func (r *recorder) check(err error) bool {
+	if err == nil {
+		r.failed = false
+		return true
+	}
+	r.errors.Inc()
+	if !r.failed {
+		r.logger.Warn("failed to record telemetry", "err", err)
+	}
+	r.failed = true
+	return false
+}
+
+// run flushes the recorded updates every interval, until ctx is canceled.
+func (r *recorder) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.lock.Lock()
+			if r.w != nil {
+				r.check(r.w.flush())
+			}
+			r.lock.Unlock()
+		}
+	}
+}
+
+// Close completes the current file. Later updates aren't recorded.
+func (r *recorder) Close() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.closed = true
+	return r.closeFile()
+}
+
+func newRecordWriter(f *os.File, format string) (recordWriter, error) {
+	if format == "parquet" {
+		return parquetRecordWriter{w: parquet.NewGenericWriter[parquetRecord](f)}, nil
+	}
+	b := bufio.NewWriter(f)
+	return csvRecordWriter{b: b, w: csv.NewWriter(b)}, nil
+}
+
+// csvRecordWriter writes recordings in the lightstreamer.PlaybackCSV format.
+type csvRecordWriter struct {
+	b *bufio.Writer
+	w *csv.Writer
+}
+
+func (w csvRecordWriter) subscribed(s recordedSubscription) error {
+	// comments aren't supported by csv.Writer: write them to the underlying buffer, after the pending records
+	w.w.Flush()
+	_, err := w.b.WriteString("# " + s.group + ": " + strings.Join(s.schema, ",") + "\n")
+	return err
+}
+
+func (w csvRecordWriter) write(received time.Time, _ string, item string, _ []string, values lightstreamer.Values) error {
+	record := make([]string, 0, 2+len(values))
+	record = append(record, received.UTC().Format(time.RFC3339Nano), item)
+	for _, value := range values {
+		if value != nil {
+			record = append(record, string(*value))
+		} else {
+			record = append(record, "")
+		}
+	}
+	return w.w.Write(record)
+}
+
+func (w csvRecordWriter) flush() error {
+	w.w.Flush()
+	if err := w.w.Error(); err != nil {
+		return err
+	}
+	return w.b.Flush()
+}
+
+func (w csvRecordWriter) close() error {
+	return w.flush()
+}
+
+// parquetRecord is a row of a Parquet recording: one field of an update.
+type parquetRecord struct {
+	Received time.Time `parquet:"received,timestamp(microsecond)"`
+	Group    string    `parquet:"group"`
+	Item     string    `parquet:"item"`
+	Field    string    `parquet:"field"`
+	Value    string    `parquet:"value"`
+}
+
+// parquetRecordWriter writes recordings as Parquet files, with one row per field of an update.
+type parquetRecordWriter struct {
+	w *parquet.GenericWriter[parquetRecord]
+}
+
+func (w parquetRecordWriter) subscribed(recordedSubscription) error {
+	return nil
+}
+
+func (w parquetRecordWriter) write(received time.Time, group string, item string, schema []string, values lightstreamer.Values) error {
+	records := make([]parquetRecord, 0, len(values))
+	for i, value := range values {
+		if value != nil && i < len(schema) {
+			records = append(records, parquetRecord{Received: received, Group: group, Item: item, Field: schema[i], Value: string(*value)})
+		}
+	}
+	_, err := w.w.Write(records)
+	return err
+}
+
+// flush doesn't write the buffered rows: they're written in row groups as large as possible.
+func (w parquetRecordWriter) flush() error {
+	return nil
+}
+
+func (w parquetRecordWriter) close() error {
+	return w.w.Close()
+}
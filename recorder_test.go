@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"github.com/clambin/iss-exporter/lightstreamer"
+	"github.com/parquet-go/parquet-go"
+	"github.com/prometheus/client_golang/prometheus"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRecorder_CSV(t *testing.T) {
+	up := upstream{subscriptions: make(map[string]func(int, lightstreamer.Values))}
+	dir := t.TempDir()
+	r, err := newRecorder(&up, dir, "csv", time.Hour, prometheus.NewCounter(prometheus.CounterOpts{Name: "errors"}), slog.New(slog.DiscardHandler))
+	if err != nil {
+		t.Fatalf("newRecorder: %v", err)
+	}
+	var received []string
+	for _, group := range []string{"USLAB000058", "USLAB000032 USLAB000033"} {
+		if err = r.Subscribe(t.Context(), "DEFAULT", group, []string{"Value", "TimeStamp"}, 0, func(_ int, values lightstreamer.Values) {
+			received = append(received, values.String())
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// updates are passed on, and recorded with the name of their item
+	up.subscriptions["USLAB000058"](1, lightstreamer.Values{valuePtr("760"), valuePtr("2.5")})
+	up.subscriptions["USLAB000032 USLAB000033"](2, lightstreamer.Values{valuePtr("-1234.5"), nil})
+	if len(received) != 2 {
+		t.Errorf("got %d updates, want 2", len(received))
+	}
+	// a later update, in the next hour, starts a new file
+	start := time.Now().Truncate(time.Hour)
+	r.record(start.Add(time.Hour+time.Second), "USLAB000058", "USLAB000058", []string{"Value", "TimeStamp"}, lightstreamer.Values{valuePtr("761"), valuePtr("3.5")})
+	if err = r.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	r.record(start.Add(2*time.Hour), "USLAB000058", "USLAB000058", []string{"Value", "TimeStamp"}, lightstreamer.Values{valuePtr("762"), valuePtr("4.5")})
+
+	first, err := os.ReadFile(filepath.Join(dir, "iss-"+start.UTC().Format("20060102T150405Z")+".csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantHeader := "# USLAB000058: Value,TimeStamp\n# USLAB000032 USLAB000033: Value,TimeStamp\n"
+	if !bytes.HasPrefix(first, []byte(wantHeader)) || !bytes.Contains(first, []byte(",USLAB000058,760,2.5\n")) || !bytes.Contains(first, []byte(",USLAB000033,-1234.5,\n")) {
+		t.Errorf("unexpected recording:\n%s", first)
+	}
+	// the recording can be played back
+	a, err := lightstreamer.NewPlaybackAdapter("USLAB000058", bytes.NewReader(first), lightstreamer.PlaybackCSV)
+	if err != nil {
+		t.Errorf("NewPlaybackAdapter: %v", err)
+	} else if a == nil {
+		t.Error("no playback adapter")
+	}
+
+	second, err := os.ReadFile(filepath.Join(dir, "iss-"+start.Add(time.Hour).UTC().Format("20060102T150405Z")+".csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// updates after Close aren't recorded
+	want := wantHeader + start.Add(time.Hour+time.Second).UTC().Format(time.RFC3339Nano) + ",USLAB000058,761,3.5\n"
+	if string(second) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", second, want)
+	}
+}
+
+func TestRecorder_Parquet(t *testing.T) {
+	up := upstream{subscriptions: make(map[string]func(int, lightstreamer.Values))}
+	dir := t.TempDir()
+	r, err := newRecorder(&up, dir, "parquet", 0, prometheus.NewCounter(prometheus.CounterOpts{Name: "errors"}), slog.New(slog.DiscardHandler))
+	if err != nil {
+		t.Fatalf("newRecorder: %v", err)
+	}
+	// an existing file isn't overwritten
+	start := time.Date(2025, 1, 2, 15, 4, 5, 0, time.UTC)
+	existing := filepath.Join(dir, "iss-20250102T150405Z.parquet")
+	if err = os.WriteFile(existing, []byte("existing"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err = r.Subscribe(t.Context(), "DEFAULT", "USLAB000058", []string{"Value"}, 0, func(int, lightstreamer.Values) {}); err != nil {
+		t.Fatal(err)
+	}
+	r.record(start, "USLAB000058", "USLAB000058", []string{"Value"}, lightstreamer.Values{valuePtr("760")})
+	// without rotation, all updates go to the same file
+	r.record(start.Add(24*time.Hour), "USLAB000058", "USLAB000058", []string{"Value"}, lightstreamer.Values{valuePtr("761")})
+	if err = r.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	if content, _ := os.ReadFile(existing); string(content) != "existing" {
+		t.Errorf("existing file was overwritten")
+	}
+	records, err := parquet.ReadFile[parquetRecord](filepath.Join(dir, "iss-20250102T150405Z-2.parquet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []parquetRecord{
+		{Received: start, Group: "USLAB000058", Item: "USLAB000058", Field: "Value", Value: "760"},
+		{Received: start.Add(24 * time.Hour), Group: "USLAB000058", Item: "USLAB000058", Field: "Value", Value: "761"},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("got %d records, want %d", len(records), len(want))
+	}
+	for i := range want {
+		if !records[i].Received.Equal(want[i].Received) || records[i].Group != want[i].Group || records[i].Item != want[i].Item ||
+			records[i].Field != want[i].Field || records[i].Value != want[i].Value {
+			t.Errorf("record %d: got %+v, want %+v", i, records[i], want[i])
+		}
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 2 {
+		t.Errorf("got %d files, want 2", len(entries))
+	}
+}
+
+func TestNewRecorder_InvalidFormat(t *testing.T) {
+	if _, err := newRecorder(nil, t.TempDir(), "xml", time.Hour, nil, slog.New(slog.DiscardHandler)); err == nil {
+		t.Error("expected an error")
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"github.com/clambin/iss-exporter/internal/collector"
+	"github.com/clambin/iss-exporter/lightstreamer"
+	"log/slog"
+	"sync"
+)
+
+// relay is a collector.Subscriber that re-publishes the updates it receives to the clients of a Lightstreamer server.
+// Downstream clients can then subscribe to the exporter, rather than to the upstream server, e.g. to share a single
+// upstream session, or to stay connected while the upstream connection is lost.
+//
+// Downstream clients connect to the same adapter set, and subscribe to the same data adapter, groups and schema, as
+// the exporter. New subscriptions receive the last update of their group right away.
+type relay struct {
+	collector.Subscriber
+	server     *lightstreamer.Server
+	adapterSet string
+	lock       sync.Mutex
+	adapters   map[relayKey]*relayAdapter
+}
+
+type relayKey struct {
+	dataAdapter string
+	group       string
+}
+
+// newRelay returns a relay for the subscriptions of subscriber, to the server configured by cfg. Downstream clients
+// must use the server's client ID, or the ISSLIVE one if cfg doesn't configure one.
+func newRelay(subscriber collector.Subscriber, cfg collector.ServerConfig, logger *slog.Logger) *relay {
+	adapterSet := cmp.Or(cfg.AdapterSet, "ISSLIVE")
+	return &relay{
+		Subscriber: subscriber,
+		server:     lightstreamer.NewServer(adapterSet, cmp.Or(cfg.CID, lightstreamer.DefaultCID), nil, logger),
+		adapterSet: adapterSet,
+		adapters:   make(map[relayKey]*relayAdapter),
+	}
+}
+
+// Subscribe subscribes to a group, and serves the group to downstream clients.
+func (r *relay) Subscribe(ctx context.Context, dataAdapter string, group string, schema []string, maxFrequency float64, f func(item int, values lightstreamer.Values)) error {
+	a := r.adapter(dataAdapter, group, len(schema))
+	return r.Subscriber.Subscribe(ctx, dataAdapter, group, schema, maxFrequency, func(item int, values lightstreamer.Values) {
+		f(item, values)
+		a.update(item, values)
+		r.server.Publish(r.adapterSet, dataAdapter, group, item, values)
+	})
+}
+
+// adapter returns the adapter serving a group to downstream clients, registering it if it's new.
+func (r *relay) adapter(dataAdapter string, group string, fields int) *relayAdapter {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	key := relayKey{dataAdapter: dataAdapter, group: group}
+	a, ok := r.adapters[key]
+	if !ok {
+		a = &relayAdapter{InjectAdapter: lightstreamer.InjectAdapter{Name: group, Fields: fields}, last: make(map[int]lightstreamer.Values)}
+		r.adapters[key] = a
+		r.server.RegisterAdapter(r.adapterSet, dataAdapter, group, a)
+	}
+	return a
+}
+
+// relayAdapter serves a relayed group. It keeps the last update of each item, as the snapshot for new subscriptions.
+type relayAdapter struct {
+	lightstreamer.InjectAdapter
+	lock sync.Mutex
+	last map[int]lightstreamer.Values
+}
+
+var _ lightstreamer.SnapshotAdapter = &relayAdapter{}
+
+func (a *relayAdapter) update(item int, values lightstreamer.Values) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.last[item] = values.Clone()
+}
+
+func (a *relayAdapter) Snapshot() []lightstreamer.AdapterUpdate {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	updates := make([]lightstreamer.AdapterUpdate, 0, len(a.last))
+	for item, values := range a.last {
+		updates = append(updates, lightstreamer.AdapterUpdate{Item: item, Values: values.Clone()})
+	}
+	return updates
+}
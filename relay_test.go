@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"github.com/clambin/iss-exporter/internal/collector"
+	"github.com/clambin/iss-exporter/lightstreamer"
+	"log/slog"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// upstream is a collector.Subscriber that records the callback of each subscription.
+type upstream struct {
+	collector.Subscriber
+	subscriptions map[string]func(int, lightstreamer.Values)
+}
+
+func (u *upstream) Subscribe(_ context.Context, _ string, group string, _ []string, _ float64, f func(int, lightstreamer.Values)) error {
+	u.subscriptions[group] = f
+	return nil
+}
+
+func TestRelay(t *testing.T) {
+	up := upstream{subscriptions: make(map[string]func(int, lightstreamer.Values))}
+	l := slog.New(slog.DiscardHandler)
+	r := newRelay(&up, collector.ServerConfig{}, l)
+	ts := httptest.NewServer(r.server)
+	t.Cleanup(ts.Close)
+
+	received := make(chan string, 10)
+	if err := r.Subscribe(t.Context(), "DEFAULT", "USLAB000058", []string{"Value"}, 0, func(_ int, values lightstreamer.Values) {
+		received <- "exporter:" + values.String()
+	}); err != nil {
+		t.Fatal(err)
+	}
+	up.subscriptions["USLAB000058"](1, lightstreamer.Values{valuePtr("760")})
+	if got := <-received; got != "exporter:760" {
+		t.Errorf("got %q, want exporter:760", got)
+	}
+
+	// a downstream client receives the last update, and later ones
+	c := lightstreamer.NewClientSession(lightstreamer.WithLogger(l), lightstreamer.WithServerURL(ts.URL), lightstreamer.WithAdapterSet("ISSLIVE"))
+	if err := c.ConnectWithSession(t.Context(), time.Second); err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	t.Cleanup(c.Disconnect)
+	if err := c.Subscribe(t.Context(), "DEFAULT", "USLAB000058", []string{"Value"}, 0, func(_ int, values lightstreamer.Values) {
+		received <- "downstream:" + values.String()
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []string{"downstream:760", "exporter:761", "downstream:761"} {
+		if i == 1 {
+			up.subscriptions["USLAB000058"](1, lightstreamer.Values{valuePtr("761")})
+		}
+		select {
+		case got := <-received:
+			if got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timeout waiting for %s", want)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"github.com/clambin/iss-exporter/internal/collector"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// A reloadable applies a new configuration. collector.Collector implements reloadable.
+type reloadable interface {
+	Reload(ctx context.Context, cfg collector.Config) error
+}
+
+// reloader reloads the configuration on SIGHUP or on a POST to its handler.
+type reloader struct {
+	// load returns the configuration, as configured by the configuration file and the flags.
+	load   func() (collector.Config, error)
+	target reloadable
+	// level is the level of the logger. If the configuration doesn't set a log level, defaultLevel is used.
+	level        *slog.LevelVar
+	defaultLevel slog.Level
+	logger       *slog.Logger
+	lock         sync.Mutex
+}
+
+// reload loads the configuration and applies it. If the configuration is invalid, the current one is kept.
+func (r *reloader) reload(ctx context.Context) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	cfg, err := r.load()
+	if err != nil {
+		return err
+	}
+	if err = r.target.Reload(ctx, cfg); err != nil {
+		return err
+	}
+	level, ok := cfg.Level()
+	if !ok {
+		level = r.defaultLevel
+	}
+	r.level.Set(level)
+	return nil
+}
+
+// run reloads the configuration each time a signal is received on ch, until ctx is canceled.
+func (r *reloader) run(ctx context.Context, ch <-chan os.Signal) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ch:
+		}
+		if err := r.reload(ctx); err != nil {
+			r.logger.Error("failed to reload configuration", "err", err)
+			continue
+		}
+		r.logger.Info("configuration reloaded")
+	}
+}
+
+// ServeHTTP reloads the configuration on a POST request.
+func (r *reloader) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.reload(req.Context()); err != nil {
+		r.logger.Error("failed to reload configuration", "err", err)
+		http.Error(w, "failed to reload configuration: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	r.logger.Info("configuration reloaded")
+	w.WriteHeader(http.StatusOK)
+}
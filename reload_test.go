@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"github.com/clambin/iss-exporter/internal/collector"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+type fakeReloadable struct {
+	reloads chan collector.Config
+	err     error
+}
+
+func (f *fakeReloadable) Reload(_ context.Context, cfg collector.Config) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.reloads <- cfg
+	return nil
+}
+
+func TestReloader_ServeHTTP(t *testing.T) {
+	tests := []struct {
+		name      string
+		method    string
+		cfg       collector.Config
+		loadErr   error
+		reloadErr error
+		want      int
+		wantLevel slog.Level
+	}{
+		{name: "reload", method: http.MethodPost, want: http.StatusOK, wantLevel: slog.LevelWarn},
+		{name: "log level", method: http.MethodPost, cfg: collector.Config{LogLevel: "debug"}, want: http.StatusOK, wantLevel: slog.LevelDebug},
+		{name: "invalid configuration", method: http.MethodPost, loadErr: errors.New("invalid"), want: http.StatusInternalServerError, wantLevel: slog.LevelError},
+		{name: "reload failed", method: http.MethodPost, cfg: collector.Config{LogLevel: "debug"}, reloadErr: errors.New("failed"), want: http.StatusInternalServerError, wantLevel: slog.LevelError},
+		{name: "get", method: http.MethodGet, want: http.StatusMethodNotAllowed, wantLevel: slog.LevelError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var level slog.LevelVar
+			level.Set(slog.LevelError)
+			target := fakeReloadable{reloads: make(chan collector.Config, 1), err: tt.reloadErr}
+			r := reloader{
+				load:         func() (collector.Config, error) { return tt.cfg, tt.loadErr },
+				target:       &target,
+				level:        &level,
+				defaultLevel: slog.LevelWarn,
+				logger:       slog.New(slog.DiscardHandler),
+			}
+			resp := httptest.NewRecorder()
+			r.ServeHTTP(resp, httptest.NewRequest(tt.method, "/-/reload", nil))
+			if resp.Code != tt.want {
+				t.Errorf("got %d, want %d", resp.Code, tt.want)
+			}
+			if got := level.Level(); got != tt.wantLevel {
+				t.Errorf("got level %v, want %v", got, tt.wantLevel)
+			}
+		})
+	}
+}
+
+func TestReloader_run(t *testing.T) {
+	target := fakeReloadable{reloads: make(chan collector.Config, 1)}
+	r := reloader{
+		load:   func() (collector.Config, error) { return collector.Config{MaxFrequency: 1}, nil },
+		target: &target,
+		level:  new(slog.LevelVar),
+		logger: slog.New(slog.DiscardHandler),
+	}
+	ch := make(chan os.Signal, 1)
+	go r.run(t.Context(), ch)
+	ch <- syscall.SIGHUP
+	select {
+	case cfg := <-target.reloads:
+		if cfg.MaxFrequency != 1 {
+			t.Errorf("unexpected configuration: %+v", cfg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for reload")
+	}
+}
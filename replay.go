@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"github.com/clambin/iss-exporter/internal/util"
+	"github.com/clambin/iss-exporter/lightstreamer"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// openReplay opens a recording to replay instead of the live feed. A recording made by -debug.record is replayed by
+// the returned http.RoundTripper, through the exporter's Lightstreamer session. A CSV recording, made by -record.dir,
+// is replayed by the returned replayer, which replaces the session.
+func openReplay(path string, speed float64, loop bool) (http.RoundTripper, *replayer, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(content), []byte("{")) {
+		if loop {
+			return nil, nil, errors.New("recordings of -debug.record can't be looped")
+		}
+		rt, err := util.ReadRecording(bytes.NewReader(content))
+		if err != nil {
+			return nil, nil, err
+		}
+		rt.Speed = speed
+		return rt, nil, nil
+	}
+	r, err := newReplayer(bytes.NewReader(content))
+	if err != nil {
+		return nil, nil, err
+	}
+	r.speed, r.loop = speed, loop
+	return nil, r, nil
+}
+
+// replayer is a collector.Subscriber that replays a CSV recording made by a recorder, rather than subscribing to a
+// Lightstreamer server, so dashboards and alerts can be developed against realistic data, e.g. during loss of signal.
+//
+// Updates are replayed on the schedule of the recording, scaled by speed, to the subscriptions of their item. Fields
+// are matched by name, using the schemas recorded for the subscriptions. The TimeStamp field of the ISSLIVE schema is
+// set to the time of the replay, so the readings look current.
+type replayer struct {
+	records       []replayRecord
+	speed         float64
+	loop          bool
+	lock          sync.Mutex
+	subscriptions map[string][]replaySubscription
+	started       time.Time
+}
+
+type replayRecord struct {
+	offset time.Duration
+	item   string
+	// fields are the names of the values, or nil if the recording doesn't have the item's schema
+	fields []string
+	values lightstreamer.Values
+}
+
+type replaySubscription struct {
+	item   int
+	schema []string
+	f      func(int, lightstreamer.Values)
+}
+
+// newReplayer returns a replayer of the recording read from r.
+func newReplayer(r io.Reader) (*replayer, error) {
+	// the schemas of the items' subscriptions
+	schemas := make(map[string][][]string)
+	var records []replayRecord
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if comment, ok := strings.CutPrefix(line, "#"); ok {
+			// # <group>: <schema>
+			if group, schema, ok := strings.Cut(comment, ":"); ok {
+				fields := strings.Split(strings.TrimSpace(schema), ",")
+				for _, item := range strings.Fields(group) {
+					schemas[item] = append(schemas[item], fields)
+				}
+			}
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		values, err := csv.NewReader(strings.NewReader(line)).Read()
+		if err != nil || len(values) < 3 {
+			return nil, fmt.Errorf("line %d: invalid record", n)
+		}
+		offset, err := parseReplayTime(values[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		record := replayRecord{offset: offset, item: values[1], values: make(lightstreamer.Values, len(values)-2)}
+		for i, value := range values[2:] {
+			v := lightstreamer.Value(value)
+			record.values[i] = &v
+		}
+		// an item may be recorded for several subscriptions: use the last schema with the same number of fields
+		for _, fields := range slices.Backward(schemas[record.item]) {
+			if len(fields) == len(record.values) {
+				record.fields = fields
+				break
+			}
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, errors.New("recording contains no updates")
+	}
+	start := records[0].offset
+	for i := range records {
+		records[i].offset -= start
+	}
+	return &replayer{records: records, subscriptions: make(map[string][]replaySubscription)}, nil
+}
+
+// parseReplayTime parses the time of a recorded update, either as an RFC 3339 timestamp or as a number of seconds, as
+// lightstreamer.PlaybackCSV does, and returns it as an offset.
+func parseReplayTime(value string) (time.Duration, error) {
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", value)
+	}
+	return time.Duration(t.UnixNano()), nil
+}
+
+// ConnectWithSession starts a new session, without subscriptions.
+func (r *replayer) ConnectWithSession(context.Context, time.Duration) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	clear(r.subscriptions)
+	r.started = time.Now()
+	return nil
+}
+
+// Subscribe subscribes to the recorded updates of the items of a group.
+func (r *replayer) Subscribe(_ context.Context, _ string, group string, schema []string, _ float64, f func(item int, values lightstreamer.Values)) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for i, item := range strings.Fields(group) {
+		r.subscriptions[item] = append(r.subscriptions[item], replaySubscription{item: i + 1, schema: slices.Clone(schema), f: f})
+	}
+	return nil
+}
+
+// State reports a connected session, even once the recording has been replayed.
+func (r *replayer) State() lightstreamer.SessionState {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	var subscriptions int
+	for _, s := range r.subscriptions {
+		subscriptions += len(s)
+	}
+	return lightstreamer.SessionState{Connections: 1, SessionID: "replay", Started: r.started, Subscriptions: subscriptions}
+}
+
+// Done returns a nil channel: like State reports, a replayed session never ends.
+func (r *replayer) Done() <-chan struct{} {
+	return nil
+}
+
+// run replays the recording until ctx is canceled or, unless loop is set, until all updates have been replayed.
+func (r *replayer) run(ctx context.Context) {
+	speed := r.speed
+	if speed <= 0 {
+		speed = 1
+	}
+	for {
+		start := time.Now()
+		for _, record := range r.records {
+			if !sleep(ctx, time.Until(start.Add(time.Duration(float64(record.offset)/speed)))) {
+				return
+			}
+			r.publish(record, time.Now())
+		}
+		// don't spin on a recording that takes no time
+		if !r.loop || !sleep(ctx, time.Until(start.Add(time.Second))) {
+			return
+		}
+	}
+}
+
+// sleep waits for d, and returns true, or returns false if ctx is canceled first.
+func sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+// publish sends a recorded update to the subscriptions of its item, in the order of their schema.
+func (r *replayer) publish(record replayRecord, now time.Time) {
+	r.lock.Lock()
+	subscriptions := slices.Clone(r.subscriptions[record.item])
+	r.lock.Unlock()
+	for _, s := range subscriptions {
+		values := record.values.Clone()
+		if record.fields != nil {
+			values = make(lightstreamer.Values, len(s.schema))
+			for i, field := range s.schema {
+				if j := slices.Index(record.fields, field); j >= 0 {
+					values[i] = record.values[j]
+				}
+			}
+		}
+		if i := slices.Index(s.schema, "TimeStamp"); i >= 0 && i < len(values) {
+			values[i] = replayTimeStamp(now)
+		}
+		s.f(s.item, values)
+	}
+}
+
+// replayTimeStamp returns a time as an ISSLIVE TimeStamp: the number of hours since the start of the year, in UTC.
+func replayTimeStamp(t time.Time) *lightstreamer.Value {
+	t = t.UTC()
+	hours := t.Sub(time.Date(t.Year(), time.January, 1, 0, 0, 0, 0, time.UTC)).Hours()
+	v := lightstreamer.Value(strconv.FormatFloat(hours, 'f', 6, 64))
+	return &v
+}
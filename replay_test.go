@@ -0,0 +1,110 @@
+package main
+
+import (
+	"github.com/clambin/iss-exporter/internal/util"
+	"github.com/clambin/iss-exporter/lightstreamer"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const replayRecording = `# USLAB000058: Value,Status.Class,TimeStamp
+# USLAB000032 USLAB000033: Value
+2025-01-02T15:04:05Z,USLAB000058,760,24,2.5
+2025-01-02T15:04:05.5Z,USLAB000033,-1234.5
+2025-01-02T15:04:06Z,USLAB000058,761,24,2.6
+`
+
+func TestReplayer(t *testing.T) {
+	r, err := newReplayer(strings.NewReader(replayRecording))
+	if err != nil {
+		t.Fatalf("newReplayer: %v", err)
+	}
+	r.speed = 100
+
+	if err = r.ConnectWithSession(t.Context(), time.Second); err != nil {
+		t.Fatalf("ConnectWithSession: %v", err)
+	}
+	received := make(chan string, 10)
+	// fields are matched by name
+	if err = r.Subscribe(t.Context(), "DEFAULT", "USLAB000058", []string{"TimeStamp", "Value", "Unknown"}, 0, func(item int, values lightstreamer.Values) {
+		if values[0] == nil || values[2] != nil {
+			t.Errorf("unexpected values: %v", values)
+		}
+		// the timestamp is the time of the replay
+		if hours, err := strconv.ParseFloat(string(*values[0]), 64); err != nil || time.Since(fromTimeStampHours(hours)).Abs() > time.Minute {
+			t.Errorf("unexpected timestamp: %v", values[0])
+		}
+		received <- strconv.Itoa(item) + ":" + string(*values[1])
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err = r.Subscribe(t.Context(), "DEFAULT", "USLAB000032 USLAB000033 USLAB000034", []string{"Value"}, 0, func(item int, values lightstreamer.Values) {
+		received <- strconv.Itoa(item) + ":" + values.String()
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if state := r.State(); state.Connections != 1 || state.Subscriptions != 4 {
+		t.Errorf("unexpected state: %+v", state)
+	}
+
+	start := time.Now()
+	r.run(t.Context())
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("replay took %v", elapsed)
+	}
+	close(received)
+	var got []string
+	for update := range received {
+		got = append(got, update)
+	}
+	if want := "1:760 2:-1234.5 1:761"; strings.Join(got, " ") != want {
+		t.Errorf("got %q, want %q", strings.Join(got, " "), want)
+	}
+}
+
+// fromTimeStampHours converts an ISSLIVE TimeStamp of the current year to a time.
+func fromTimeStampHours(hours float64) time.Time {
+	return time.Date(time.Now().UTC().Year(), time.January, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(hours * float64(time.Hour)))
+}
+
+func TestReplayer_Invalid(t *testing.T) {
+	for _, recording := range []string{"", "# comment only\n", "yesterday,USLAB000058,760\n", "2025-01-02T15:04:05Z,USLAB000058\n"} {
+		if _, err := newReplayer(strings.NewReader(recording)); err == nil {
+			t.Errorf("%q: expected an error", recording)
+		}
+	}
+}
+
+func TestOpenReplay(t *testing.T) {
+	dir := t.TempDir()
+	csvPath := filepath.Join(dir, "recording.csv")
+	if err := os.WriteFile(csvPath, []byte(replayRecording), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rt, r, err := openReplay(csvPath, 2, true)
+	if err != nil || rt != nil || r == nil || r.speed != 2 || !r.loop {
+		t.Errorf("csv: unexpected replay: %v, %v, %v", rt, r, err)
+	}
+
+	jsonPath := filepath.Join(dir, "recording.json")
+	if err = os.WriteFile(jsonPath, []byte(`{"time":"2025-01-02T15:04:05Z","type":"request","id":1,"method":"POST","url":"http://localhost/lightstreamer/create_session.txt"}`+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rt, r, err = openReplay(jsonPath, 2, false)
+	if err != nil || r != nil {
+		t.Fatalf("debug recording: unexpected replay: %v, %v", r, err)
+	}
+	if replay, ok := rt.(*util.ReplayRoundTripper); !ok || replay.Speed != 2 {
+		t.Errorf("debug recording: unexpected round tripper: %v", rt)
+	}
+	if _, _, err = openReplay(jsonPath, 1, true); err == nil {
+		t.Error("debug recording: loop should fail")
+	}
+	if _, _, err = openReplay(filepath.Join(dir, "missing.csv"), 1, false); err == nil {
+		t.Error("missing recording: expected an error")
+	}
+}
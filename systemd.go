@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"strconv"
+	"time"
+)
+
+// sdNotify sends a state (e.g. "READY=1") to systemd's notification socket, as sd_notify(3) does. If socket is
+// empty (i.e. NOTIFY_SOCKET isn't set: the exporter isn't run by systemd), sdNotify does nothing.
+func sdNotify(socket string, state string) error {
+	if socket == "" {
+		return nil
+	}
+	// a leading @ denotes a socket in the abstract namespace
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer func() { _ = conn.Close() }()
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// watchdogInterval returns the interval at which the systemd watchdog expects a keep-alive, as sd_watchdog_enabled(3)
+// does: half the watchdog timeout. It returns false if the watchdog isn't enabled for this process.
+func watchdogInterval(lookup func(string) (string, bool), pid int) (time.Duration, bool) {
+	usec, ok := lookup("WATCHDOG_USEC")
+	if !ok {
+		return 0, false
+	}
+	timeout, err := strconv.ParseInt(usec, 10, 64)
+	if err != nil || timeout <= 0 {
+		return 0, false
+	}
+	if watchdogPID, ok := lookup("WATCHDOG_PID"); ok && watchdogPID != strconv.Itoa(pid) {
+		return 0, false
+	}
+	return time.Duration(timeout) * time.Microsecond / 2, true
+}
+
+// runWatchdog sends a keep-alive to the systemd watchdog every interval, as long as healthy returns true, until ctx is
+// canceled. If healthy returns false, e.g. because the stream froze, the keep-alives stop and systemd restarts the
+// exporter once the watchdog times out.
+func runWatchdog(ctx context.Context, socket string, interval time.Duration, healthy func() bool, logger *slog.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if !healthy() {
+			logger.Warn("exporter unhealthy. not notifying the systemd watchdog")
+			continue
+		}
+		if err := sdNotify(socket, "WATCHDOG=1"); err != nil {
+			logger.Warn("failed to notify the systemd watchdog", "err", err)
+		}
+	}
+}
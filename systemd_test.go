@@ -0,0 +1,87 @@
+package main
+
+import (
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// listenNotify returns a socket that receives systemd notifications.
+func listenNotify(t *testing.T) (string, *net.UnixConn) {
+	t.Helper()
+	// use a short path: unix socket paths are limited to about 100 characters
+	dir, err := os.MkdirTemp("", "sd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	socket := filepath.Join(dir, "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return socket, conn
+}
+
+func receive(t *testing.T, conn *net.UnixConn) string {
+	t.Helper()
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, 64)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(buf[:n])
+}
+
+func TestSdNotify(t *testing.T) {
+	if err := sdNotify("", "READY=1"); err != nil {
+		t.Errorf("no socket: unexpected error: %v", err)
+	}
+	socket, conn := listenNotify(t)
+	if err := sdNotify(socket, "READY=1"); err != nil {
+		t.Fatal(err)
+	}
+	if got := receive(t, conn); got != "READY=1" {
+		t.Errorf("got %q, want READY=1", got)
+	}
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	tests := []struct {
+		name   string
+		env    map[string]string
+		want   time.Duration
+		wantOK bool
+	}{
+		{name: "disabled"},
+		{name: "enabled", env: map[string]string{"WATCHDOG_USEC": "30000000"}, want: 15 * time.Second, wantOK: true},
+		{name: "this process", env: map[string]string{"WATCHDOG_USEC": "30000000", "WATCHDOG_PID": "42"}, want: 15 * time.Second, wantOK: true},
+		{name: "other process", env: map[string]string{"WATCHDOG_USEC": "30000000", "WATCHDOG_PID": "1"}},
+		{name: "invalid", env: map[string]string{"WATCHDOG_USEC": "soon"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lookup := func(name string) (string, bool) {
+				value, ok := tt.env[name]
+				return value, ok
+			}
+			got, ok := watchdogInterval(lookup, 42)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("got (%v, %v), want (%v, %v)", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestRunWatchdog(t *testing.T) {
+	socket, conn := listenNotify(t)
+	go runWatchdog(t.Context(), socket, 10*time.Millisecond, func() bool { return true }, slog.New(slog.DiscardHandler))
+	if got := receive(t, conn); got != "WATCHDOG=1" {
+		t.Errorf("got %q, want WATCHDOG=1", got)
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"runtime"
+	runtimedebug "runtime/debug"
+)
+
+// revision returns the VCS revision the binary was built from, or "unknown" if it wasn't built from a VCS checkout.
+func revision() string {
+	if info, ok := runtimedebug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				return setting.Value
+			}
+		}
+	}
+	return "unknown"
+}
+
+// newBuildInfo returns the iss_build_info metric, which is always 1 and reports the version of the exporter as labels.
+func newBuildInfo(version string) prometheus.Gauge {
+	g := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "iss_build_info",
+		Help: "Build information of the exporter",
+		ConstLabels: prometheus.Labels{
+			"version":   version,
+			"revision":  revision(),
+			"goversion": runtime.Version(),
+		},
+	})
+	g.Set(1)
+	return g
+}
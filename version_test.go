@@ -0,0 +1,24 @@
+package main
+
+import (
+	dto "github.com/prometheus/client_model/go"
+	"runtime"
+	"testing"
+)
+
+func TestNewBuildInfo(t *testing.T) {
+	var m dto.Metric
+	if err := newBuildInfo("v1.2.3").Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.GetGauge().GetValue(); got != 1 {
+		t.Errorf("got %v, want 1", got)
+	}
+	labels := make(map[string]string)
+	for _, label := range m.GetLabel() {
+		labels[label.GetName()] = label.GetValue()
+	}
+	if labels["version"] != "v1.2.3" || labels["goversion"] != runtime.Version() || labels["revision"] == "" {
+		t.Errorf("unexpected labels: %v", labels)
+	}
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	"html/template"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// webConfig configures how the HTTP endpoints are served.
+type webConfig struct {
+	// certFile and keyFile serve the endpoints over TLS. If empty, the endpoints are served over plain HTTP.
+	certFile string
+	keyFile  string
+	// username and password require HTTP basic authentication. If username is empty, no authentication is required.
+	username string
+	password string
+	// minVersion and maxVersion limit the accepted TLS versions, and cipherSuites the accepted TLS 1.0-1.2 cipher
+	// suites. Zero values use the crypto/tls defaults.
+	minVersion   uint16
+	maxVersion   uint16
+	cipherSuites []uint16
+	// http1Only disables HTTP/2.
+	http1Only bool
+	// headers are added to all responses, e.g. Strict-Transport-Security.
+	headers map[string]string
+}
+
+func (w webConfig) validate() error {
+	if (w.certFile == "") != (w.keyFile == "") {
+		return errors.New("TLS needs both a certificate and a key")
+	}
+	if w.certFile == "" && (w.minVersion != 0 || w.maxVersion != 0 || len(w.cipherSuites) > 0) {
+		return errors.New("TLS settings need a certificate and a key")
+	}
+	if w.minVersion != 0 && w.maxVersion != 0 && w.minVersion > w.maxVersion {
+		return errors.New("TLS min_version is higher than max_version")
+	}
+	if w.username != "" && w.password == "" {
+		return errors.New("basic auth needs a password")
+	}
+	return nil
+}
+
+// webConfigFile is the web configuration file. It follows the layout of the Prometheus exporter-toolkit's web
+// configuration, in JSON, for the settings the exporter supports.
+type webConfigFile struct {
+	TLSServerConfig struct {
+		CertFile string `json:"cert_file"`
+		KeyFile  string `json:"key_file"`
+		// MinVersion and MaxVersion are TLS versions: "TLS10", "TLS11", "TLS12" or "TLS13".
+		MinVersion string `json:"min_version"`
+		MaxVersion string `json:"max_version"`
+		// CipherSuites are the names of the accepted cipher suites, e.g. "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256".
+		CipherSuites []string `json:"cipher_suites"`
+	} `json:"tls_server_config"`
+	HTTPServerConfig struct {
+		// HTTP2 enables HTTP/2. Defaults to true.
+		HTTP2   *bool             `json:"http2"`
+		Headers map[string]string `json:"headers"`
+	} `json:"http_server_config"`
+}
+
+var (
+	tlsVersions = map[string]uint16{
+		"TLS10": tls.VersionTLS10,
+		"TLS11": tls.VersionTLS11,
+		"TLS12": tls.VersionTLS12,
+		"TLS13": tls.VersionTLS13,
+	}
+
+	// securityHeaders are the headers that the web configuration file can set.
+	securityHeaders = []string{
+		"Content-Security-Policy",
+		"Strict-Transport-Security",
+		"X-Content-Type-Options",
+		"X-Frame-Options",
+		"X-Xss-Protection",
+	}
+)
+
+// readWebConfigFile reads a web configuration file, and returns w with its settings applied. A certificate and key in
+// the file replace those of w.
+func readWebConfigFile(path string, w webConfig) (webConfig, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return w, err
+	}
+	defer func() { _ = f.Close() }()
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	var cfg webConfigFile
+	if err = dec.Decode(&cfg); err != nil {
+		return w, fmt.Errorf("%s: %w", path, err)
+	}
+	if err = cfg.apply(&w); err != nil {
+		return w, fmt.Errorf("%s: %w", path, err)
+	}
+	return w, nil
+}
+
+func (f webConfigFile) apply(w *webConfig) error {
+	if f.TLSServerConfig.CertFile != "" || f.TLSServerConfig.KeyFile != "" {
+		w.certFile, w.keyFile = f.TLSServerConfig.CertFile, f.TLSServerConfig.KeyFile
+	}
+	for _, version := range []struct {
+		name  string
+		value *uint16
+	}{
+		{name: f.TLSServerConfig.MinVersion, value: &w.minVersion},
+		{name: f.TLSServerConfig.MaxVersion, value: &w.maxVersion},
+	} {
+		if version.name == "" {
+			continue
+		}
+		var ok bool
+		if *version.value, ok = tlsVersions[version.name]; !ok {
+			return fmt.Errorf("unknown TLS version %q. supported: %s", version.name, strings.Join(slices.Sorted(maps.Keys(tlsVersions)), ", "))
+		}
+	}
+	for _, name := range f.TLSServerConfig.CipherSuites {
+		i := slices.IndexFunc(tls.CipherSuites(), func(s *tls.CipherSuite) bool { return s.Name == name })
+		if i < 0 {
+			return fmt.Errorf("unknown or insecure cipher suite %q", name)
+		}
+		w.cipherSuites = append(w.cipherSuites, tls.CipherSuites()[i].ID)
+	}
+	if f.HTTPServerConfig.HTTP2 != nil {
+		w.http1Only = !*f.HTTPServerConfig.HTTP2
+	}
+	for header, value := range f.HTTPServerConfig.Headers {
+		if !slices.Contains(securityHeaders, http.CanonicalHeaderKey(header)) {
+			return fmt.Errorf("header %q not supported. supported: %s", header, strings.Join(securityHeaders, ", "))
+		}
+		if w.headers == nil {
+			w.headers = make(map[string]string)
+		}
+		w.headers[http.CanonicalHeaderKey(header)] = value
+	}
+	return nil
+}
+
+// handler wraps h so that it adds the configured headers to all responses and requires basic authentication, if
+// configured.
+func (w webConfig) handler(h http.Handler) http.Handler {
+	h = w.basicAuth(h)
+	if len(w.headers) == 0 {
+		return h
+	}
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		for header, value := range w.headers {
+			rw.Header().Set(header, value)
+		}
+		h.ServeHTTP(rw, r)
+	})
+}
+
+// basicAuth wraps h so that it requires basic authentication, if configured.
+func (w webConfig) basicAuth(h http.Handler) http.Handler {
+	if w.username == "" {
+		return h
+	}
+	// compare hashes, so the comparison takes the same time, regardless of the length of the credentials
+	wantUser, wantPassword := sha256.Sum256([]byte(w.username)), sha256.Sum256([]byte(w.password))
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		user, pass := sha256.Sum256([]byte(username)), sha256.Sum256([]byte(password))
+		if !ok ||
+			subtle.ConstantTimeCompare(user[:], wantUser[:]) != 1 ||
+			subtle.ConstantTimeCompare(pass[:], wantPassword[:]) != 1 {
+			rw.Header().Set("WWW-Authenticate", `Basic realm="iss-exporter", charset="UTF-8"`)
+			http.Error(rw, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(rw, r)
+	})
+}
+
+// serve serves s until it's shut down. If s fails, e.g. because its address is in use, the failure is counted in
+// errs, and s is restarted after retry, doubling up to maxRetry, until ctx is canceled.
+func (w webConfig) serve(ctx context.Context, s *http.Server, retry time.Duration, maxRetry time.Duration, errs prometheus.Counter, logger *slog.Logger) {
+	s.Handler = w.handler(s.Handler)
+	for {
+		err := w.listenAndServe(s)
+		if errors.Is(err, http.ErrServerClosed) {
+			return
+		}
+		errs.Inc()
+		logger.Error("HTTP server failed. restarting", "addr", s.Addr, "err", err, "retry", retry)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retry):
+		}
+		retry = min(2*retry, maxRetry)
+	}
+}
+
+// listenAndServe serves s, over TLS if configured. s.Handler should be wrapped by handler.
+func (w webConfig) listenAndServe(s *http.Server) error {
+	if w.http1Only {
+		s.Protocols = new(http.Protocols)
+		s.Protocols.SetHTTP1(true)
+	}
+	if w.certFile != "" {
+		s.TLSConfig = &tls.Config{MinVersion: w.minVersion, MaxVersion: w.maxVersion, CipherSuites: w.cipherSuites}
+		return s.ListenAndServeTLS(w.certFile, w.keyFile)
+	}
+	return s.ListenAndServe()
+}
+
+// withScrapeTimeout bounds the context of a scrape by the scrape timeout sent by Prometheus in the
+// X-Prometheus-Scrape-Timeout-Seconds header, less offset to allow for network latency. Requests without the header
+// aren't bounded.
+func withScrapeTimeout(offset time.Duration) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if timeout, ok := scrapeTimeout(r, offset); ok {
+				ctx, cancel := context.WithTimeout(r.Context(), timeout)
+				defer cancel()
+				r = r.WithContext(ctx)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// scrapeTimeout returns the scrape timeout of a request, less offset. If the remaining timeout isn't positive,
+// offset is ignored.
+func scrapeTimeout(r *http.Request, offset time.Duration) (time.Duration, bool) {
+	seconds, err := strconv.ParseFloat(r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds"), 64)
+	if err != nil || seconds <= 0 {
+		return 0, false
+	}
+	timeout := time.Duration(seconds * float64(time.Second))
+	if timeout > offset {
+		timeout -= offset
+	}
+	return timeout, true
+}
+
+// landingTemplate is the exporter's landing page.
+var landingTemplate = template.Must(template.New("landing").Parse(`<!DOCTYPE html>
+<html>
+<head><title>ISS Exporter</title></head>
+<body>
+<h1>ISS Exporter</h1>
+<p>Version {{.Version}} ({{.GoVersion}})</p>
+<ul>
+{{- range .Links}}
+<li><a href="{{.}}">{{.}}</a></li>
+{{- end}}
+</ul>
+</body>
+</html>
+`))
+
+// landingPage returns an http.Handler that serves a landing page with the exporter's version and links to its
+// endpoints.
+func landingPage(version string, links ...string) http.Handler {
+	data := struct {
+		Version   string
+		GoVersion string
+		Links     []string
+	}{Version: version, GoVersion: runtime.Version(), Links: links}
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_ = landingTemplate.Execute(w, data)
+	})
+}
+
+// handleDebug registers the pprof endpoints under /debug/pprof/ and the expvar endpoint on /debug/vars.
+func handleDebug(mux *http.ServeMux) {
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"crypto/tls"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWebConfig_validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		web     webConfig
+		wantErr bool
+	}{
+		{name: "empty"},
+		{name: "tls", web: webConfig{certFile: "cert.pem", keyFile: "key.pem"}},
+		{name: "tls without key", web: webConfig{certFile: "cert.pem"}, wantErr: true},
+		{name: "basic auth", web: webConfig{username: "user", password: "secret"}},
+		{name: "basic auth without password", web: webConfig{username: "user"}, wantErr: true},
+		{name: "tls settings", web: webConfig{certFile: "cert.pem", keyFile: "key.pem", minVersion: tls.VersionTLS12, maxVersion: tls.VersionTLS13}},
+		{name: "tls settings without certificate", web: webConfig{minVersion: tls.VersionTLS13}, wantErr: true},
+		{name: "min version above max version", web: webConfig{certFile: "cert.pem", keyFile: "key.pem", minVersion: tls.VersionTLS13, maxVersion: tls.VersionTLS12}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.web.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWebConfig_handler(t *testing.T) {
+	h := webConfig{username: "user", password: "secret"}.handler(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	tests := []struct {
+		name     string
+		username string
+		password string
+		noAuth   bool
+		want     int
+	}{
+		{name: "valid", username: "user", password: "secret", want: http.StatusOK},
+		{name: "wrong password", username: "user", password: "guess", want: http.StatusUnauthorized},
+		{name: "wrong user", username: "admin", password: "secret", want: http.StatusUnauthorized},
+		{name: "no credentials", noAuth: true, want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if !tt.noAuth {
+				req.SetBasicAuth(tt.username, tt.password)
+			}
+			resp := httptest.NewRecorder()
+			h.ServeHTTP(resp, req)
+			if resp.Code != tt.want {
+				t.Errorf("got %d, want %d", resp.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadWebConfigFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+		want    webConfig
+	}{
+		{
+			name:    "empty",
+			content: `{}`,
+			want:    webConfig{certFile: "flag.pem", keyFile: "flag.key"},
+		},
+		{
+			name:    "tls",
+			content: `{"tls_server_config":{"cert_file":"cert.pem","key_file":"key.pem","min_version":"TLS12","max_version":"TLS13","cipher_suites":["TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"]}}`,
+			want: webConfig{
+				certFile:     "cert.pem",
+				keyFile:      "key.pem",
+				minVersion:   tls.VersionTLS12,
+				maxVersion:   tls.VersionTLS13,
+				cipherSuites: []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256},
+			},
+		},
+		{
+			name:    "http server",
+			content: `{"http_server_config":{"http2":false,"headers":{"strict-transport-security":"max-age=31536000"}}}`,
+			want:    webConfig{certFile: "flag.pem", keyFile: "flag.key", http1Only: true, headers: map[string]string{"Strict-Transport-Security": "max-age=31536000"}},
+		},
+		{name: "invalid json", content: `{`, wantErr: true},
+		{name: "unknown field", content: `{"basic_auth_users":{}}`, wantErr: true},
+		{name: "unknown tls version", content: `{"tls_server_config":{"min_version":"SSL3"}}`, wantErr: true},
+		{name: "insecure cipher suite", content: `{"tls_server_config":{"cipher_suites":["TLS_RSA_WITH_RC4_128_SHA"]}}`, wantErr: true},
+		{name: "unsupported header", content: `{"http_server_config":{"headers":{"Server":"iss-exporter"}}}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "web.json")
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := readWebConfigFile(path, webConfig{certFile: "flag.pem", keyFile: "flag.key"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readWebConfigFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.certFile != tt.want.certFile || got.keyFile != tt.want.keyFile ||
+				got.minVersion != tt.want.minVersion || got.maxVersion != tt.want.maxVersion ||
+				!slices.Equal(got.cipherSuites, tt.want.cipherSuites) || got.http1Only != tt.want.http1Only ||
+				len(got.headers) != len(tt.want.headers) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+			for header, value := range tt.want.headers {
+				if got.headers[header] != value {
+					t.Errorf("header %s: got %q, want %q", header, got.headers[header], value)
+				}
+			}
+		})
+	}
+
+	if _, err := readWebConfigFile(filepath.Join(t.TempDir(), "missing.json"), webConfig{}); err == nil {
+		t.Error("readWebConfigFile() expected error for missing file")
+	}
+}
+
+func TestWebConfig_handler_Headers(t *testing.T) {
+	h := webConfig{username: "user", password: "secret", headers: map[string]string{"X-Frame-Options": "DENY"}}.handler(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if resp.Code != http.StatusUnauthorized {
+		t.Errorf("got %d, want %d", resp.Code, http.StatusUnauthorized)
+	}
+	if got := resp.Header().Get("X-Frame-Options"); got != "DENY" {
+		t.Errorf("X-Frame-Options: got %q, want %q", got, "DENY")
+	}
+}
+
+func TestWebConfig_serve(t *testing.T) {
+	// occupy the address, so the server fails until it's released
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &http.Server{Addr: l.Addr().String(), Handler: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})}
+	errs := prometheus.NewCounter(prometheus.CounterOpts{Name: "errors_total"})
+	done := make(chan struct{})
+	go func() {
+		webConfig{}.serve(t.Context(), s, 10*time.Millisecond, 20*time.Millisecond, errs, slog.New(slog.DiscardHandler))
+		close(done)
+	}()
+
+	var m dto.Metric
+	for m.GetCounter().GetValue() == 0 {
+		time.Sleep(10 * time.Millisecond)
+		_ = errs.Write(&m)
+	}
+	_ = l.Close()
+
+	// the server is restarted once the address is free
+	for {
+		resp, err := http.Get("http://" + s.Addr)
+		if err == nil {
+			_ = resp.Body.Close()
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err = s.Shutdown(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+}
+
+func TestWithScrapeTimeout(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   time.Duration
+		wantOK bool
+	}{
+		{name: "none"},
+		{name: "invalid", header: "soon"},
+		{name: "zero", header: "0"},
+		{name: "timeout", header: "10", want: 9500 * time.Millisecond, wantOK: true},
+		{name: "fractional", header: "2.5", want: 2 * time.Second, wantOK: true},
+		{name: "shorter than offset", header: "0.2", want: 200 * time.Millisecond, wantOK: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/probe", nil)
+			if tt.header != "" {
+				req.Header.Set("X-Prometheus-Scrape-Timeout-Seconds", tt.header)
+			}
+			var deadline time.Time
+			var ok bool
+			withScrapeTimeout(500*time.Millisecond)(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				deadline, ok = r.Context().Deadline()
+			})).ServeHTTP(httptest.NewRecorder(), req)
+			if ok != tt.wantOK {
+				t.Fatalf("got deadline %v, want %v", ok, tt.wantOK)
+			}
+			if remaining := time.Until(deadline); ok && (remaining > tt.want || remaining < tt.want-time.Second) {
+				t.Errorf("got %v remaining, want %v", remaining, tt.want)
+			}
+		})
+	}
+}
+
+func TestLandingPage(t *testing.T) {
+	h := landingPage("v1.2.3", "/metrics", "/health")
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/", nil))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("got %d, want %d", resp.Code, http.StatusOK)
+	}
+	if got := resp.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/html") {
+		t.Errorf("unexpected content type: %q", got)
+	}
+	body := resp.Body.String()
+	for _, want := range []string{"v1.2.3", `<a href="/metrics">`, `<a href="/health">`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("body does not contain %q", want)
+		}
+	}
+}
+
+func TestHandleDebug(t *testing.T) {
+	mux := http.NewServeMux()
+	handleDebug(mux)
+	for _, target := range []string{"/debug/pprof/", "/debug/pprof/goroutine?debug=1", "/debug/vars"} {
+		t.Run(target, func(t *testing.T) {
+			resp := httptest.NewRecorder()
+			mux.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, target, nil))
+			if resp.Code != http.StatusOK {
+				t.Errorf("got %d, want %d", resp.Code, http.StatusOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/clambin/iss-exporter/internal/collector"
+	"github.com/prometheus/client_golang/prometheus"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// eventQueueSize is the number of state transitions queued for the webhook before new ones are dropped.
+const eventQueueSize = 64
+
+// queueEvents returns a channel that receives the state transitions of c, until stop is called. If the channel is
+// full, new events are dropped, and counted by dropped.
+func queueEvents(c *collector.Collector, dropped prometheus.Counter) (<-chan collector.Event, func()) {
+	events := make(chan collector.Event, eventQueueSize)
+	stop := c.OnEvent(func(e collector.Event) {
+		select {
+		case events <- e:
+		default:
+			dropped.Inc()
+		}
+	})
+	return events, stop
+}
+
+// eventWebhook posts the Collector's state transitions (the session being lost or re-established, AOS/LOS and
+// subscription errors) to a webhook, as a collector.Event in JSON, so automation can react to them. Events have a
+// "text" field, so a Slack-compatible webhook posts them to a chat channel as is.
+type eventWebhook struct {
+	url        string
+	httpClient *http.Client
+	errors     prometheus.Counter
+	logger     *slog.Logger
+}
+
+// run posts the events until ctx is canceled. Events that can't be posted are dropped.
+func (w *eventWebhook) run(ctx context.Context, events <-chan collector.Event) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e := <-events:
+			if err := w.post(ctx, e); err != nil {
+				w.errors.Inc()
+				w.logger.Warn("failed to post event to webhook", "event", e.Type, "err", err)
+			}
+		}
+	}
+}
+
+func (w *eventWebhook) post(ctx context.Context, e collector.Event) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	body, _ := json.Marshal(e)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := w.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return errors.New(resp.Status)
+	}
+	return nil
+}
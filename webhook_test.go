@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/clambin/iss-exporter/internal/collector"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestEventWebhook(t *testing.T) {
+	received := make(chan collector.Event, 10)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var e collector.Event
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" || json.NewDecoder(r.Body).Decode(&e) != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if e.Type == collector.EventSubscriptionError {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		received <- e
+	}))
+	t.Cleanup(ts.Close)
+
+	errs := prometheus.NewCounter(prometheus.CounterOpts{Name: "errors"})
+	w := eventWebhook{url: ts.URL, httpClient: ts.Client(), errors: errs, logger: slog.New(slog.DiscardHandler)}
+	events := make(chan collector.Event, 10)
+	go w.run(t.Context(), events)
+	now := time.Now().UTC().Truncate(time.Second)
+	events <- collector.Event{Type: collector.EventSubscriptionError, Time: now, Text: "failed", Group: "A", Error: "invalid group"}
+	events <- collector.Event{Type: collector.EventSignalAcquired, Time: now, Text: "ISS signal acquired (AOS)"}
+
+	select {
+	case e := <-received:
+		if e.Type != collector.EventSignalAcquired || !e.Time.Equal(now) || e.Text != "ISS signal acquired (AOS)" {
+			t.Errorf("unexpected event: %+v", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout")
+	}
+	var m dto.Metric
+	_ = errs.Write(&m)
+	if got := m.GetCounter().GetValue(); got != 1 {
+		t.Errorf("got %v errors, want 1", got)
+	}
+}